@@ -0,0 +1,125 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// badgeWeeks is how far back badges look, in weeks
+const badgeWeeks = 104
+
+// Badge is an achievement worked towards from the history, earned once Count
+// reaches Goal
+type Badge struct {
+    Name     string
+    Goal     int
+    Count    int
+    progress string
+}
+
+// Earned reports whether the badge's goal has been reached
+func (b Badge) Earned() bool {
+    return b.Count >= b.Goal
+}
+
+// Progress says how far along the badge is, e.g. "7 new dinners tried, 10 earns it"
+func (b Badge) Progress() string {
+    progress := fmt.Sprintf(b.progress, b.Count)
+    if !b.Earned() {
+        progress += fmt.Sprintf(", %d earns it", b.Goal)
+    }
+    return progress
+}
+
+// weekStreak counts the finished weeks, going back from the one before
+// current, that are all in the history and pass keep
+func weekStreak(weeks []HistoryWeek, current time.Time, keep func(HistoryWeek) bool) int {
+    byStart := make(map[string]HistoryWeek)
+    for _, week := range weeks {
+        byStart[week.WeekStart.Format("2006-01-02")] = week
+    }
+    streak := 0
+    for start := current.AddDate(0, 0, -7); ; start = start.AddDate(0, 0, -7) {
+        week, ok := byStart[start.Format("2006-01-02")]
+        if !ok || !keep(week) {
+            return streak
+        }
+        streak++
+    }
+}
+
+// newDinnersTried counts the dinners added to the catalog since it was set up
+// (those with a recorded origin) that have been eaten at least once
+func newDinnersTried(history []HistoryWeek, dinners *DinnerData) int {
+    eaten := make(map[string]bool)
+    for _, week := range history {
+        for _, day := range week.Days {
+            switch day.Outcome {
+            case OutcomeSkipped:
+            case OutcomeSubstituted:
+                eaten[strings.ToLower(day.Substitute)] = true
+            default:
+                eaten[strings.ToLower(day.Dinner)] = true
+            }
+        }
+    }
+    tried := 0
+    for _, dinner := range dinners.AllDinners() {
+        if dinner.Origin != nil && !dinner.Origin.CreatedAt.IsZero() && eaten[strings.ToLower(dinner.Name)] {
+            tried++
+        }
+    }
+    return tried
+}
+
+// Badges works out the household's badges: a planning streak, new dinners
+// tried, and a month without takeaway
+func Badges(state *WeekState, dinners *DinnerData) []Badge {
+    weeks := recentWeeks(state, badgeWeeks)
+    planned := weekStreak(weeks, state.WeekStart, func(HistoryWeek) bool { return true })
+    homeCooked := weekStreak(weeks, state.WeekStart, func(week HistoryWeek) bool {
+        return factsOf(week, dinners).takeout == 0
+    })
+    return []Badge{
+        {Name: "Planner", Goal: 4, Count: planned, progress: "planned %d week(s) in a row"},
+        {Name: "Explorer", Goal: 10, Count: newDinnersTried(state.History, dinners), progress: "%d new dinners tried"},
+        {Name: "Home cook", Goal: 4, Count: homeCooked, progress: "%d week(s) without takeaway"},
+    }
+}
+
+// badgeLines are the badges for the weekly message: the ones earned, and the
+// ones more than halfway there
+func badgeLines(badges []Badge) []string {
+    var lines []string
+    for _, badge := range badges {
+        switch {
+        case badge.Earned():
+            lines = append(lines, fmt.Sprintf("Badge: %s, %s", badge.Name, badge.Progress()))
+        case badge.Count*2 > badge.Goal:
+            lines = append(lines, fmt.Sprintf("Almost %s: %s", badge.Name, badge.Progress()))
+        }
+    }
+    return lines
+}
+
+// weekBadges returns the badge lines for the "week" run, or nothing if the
+// catalog can't be read
+func weekBadges(state *WeekState) []string {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil
+    }
+    return badgeLines(Badges(state, dinners))
+}
+
+// printBadges lists every badge, earned or not, for "stats badges"
+func printBadges(badges []Badge) {
+    for _, badge := range badges {
+        mark := "[ ]"
+        if badge.Earned() {
+            mark = "[x]"
+        }
+        fmt.Printf("  %s %-10s %s\n", mark, badge.Name, badge.Progress())
+    }
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "reflect"
+    "sort"
+    "time"
+)
+
+const ArchiveVersion = 1
+
+const DefaultProfile = "default"
+
+// Archive is a portable snapshot of everything needed to bootstrap a new installation
+type Archive struct {
+    Version    int                       `json:"version"`
+    ExportedAt time.Time                 `json:"exported_at"`
+    Profiles   map[string]ProfileArchive `json:"profiles"`
+
+    // Config is the default profile's config in archives from before each
+    // profile carried its own
+    Config *Config `json:"config,omitempty"`
+}
+
+// ProfileArchive holds one profile's dinners, state, pantry and config
+type ProfileArchive struct {
+    Dinners *DinnerData `json:"dinners"`
+    State   *WeekState  `json:"state,omitempty"`
+    Pantry  *Pantry     `json:"pantry,omitempty"`
+    Config  *Config     `json:"config,omitempty"`
+}
+
+// BuildArchive collects every profile's data into an archive
+func BuildArchive() (*Archive, error) {
+    names, err := profileNames()
+    if err != nil {
+        return nil, err
+    }
+    archive := &Archive{
+        Version:    ArchiveVersion,
+        ExportedAt: time.Now(),
+        Profiles:   make(map[string]ProfileArchive),
+    }
+    for _, name := range names {
+        err := inProfile(name, func() error {
+            profile, err := buildProfileArchive()
+            if err != nil {
+                return err
+            }
+            archive.Profiles[name] = *profile
+            return nil
+        })
+        if err != nil {
+            return nil, fmt.Errorf("profile %s: %w", name, err)
+        }
+    }
+    return archive, nil
+}
+
+// buildProfileArchive collects the data of the profile in use
+func buildProfileArchive() (*ProfileArchive, error) {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil, err
+    }
+
+    var state *WeekState
+    if hasState() {
+        state, err = LoadState()
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    pantry, err := LoadPantry()
+    if err != nil {
+        return nil, err
+    }
+
+    var config *Config
+    if _, err := os.Stat(dataPath(ConfigFileName)); err == nil {
+        config, err = LoadConfig()
+        if err != nil {
+            return nil, err
+        }
+    }
+    return &ProfileArchive{Dinners: dinners, State: state, Pantry: pantry, Config: config}, nil
+}
+
+// LoadArchive reads an archive file and checks its version
+func LoadArchive(filename string) (*Archive, error) {
+    file, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error reading archive: %w", err)
+    }
+
+    var archive Archive
+    err = json.Unmarshal(file, &archive)
+    if err != nil {
+        return nil, fmt.Errorf("error parsing archive JSON: %w", err)
+    }
+
+    if archive.Version < 1 || archive.Version > ArchiveVersion {
+        return nil, fmt.Errorf("unsupported archive version %d", archive.Version)
+    }
+    if profile, ok := archive.Profiles[DefaultProfile]; ok && profile.Config == nil && archive.Config != nil {
+        profile.Config = archive.Config
+        archive.Profiles[DefaultProfile] = profile
+    }
+    archive.Config = nil
+
+    return &archive, nil
+}
+
+// dinnerCollisions returns the names of dinners that exist in both sets with different contents
+func dinnerCollisions(existing, incoming *DinnerData) []string {
+    byName := make(map[string]Dinner)
+    for _, dinners := range existing.Dinners {
+        for _, dinner := range dinners {
+            byName[dinner.Name] = dinner
+        }
+    }
+
+    var collisions []string
+    for _, dinners := range incoming.Dinners {
+        for _, dinner := range dinners {
+            if old, ok := byName[dinner.Name]; ok && !reflect.DeepEqual(old, dinner) {
+                collisions = append(collisions, dinner.Name)
+            }
+        }
+    }
+    return collisions
+}
+
+// mergeDinners adds incoming dinners whose names aren't already present, keeping existing ones.
+// Added dinners keep the origin they had on the other machine, or are marked as imported.
+func mergeDinners(existing, incoming *DinnerData) int {
+    seen := make(map[string]bool)
+    for _, dinners := range existing.Dinners {
+        for _, dinner := range dinners {
+            seen[dinner.Name] = true
+        }
+    }
+
+    if existing.Dinners == nil {
+        existing.Dinners = make(map[string][]Dinner)
+    }
+    added := 0
+    for category, dinners := range incoming.Dinners {
+        for _, dinner := range dinners {
+            if seen[dinner.Name] {
+                continue
+            }
+            if dinner.Origin == nil {
+                dinner.Origin = importedOrigin("import-all", "")
+            }
+            existing.Dinners[category] = append(existing.Dinners[category], dinner)
+            seen[dinner.Name] = true
+            added++
+        }
+    }
+    return added
+}
+
+// runExportAllCommand handles "export-all [file]", writing the archive to a file or stdout
+func runExportAllCommand(args []string) error {
+    archive, err := BuildArchive()
+    if err != nil {
+        return err
+    }
+
+    data, err := json.MarshalIndent(archive, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling archive: %w", err)
+    }
+
+    if len(args) == 0 {
+        fmt.Println(string(data))
+        return nil
+    }
+
+    err = os.WriteFile(args[0], data, 0644)
+    if err != nil {
+        return fmt.Errorf("error writing archive: %w", err)
+    }
+    logf("Exported %d profile(s) to %s\n", len(archive.Profiles), args[0])
+    return nil
+}
+
+// runImportAllCommand handles "import-all <file> [--merge|--overwrite]",
+// bringing in every profile in the archive, each into the profile of the
+// same name, which is created if it's missing. Conflicts are looked for in
+// every profile before anything is written.
+func runImportAllCommand(args []string) error {
+    fs := flag.NewFlagSet("import-all", flag.ContinueOnError)
+    merge := fs.Bool("merge", false, "keep existing data and only add dinners that are missing")
+    overwrite := fs.Bool("overwrite", false, "replace existing data with the archive contents")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 || (*merge && *overwrite) {
+        return fmt.Errorf("usage: dinner-picker import-all <file> [--merge|--overwrite]")
+    }
+
+    archive, err := LoadArchive(positional[0])
+    if err != nil {
+        return err
+    }
+    var names []string
+    for name, profile := range archive.Profiles {
+        if name != DefaultProfile {
+            if err := checkProfileName(name); err != nil {
+                return err
+            }
+        }
+        if profile.Dinners == nil {
+            return fmt.Errorf("archive's %s profile has no dinners", name)
+        }
+        names = append(names, name)
+    }
+    if len(names) == 0 {
+        return fmt.Errorf("archive has no profiles")
+    }
+    sort.Strings(names)
+
+    if !*merge && !*overwrite {
+        conflicts := false
+        for _, name := range names {
+            if !profileExists(name) {
+                continue
+            }
+            err := inProfile(name, func() error {
+                found, err := profileConflicts(archive.Profiles[name])
+                for _, conflict := range found {
+                    fmt.Printf("  %s: %s\n", name, conflict)
+                }
+                conflicts = conflicts || len(found) > 0
+                return err
+            })
+            if err != nil {
+                return err
+            }
+        }
+        if conflicts {
+            return fmt.Errorf("existing data found, rerun with --merge to keep it or --overwrite to replace it")
+        }
+    }
+
+    for _, name := range names {
+        if !profileExists(name) {
+            for _, dir := range profileDirs(name).all() {
+                if err := os.MkdirAll(dir, 0755); err != nil {
+                    return fmt.Errorf("error creating profile: %w", err)
+                }
+            }
+        }
+        err := inProfile(name, func() error {
+            return importProfile(name, archive.Profiles[name], positional[0], *overwrite)
+        })
+        if err != nil {
+            return fmt.Errorf("profile %s: %w", name, err)
+        }
+    }
+    return nil
+}
+
+// profileConflicts lists what importing a profile would replace in the
+// profile in use: dinners that differ, and the state
+func profileConflicts(profile ProfileArchive) ([]string, error) {
+    existing, err := loadCatalog()
+    if err != nil && !errors.Is(err, errNoDinners) {
+        return nil, err
+    }
+    if existing == nil {
+        return nil, nil
+    }
+    var conflicts []string
+    for _, name := range dinnerCollisions(existing, profile.Dinners) {
+        conflicts = append(conflicts, "conflicting dinner: "+name)
+    }
+    if hasState() && profile.State != nil {
+        conflicts = append(conflicts, "existing state file would be replaced")
+    }
+    return conflicts, nil
+}
+
+// importProfile brings an archived profile into the profile in use: all of
+// it when there's no catalog yet or overwrite is set, otherwise merging in
+// the missing dinners and whatever files aren't there yet
+func importProfile(name string, profile ProfileArchive, source string, overwrite bool) error {
+    existing, err := loadCatalog()
+    if err != nil && !errors.Is(err, errNoDinners) {
+        return err
+    }
+    stateSaved := hasState()
+    _, configErr := os.Stat(dataPath(ConfigFileName))
+    hasConfig := configErr == nil
+
+    if existing == nil || overwrite {
+        if err := saveCatalog(profile.Dinners); err != nil {
+            return err
+        }
+        if profile.State != nil {
+            if err := profile.State.Record("import-all", "from "+source); err != nil {
+                return err
+            }
+        }
+        if profile.Pantry != nil {
+            if err := profile.Pantry.SavePantry(); err != nil {
+                return err
+            }
+        }
+        if profile.Config != nil {
+            if err := profile.Config.SaveConfig(); err != nil {
+                return err
+            }
+        }
+        fmt.Printf("Imported %s from %s\n", name, source)
+        return nil
+    }
+
+    added := mergeDinners(existing, profile.Dinners)
+    if err := saveCatalog(existing); err != nil {
+        return err
+    }
+    if !stateSaved && profile.State != nil {
+        if err := profile.State.Record("import-all", "from "+source); err != nil {
+            return err
+        }
+    }
+    if _, err := os.Stat(dataPath(PantryFileName)); os.IsNotExist(err) && profile.Pantry != nil {
+        if err := profile.Pantry.SavePantry(); err != nil {
+            return err
+        }
+    }
+    if !hasConfig && profile.Config != nil {
+        if err := profile.Config.SaveConfig(); err != nil {
+            return err
+        }
+    }
+    fmt.Printf("Merged %d new dinner(s) into %s from %s\n", added, name, source)
+    return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// DayMode says how a planned day should be treated
+type DayMode string
+
+const (
+    DayNormal DayMode = ""
+    DayQuick  DayMode = "quick"
+    DaySkip   DayMode = "skip"
+)
+
+// QuickTag marks dinners that are fast enough for busy evenings regardless of cook time
+const QuickTag = "quick"
+
+// CalendarConfig points at an ICS feed and says which evening events change the plan
+type CalendarConfig struct {
+    URL          string `json:"url"`
+    QuickAfter   string `json:"quick_after,omitempty"`
+    SkipAfter    string `json:"skip_after,omitempty"`
+    QuickMinutes int    `json:"quick_minutes,omitempty"`
+
+    // HomeFrom is the earliest cooking can start on a day with an evening
+    // event (default 17:00), for telling whether the dinner fits before it
+    HomeFrom string `json:"home_from,omitempty"`
+}
+
+// Event is a single timed calendar entry
+type Event struct {
+    Summary string
+    Start   time.Time
+    End     time.Time
+}
+
+// quickMinutes returns the longest cook time that still counts as a quick meal
+func (c *CalendarConfig) quickMinutes() int {
+    if c.QuickMinutes > 0 {
+        return c.QuickMinutes
+    }
+    return 30
+}
+
+// eveningEvents returns the date's events that start from noon on, the only
+// ones that get in the way of dinner
+func eveningEvents(events []Event, date time.Time) []Event {
+    var evening []Event
+    for _, event := range events {
+        if event.Start.Year() == date.Year() && event.Start.YearDay() == date.YearDay() && event.Start.Hour() >= 12 {
+            evening = append(evening, event)
+        }
+    }
+    return evening
+}
+
+// IsQuick reports whether a dinner can be made on a busy evening
+func (d Dinner) IsQuick(maxMinutes int) bool {
+    return d.NoCook || d.HasTag(QuickTag) || (d.CookTime > 0 && d.CookTime <= maxMinutes)
+}
+
+// FetchCalendar reads an ICS feed from an http(s)/webcal URL or a local file
+func FetchCalendar(source string) ([]Event, error) {
+    var reader io.Reader
+    if strings.HasPrefix(source, "webcal://") {
+        source = "https://" + strings.TrimPrefix(source, "webcal://")
+    }
+    if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+        client := &http.Client{Timeout: 10 * time.Second}
+        resp, err := client.Get(source)
+        if err != nil {
+            return nil, fmt.Errorf("error fetching calendar: %w", err)
+        }
+        defer resp.Body.Close()
+        if resp.StatusCode != http.StatusOK {
+            return nil, fmt.Errorf("error fetching calendar: %s", resp.Status)
+        }
+        reader = resp.Body
+    } else {
+        file, err := os.ReadFile(source)
+        if err != nil {
+            return nil, fmt.Errorf("error reading calendar: %w", err)
+        }
+        reader = bytes.NewReader(file)
+    }
+    return ParseICS(reader)
+}
+
+// ParseICS extracts timed events from an iCalendar stream. All-day events and
+// recurrence rules are ignored.
+func ParseICS(r io.Reader) ([]Event, error) {
+    var lines []string
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimRight(scanner.Text(), "\r")
+        // Folded lines continue the previous one after a leading space or tab
+        if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+            lines[len(lines)-1] += line[1:]
+            continue
+        }
+        lines = append(lines, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading calendar: %w", err)
+    }
+
+    var events []Event
+    var current *Event
+    for _, line := range lines {
+        name, value, ok := strings.Cut(line, ":")
+        if !ok {
+            continue
+        }
+        name, params, _ := strings.Cut(name, ";")
+
+        switch {
+        case name == "BEGIN" && value == "VEVENT":
+            current = &Event{}
+        case name == "END" && value == "VEVENT":
+            if current != nil && !current.Start.IsZero() {
+                if current.End.IsZero() {
+                    current.End = current.Start
+                }
+                events = append(events, *current)
+            }
+            current = nil
+        case current == nil:
+        case name == "SUMMARY":
+            current.Summary = value
+        case name == "DTSTART" || name == "DTEND":
+            t, ok := parseICSTime(value, params)
+            if !ok {
+                // All-day or unparseable entries don't affect dinner time
+                current = nil
+                continue
+            }
+            if name == "DTSTART" {
+                current.Start = t
+            } else {
+                current.End = t
+            }
+        }
+    }
+    return events, nil
+}
+
+// parseICSTime parses DATE-TIME values in UTC, floating or TZID form
+func parseICSTime(value, params string) (time.Time, bool) {
+    loc := time.Local
+    for _, param := range strings.Split(params, ";") {
+        key, val, _ := strings.Cut(param, "=")
+        if key == "VALUE" && val == "DATE" {
+            return time.Time{}, false
+        }
+        if key == "TZID" {
+            if l, err := time.LoadLocation(strings.Trim(val, `"`)); err == nil {
+                loc = l
+            }
+        }
+    }
+
+    if strings.HasSuffix(value, "Z") {
+        t, err := time.Parse("20060102T150405Z", value)
+        return t.In(time.Local), err == nil
+    }
+    t, err := time.ParseInLocation("20060102T150405", value, loc)
+    return t.In(time.Local), err == nil
+}
+
+// parseClock turns "17:30" into minutes after midnight
+func parseClock(clock string, fallback int) int {
+    t, err := time.Parse("15:04", clock)
+    if err != nil {
+        return fallback
+    }
+    return t.Hour()*60 + t.Minute()
+}
+
+// DayModes works out which days of the week starting at weekStart need a quick
+// meal or no dinner at all. Events ending after skip_after (default 19:30) skip
+// the day; events ending after quick_after (default 17:00) make it quick.
+func (c *CalendarConfig) DayModes(events []Event, weekStart time.Time) (map[string]DayMode, map[string]string) {
+    quickAfter := parseClock(c.QuickAfter, 17*60)
+    skipAfter := parseClock(c.SkipAfter, 19*60+30)
+
+    modes := make(map[string]DayMode)
+    reasons := make(map[string]string)
+    for i, day := range weekDays {
+        date := weekStart.AddDate(0, 0, i)
+        for _, event := range events {
+            start, end := event.Start, event.End
+            if start.Year() != date.Year() || start.YearDay() != date.YearDay() {
+                continue
+            }
+            // Only afternoon and evening events get in the way of dinner
+            if start.Hour() < 12 {
+                continue
+            }
+            endMinutes := end.Hour()*60 + end.Minute()
+            if end.YearDay() != start.YearDay() {
+                endMinutes = 24 * 60
+            }
+
+            mode := DayNormal
+            if endMinutes >= skipAfter {
+                mode = DaySkip
+            } else if endMinutes >= quickAfter {
+                mode = DayQuick
+            }
+            if mode == DaySkip || (mode == DayQuick && modes[day] != DaySkip) {
+                modes[day] = mode
+                reasons[day] = fmt.Sprintf("%s until %s", event.Summary, end.Format("15:04"))
+            }
+        }
+    }
+    return modes, reasons
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+)
+
+// cardDetails lists what a card says under the dinner's name: how long it
+// takes, the prep, leftovers, equipment and where the recipe is from
+func cardDetails(dinner Dinner) []string {
+    var details []string
+    if dinner.CookTime > 0 {
+        details = append(details, fmt.Sprintf("%d min", dinner.CookTime))
+    }
+    if dinner.PrepDays > 0 {
+        details = append(details, fmt.Sprintf("start %d day(s) ahead", dinner.PrepDays))
+    }
+    if dinner.MakesLeftovers {
+        details = append(details, "makes leftovers")
+    }
+    if len(dinner.Equipment) > 0 {
+        details = append(details, strings.Join(dinner.Equipment, ", "))
+    }
+    if dinner.Source != nil {
+        details = append(details, dinner.Source.String())
+    }
+    return details
+}
+
+// RenderCards writes a markdown prep card for each planned day: what to get
+// out, what to start ahead and the steps, as checklists to tick off while cooking
+func RenderCards(w io.Writer, plan *Plan, styles CategoryStyles) {
+    for i, entry := range plan.Days {
+        dinner := entry.Dinner
+        if i > 0 {
+            fmt.Fprint(w, "\n---\n\n")
+        }
+        fmt.Fprintf(w, "## %s: %s\n\n", entry.Day, styles.Label(dinner.Category, dinner.Name))
+
+        details := cardDetails(dinner)
+        if len(details) > 0 {
+            fmt.Fprintf(w, "_%s_\n\n", strings.Join(details, " · "))
+        }
+
+        fmt.Fprintln(w, "### Ingredients")
+        for _, ingredient := range dinner.Ingredients {
+            if ingredient.Name != "" {
+                fmt.Fprintf(w, "- [ ] %s\n", ingredient)
+            }
+        }
+
+        if steps := dinner.Steps.Items(); len(steps) > 0 {
+            fmt.Fprintln(w, "\n### Steps")
+            for _, step := range steps {
+                fmt.Fprintf(w, "- [ ] %s\n", step)
+            }
+        }
+    }
+}
+
+// WriteCardsPDF writes the same cards as a PDF document to print, with boxes
+// to tick as text; the category icons are left out, as Helvetica has none
+func WriteCardsPDF(w io.Writer, plan *Plan) error {
+    var lines []pdfLine
+    for i, entry := range plan.Days {
+        dinner := entry.Dinner
+        if i > 0 {
+            lines = append(lines, pdfLine{}, pdfLine{})
+        }
+        lines = append(lines, pdfLine{Text: entry.Day + ": " + dinner.Name, Size: 18})
+        if details := cardDetails(dinner); len(details) > 0 {
+            lines = append(lines, pdfLine{Text: strings.Join(details, " · "), Size: 10})
+        }
+
+        lines = append(lines, pdfLine{}, pdfLine{Text: "Ingredients", Size: 13})
+        for _, ingredient := range dinner.Ingredients {
+            if ingredient.Name != "" {
+                lines = append(lines, pdfLine{Text: "[ ] " + ingredient.String(), Size: 11})
+            }
+        }
+
+        if steps := dinner.Steps.Items(); len(steps) > 0 {
+            lines = append(lines, pdfLine{}, pdfLine{Text: "Steps", Size: 13})
+            for _, step := range steps {
+                lines = append(lines, pdfLine{Text: "[ ] " + step, Size: 11})
+            }
+        }
+    }
+    return writePDF(w, "Prep cards for the week of "+plan.WeekStart.Format("January 2, 2006"), lines)
+}
+
+// exportCards handles "export cards [file] [--format markdown|pdf]", writing
+// this week's prep cards as markdown or, for printing, a PDF
+func exportCards(args []string) error {
+    fs := flag.NewFlagSet("export cards", flag.ContinueOnError)
+    format := fs.String("format", "", "markdown or pdf (default from the file's extension, else markdown)")
+    rest, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(rest) > 1 {
+        return fmt.Errorf("usage: dinner-picker export cards [file] [--format markdown|pdf]")
+    }
+    if *format == "" {
+        *format = "markdown"
+        if len(rest) == 1 && strings.HasSuffix(strings.ToLower(rest[0]), ".pdf") {
+            *format = "pdf"
+        }
+    }
+    var write func(io.Writer, *Plan) error
+    switch *format {
+    case "markdown":
+        write = func(w io.Writer, plan *Plan) error {
+            RenderCards(w, plan, loadStyles())
+            return nil
+        }
+    case "pdf":
+        write = WriteCardsPDF
+    default:
+        return fmt.Errorf("unknown cards format %q, want markdown or pdf", *format)
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    if state.Plan.IsEmpty() {
+        return fmt.Errorf("no dinners planned for this week yet")
+    }
+
+    if len(rest) == 0 {
+        return write(os.Stdout, state.Plan)
+    }
+    file, err := os.Create(rest[0])
+    if err != nil {
+        return fmt.Errorf("error writing cards: %w", err)
+    }
+    defer file.Close()
+    if err := write(file, state.Plan); err != nil {
+        return fmt.Errorf("error writing cards: %w", err)
+    }
+    logf("Exported %d cards to %s\n", len(state.Plan.Days), rest[0])
+    return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// export cards --format pdf writes a complete PDF document, whatever the
+// file is called
+func TestExportCardsPDF(t *testing.T) {
+    dir := useRepoData(t)
+    state, err := LoadState()
+    if err != nil {
+        t.Fatal(err)
+    }
+    state.CheckNewWeek()
+    week := state.WeekStart
+    state.Plan = &Plan{WeekStart: week, Days: []PlanDay{{
+        Day:  "Monday",
+        Date: week.AddDate(0, 0, 1),
+        Dinner: Dinner{
+            Name:        "Tomato soup",
+            Category:    "soup",
+            CookTime:    30,
+            Ingredients: []Ingredient{ParseIngredient("4 tomatoes"), ParseIngredient("1 onion")},
+        },
+    }}}
+    if err := state.Record("test", "plan"); err != nil {
+        t.Fatal(err)
+    }
+
+    file := filepath.Join(dir, "cards.out")
+    if err := exportCards([]string{file, "--format", "pdf"}); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.HasPrefix(data, []byte("%PDF-")) {
+        t.Fatalf("cards start %q, want %%PDF-", data[:min(len(data), 16)])
+    }
+    if !bytes.HasSuffix(bytes.TrimSpace(data), []byte("%%EOF")) {
+        t.Errorf("cards end %q, want %s", data[max(0, len(data)-16):], "%%EOF")
+    }
+    for _, want := range []string{"Monday: Tomato soup", "[ ] 4 tomatoes", "Prep cards for the week of " + week.Format("January 2, 2006")} {
+        if !strings.Contains(string(data), want) {
+            t.Errorf("cards don't say %q", want)
+        }
+    }
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// ResolveCategory finds where dinners of a category live now, following
+// renames and then the configured fallbacks for categories that are gone or
+// empty. It returns "" when nothing is left, and a note whenever the answer
+// isn't the category that was asked for.
+func (d *DinnerData) ResolveCategory(category string, fallbacks map[string][]string) (string, string) {
+    seen := make(map[string]bool)
+    var resolve func(name string) string
+    resolve = func(name string) string {
+        for d.Renamed[name] != "" && !seen[name] {
+            seen[name] = true
+            name = d.Renamed[name]
+        }
+        if len(d.Dinners[name]) > 0 {
+            return name
+        }
+        for _, fallback := range fallbacks[name] {
+            if seen[fallback] {
+                continue
+            }
+            seen[fallback] = true
+            if found := resolve(fallback); found != "" {
+                return found
+            }
+        }
+        return ""
+    }
+
+    found := resolve(category)
+    switch {
+    case found == category:
+        return found, ""
+    case found == "":
+        return "", fmt.Sprintf("no %s dinners", category)
+    case d.Renamed[category] != "" && len(d.Dinners[d.Renamed[category]]) > 0:
+        return found, fmt.Sprintf("%s was renamed to %s", category, found)
+    default:
+        return found, fmt.Sprintf("no %s dinners, using %s", category, found)
+    }
+}
+
+// RenameCategory moves every dinner from oldName to newName and remembers the rename
+// so plans made before it still resolve
+func (d *DinnerData) RenameCategory(oldName, newName string) error {
+    if len(d.Dinners[oldName]) == 0 {
+        return fmt.Errorf("no category named %q", oldName)
+    }
+    if oldName == newName {
+        return nil
+    }
+
+    for _, dinner := range d.Dinners[oldName] {
+        dinner.Category = newName
+        dinner.markEdited()
+        d.Dinners[newName] = append(d.Dinners[newName], dinner)
+    }
+    delete(d.Dinners, oldName)
+    for i, category := range d.order {
+        if category == oldName {
+            d.order[i] = newName
+        }
+    }
+
+    if d.Renamed == nil {
+        d.Renamed = make(map[string]string)
+    }
+    for from, to := range d.Renamed {
+        if to == oldName {
+            d.Renamed[from] = newName
+        }
+    }
+    d.Renamed[oldName] = newName
+    delete(d.Renamed, newName)
+
+    if style, ok := d.Categories[oldName]; ok {
+        if _, taken := d.Categories[newName]; !taken {
+            d.Categories[newName] = style
+        }
+        delete(d.Categories, oldName)
+    }
+    return nil
+}
+
+// runCategoryCommand handles "category list" and "category rename <old> <new>"
+func runCategoryCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker category list|rename <old> <new>")
+    if len(args) == 0 {
+        return usage
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+
+    switch args[0] {
+    case "list":
+        var names []string
+        for name := range dinners.Dinners {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            fmt.Printf("%s (%d)\n", name, len(dinners.Dinners[name]))
+        }
+        for from, to := range dinners.Renamed {
+            fmt.Printf("  %s -> %s\n", from, to)
+        }
+        return nil
+    case "rename":
+        if len(args) != 3 {
+            return usage
+        }
+        from, to := args[1], strings.TrimSpace(args[2])
+        if to == "" {
+            return usage
+        }
+        if err := dinners.RenameCategory(from, to); err != nil {
+            return err
+        }
+        if err := saveCatalog(dinners); err != nil {
+            return err
+        }
+        fmt.Printf("Renamed %s to %s\n", from, to)
+        return nil
+    }
+    return usage
+}
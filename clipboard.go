@@ -0,0 +1,46 @@
+package main
+
+import (
+    "fmt"
+    "os/exec"
+    "runtime"
+    "strings"
+)
+
+// clipboardCommands are the programs that can take text on stdin and put it on
+// the clipboard, per platform, in order of preference
+var clipboardCommands = map[string][][]string{
+    "darwin":  {{"pbcopy"}},
+    "windows": {{"clip.exe"}},
+    "linux": {
+        {"wl-copy"},
+        {"xclip", "-selection", "clipboard"},
+        {"xsel", "--clipboard", "--input"},
+        {"termux-clipboard-set"},
+    },
+}
+
+// CopyToClipboard puts text on the system clipboard using whichever clipboard
+// program the platform has
+func CopyToClipboard(text string) error {
+    commands, ok := clipboardCommands[runtime.GOOS]
+    if !ok {
+        commands = clipboardCommands["linux"]
+    }
+
+    var tried []string
+    for _, command := range commands {
+        path, err := exec.LookPath(command[0])
+        if err != nil {
+            tried = append(tried, command[0])
+            continue
+        }
+        cmd := exec.Command(path, command[1:]...)
+        cmd.Stdin = strings.NewReader(text)
+        if err := cmd.Run(); err != nil {
+            return fmt.Errorf("error copying to clipboard with %s: %w", command[0], err)
+        }
+        return nil
+    }
+    return fmt.Errorf("no clipboard program found (tried %s)", strings.Join(tried, ", "))
+}
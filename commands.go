@@ -0,0 +1,940 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+)
+
+// parseArgs parses flags that may appear before, between or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+    var positional []string
+    for {
+        if err := fs.Parse(args); err != nil {
+            return nil, err
+        }
+        if fs.NArg() == 0 {
+            return positional, nil
+        }
+        positional = append(positional, fs.Arg(0))
+        args = fs.Args()[1:]
+    }
+}
+
+// flagGiven reports whether a flag was on the command line, for a flag whose
+// zero value is also its default
+func flagGiven(fs *flag.FlagSet, name string) bool {
+    given := false
+    fs.Visit(func(fl *flag.Flag) {
+        if fl.Name == name {
+            given = true
+        }
+    })
+    return given
+}
+
+// repeatWeeksFlag returns --no-repeat-weeks when it was given, or nil to
+// leave the window to the config
+func repeatWeeksFlag(fs *flag.FlagSet, weeks int) (*int, error) {
+    if !flagGiven(fs, "no-repeat-weeks") {
+        return nil, nil
+    }
+    if weeks < 0 {
+        return nil, fmt.Errorf("--no-repeat-weeks can't be negative")
+    }
+    return &weeks, nil
+}
+
+// normalizeDay turns "monday" or "MON" into "Monday"
+func normalizeDay(day string) (string, bool) {
+    for _, d := range weekDays {
+        if strings.EqualFold(d, day) || (len(day) >= 3 && strings.HasPrefix(strings.ToLower(d), strings.ToLower(day))) {
+            return d, true
+        }
+    }
+    return "", false
+}
+
+// planSpan returns the days covered by --days/--starting, or nil for the default week
+func planSpan(count int, starting string) ([]string, error) {
+    if count == 0 && starting == "" {
+        return nil, nil
+    }
+
+    start := 0
+    if starting != "" {
+        day, ok := normalizeDay(starting)
+        if !ok {
+            return nil, fmt.Errorf("unknown day: %s", starting)
+        }
+        for i, d := range weekDays {
+            if d == day {
+                start = i
+            }
+        }
+    }
+    if count == 0 {
+        count = len(defaultPlanDays)
+    }
+    if count < 0 || start+count > len(weekDays) {
+        return nil, fmt.Errorf("--days %d from %s runs past Saturday", count, weekDays[start])
+    }
+    return weekDays[start : start+count], nil
+}
+
+// runPlanCommand handles "plan [--days N] [--starting day] [--template name]
+// [--no-repeat-weeks N] [--max-budget N] [--require-tag t] [--exclude-tag t]
+// [--exclude-allergen a] [--interactive] [--output text|json|markdown]",
+// picking dinners for the week
+func runPlanCommand(args []string) error {
+    if len(args) > 0 {
+        switch args[0] {
+        case "diff":
+            return runPlanDiffCommand(args[1:])
+        case "export-card":
+            return runExportCardCommand(args[1:])
+        case "import-card":
+            return runImportCardCommand(args[1:])
+        }
+    }
+    fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+    count := fs.Int("days", 0, "number of days to plan")
+    starting := fs.String("starting", "", "first day to plan (default Sunday)")
+    menuMode := fs.String("menu", "", "menu detail: names, short or full")
+    pattern := fs.String("pattern", "", "week pattern from the rotation to use instead of the scheduled one")
+    template := fs.String("template", "", "theme week from the config to plan, e.g. italian (see template list)")
+    guestList := fs.String("guests", "", "people eating on busier days, e.g. saturday=8,sunday=6")
+    repeatWeeks := fs.Int("no-repeat-weeks", 0, "weeks before a dinner may be planned again, 0 for no limit (default from config)")
+    maxBudget := fs.Float64("max-budget", 0, "swap in cheaper dinners until the week costs at most this (default spend.weekly_budget)")
+    useUp := fs.String("use-up", "", "comma-separated food to use up this week, e.g. \"half a cabbage, 200g feta\"")
+    seasonName := fs.String("season", "", "plan for this season instead of the week's: spring, summer, fall or winter")
+    seed := fs.Int64("seed", 0, "plan the week the same as the plan with this seed (see show)")
+    interactive := fs.Bool("interactive", false, "adjust the proposed week with the keyboard before saving it")
+    yes := fs.Bool("yes", false, "save the plan without asking, even at a terminal")
+    force := fs.Bool("force", false, "pick every day again, not only the days missing from this week's plan")
+    diet := newDietFlags(fs)
+    output := fs.String("output", "text", "menu format: text, json or markdown")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    if *count < 1 && flagGiven(fs, "days") {
+        return fmt.Errorf("usage: dinner-picker plan --days N needs N of 1 or more, not %d", *count)
+    }
+    weeks, err := repeatWeeksFlag(fs, *repeatWeeks)
+    if err != nil {
+        return err
+    }
+    formatter, err := menuFormatter(*output)
+    if err != nil {
+        return err
+    }
+    days, err := planSpan(*count, *starting)
+    if err != nil {
+        return err
+    }
+    guests, err := parseGuests(*guestList)
+    if err != nil {
+        return err
+    }
+    season, err := seasonFlag(*seasonName)
+    if err != nil {
+        return err
+    }
+    
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    menu, err := NewMenuOptions(*menuMode, config)
+    if err != nil {
+        return err
+    }
+    req := PlanRequest{Days: days, Pattern: *pattern, Template: *template, Guests: guests, RepeatWeeks: weeks, MaxBudget: *maxBudget, UseUp: splitList(*useUp), Season: season, Diet: diet.rule(), Seed: *seed, Force: *force}
+    if *interactive {
+        return weekPlannedHint(runInteractivePlan(req, menu, formatter))
+    }
+    confirm := !*yes && confirmingPlan(config)
+    req.Draft = confirm
+    state, _, err := planWeek(req)
+    if err != nil {
+        return weekPlannedHint(err)
+    }
+    
+    // Print the menu
+    if err := printPlan(formatter, state, config, menu); err != nil {
+        return err
+    }
+    if !confirm {
+        return nil
+    }
+    save, err := confirmPlan(state, *force)
+    if err != nil {
+        return err
+    }
+    if !save {
+        logf("Not saved, the week stays as it was\n")
+        return nil
+    }
+    if err := state.Record("plan", planSummary(state.Plan)); err != nil {
+        return err
+    }
+    logf("Saved\n")
+    return nil
+}
+
+// PlanRequest is what a caller asks of planWeek; the zero value plans the
+// default week with the config's settings
+type PlanRequest struct {
+    // Days to plan, nil for the default week or the rotation's pattern
+    Days []string
+
+    // Pattern overrides the rotation's pattern for the week
+    Pattern string
+
+    // Template plans a theme week from the config in place of the schedule
+    Template string
+
+    // Guests gives the headcount on days with company
+    Guests map[string]int
+
+    // RepeatWeeks overrides the config's no-repeat window, in weeks, when set
+    RepeatWeeks *int
+
+    // MaxBudget overrides the config's weekly budget
+    MaxBudget float64
+
+    // UseUp lists food to use up, e.g. "200g feta"; the planner swaps in
+    // dinners that use it
+    UseUp []string
+
+    // Season plans for a season other than the week's, e.g. "winter"
+    Season string
+
+    // Diet is a dietary rule for every day, from the command line
+    Diet *Observance
+
+    // Seed makes the plan the same as an earlier one made with it, 0 for a new one
+    Seed int64
+
+    // Force picks every day afresh rather than only the days the week's
+    // plan is missing; days already eaten are kept either way
+    Force bool
+
+    // Draft returns the plan without saving it, for the caller to adjust
+    // and record
+    Draft bool
+}
+
+// errWeekPlanned is planWeek's answer when the week has nothing left to fill in
+var errWeekPlanned = errors.New("every day this week is planned already")
+
+// keptDays returns the days of the plan so far that a new plan keeps: every
+// day with an outcome, and unless force the other planned days too, bar ones
+// pinned to another dinner since. Leftovers stay only with their batch.
+func keptDays(plan *Plan, pins map[string]Dinner, force bool) map[string]PlanDay {
+    keep := make(map[string]PlanDay)
+    if plan == nil {
+        return keep
+    }
+    for _, entry := range plan.Days {
+        pin, pinned := pins[entry.Day]
+        if entry.Outcome == "" && (force || pinned && pin.Name != entry.Dinner.Name) {
+            continue
+        }
+        keep[entry.Day] = entry
+    }
+    for day, entry := range keep {
+        if _, ok := keep[entry.LeftoversFrom]; entry.IsLeftovers() && entry.Outcome == "" && !ok {
+            delete(keep, day)
+        }
+    }
+    return keep
+}
+
+// planWeek picks dinners for the days the week's plan is missing, or every
+// day not yet eaten with Force, and saves the new plan. It returns
+// errWeekPlanned when there's nothing to pick.
+func planWeek(req PlanRequest) (*WeekState, *Config, error) {
+    days, guests := req.Days, req.Guests
+    // Load dinner data
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil, nil, err
+    }
+    
+    // Load state
+    state, err := LoadState()
+    if err != nil {
+        return nil, nil, err
+    }
+    
+    // Check if it's a new week
+    state.CheckNewWeek()
+    
+    config, err := LoadConfig()
+    if err != nil {
+        return nil, nil, err
+    }
+    
+    // Alternating households plan the days of whichever week pattern is due
+    week, err := weekPattern(config, state.WeekStart, req.Pattern)
+    if err != nil {
+        return nil, nil, err
+    }
+    var notes []string
+    if week.Name != "" {
+        notes = append(notes, fmt.Sprintf("Week pattern: %s", week.Name))
+        if days == nil {
+            days = week.planDays()
+        }
+    }
+
+    // A theme week draws from its own days and categories, its tags a rule for every day
+    var themeRule *Observance
+    if req.Template != "" {
+        template, err := config.findTemplate(req.Template)
+        if err != nil {
+            return nil, nil, err
+        }
+        config.Schedule = template.schedule(config.Schedule)
+        themeRule = template.rule()
+        notes = append(notes, "Template: "+template.Name)
+    }
+    
+    // Adapt busy evenings from the family calendar, planning normally if it can't be read
+    opts := PlanOptions{Days: days}
+    if config.Calendar != nil {
+        opts.QuickMinutes = config.Calendar.quickMinutes()
+        events, err := FetchCalendar(config.Calendar.URL)
+        if err != nil {
+            warnf("ignoring calendar: %v", err)
+        } else {
+            var reasons map[string]string
+            opts.Modes, reasons = config.Calendar.DayModes(events, state.WeekStart)
+            for _, day := range weekDays {
+                if opts.Modes[day] != DayNormal {
+                    notes = append(notes, fmt.Sprintf("%s: %s (%s)", day, opts.Modes[day], reasons[day]))
+                }
+            }
+        }
+    }
+    var holidays map[string]Holiday
+    if config.Holidays != nil {
+        if opts.Modes == nil {
+            opts.Modes = make(map[string]DayMode)
+        }
+        holidays = config.Holidays.HolidaysIn(state.WeekStart)
+        notes = append(notes, config.Holidays.DayModes(holidays, opts.Modes)...)
+        opts.ProjectMinutes = config.Holidays.projectMinutes()
+    }
+    var signalNotes []string
+    opts.Bias, signalNotes = CollectBias(config.SignalProviders(), state.WeekStart)
+    notes = append(notes, signalNotes...)
+    opts.Protein = config.Protein
+    opts.LunchTarget = config.LunchTarget
+    if req.Diet != nil {
+        config.Observances = append(append([]Observance(nil), config.Observances...), *req.Diet)
+        notes = append(notes, "Dietary rule: "+req.Diet.Name)
+    }
+    if themeRule != nil {
+        config.Observances = append(append([]Observance(nil), config.Observances...), *themeRule)
+    }
+    opts.Observances = config.Observances
+    opts.Fallbacks = config.CategoryFallbacks
+    opts.Equipment = config.Equipment
+    opts.RepeatDays = config.RepeatDays(req.RepeatWeeks)
+    opts.Goals = config.Goals
+    opts.Guests = guests
+    opts.Household = config.Household
+    opts.MinVeggies = config.MinVeggieServings
+    opts.NoCookNights = config.NoCookNights
+    opts.LeftoverNights = config.LeftoverNights
+    opts.Schedule = config.Schedule
+    opts.Spend = config.Spend
+    opts.Budget = config.Spend.budget(req.MaxBudget)
+    opts.UseUp = parseUseUp(req.UseUp)
+    opts.Season, opts.Seasons = req.Season, config.Seasons
+    if req.Season != "" || dinners.hasSeasons() {
+        note := "Season: " + opts.seasonOn(state.WeekStart.AddDate(0, 0, 3))
+        if config.Seasons.strict() {
+            note += ", only dinners in season"
+        }
+        notes = append(notes, note)
+    }
+    var pinNotes []string
+    opts.Pins, pinNotes = state.pinnedDinners(dinners)
+    notes = append(notes, pinNotes...)
+    
+    // Days already planned stay, so running again only fills the gaps
+    opts.Keep = keptDays(state.Plan, opts.Pins, req.Force)
+    wanted := days
+    if wanted == nil && week.Name == "" {
+        wanted = opts.schedule().planDays()
+    }
+    missing := false
+    for _, day := range wanted {
+        _, kept := opts.Keep[day]
+        missing = missing || !kept && opts.Modes[day] != DaySkip
+    }
+    for day := range opts.Pins {
+        _, kept := opts.Keep[day]
+        missing = missing || !kept
+    }
+    if !missing && len(opts.Keep) > 0 {
+        return nil, nil, errWeekPlanned
+    }
+    if len(opts.Keep) > 0 && state.Plan != nil {
+        notes = append(append([]string(nil), state.Plan.Notes...), notes...)
+    }
+    seed := req.Seed
+    if seed == 0 {
+        seed = time.Now().UnixNano()
+    }
+    opts.Rand = newRand(seed)
+    opts.Choose = config.Fairness.Chooser(dinners, state.History, state.WeekStart, state.TasteWeights(dinners, config.Ratings), opts.Rand)
+    
+    for _, rule := range []*Observance{themeRule, req.Diet} {
+        if err := checkDiet(dinners, opts, rule); err != nil {
+            return nil, nil, err
+        }
+    }
+    
+    // The days picked again free their old dinners
+    if state.Plan != nil {
+        for _, entry := range state.Plan.Days {
+            if _, kept := opts.Keep[entry.Day]; !kept && !entry.IsLeftovers() {
+                state.RemoveSelection(entry.Dinner)
+            }
+        }
+    }
+    
+    // Select dinners for the week, unless the pattern has none
+    plan := NewPlan(state.WeekStart)
+    if week.Name != "" && len(days) == 0 {
+        plan.Notes = append(plan.Notes, fmt.Sprintf("Nothing to plan in a %s week", week.Name))
+        for _, entry := range opts.Keep {
+            plan.Put(entry)
+        }
+    } else {
+        plan = SelectWeeklyDinners(dinners, state, opts)
+    }
+    plan.Pattern = week.Name
+    plan.Seed = seed
+    plan.Budget = opts.Budget
+    plan.UseUp = req.UseUp
+    plan.Season = req.Season
+    for i := range plan.Days {
+        plan.Days[i].Holiday = holidays[plan.Days[i].Day].Name
+    }
+    annotateServings(dinners, plan, guests, config.Household)
+    plan.Notes = uniqueStrings(append(notes, plan.Notes...))
+    if state.Plan != nil {
+        plan.Revision = state.Plan.Revision
+    }
+    plan.Revision++
+    state.Plan = plan
+    
+    // Save updated state, unless the caller wants to adjust it first
+    if req.Draft {
+        return state, config, nil
+    }
+    if err := state.Record("plan", planSummary(plan)); err != nil {
+        return nil, nil, err
+    }
+    return state, config, nil
+}
+
+// weekPlannedHint says how to plan the week again when there was nothing to fill in
+func weekPlannedHint(err error) error {
+    if errors.Is(err, errWeekPlanned) {
+        return fmt.Errorf("%w: show prints it, swap changes a day and plan --force picks every day not yet eaten again", err)
+    }
+    return err
+}
+
+// planSummary describes a plan for the journal, e.g. "Sun Tom kha kai, Mon Chorizo pasta"
+func planSummary(plan *Plan) string {
+    var planned []string
+    for _, entry := range plan.Days {
+        planned = append(planned, entry.Day[:3]+" "+entry.title())
+    }
+    return strings.Join(planned, ", ")
+}
+
+// confirmingPlan reports whether a new plan waits for a yes before it's
+// saved: when someone's at a terminal, unless the config switches it off
+func confirmingPlan(config *Config) bool {
+    return enabled(config.ConfirmPlan) && stdinIsTerminal() && isTerminal()
+}
+
+// confirmPlan asks whether to save the proposed plan shown above, saying so
+// when it replaces one saved earlier this week; anything but yes keeps the
+// week as it was
+func confirmPlan(state *WeekState, replacing bool) (bool, error) {
+    question := "Save this plan? [y/N]: "
+    if replacing && state.Plan.Revision > 1 {
+        question = "This week already has a plan. Replace it with this one? [y/N]: "
+    }
+    answer, err := prompt(bufio.NewReader(os.Stdin), question)
+    if errors.Is(err, io.EOF) {
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    answer = strings.ToLower(answer)
+    return answer == "y" || answer == "yes", nil
+}
+
+// runWeekCommand handles "week note [text]", printing or setting the note for
+// the current week, and "week [--skip steps] [--yes]", which runs the weekly routine
+func runWeekCommand(args []string) error {
+    if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+        return runWeekRun(args)
+    }
+    if args[0] != "note" {
+        return fmt.Errorf("usage: dinner-picker week [--skip steps] [--yes] | week note [text]")
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    text := strings.TrimSpace(strings.Join(args[1:], " "))
+    if text == "" {
+        if state.Note == "" {
+            fmt.Println("No note for this week")
+        } else {
+            fmt.Println(state.Note)
+        }
+        return nil
+    }
+
+    state.Note = text
+    if err := state.Record("week note", text); err != nil {
+        return err
+    }
+    logf("Note for week of %s: %s\n", state.WeekStart.Format("January 2, 2006"), state.Note)
+    return nil
+}
+
+// runShowCommand handles "show [--grid] [--width N] [--output text|json|markdown]", printing the current plan without re-rolling
+func runShowCommand(args []string) error {
+    fs := flag.NewFlagSet("show", flag.ContinueOnError)
+    grid := fs.Bool("grid", false, "render the week as a compact grid")
+    width := fs.Int("width", terminalWidth(), "grid width in columns")
+    menuMode := fs.String("menu", "", "menu detail: names, short or full")
+    output := fs.String("output", "text", "menu format: text, json or markdown")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    formatter, err := menuFormatter(*output)
+    if err != nil {
+        return err
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    if state.Plan.IsEmpty() {
+        fmt.Println("No dinners planned for this week yet")
+        return nil
+    }
+
+    if *grid {
+        RenderGrid(os.Stdout, state.Plan, *width, loadStyles())
+        return nil
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    menu, err := NewMenuOptions(*menuMode, config)
+    if err != nil {
+        return err
+    }
+    return printPlan(formatter, state, config, menu)
+}
+
+// runRecipeCommand handles "recipe <name> [--servings N]", printing a single
+// dinner in full, with amounts scaled for N and a warning when it won't scale to N
+func runRecipeCommand(args []string) error {
+    fs := flag.NewFlagSet("recipe", flag.ContinueOnError)
+    people := fs.Int("servings", 0, "how many people to cook for")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    name := strings.TrimSpace(strings.Join(positional, " "))
+    if name == "" {
+        return fmt.Errorf("usage: dinner-picker recipe <name> [--servings N]")
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    dinner, ok := dinners.FindDinner(name)
+    if !ok {
+        return fmt.Errorf("no dinner named %q", name)
+    }
+
+    fmt.Printf("%s (%s)\n", dinner.Name, dinner.Category)
+    if dinner.NoCook {
+        fmt.Println("No cooking: put together and serve")
+    }
+    if dinner.CookTime > 0 {
+        fmt.Printf("Cook time: %d min\n", dinner.CookTime)
+    }
+    if dinner.Source != nil {
+        fmt.Printf("Source: %s\n", dinner.Source)
+    }
+    if len(dinner.PreferredDays) > 0 {
+        fmt.Printf("Best on: %s\n", strings.Join(dinner.PreferredDays, ", "))
+    }
+    if len(dinner.Seasons) > 0 {
+        fmt.Printf("In season: %s\n", strings.Join(dinner.Seasons, ", "))
+    }
+    if len(dinner.Allergens) > 0 {
+        fmt.Printf("Contains: %s\n", strings.Join(dinner.Allergens, ", "))
+    }
+    if unlisted := dinner.unlistedAllergens(); len(unlisted) > 0 {
+        fmt.Printf("From the ingredients: %s\n", strings.Join(unlisted, ", "))
+    }
+    if diets := dinner.DerivedDietTags(); len(diets) > 0 {
+        fmt.Printf("Diet, from the ingredients: %s\n", strings.Join(diets, ", "))
+    }
+    if dinner.Nutrition != nil {
+        fmt.Printf("Nutrition: %s a portion\n", dinner.Nutrition)
+    }
+    fmt.Printf("Added: %s\n", dinner.Origin)
+    serves := dinner.servings(config.Household)
+    scale := 1.0
+    if *people > 0 && *people != serves {
+        scale = float64(*people) / float64(serves)
+        fmt.Printf("Serves: %d, scaled by %.2g for %d\n", serves, scale, *people)
+        if !dinner.scalesTo(*people, config.Household) {
+            warnf("%s", scaleWarning(dinners, dinner, *people, config.Household))
+        }
+    } else {
+        fmt.Printf("Serves: %d\n", serves)
+    }
+    fmt.Println("Ingredients:")
+    for _, ingredient := range dinner.Ingredients {
+        fmt.Printf("  %s\n", ingredient.Scaled(scale))
+    }
+    return nil
+}
+
+// runSearchCommand handles "search [text] [--source text]", ranking matches in names, tags, ingredients and sources
+func runSearchCommand(args []string) error {
+    fs := flag.NewFlagSet("search", flag.ContinueOnError)
+    source := fs.String("source", "", "only show dinners whose source contains this text")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    query := strings.ToLower(strings.Join(positional, " "))
+    if query == "" && *source == "" {
+        return fmt.Errorf("usage: dinner-picker search [text] [--source text]")
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    plannedOn := make(map[string]string)
+    if state.Plan != nil {
+        for _, entry := range state.Plan.Days {
+            plannedOn[entry.Dinner.Name] = entry.Day
+        }
+    }
+
+    var results []SearchResult
+    if query != "" {
+        results = IndexFor(dinners).Search(query)
+    } else {
+        for _, dinner := range dinners.AllDinners() {
+            results = append(results, SearchResult{Dinner: dinner})
+        }
+    }
+
+    found := 0
+    for _, result := range results {
+        dinner := result.Dinner
+        if *source != "" && !strings.Contains(strings.ToLower(dinner.Source.String()), strings.ToLower(*source)) {
+            continue
+        }
+
+        found++
+        line := fmt.Sprintf("%s (%s)", Highlight(dinner.Name, query), dinner.Category)
+        if dinner.Source != nil {
+            line += " - " + Highlight(dinner.Source.String(), query)
+        }
+        if day, ok := plannedOn[dinner.Name]; ok {
+            line += fmt.Sprintf(" [planned %s]", day)
+        }
+        fmt.Println(line)
+        for _, match := range result.Matches {
+            if match != dinner.Name && match != dinner.Source.String() {
+                fmt.Printf("  %s\n", Highlight(match, query))
+            }
+        }
+    }
+    if found == 0 {
+        fmt.Println("No matching dinners")
+    }
+    return nil
+}
+
+// SwapResult is what swapDay changed
+type SwapResult struct {
+    Day         string   `json:"day"`
+    Previous    string   `json:"previous"`
+    Replacement Dinner   `json:"replacement"`
+    NewItems    []string `json:"new_items"`
+    Note        string   `json:"note,omitempty"`
+}
+
+// swapDay replaces one day's dinner in the state's plan with another from its
+// category, preferring dinners that reuse the rest of the week's shopping when
+// minimize is set, and only ones that cook within maxMinutes if it's set. The
+// caller records the change.
+func swapDay(dinners *DinnerData, state *WeekState, config *Config, day string, minimize bool, repeatDays, maxMinutes int) (*SwapResult, error) {
+    current, ok := state.Plan.Dinner(day)
+    if !ok {
+        return nil, fmt.Errorf("nothing planned for %s this week", day)
+    }
+
+    category, note := dinners.ResolveCategory(current.Category, config.CategoryFallbacks)
+    if category == "" {
+        return nil, fmt.Errorf("nothing left to swap in for %s's %s", day, current.Name)
+    }
+
+    date := state.Plan.WeekStart.AddDate(0, 0, dayIndex(day))
+    season := state.Plan.Season
+    if season == "" {
+        season = config.Seasons.On(date)
+    }
+    // Each filter a candidate has to pass, with what to say when it's the
+    // one that leaves nothing to swap in
+    filters := []struct {
+        keep func(Dinner) bool
+        none string
+    }{
+        {func(dinner Dinner) bool { return !state.IsAlreadySelected(dinner) }, fmt.Sprintf("every other %s dinner is planned this week already", category)},
+        {func(dinner Dinner) bool { return !state.IsBanned(dinner) }, fmt.Sprintf("every other %s dinner left is banned", category)},
+        {func(dinner Dinner) bool { return !config.Seasons.strict() || dinner.InSeason(season) }, fmt.Sprintf("no other %s dinner left is in season", category)},
+        {func(dinner Dinner) bool { return maxMinutes <= 0 || config.cookMinutes(dinner) <= maxMinutes }, fmt.Sprintf("no other %s dinner left cooks in %dm or less", category, maxMinutes)},
+        {func(dinner Dinner) bool { return observancesPermit(config.Observances, date, dinner) }, fmt.Sprintf("no other %s dinner left fits %s's dietary rules", category, day)},
+        {func(dinner Dinner) bool { return config.Equipment.Permits(date, dinner) }, fmt.Sprintf("no other %s dinner left can be made with %s's equipment", category, day)},
+    }
+    passes := func(dinner Dinner) bool {
+        for _, filter := range filters {
+            if !filter.keep(dinner) {
+                return false
+            }
+        }
+        return true
+    }
+    eligible := func(relaxed bool) []Dinner {
+        var candidates []Dinner
+        for _, dinner := range dinners.Dinners[category] {
+            if dinner.Name == current.Name || (!relaxed && state.TooRecent(dinner, date, repeatDays)) || !passes(dinner) {
+                continue
+            }
+            candidates = append(candidates, dinner)
+        }
+        return candidates
+    }
+    // Rather a dinner eaten recently than no swap at all
+    candidates := eligible(false)
+    if len(candidates) == 0 {
+        candidates = eligible(true)
+        if len(candidates) > 0 {
+            note = strings.TrimPrefix(note+"; ", "; ") + fmt.Sprintf("every other %s dinner was eaten recently", category)
+        }
+    }
+    if len(candidates) == 0 {
+        // Say which filter left nothing, applying them in turn
+        var left []Dinner
+        for _, dinner := range dinners.Dinners[category] {
+            if dinner.Name != current.Name {
+                left = append(left, dinner)
+            }
+        }
+        if len(left) == 0 {
+            return nil, fmt.Errorf("%s is the only %s dinner, so there's nothing to swap in", current.Name, category)
+        }
+        for _, filter := range filters {
+            var kept []Dinner
+            for _, dinner := range left {
+                if filter.keep(dinner) {
+                    kept = append(kept, dinner)
+                }
+            }
+            if len(kept) == 0 {
+                return nil, fmt.Errorf("%s (the category has %d)", filter.none, len(dinners.Dinners[category]))
+            }
+            left = kept
+        }
+        return nil, fmt.Errorf("no other %s dinners available to swap in (the category has %d)", category, len(dinners.Dinners[category]))
+    }
+
+    // Dinners in season come first, others only when none is
+    var inSeason []Dinner
+    for _, dinner := range candidates {
+        if dinner.InSeason(season) {
+            inSeason = append(inSeason, dinner)
+        }
+    }
+    if len(inSeason) > 0 {
+        candidates = inSeason
+    }
+
+    // A batch with leftovers planned from it is swapped for another batch
+    // if there is one, since the leftover days follow it
+    for _, entry := range state.Plan.Days {
+        if entry.LeftoversFrom != day {
+            continue
+        }
+        var batches []Dinner
+        for _, dinner := range candidates {
+            if dinner.feedsTwice(config.Household) {
+                batches = append(batches, dinner)
+            }
+        }
+        if len(batches) > 0 {
+            candidates = batches
+        } else {
+            note = strings.TrimPrefix(note+"; ", "; ") + fmt.Sprintf("no other %s dinner makes leftovers for %s", category, entry.Day)
+        }
+        break
+    }
+
+    // Everything the rest of the week already needs counts as on the list
+    var others []Dinner
+    for _, entry := range state.Plan.Days {
+        if entry.Day != day {
+            others = append(others, entry.Dinner)
+        }
+    }
+    onList := make(map[string]bool)
+    for _, item := range ShoppingList(others) {
+        onList[item] = true
+    }
+
+    if minimize {
+        var fewest []Dinner
+        best := -1
+        for _, dinner := range candidates {
+            n := len(NewItems(dinner, onList))
+            if best < 0 || n < best {
+                fewest, best = nil, n
+            }
+            if n == best {
+                fewest = append(fewest, dinner)
+            }
+        }
+        candidates = fewest
+    }
+    r := newRand(0)
+    replacement := preferDays(r, day, config.Fairness.Chooser(dinners, state.History, state.WeekStart, state.TasteWeights(dinners, config.Ratings), r))(candidates)
+
+    state.Plan.Replace(day, replacement)
+    state.Plan.Revision++
+    state.RemoveSelection(current)
+    state.AddSelection(replacement)
+    return &SwapResult{
+        Day:         day,
+        Previous:    current.Name,
+        Replacement: replacement,
+        NewItems:    withoutStaples(NewItems(replacement, onList), config.Staples),
+        Note:        note,
+    }, nil
+}
+
+// runSwapCommand handles "swap <day> [--minimize-new-items] [--no-repeat-weeks N]
+// [--max-minutes N]", replacing one planned dinner
+func runSwapCommand(args []string) error {
+    fs := flag.NewFlagSet("swap", flag.ContinueOnError)
+    minimize := fs.Bool("minimize-new-items", false, "prefer dinners whose ingredients are already on the shopping list")
+    repeatWeeks := fs.Int("no-repeat-weeks", 0, "weeks before a dinner may be planned again, 0 for no limit (default from config)")
+    maxMinutes := fs.Int("max-minutes", 0, "only swap in dinners that cook in this many minutes or less")
+    diet := newDietFlags(fs)
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 {
+        return fmt.Errorf("usage: dinner-picker swap <day> [--minimize-new-items] [--no-repeat-weeks N] [--max-minutes N] [--require-tag t] [--exclude-tag t] [--exclude-allergen a]")
+    }
+    day, ok := normalizeDay(positional[0])
+    if !ok {
+        return fmt.Errorf("unknown day: %s", positional[0])
+    }
+    weeks, err := repeatWeeksFlag(fs, *repeatWeeks)
+    if err != nil {
+        return err
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    if rule := diet.rule(); rule != nil {
+        config.Observances = append(config.Observances, *rule)
+    }
+    swap, err := swapDay(dinners, state, config, day, *minimize, config.RepeatDays(weeks), *maxMinutes)
+    if err != nil {
+        return err
+    }
+    if swap.Note != "" {
+        logf("Note: %s\n", swap.Note)
+    }
+    if err := state.Record("swap", fmt.Sprintf("%s: %s -> %s", day, swap.Previous, swap.Replacement.Name)); err != nil {
+        return err
+    }
+
+    fmt.Printf("%s - %s (was %s)\n", day, swap.Replacement.Name, swap.Previous)
+    if len(swap.NewItems) == 0 {
+        fmt.Println("No new items needed")
+        return nil
+    }
+    fmt.Println("New items needed:")
+    for _, item := range swap.NewItems {
+        fmt.Printf("  %s\n", item)
+    }
+    return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+const ConfigFileName = "config.json"
+
+// Config holds optional settings; a missing config file means defaults everywhere
+type Config struct {
+    Calendar *CalendarConfig `json:"calendar,omitempty"`
+    Weather  *WeatherConfig  `json:"weather,omitempty"`
+    Protein  *ProteinRules   `json:"protein,omitempty"`
+    Update   *UpdateConfig   `json:"update,omitempty"`
+
+    // LunchTarget is how many weekday lunches leftovers should cover each week
+    LunchTarget int `json:"lunch_target,omitempty"`
+
+    // NoCookNights is how many nights a week get a no-cook dinner
+    NoCookNights int `json:"no_cook_nights,omitempty"`
+
+    // LeftoverNights is how many nights a week eat an earlier night's big
+    // batch again: cook once, eat twice
+    LeftoverNights int `json:"leftover_nights,omitempty"`
+
+    // MenuMode is the default menu detail (names, short or full) and Staples
+    // are everyday ingredients like salt and oil that the menu collapses and
+    // the shopping list leaves out as always stocked
+    MenuMode string   `json:"menu_mode,omitempty"`
+    Staples  []string `json:"staples,omitempty"`
+
+    // NoRepeatDays is how long after a dinner was eaten it can be planned
+    // again (default 10, and 0 turns the rule off); NoRepeatWeeks says the
+    // same in weeks
+    NoRepeatDays  *int `json:"no_repeat_days,omitempty"`
+    NoRepeatWeeks *int `json:"no_repeat_weeks,omitempty"`
+
+    // DinnerHour is when "next" moves on from tonight's dinner (default 19:00)
+    DinnerHour string `json:"dinner_hour,omitempty"`
+
+    Observances []Observance    `json:"observances,omitempty"`
+    Fairness    *FairnessConfig `json:"fairness,omitempty"`
+    Ratings     *RatingsConfig  `json:"ratings,omitempty"`
+
+    Stores    *StoreConfig     `json:"stores,omitempty"`
+    Equipment *EquipmentConfig `json:"equipment,omitempty"`
+    Week      *WeekConfig      `json:"week,omitempty"`
+    Schedule  *ScheduleConfig  `json:"schedule,omitempty"`
+    Reminders *RemindersConfig `json:"reminders,omitempty"`
+    Rotation  *RotationConfig  `json:"rotation,omitempty"`
+    Templates []PlanTemplate   `json:"templates,omitempty"`
+    Holidays  *HolidayConfig   `json:"holidays,omitempty"`
+    History   *HistoryConfig   `json:"history,omitempty"`
+    Storage   *StorageConfig   `json:"storage,omitempty"`
+    Spend     *SpendConfig     `json:"spend,omitempty"`
+    Seasons   *SeasonConfig    `json:"seasons,omitempty"`
+
+    // Household is how many people usually eat, and what recipes without
+    // servings are assumed to feed (default 4)
+    Household int `json:"household,omitempty"`
+
+    // Goals are weekly targets the planner aims for and "stats goals" scores
+    Goals []Goal `json:"goals,omitempty"`
+
+    // MinVeggieServings is the fewest vegetable servings per person a week's
+    // dinners should add up to, by the dinners' veggie_servings estimates
+    MinVeggieServings float64 `json:"min_veggie_servings,omitempty"`
+
+    // CategoryFallbacks lists, per category, where to pick from instead when
+    // it has been removed or emptied
+    CategoryFallbacks map[string][]string `json:"category_fallbacks,omitempty"`
+
+    // ConfirmPlan shows a new plan and asks before saving it when someone's
+    // at a terminal; runs from cron and scripts save straight away (default on)
+    ConfirmPlan *bool `json:"confirm_plan,omitempty"`
+}
+
+// Validate checks settings that can't be checked by JSON decoding alone
+func (c *Config) Validate() error {
+    if c.Equipment != nil {
+        if err := c.Equipment.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Holidays != nil {
+        if err := c.Holidays.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Schedule != nil {
+        if err := c.Schedule.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Rotation != nil {
+        if err := c.Rotation.validate(); err != nil {
+            return err
+        }
+    }
+    if err := validateTemplates(c.Templates); err != nil {
+        return err
+    }
+    if c.Reminders != nil {
+        if err := c.Reminders.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Fairness != nil {
+        if err := c.Fairness.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Ratings != nil {
+        if err := c.Ratings.validate(); err != nil {
+            return err
+        }
+    }
+    if c.History != nil {
+        if err := c.History.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Storage != nil {
+        if err := c.Storage.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Spend != nil {
+        if err := c.Spend.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Seasons != nil {
+        if err := c.Seasons.validate(); err != nil {
+            return err
+        }
+    }
+    if (c.NoRepeatDays != nil && *c.NoRepeatDays < 0) || (c.NoRepeatWeeks != nil && *c.NoRepeatWeeks < 0) {
+        return fmt.Errorf("no_repeat_days and no_repeat_weeks can't be negative")
+    }
+    if c.NoRepeatDays != nil && c.NoRepeatWeeks != nil {
+        return fmt.Errorf("set no_repeat_days or no_repeat_weeks, not both")
+    }
+    if c.NoCookNights < 0 || c.NoCookNights > 7 {
+        return fmt.Errorf("no_cook_nights must be between 0 and 7")
+    }
+    if c.LeftoverNights < 0 || c.LeftoverNights > 3 {
+        return fmt.Errorf("leftover_nights must be between 0 and 3")
+    }
+    if c.MinVeggieServings < 0 {
+        return fmt.Errorf("min_veggie_servings can't be negative")
+    }
+    for _, g := range c.Goals {
+        if err := g.validate(); err != nil {
+            return err
+        }
+    }
+    for _, o := range c.Observances {
+        if err := o.validate(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// RepeatDays returns how many days a dinner rests after it was eaten: the
+// weeks asked for on the command line, else the config's, else
+// defaultRepeatDays. Only a value that was never set falls back; 0 is 0.
+func (c *Config) RepeatDays(weeks *int) int {
+    switch {
+    case weeks != nil:
+        return *weeks * 7
+    case c.NoRepeatWeeks != nil:
+        return *c.NoRepeatWeeks * 7
+    case c.NoRepeatDays != nil:
+        return *c.NoRepeatDays
+    }
+    return defaultRepeatDays
+}
+
+// SignalProviders returns the enabled providers that bias day/category choices
+func (c *Config) SignalProviders() []SignalProvider {
+    var providers []SignalProvider
+    if c.Weather != nil && c.Weather.Enabled {
+        providers = append(providers, NewWeatherProvider(c.Weather))
+    }
+    return providers
+}
+
+// LoadConfig reads the config file, returning an empty config if it doesn't exist
+func LoadConfig() (*Config, error) {
+    file, err := os.ReadFile(dataPath(ConfigFileName))
+    if os.IsNotExist(err) {
+        return &Config{}, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading config file: %w", err)
+    }
+
+    var config Config
+    err = json.Unmarshal(file, &config)
+    if err != nil {
+        return nil, fmt.Errorf("error parsing config JSON: %w", err)
+    }
+    if err := config.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid config: %w", err)
+    }
+
+    return &config, nil
+}
+
+// SaveConfig writes the config file
+func (c *Config) SaveConfig() error {
+    data, err := json.MarshalIndent(c, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling config: %w", err)
+    }
+
+    err = writeFileAtomic(dataPath(ConfigFileName), data)
+    if err != nil {
+        return fmt.Errorf("error writing config file: %w", err)
+    }
+
+    return nil
+}
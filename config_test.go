@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+// The no-repeat window comes from the flag, then the config's weeks, then
+// its days, and only a window never set falls back to the default
+func TestRepeatDays(t *testing.T) {
+    n := func(v int) *int { return &v }
+    tests := []struct {
+        name   string
+        config Config
+        flag   *int
+        want   int
+    }{
+        {"nothing set", Config{}, nil, defaultRepeatDays},
+        {"config days", Config{NoRepeatDays: n(5)}, nil, 5},
+        {"config weeks", Config{NoRepeatWeeks: n(4)}, nil, 28},
+        {"weeks over days", Config{NoRepeatDays: n(5), NoRepeatWeeks: n(2)}, nil, 14},
+        {"flag over config", Config{NoRepeatDays: n(5), NoRepeatWeeks: n(2)}, n(3), 21},
+        {"config turns it off", Config{NoRepeatWeeks: n(0)}, nil, 0},
+        {"flag turns it off", Config{NoRepeatDays: n(5)}, n(0), 0},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if got := test.config.RepeatDays(test.flag); got != test.want {
+                t.Errorf("RepeatDays = %d, want %d", got, test.want)
+            }
+        })
+    }
+}
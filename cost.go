@@ -0,0 +1,284 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "strconv"
+)
+
+// SpendConfig prices what dinners don't price themselves, and sets a weekly
+// budget
+type SpendConfig struct {
+    // PerServing is what a home-cooked portion costs on average, for dinners
+    // with no cost of their own or their ingredients'
+    PerServing float64 `json:"per_serving,omitempty"`
+
+    // Takeout is what a takeaway night costs the household
+    Takeout float64 `json:"takeout,omitempty"`
+
+    // Currency is put before amounts, e.g. "€" (default none)
+    Currency string `json:"currency,omitempty"`
+
+    // WeeklyBudget is what a week's dinners should cost at most; the
+    // planner swaps in cheaper dinners until the estimate fits
+    WeeklyBudget float64 `json:"weekly_budget,omitempty"`
+}
+
+// validate checks no price is negative
+func (s *SpendConfig) validate() error {
+    if s.PerServing < 0 || s.Takeout < 0 || s.WeeklyBudget < 0 {
+        return fmt.Errorf("spend: prices can't be negative")
+    }
+    return nil
+}
+
+// format spells an amount in the configured currency, e.g. "€1,234"
+func (s *SpendConfig) format(amount float64) string {
+    digits := strconv.Itoa(int(amount + 0.5))
+    for i := len(digits) - 3; i > 0; i -= 3 {
+        digits = digits[:i] + "," + digits[i:]
+    }
+    if s == nil {
+        return digits
+    }
+    return s.Currency + digits
+}
+
+// householdSize is how many usually eat: the config's household, or the
+// default servings
+func householdSize(household int) int {
+    if household > 0 {
+        return household
+    }
+    return defaultServings
+}
+
+// costFor returns what the dinner costs to make for people, counting only
+// the ingredients buy accepts (nil for all): its own cost, else its
+// ingredients' costs added up, else the spend config's per_serving a
+// portion. The first two are for the recipe as written and scale with
+// people. It reports false when nothing gives a price.
+func (d Dinner) costFor(people, household int, spend *SpendConfig, buy func(Ingredient) bool) (float64, bool) {
+    scale := float64(people) / float64(d.servings(household))
+    if d.Cost > 0 {
+        return d.Cost * scale, true
+    }
+    priced, total := false, 0.0
+    for _, ingredient := range d.Ingredients {
+        if ingredient.Cost <= 0 {
+            continue
+        }
+        priced = true
+        if buy == nil || buy(ingredient) {
+            total += ingredient.Cost
+        }
+    }
+    if priced {
+        return total * scale, true
+    }
+    if spend != nil && spend.PerServing > 0 {
+        return spend.PerServing * float64(people), true
+    }
+    return 0, false
+}
+
+// planCost adds up what the plan's dinners cost for the people eating them,
+// counting only the ingredients buy accepts (nil for all). Leftover and
+// skipped days cost nothing. It returns the dinners it couldn't price too.
+func planCost(plan *Plan, household int, spend *SpendConfig, buy func(Ingredient) bool) (float64, []string) {
+    if plan == nil {
+        return 0, nil
+    }
+    total := 0.0
+    var unpriced []string
+    for _, entry := range plan.Days {
+        if entry.IsLeftovers() || entry.Outcome == OutcomeSkipped {
+            continue
+        }
+        people := entry.Servings
+        if people == 0 {
+            people = householdSize(household)
+        }
+        cost, ok := entry.Dinner.costFor(people, household, spend, buy)
+        if !ok {
+            unpriced = append(unpriced, entry.Dinner.Name)
+        }
+        total += cost
+    }
+    return total, unpriced
+}
+
+// dayCost estimates what a past day's dinner cost, by its price in the
+// catalog now; a takeaway night costs the spend config's takeout. It
+// reports false when nothing gives a price, or nothing was eaten.
+func dayCost(day HistoryDay, dinners *DinnerData, household int, spend *SpendConfig) (float64, bool) {
+    name, eaten := eatenName(day)
+    if !eaten {
+        return 0, false
+    }
+    dinner, found := dinners.FindDinner(name)
+    if isTakeout(dinner, found, name) && spend != nil && spend.Takeout > 0 {
+        return spend.Takeout, true
+    }
+    if !found {
+        // A dinner since removed, or a substitute, costs what a portion does
+        dinner = Dinner{Name: name}
+    }
+    return dinner.costFor(householdSize(household), household, spend, nil)
+}
+
+// historyCost estimates what a past week's eaten dinners cost, and returns
+// the dinners and substitutes it couldn't price too
+func historyCost(week HistoryWeek, dinners *DinnerData, household int, spend *SpendConfig) (float64, []string) {
+    total := 0.0
+    var unpriced []string
+    for _, day := range week.Days {
+        cost, ok := dayCost(day, dinners, household, spend)
+        if name, eaten := eatenName(day); eaten && !ok {
+            unpriced = append(unpriced, name)
+        }
+        total += cost
+    }
+    return total, unpriced
+}
+
+// budget returns the week's budget: over, from the command line, else the
+// spend config's weekly_budget, else 0 for none
+func (s *SpendConfig) budget(over float64) float64 {
+    if over > 0 {
+        return over
+    }
+    if s == nil {
+        return 0
+    }
+    return s.WeeklyBudget
+}
+
+// peopleOn is how many a day's dinner is for: its guests, or the household
+func (o PlanOptions) peopleOn(plan *Plan, day string) int {
+    if entry, ok := plan.Entry(day); ok && entry.Servings > 0 {
+        return entry.Servings
+    }
+    return householdSize(o.Household)
+}
+
+// withinBudget reports whether swapping a day's current dinner for candidate
+// keeps the week within the budget, or doesn't cost more than it did; with
+// a budget, a candidate that can't be priced doesn't come in
+func (o PlanOptions) withinBudget(plan *Plan, day string, current, candidate Dinner) bool {
+    if o.Budget <= 0 {
+        return true
+    }
+    now, _ := current.costFor(o.peopleOn(plan, day), o.Household, o.Spend, nil)
+    then, ok := candidate.costFor(o.peopleOn(plan, day), o.Household, o.Spend, nil)
+    if !ok {
+        return false
+    }
+    total, _ := planCost(plan, o.Household, o.Spend, nil)
+    return then <= now || total-now+then <= o.Budget
+}
+
+// ensureBudget swaps days within their categories for cheaper dinners until
+// the week's estimated cost fits the budget, keeping goals and protein rules
+// as they were. What the catalog can't bring under budget is noted.
+func ensureBudget(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    if opts.Budget <= 0 {
+        return
+    }
+    for {
+        total, _ := planCost(plan, opts.Household, opts.Spend, nil)
+        if total <= opts.Budget {
+            return
+        }
+        if !replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+            now, _ := current.costFor(opts.peopleOn(plan, day), opts.Household, opts.Spend, nil)
+            then, ok := candidate.costFor(opts.peopleOn(plan, day), opts.Household, opts.Spend, nil)
+            return ok && then < now && keepsRules(plan, opts, current, candidate)
+        }) {
+            break
+        }
+    }
+    total, unpriced := planCost(plan, opts.Household, opts.Spend, nil)
+    note := fmt.Sprintf("Budget: about %s planned, over the %s budget", opts.Spend.format(total), opts.Spend.format(opts.Budget))
+    if len(unpriced) > 0 {
+        note += ", " + insufficientData("cost", unpriced)
+    }
+    plan.Notes = append(plan.Notes, note)
+}
+
+// printCostSummary prints the week's estimated cost, once any dinner has a
+// price or a budget is set
+func printCostSummary(plan *Plan, config *Config) {
+    total, unpriced := planCost(plan, config.Household, config.Spend, nil)
+    budget := config.Spend.budget(plan.Budget)
+    if total == 0 && budget == 0 {
+        return
+    }
+    line := fmt.Sprintf("Cost: about %s", config.Spend.format(total))
+    if budget > 0 {
+        line += fmt.Sprintf(" of a %s budget", config.Spend.format(budget))
+    }
+    if len(unpriced) > 0 {
+        line += ", " + insufficientData("cost", unpriced)
+    }
+    fmt.Println(line)
+}
+
+// runBudgetCommand handles "budget report [--weeks 13]", what recent weeks'
+// dinners cost against the weekly budget
+func runBudgetCommand(args []string) error {
+    if len(args) == 0 || args[0] != "report" {
+        return fmt.Errorf("usage: dinner-picker budget report [--weeks 13]")
+    }
+    fs := flag.NewFlagSet("budget report", flag.ContinueOnError)
+    weeks := fs.Int("weeks", 13, "how many past weeks to report")
+    if _, err := parseArgs(fs, args[1:]); err != nil {
+        return err
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    recent := recentWeeks(state, *weeks)
+    if len(recent) == 0 {
+        fmt.Println("No finished weeks to report yet")
+        return nil
+    }
+
+    budget := config.Spend.budget(0)
+    fmt.Println("  Week        Cost")
+    sum, over := 0.0, 0
+    var unpriced []string
+    for _, week := range recent {
+        total, missing := historyCost(week, dinners, config.Household, config.Spend)
+        sum += total
+        mark := ""
+        if len(missing) > 0 {
+            mark = "+"
+            unpriced = append(unpriced, missing...)
+        }
+        if budget > 0 && total > budget {
+            mark += fmt.Sprintf("  over by %s", config.Spend.format(total-budget))
+            over++
+        }
+        fmt.Printf("  %s  %8s%s\n", week.WeekStart.Format("2006-01-02"), config.Spend.format(total), mark)
+    }
+    fmt.Printf("Average %s a week, %s in all", config.Spend.format(sum/float64(len(recent))), config.Spend.format(sum))
+    if budget > 0 {
+        fmt.Printf("; %d of %d weeks over the %s budget", over, len(recent), config.Spend.format(budget))
+    }
+    fmt.Println()
+    if len(unpriced) > 0 {
+        fmt.Printf("(+ at least, %s)\n", insufficientData("cost", uniqueStrings(unpriced)))
+    }
+    return nil
+}
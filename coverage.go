@@ -0,0 +1,54 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// dinnerField is an optional field of a dinner, what relies on it, and how
+// to tell it's been filled in
+type dinnerField struct {
+    name   string
+    usedBy string
+    has    func(Dinner) bool
+}
+
+// dinnerFields are the optional fields features lean on. Each feature works
+// without them, but says so rather than counting a missing one as zero.
+var dinnerFields = []dinnerField{
+    {"cook_time", "quick and project days, reminders", func(d Dinner) bool { return d.CookTime > 0 }},
+    {"servings", "guests, leftover nights", func(d Dinner) bool { return d.Servings > 0 }},
+    {"protein", "protein rules and goals, trends", func(d Dinner) bool { return d.MainProtein() != "" }},
+    {"tags", "tag goals, observances, taste", func(d Dinner) bool { return len(d.Tags) > 0 }},
+    {"allergens", "allergy rules", func(d Dinner) bool { return len(d.Allergens) > 0 }},
+    {"veggie_servings", "veggie totals and stats", func(d Dinner) bool { return d.VeggieServings > 0 }},
+    {"steps", "prep cards", func(d Dinner) bool { return len(d.Steps.Items()) > 0 }},
+    {"equipment", "equipment outages", func(d Dinner) bool { return len(d.Equipment) > 0 }},
+    {"source", "recipe", func(d Dinner) bool { return d.Source != nil }},
+    {"cost", "cost estimates and budgets", func(d Dinner) bool { _, ok := d.costFor(1, 0, nil, nil); return ok }},
+    {"seasons", "seasonal planning", func(d Dinner) bool { return len(d.Seasons) > 0 }},
+    {"nutrition", "nutrition totals and the calorie limit", func(d Dinner) bool { return d.Nutrition != nil }},
+}
+
+// printCoverage prints how much of the catalog has each optional field
+func printCoverage(dinners []Dinner) {
+    if len(dinners) == 0 {
+        return
+    }
+    fmt.Printf("Field coverage, of %d dinners:\n", len(dinners))
+    for _, field := range dinnerFields {
+        n := 0
+        for _, dinner := range dinners {
+            if field.has(dinner) {
+                n++
+            }
+        }
+        fmt.Printf("  %-16s %3d%%  %s\n", field.name, n*100/len(dinners), field.usedBy)
+    }
+}
+
+// insufficientData notes the dinners a figure couldn't take into account,
+// e.g. "insufficient data: no veggie_servings for Nasi, Hotdogs"
+func insufficientData(field string, names []string) string {
+    return fmt.Sprintf("insufficient data: no %s for %s", field, strings.Join(names, ", "))
+}
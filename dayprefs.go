@@ -0,0 +1,84 @@
+package main
+
+import (
+    "math/rand"
+    "sort"
+    "strings"
+)
+
+// How much more or less likely a dinner with preferred days is to be picked:
+// on one of its days, or on a day it doesn't list. Dinners without preferred
+// days count 1.
+const (
+    preferredDayWeight = 3.0
+    otherDayWeight     = 0.25
+)
+
+// onDay reports whether a preferred_days entry covers the day: a day name, or
+// "weekday" or "weekend"
+func onDay(entry, day string) bool {
+    switch strings.ToLower(entry) {
+    case "weekday":
+        return day != "Saturday" && day != "Sunday"
+    case "weekend":
+        return day == "Saturday" || day == "Sunday"
+    }
+    d, ok := normalizeDay(entry)
+    return ok && d == day
+}
+
+// validPreferredDay reports whether a preferred_days entry is one onDay understands
+func validPreferredDay(entry string) bool {
+    _, ok := normalizeDay(entry)
+    return ok || strings.EqualFold(entry, "weekday") || strings.EqualFold(entry, "weekend")
+}
+
+// dayWeight is how a dinner's preferred days weigh its chance on a day
+func (d Dinner) dayWeight(day string) float64 {
+    if len(d.PreferredDays) == 0 {
+        return 1
+    }
+    for _, entry := range d.PreferredDays {
+        if onDay(entry, day) {
+            return preferredDayWeight
+        }
+    }
+    return otherDayWeight
+}
+
+// preferDays wraps a chooser so dinners are likelier on their preferred days.
+// The candidates are grouped by weight, a group is drawn in proportion to its
+// weight and size, and the chooser picks within it, so fairness and taste
+// still decide between dinners that suit the day equally. Groups are drawn
+// with r.
+func preferDays(r *rand.Rand, day string, choose func([]Dinner) Dinner) func([]Dinner) Dinner {
+    return func(candidates []Dinner) Dinner {
+        groups := make(map[float64][]Dinner)
+        for _, dinner := range candidates {
+            w := dinner.dayWeight(day)
+            groups[w] = append(groups[w], dinner)
+        }
+        if len(groups) == 1 {
+            return choose(candidates)
+        }
+        var weights []float64
+        total := 0.0
+        for w, group := range groups {
+            weights = append(weights, w)
+            total += w * float64(len(group))
+        }
+        sort.Float64s(weights)
+        n := r.Float64() * total
+        for _, w := range weights {
+            if n -= w * float64(len(groups[w])); n < 0 {
+                return choose(groups[w])
+            }
+        }
+        return choose(groups[weights[len(weights)-1]])
+    }
+}
+
+// chooseOn is the chooser for one day, leaning towards dinners that prefer it
+func (o PlanOptions) chooseOn(day string) func([]Dinner) Dinner {
+    return preferDays(o.random(), day, o.choose)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+    _ "embed"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "time"
+)
+
+// demoDinners is the sample catalog "demo" plans from
+//
+//go:embed demo_dinners.json
+var demoDinners []byte
+
+// demoWeeks is how many weeks of made-up history the demo starts with, so
+// stats have something to show
+const demoWeeks = 6
+
+// demoConfig shows off a few settings that need no accounts or network
+func demoConfig() *Config {
+    return &Config{
+        Staples:           []string{"olive oil", "butter", "mayonnaise"},
+        MinVeggieServings: 8,
+        Household:         4,
+        Goals:             []Goal{{Tag: "vegetarian", Min: 2}},
+    }
+}
+
+// demoHistory makes up past weeks in the default schedule's shape: every
+// protein but fish, a skipped evening, and takeaway creeping in lately, so
+// "stats trends" has something to say
+func demoHistory(dinners *DinnerData, current time.Time) []HistoryWeek {
+    var weeks []HistoryWeek
+    n := 0
+    for ago := demoWeeks; ago >= 1; ago-- {
+        start := current.AddDate(0, 0, -7*ago)
+        week := HistoryWeek{WeekStart: start}
+        for i, day := range defaultPlanDays {
+            category := defaultSchedule.Days[day][(ago+i)%len(defaultSchedule.Days[day])]
+            list := dinners.Dinners[category]
+            dinner := list[n%len(list)]
+            for tries := 0; dinner.MainProtein() == "fish" && ago < demoWeeks && tries < len(list); tries++ {
+                n++
+                dinner = list[n%len(list)]
+            }
+            n++
+            entry := HistoryDay{Day: day, Date: start.AddDate(0, 0, dayIndex(day)), Dinner: dinner.Name, Category: category, Outcome: OutcomeCooked, Rating: 3 + n%3}
+            switch {
+            case ago <= 2 && day == "Thursday":
+                entry.Outcome, entry.Substitute, entry.Rating = OutcomeSubstituted, "takeaway pizza", 0
+            case ago == 3 && day == "Tuesday":
+                entry.Outcome, entry.Rating = OutcomeSkipped, 0
+            }
+            week.Days = append(week.Days, entry)
+        }
+        weeks = append(weeks, week)
+    }
+    return weeks
+}
+
+// setUpDemo fills a fresh data directory with the sample catalog, config and history
+func setUpDemo(dir string) error {
+    dataDirs = singleDir(dir)
+    if err := writeFileAtomic(dataPath(DinnersFileName), demoDinners); err != nil {
+        return fmt.Errorf("error writing demo dinners: %w", err)
+    }
+    config, err := json.MarshalIndent(demoConfig(), "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling demo config: %w", err)
+    }
+    if err := writeFileAtomic(dataPath(ConfigFileName), config); err != nil {
+        return fmt.Errorf("error writing demo config: %w", err)
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state := &WeekState{WeekStart: GetCurrentWeekStart(), CurrentWeek: []Dinner{}}
+    state.History = demoHistory(dinners, state.WeekStart)
+    return state.Record("demo", fmt.Sprintf("%d weeks of sample history", demoWeeks))
+}
+
+// runDemoCommand handles "demo [--keep]", showing planning, a swap, the
+// shopping list and stats on a built-in sample catalog in a temporary
+// directory, leaving real data alone
+func runDemoCommand(args []string) error {
+    fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+    keep := fs.Bool("keep", false, "keep the demo data directory to explore afterwards")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    dir, err := os.MkdirTemp("", "dinner-picker-demo-")
+    if err != nil {
+        return fmt.Errorf("error creating demo directory: %w", err)
+    }
+    if !*keep {
+        defer os.RemoveAll(dir)
+    }
+    if err := setUpDemo(dir); err != nil {
+        return err
+    }
+
+    swapDay := "Monday"
+    steps := []struct {
+        command string
+        run     func() error
+    }{
+        {"plan", func() error { return runPlanCommand(nil) }},
+        {"swap " + swapDay, func() error { return runSwapCommand([]string{swapDay}) }},
+        {"shopping-list", func() error { return runShoppingListCommand(nil) }},
+        {"stats goals", func() error { return runStatsCommand([]string{"goals"}) }},
+        {"stats trends", func() error { return runStatsCommand([]string{"trends"}) }},
+    }
+    fmt.Println("Demo: a sample catalog and six made-up weeks, nothing of yours is touched")
+    for _, step := range steps {
+        fmt.Printf("\n$ dinner-picker %s\n", step.command)
+        if err := step.run(); err != nil {
+            fmt.Printf("Error: %v\n", err)
+        }
+    }
+
+    if *keep {
+        fmt.Printf("\nThe demo data is in %s; try it with DINNER_PICKER_HOME=%s dinner-picker <command>\n", dir, dir)
+    }
+    return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "strings"
+)
+
+// HasAllergen reports whether the dinner lists an allergen, or its
+// ingredients contain it, ignoring case and a plural "s"
+func (d Dinner) HasAllergen(allergen string) bool {
+    for _, a := range d.Allergens {
+        if sameAllergen(a, allergen) {
+            return true
+        }
+    }
+    for _, a := range d.DerivedAllergens() {
+        if sameAllergen(a, allergen) {
+            return true
+        }
+    }
+    return false
+}
+
+// sameAllergen reports whether two allergens are spelled alike, ignoring
+// case, a plural "s" and hyphens, with "tree nuts" the same as "nuts"
+func sameAllergen(a, b string) bool {
+    plain := func(s string) string {
+        s = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", " ")
+        return strings.TrimSuffix(strings.TrimPrefix(s, "tree "), "s")
+    }
+    return plain(a) == plain(b)
+}
+
+// dietFlags are the dietary rules plan and swap take on the command line
+type dietFlags struct {
+    requireTags      *string
+    excludeTags      *string
+    excludeAllergens *string
+    maxCalories      *float64
+}
+
+// newDietFlags defines the dietary rules on a flag set
+func newDietFlags(fs *flag.FlagSet) dietFlags {
+    return dietFlags{
+        requireTags:      fs.String("require-tag", "", "only dinners with these tags, comma-separated, e.g. vegetarian"),
+        excludeTags:      fs.String("exclude-tag", "", "no dinners with these tags, comma-separated, e.g. meat"),
+        excludeAllergens: fs.String("exclude-allergen", "", "no dinners listing these allergens, comma-separated, e.g. peanuts,gluten"),
+        maxCalories:      fs.Float64("max-calories-per-day", 0, "no dinners over this many kcal a portion, by their nutrition"),
+    }
+}
+
+// rule returns the flags as an observance for every day, or nil if none
+// were given
+func (f dietFlags) rule() *Observance {
+    rule := &Observance{
+        RequireTags:      splitList(*f.requireTags),
+        ExcludeTags:      splitList(*f.excludeTags),
+        ExcludeAllergens: splitList(*f.excludeAllergens),
+        MaxCalories:      *f.maxCalories,
+        adHoc:            true,
+    }
+    var parts []string
+    parts = append(parts, rule.RequireTags...)
+    for _, tag := range rule.ExcludeTags {
+        parts = append(parts, "no "+tag)
+    }
+    for _, allergen := range rule.ExcludeAllergens {
+        parts = append(parts, "no "+allergen)
+    }
+    if rule.MaxCalories > 0 {
+        parts = append(parts, fmt.Sprintf("at most %.0f kcal", rule.MaxCalories))
+    }
+    if len(parts) == 0 {
+        return nil
+    }
+    rule.Name = strings.Join(parts, ", ")
+    return rule
+}
+
+// checkDiet makes sure the command line's rule leaves every planned day
+// something to pick from, among the categories the day draws from and their
+// fallbacks, so a rule that can't be met is an error rather than an empty week
+func checkDiet(dinners *DinnerData, opts PlanOptions, rule *Observance) error {
+    if rule == nil {
+        return nil
+    }
+    schedule := opts.schedule()
+    days := opts.Days
+    if len(days) == 0 {
+        days = schedule.planDays()
+    }
+    for _, day := range days {
+        if opts.Modes[day] == DaySkip {
+            continue
+        }
+        var tried []string
+        fits := false
+        for _, category := range schedule.categoriesOn(dinners, day) {
+            resolved, _ := dinners.ResolveCategory(category, opts.Fallbacks)
+            if resolved == "" {
+                continue
+            }
+            for _, name := range append([]string{resolved}, alternateCategories(dinners, opts, day, resolved)...) {
+                tried = append(tried, name)
+                for _, dinner := range dinners.Dinners[name] {
+                    fits = fits || rule.Permits(dinner)
+                }
+            }
+        }
+        if !fits {
+            return fmt.Errorf("%s: no %s dinner is %s, loosen the rule or tag more dinners", day, strings.Join(uniqueStrings(tried), " or "), rule.Name)
+        }
+    }
+    return nil
+}
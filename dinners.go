@@ -0,0 +1,372 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+)
+
+// splitList splits a comma-separated flag value, dropping blanks
+func splitList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// dinnerFlags are the fields "dinner add" and "dinner edit" can set
+type dinnerFlags struct {
+    category    *string
+    newCategory *bool
+    ingredients *string
+    tags        *string
+    allergens   *string
+    protein     *string
+    days        *string
+    seasons     *string
+    cookTime    *int
+    servings    *int
+    noCook      *bool
+    nutrition   *string
+}
+
+// newDinnerFlags defines the dinner fields on a flag set
+func newDinnerFlags(fs *flag.FlagSet) dinnerFlags {
+    return dinnerFlags{
+        category:    fs.String("category", "", "category to file the dinner under"),
+        newCategory: fs.Bool("new-category", false, "allow --category to start a new category"),
+        ingredients: fs.String("ingredients", "", "comma-separated ingredients, e.g. \"400 g pasta, 2 eggs\""),
+        tags:        fs.String("tags", "", "comma-separated tags"),
+        allergens:   fs.String("allergens", "", "comma-separated allergens, e.g. peanuts,gluten"),
+        protein:     fs.String("protein", "", "main protein, or none"),
+        days:        fs.String("preferred-days", "", "comma-separated days the dinner suits best"),
+        seasons:     fs.String("seasons", "", "comma-separated seasons the dinner suits, e.g. fall,winter"),
+        cookTime:    fs.Int("cook-time", 0, "cook time in minutes"),
+        servings:    fs.Int("servings", 0, "how many the recipe feeds as written"),
+        noCook:      fs.Bool("no-cook", false, "an assembly meal with nothing to cook (--no-cook=false to undo)"),
+        nutrition:   fs.String("nutrition", "", "a portion's calories,protein,carbs,fat, e.g. 520,32,60,18 (none to clear)"),
+    }
+}
+
+// apply sets the fields given on the command line, checking each
+func (f dinnerFlags) apply(fs *flag.FlagSet, dinner *Dinner) error {
+    var err error
+    fs.Visit(func(fl *flag.Flag) {
+        if err != nil {
+            return
+        }
+        switch fl.Name {
+        case "ingredients":
+            items := splitList(*f.ingredients)
+            if len(items) == 0 {
+                err = fmt.Errorf("--ingredients lists nothing")
+                return
+            }
+            dinner.Ingredients = nil
+            for _, item := range items {
+                dinner.Ingredients = append(dinner.Ingredients, ParseIngredient(item))
+            }
+        case "tags":
+            dinner.Tags = splitList(*f.tags)
+        case "allergens":
+            dinner.Allergens = splitList(*f.allergens)
+        case "protein":
+            dinner.Protein = strings.ToLower(strings.TrimSpace(*f.protein))
+        case "preferred-days":
+            days := splitList(*f.days)
+            for _, day := range days {
+                if !validPreferredDay(day) {
+                    err = fmt.Errorf("unknown day %q in --preferred-days", day)
+                    return
+                }
+            }
+            dinner.PreferredDays = days
+        case "seasons":
+            var names []string
+            for _, season := range splitList(*f.seasons) {
+                name, ok := normalizeSeason(season)
+                if !ok {
+                    err = fmt.Errorf("unknown season %q in --seasons", season)
+                    return
+                }
+                names = append(names, name)
+            }
+            dinner.Seasons = names
+        case "cook-time":
+            if *f.cookTime < 0 {
+                err = fmt.Errorf("--cook-time can't be negative")
+                return
+            }
+            dinner.CookTime = *f.cookTime
+        case "servings":
+            if *f.servings < 0 {
+                err = fmt.Errorf("--servings can't be negative")
+                return
+            }
+            dinner.Servings = *f.servings
+        case "no-cook":
+            dinner.NoCook = *f.noCook
+        case "nutrition":
+            if strings.EqualFold(strings.TrimSpace(*f.nutrition), "none") {
+                dinner.Nutrition = nil
+                return
+            }
+            dinner.Nutrition, err = parseNutrition(*f.nutrition)
+        }
+    })
+    return err
+}
+
+// categoryIn checks a category given on the command line, returning its
+// spelling in the catalog; a new one needs --new-category
+func (f dinnerFlags) categoryIn(dinners *DinnerData, name string) (string, error) {
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return "", fmt.Errorf("the category can't be blank")
+    }
+    if existing, ok := dinners.hasCategory(name); ok {
+        return existing, nil
+    }
+    if !*f.newCategory {
+        return "", fmt.Errorf("no category named %q (add --new-category to start one)", name)
+    }
+    return name, nil
+}
+
+// locate finds a dinner by name, returning its category and index
+func (d *DinnerData) locate(name string) (string, int, bool) {
+    for category, list := range d.Dinners {
+        for i, dinner := range list {
+            if strings.EqualFold(dinner.Name, name) {
+                return category, i, true
+            }
+        }
+    }
+    return "", 0, false
+}
+
+// editable finds a dinner the catalog's own file holds, so changes to it can
+// be saved
+func (d *DinnerData) editable(name string) (string, int, error) {
+    category, i, ok := d.locate(name)
+    if !ok {
+        return "", 0, fmt.Errorf("no dinner named %q", name)
+    }
+    if d.included != nil && d.included.dinners[strings.ToLower(d.Dinners[category][i].Name)] {
+        return "", 0, fmt.Errorf("%s comes from an included file, edit it there", d.Dinners[category][i].Name)
+    }
+    return category, i, nil
+}
+
+// AddDinner files a new dinner under its category, refusing duplicate names
+func (d *DinnerData) AddDinner(dinner Dinner) error {
+    if strings.TrimSpace(dinner.Name) == "" {
+        return fmt.Errorf("the dinner needs a name")
+    }
+    if existing, ok := d.FindDinner(dinner.Name); ok {
+        return fmt.Errorf("%s is already in the catalog, under %s", existing.Name, existing.Category)
+    }
+    if d.Dinners == nil {
+        d.Dinners = make(map[string][]Dinner)
+    }
+    d.Dinners[dinner.Category] = append(d.Dinners[dinner.Category], dinner)
+    return nil
+}
+
+// RemoveDinner takes a dinner out of the catalog, dropping its category if
+// it was the last one
+func (d *DinnerData) RemoveDinner(name string) (Dinner, error) {
+    category, i, err := d.editable(name)
+    if err != nil {
+        return Dinner{}, err
+    }
+    list := d.Dinners[category]
+    dinner := list[i]
+    list = append(list[:i:i], list[i+1:]...)
+    if len(list) == 0 {
+        delete(d.Dinners, category)
+    } else {
+        d.Dinners[category] = list
+    }
+    return dinner, nil
+}
+
+// MoveDinner refiles a dinner under another category
+func (d *DinnerData) MoveDinner(name, category string) (Dinner, error) {
+    dinner, err := d.RemoveDinner(name)
+    if err != nil {
+        return Dinner{}, err
+    }
+    dinner.Category = category
+    dinner.markEdited()
+    d.Dinners[category] = append(d.Dinners[category], dinner)
+    return dinner, nil
+}
+
+// stillPlanned says which days this week a dinner is planned for, if any
+func stillPlanned(name string) string {
+    state, err := LoadState()
+    if err != nil || state.Plan == nil {
+        return ""
+    }
+    var days []string
+    for _, entry := range state.Plan.Days {
+        if strings.EqualFold(entry.Dinner.Name, name) && entry.Outcome == "" {
+            days = append(days, entry.Day)
+        }
+    }
+    return strings.Join(days, ", ")
+}
+
+// runDinnerCommand handles "dinner add|edit|remove|move", changing the
+// catalog without editing dinners.json by hand
+func runDinnerCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker dinner add <name> [--category c] [--ingredients \"a, b\"] ...|edit <name> [--name new] ...|remove <name>|move <name> <category>")
+    if len(args) == 0 {
+        return usage
+    }
+    action := args[0]
+    fs := flag.NewFlagSet("dinner "+action, flag.ContinueOnError)
+    fields := newDinnerFlags(fs)
+    rename := fs.String("name", "", "new name (edit only)")
+    positional, err := parseArgs(fs, args[1:])
+    if err != nil {
+        return err
+    }
+    if *rename != "" && action != "edit" {
+        return fmt.Errorf("--name only goes with dinner edit")
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    var message string
+    switch action {
+    case "add":
+        dinner := Dinner{Name: strings.TrimSpace(strings.Join(positional, " "))}
+        if err := fields.apply(fs, &dinner); err != nil {
+            return err
+        }
+        if dinner.Name == "" {
+            return usage
+        }
+        if len(dinner.Ingredients) == 0 {
+            return fmt.Errorf("%s needs --ingredients", dinner.Name)
+        }
+        if *fields.category != "" {
+            if dinner.Category, err = fields.categoryIn(dinners, *fields.category); err != nil {
+                return err
+            }
+        } else {
+            suggestion := dinners.SuggestCategory(dinner)
+            switch {
+            case stdinIsTerminal():
+                chosen, err := askCategory(bufio.NewReader(os.Stdin), dinners, dinner, suggestion)
+                if err != nil && !errors.Is(err, io.EOF) {
+                    return err
+                }
+                if chosen == "" {
+                    fmt.Println("Nothing added")
+                    return nil
+                }
+                dinner.Category = chosen
+            case suggestion != "":
+                fmt.Printf("Filing %s under %s, the closest match; move it with \"dinner move\" if that's wrong\n", dinner.Name, suggestion)
+                dinner.Category = suggestion
+            default:
+                return fmt.Errorf("no category given for %s and nothing alike to suggest one, use --category", dinner.Name)
+            }
+        }
+        dinner.Origin = &Origin{CreatedAt: time.Now(), Via: OriginManual}
+        if err := dinners.AddDinner(dinner); err != nil {
+            return err
+        }
+        message = fmt.Sprintf("Added %s to %s", dinner.Name, dinner.Category)
+
+    case "edit":
+        name := strings.TrimSpace(strings.Join(positional, " "))
+        if name == "" {
+            return usage
+        }
+        category, i, err := dinners.editable(name)
+        if err != nil {
+            return err
+        }
+        dinner := dinners.Dinners[category][i]
+        changed := false
+        fs.Visit(func(*flag.Flag) { changed = true })
+        if !changed {
+            return fmt.Errorf("nothing to change, give the fields to set (see dinner-picker dinner edit --help)")
+        }
+        if err := fields.apply(fs, &dinner); err != nil {
+            return err
+        }
+        if newName := strings.TrimSpace(*rename); newName != "" {
+            if existing, ok := dinners.FindDinner(newName); ok && !strings.EqualFold(newName, dinner.Name) {
+                return fmt.Errorf("%s is already in the catalog, under %s", existing.Name, existing.Category)
+            }
+            dinner.Name = newName
+        }
+        dinner.markEdited()
+        dinners.Dinners[category][i] = dinner
+        if *fields.category != "" {
+            target, err := fields.categoryIn(dinners, *fields.category)
+            if err != nil {
+                return err
+            }
+            if target != category {
+                if _, err := dinners.MoveDinner(dinner.Name, target); err != nil {
+                    return err
+                }
+            }
+        }
+        message = fmt.Sprintf("Updated %s", dinner.Name)
+
+    case "remove":
+        name := strings.TrimSpace(strings.Join(positional, " "))
+        if name == "" {
+            return usage
+        }
+        dinner, err := dinners.RemoveDinner(name)
+        if err != nil {
+            return err
+        }
+        message = fmt.Sprintf("Removed %s from %s", dinner.Name, dinner.Category)
+        if days := stillPlanned(dinner.Name); days != "" {
+            message += fmt.Sprintf(" (still planned for %s this week, swap it if you like)", days)
+        }
+
+    case "move":
+        if len(positional) < 2 {
+            return usage
+        }
+        name := strings.Join(positional[:len(positional)-1], " ")
+        target, err := fields.categoryIn(dinners, positional[len(positional)-1])
+        if err != nil {
+            return err
+        }
+        dinner, err := dinners.MoveDinner(name, target)
+        if err != nil {
+            return err
+        }
+        message = fmt.Sprintf("Moved %s to %s", dinner.Name, target)
+
+    default:
+        return usage
+    }
+
+    if err := saveCatalog(dinners); err != nil {
+        return err
+    }
+    fmt.Println(message)
+    return nil
+}
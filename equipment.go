@@ -0,0 +1,182 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// defaultEquipment is what a kitchen is assumed to have when config doesn't say
+var defaultEquipment = []string{"oven", "stovetop", "grill", "slow cooker", "pressure cooker", "microwave", "wok", "blender"}
+
+// EquipmentConfig lists the kitchen's equipment and when some of it can't be used
+type EquipmentConfig struct {
+    Known       []string         `json:"known,omitempty"`
+    Unavailable []EquipmentOutage `json:"unavailable,omitempty"`
+}
+
+// EquipmentOutage makes a piece of equipment unavailable on a date range and/or
+// weekdays, with the same From/To/Days rules as observances
+type EquipmentOutage struct {
+    Item string   `json:"item"`
+    From string   `json:"from,omitempty"`
+    To   string   `json:"to,omitempty"`
+    Days []string `json:"days,omitempty"`
+    Note string   `json:"note,omitempty"`
+}
+
+// schedule returns the outage's dates as an observance so both share date handling
+func (o EquipmentOutage) schedule() Observance {
+    name := o.Item
+    if o.Note != "" {
+        name += " (" + o.Note + ")"
+    }
+    return Observance{Name: name, From: o.From, To: o.To, Days: o.Days}
+}
+
+// validate checks the outage's dates and days
+func (c *EquipmentConfig) validate() error {
+    for _, outage := range c.Unavailable {
+        if outage.Item == "" {
+            return fmt.Errorf("equipment outage without an item")
+        }
+        if err := outage.schedule().validate(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// known returns the configured equipment, or the defaults
+func (c *EquipmentConfig) known() []string {
+    if c == nil || len(c.Known) == 0 {
+        return defaultEquipment
+    }
+    return c.Known
+}
+
+// IsKnown reports whether a piece of equipment is in the kitchen's list
+func (c *EquipmentConfig) IsKnown(item string) bool {
+    for _, known := range c.known() {
+        if strings.EqualFold(known, strings.TrimSpace(item)) {
+            return true
+        }
+    }
+    return false
+}
+
+// UnavailableOn returns the outages in force on a date
+func (c *EquipmentConfig) UnavailableOn(date time.Time) []EquipmentOutage {
+    if c == nil {
+        return nil
+    }
+    var outages []EquipmentOutage
+    for _, outage := range c.Unavailable {
+        if outage.schedule().ActiveOn(date) {
+            outages = append(outages, outage)
+        }
+    }
+    return outages
+}
+
+// Permits reports whether everything a dinner needs is available on a date
+func (c *EquipmentConfig) Permits(date time.Time, dinner Dinner) bool {
+    for _, outage := range c.UnavailableOn(date) {
+        for _, item := range dinner.Equipment {
+            if strings.EqualFold(strings.TrimSpace(item), outage.Item) {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// runValidateCommand handles "validate" (or "doctor"), checking dinners.json
+// and config.json for mistakes the planner would otherwise work around
+// silently, and how much of the catalog has each optional field
+func runValidateCommand(args []string) error {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    var problems []string
+    seen := make(map[string]string)
+    for category, list := range dinners.Dinners {
+        if len(list) == 0 {
+            problems = append(problems, fmt.Sprintf("category %s has no dinners", category))
+        }
+        for _, dinner := range list {
+            if dinner.Category != category {
+                problems = append(problems, fmt.Sprintf("%s is listed under %s but says category %q", dinner.Name, category, dinner.Category))
+            }
+            if other, ok := seen[strings.ToLower(dinner.Name)]; ok {
+                problems = append(problems, fmt.Sprintf("%s appears in both %s and %s", dinner.Name, other, category))
+            }
+            seen[strings.ToLower(dinner.Name)] = category
+            if _, err := dinner.Steps.decode(); err != nil {
+                problems = append(problems, fmt.Sprintf("%s has steps that aren't all text", dinner.Name))
+            }
+            for _, entry := range dinner.PreferredDays {
+                if !validPreferredDay(entry) {
+                    problems = append(problems, fmt.Sprintf("%s prefers unknown day %q", dinner.Name, entry))
+                }
+            }
+            for _, season := range dinner.Seasons {
+                if _, ok := normalizeSeason(season); !ok {
+                    problems = append(problems, fmt.Sprintf("%s has unknown season %q", dinner.Name, season))
+                }
+            }
+            for _, item := range dinner.Equipment {
+                if !config.Equipment.IsKnown(item) {
+                    problems = append(problems, fmt.Sprintf("%s needs unknown equipment %q", dinner.Name, item))
+                }
+            }
+        }
+    }
+    for category, style := range dinners.Categories {
+        if err := style.validate(category); err != nil {
+            problems = append(problems, err.Error())
+        }
+    }
+    if config.Schedule != nil {
+        for _, category := range config.Schedule.categories() {
+            if _, ok := dinners.hasCategory(category); !ok && len(config.CategoryFallbacks[category]) == 0 {
+                problems = append(problems, fmt.Sprintf("schedule draws from unknown category %q", category))
+            }
+        }
+    }
+    for _, template := range config.Templates {
+        for _, category := range template.schedule(nil).categories() {
+            if _, ok := dinners.hasCategory(category); !ok && len(config.CategoryFallbacks[category]) == 0 {
+                problems = append(problems, fmt.Sprintf("template %s draws from unknown category %q", template.Name, category))
+            }
+        }
+    }
+    if config.Equipment != nil {
+        for _, outage := range config.Equipment.Unavailable {
+            if !config.Equipment.IsKnown(outage.Item) {
+                problems = append(problems, fmt.Sprintf("config marks unknown equipment %q unavailable", outage.Item))
+            }
+        }
+    }
+
+    sort.Strings(problems)
+    for _, problem := range problems {
+        fmt.Println(problem)
+    }
+    if len(problems) == 0 {
+        fmt.Println("No problems found")
+    }
+    fmt.Println()
+    printCoverage(dinners.AllDinners())
+    if len(problems) > 0 {
+        return fmt.Errorf("found %d problems", len(problems))
+    }
+    return nil
+}
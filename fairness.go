@@ -0,0 +1,152 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+    "time"
+)
+
+// Fairness modes
+const (
+    FairnessUniform  = "uniform"
+    FairnessCooldown = "cooldown"
+    FairnessWeighted = "weighted"
+)
+
+// FairnessConfig decides how a dinner is chosen among the eligible ones in a
+// category. Uniform picks any of them; cooldown rests a dinner for a number of
+// weeks scaled by its category's size; weighted favours dinners by how long
+// it's been since they were planned, measured against the whole catalog.
+type FairnessConfig struct {
+    Mode string `json:"mode"`
+
+    // CooldownFactor turns category size into rest weeks (default 0.5, so a
+    // dinner in a category of 4 rests 2 weeks and one in a category of 30 rests 15)
+    CooldownFactor float64 `json:"cooldown_factor,omitempty"`
+}
+
+// validate checks the mode name
+func (f *FairnessConfig) validate() error {
+    switch f.Mode {
+    case "", FairnessUniform, FairnessCooldown, FairnessWeighted:
+    default:
+        return fmt.Errorf("unknown fairness mode %q (want uniform, cooldown or weighted)", f.Mode)
+    }
+    if f.CooldownFactor < 0 {
+        return fmt.Errorf("fairness cooldown_factor can't be negative")
+    }
+    return nil
+}
+
+// cooldownFactor returns the configured factor or the default
+func (f *FairnessConfig) cooldownFactor() float64 {
+    if f.CooldownFactor > 0 {
+        return f.CooldownFactor
+    }
+    return 0.5
+}
+
+// restWeeks is how many weeks a dinner rests in cooldown mode, from the size
+// of its category
+func (f *FairnessConfig) restWeeks(categorySize int) int {
+    return int(math.Ceil(float64(categorySize) * f.cooldownFactor()))
+}
+
+// lastPlanned maps each dinner to the start of the latest history week it was planned in
+func lastPlanned(history []HistoryWeek) map[string]time.Time {
+    last := make(map[string]time.Time)
+    for _, week := range history {
+        for _, day := range week.Days {
+            if week.WeekStart.After(last[day.Dinner]) {
+                last[day.Dinner] = week.WeekStart
+            }
+        }
+    }
+    return last
+}
+
+// weightedPick picks a candidate with probability proportional to its weight
+func weightedPick(r *rand.Rand, candidates []Dinner, weight func(Dinner) float64) Dinner {
+    weights := make([]float64, len(candidates))
+    total := 0.0
+    for i, dinner := range candidates {
+        weights[i] = weight(dinner)
+        total += weights[i]
+    }
+    if total == 0 {
+        return candidates[r.Intn(len(candidates))]
+    }
+    n := r.Float64() * total
+    for i, w := range weights {
+        if n < w {
+            return candidates[i]
+        }
+        n -= w
+    }
+    return candidates[len(candidates)-1]
+}
+
+// Chooser returns the function that picks one of a category's eligible dinners
+// for the week starting at weekStart, drawing from r. taste scales each
+// dinner's chance (nil for none); a nil config otherwise picks uniformly.
+func (f *FairnessConfig) Chooser(dinners *DinnerData, history []HistoryWeek, weekStart time.Time, taste func(Dinner) float64, r *rand.Rand) func([]Dinner) Dinner {
+    if taste == nil {
+        taste = func(Dinner) float64 { return 1 }
+    }
+    if f == nil || f.Mode == "" || f.Mode == FairnessUniform {
+        return func(candidates []Dinner) Dinner {
+            return weightedPick(r, candidates, taste)
+        }
+    }
+
+    last := lastPlanned(history)
+    // weeksSince is -1 for dinners that were never planned
+    weeksSince := func(dinner Dinner) int {
+        when, ok := last[dinner.Name]
+        if !ok {
+            return -1
+        }
+        return int(weekStart.Sub(when).Hours() / 24 / 7)
+    }
+
+    if f.Mode == FairnessCooldown {
+        return func(candidates []Dinner) Dinner {
+            rest := f.restWeeks(len(dinners.Dinners[candidates[0].Category]))
+            var rested []Dinner
+            for _, dinner := range candidates {
+                if weeks := weeksSince(dinner); weeks < 0 || weeks >= rest {
+                    rested = append(rested, dinner)
+                }
+            }
+            if len(rested) > 0 {
+                return weightedPick(r, rested, taste)
+            }
+
+            // Everything is still resting: take whichever has rested longest
+            best := candidates[0]
+            for _, dinner := range candidates[1:] {
+                if weeksSince(dinner) > weeksSince(best) {
+                    best = dinner
+                }
+            }
+            return best
+        }
+    }
+
+    // Weighted: a dinner is fully due once the whole catalog could have gone
+    // round, so small categories don't repeat faster than large ones
+    rotation := len(dinners.AllDinners()) / len(defaultPlanDays)
+    if rotation < 1 {
+        rotation = 1
+    }
+    return func(candidates []Dinner) Dinner {
+        return weightedPick(r, candidates, func(dinner Dinner) float64 {
+            weeks := weeksSince(dinner)
+            if weeks < 0 || weeks > rotation {
+                weeks = rotation
+            }
+            return float64(weeks+1) * taste(dinner)
+        })
+    }
+}
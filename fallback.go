@@ -0,0 +1,79 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// ExhaustedError says a day's category had nothing left that could be
+// planned, and why, so the catalog can be filled out
+type ExhaustedError struct {
+    Day      string
+    Category string
+    Size     int
+    Reason   string
+}
+
+func (e *ExhaustedError) Error() string {
+    return fmt.Sprintf("%s: %s, left unplanned", e.Day, e.Reason)
+}
+
+// dayRules are the checks a day's dinner goes through: permitted are the
+// hard rules (observances, equipment), require adds the no-repeat rule, and
+// prefer the soft ones
+type dayRules struct {
+    permitted func(Dinner) bool
+    require   func(Dinner) bool
+    prefer    func(Dinner) bool
+}
+
+// pickFallback is tried when nothing in the category passes every rule. It
+// first relaxes the no-repeat rule, then tries the other categories the day
+// could have drawn from; observances and equipment are never relaxed. It
+// returns the dinner with a note saying what gave, or an *ExhaustedError.
+func pickFallback(dinners *DinnerData, state *WeekState, opts PlanOptions, day, category string, rules dayRules) (Dinner, string, error) {
+    if dinner, ok := pickDinner(dinners, state, category, rules.permitted, rules.prefer, opts.chooseOn(day)); ok {
+        return dinner, fmt.Sprintf("%s: every %s dinner was eaten recently, repeating %s", day, category, dinner.Name), nil
+    }
+    size := len(dinners.Dinners[category])
+    planned, permitted := 0, 0
+    for _, dinner := range dinners.Dinners[category] {
+        if state.IsAlreadySelected(dinner) {
+            planned++
+        }
+        if rules.permitted(dinner) {
+            permitted++
+        }
+    }
+    reason := fmt.Sprintf("no %s dinner fits", category)
+    switch {
+    case size > 0 && permitted == 0:
+        reason = fmt.Sprintf("none of the %d %s dinners fit the day's dietary rules and equipment", size, category)
+    case planned == size:
+        reason = fmt.Sprintf("all %d %s dinners are already planned this week", size, category)
+    }
+
+    for _, other := range alternateCategories(dinners, opts, day, category) {
+        if dinner, ok := pickDinner(dinners, state, other, rules.require, rules.prefer, opts.chooseOn(day)); ok {
+            return dinner, fmt.Sprintf("%s: %s, using %s", day, reason, other), nil
+        }
+    }
+    return Dinner{}, "", &ExhaustedError{Day: day, Category: category, Size: size, Reason: reason}
+}
+
+// alternateCategories lists where else a day can pick from: the category's
+// fallbacks from the config, then the other categories its schedule gives it
+func alternateCategories(dinners *DinnerData, opts PlanOptions, day, category string) []string {
+    var alternates []string
+    seen := map[string]bool{strings.ToLower(category): true}
+    candidates := append(append([]string(nil), opts.Fallbacks[category]...), opts.schedule().categoriesOn(dinners, day)...)
+    for _, name := range candidates {
+        name, ok := dinners.hasCategory(name)
+        if !ok || seen[strings.ToLower(name)] {
+            continue
+        }
+        seen[strings.ToLower(name)] = true
+        alternates = append(alternates, name)
+    }
+    return alternates
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+// When nothing in a day's category passes every rule, the planner repeats a
+// recent dinner, then tries the fallback categories, then gives up saying why
+func TestPickFallback(t *testing.T) {
+    tomato := Dinner{Name: "Tomato soup", Category: "soup"}
+    ramen := Dinner{Name: "Ramen", Category: "soup", Tags: []string{"meat"}}
+    pesto := Dinner{Name: "Pesto pasta", Category: "pasta"}
+    dinners := &DinnerData{Dinners: map[string][]Dinner{
+        "soup":  {tomato, ramen},
+        "pasta": {pesto},
+    }}
+    everything := func(Dinner) bool { return true }
+    nothing := func(Dinner) bool { return false }
+    meatless := func(dinner Dinner) bool { return !dinner.HasTag("meat") }
+    meaty := func(dinner Dinner) bool { return dinner.HasTag("meat") }
+
+    tests := []struct {
+        name      string
+        selected  []Dinner
+        fallbacks map[string][]string
+        rules     dayRules
+        want      string // the dinner picked, "" if none
+        note      string
+    }{
+        {"repeats a recent dinner that suits the day", nil, nil,
+            dayRules{permitted: everything, require: nothing, prefer: meaty},
+            "Ramen", "every soup dinner was eaten recently, repeating Ramen"},
+        {"repeat keeps the hard rules", nil, nil,
+            dayRules{permitted: meatless, require: nothing, prefer: everything},
+            "Tomato soup", "every soup dinner was eaten recently"},
+        {"then the fallback category", nil, map[string][]string{"soup": {"pasta"}},
+            dayRules{permitted: nothing, require: everything, prefer: everything},
+            "Pesto pasta", "none of the 2 soup dinners fit the day's dietary rules and equipment, using pasta"},
+        {"fallbacks keep the no-repeat rule", nil, map[string][]string{"soup": {"pasta"}},
+            dayRules{permitted: nothing, require: nothing, prefer: everything},
+            "", "none of the 2 soup dinners fit the day's dietary rules and equipment"},
+        {"all planned already", []Dinner{tomato, ramen}, nil,
+            dayRules{permitted: everything, require: everything, prefer: everything},
+            "", "all 2 soup dinners are already planned this week"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            state := &WeekState{CurrentWeek: test.selected}
+            opts := PlanOptions{
+                Fallbacks: test.fallbacks,
+                Schedule:  &ScheduleConfig{Days: map[string][]string{"Monday": {"soup"}}},
+                Rand:      newRand(1),
+            }
+            dinner, note, err := pickFallback(dinners, state, opts, "Monday", "soup", test.rules)
+            if test.want == "" {
+                var exhausted *ExhaustedError
+                if !errors.As(err, &exhausted) {
+                    t.Fatalf("got %q, %q, %v; want an *ExhaustedError", dinner.Name, note, err)
+                }
+                if exhausted.Size != 2 || exhausted.Category != "soup" || !strings.Contains(exhausted.Reason, test.note) {
+                    t.Errorf("got %+v, want %q", exhausted, test.note)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if dinner.Name != test.want || !strings.Contains(note, test.note) {
+                t.Errorf("got %q with note %q, want %q with %q", dinner.Name, note, test.want, test.note)
+            }
+        })
+    }
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// How much was left over, as feedback gives it
+const (
+    LeftoversNone   = "none"
+    LeftoversSome   = "some"
+    LeftoversPlenty = "plenty"
+)
+
+// Feedback is what the household says about a dinner once it's eaten. Every
+// field is optional, but something has to be given.
+type Feedback struct {
+    Rating    int    `json:"rating,omitempty"`
+    Leftovers string `json:"leftovers,omitempty"`
+    Comment   string `json:"comment,omitempty"`
+
+    // Veto all but rules the dinner out of future plans, as "preferences
+    // veto" does: it's picked a hundredth as often as otherwise
+    Veto bool `json:"veto,omitempty"`
+}
+
+// validate checks the rating and leftovers are ones there are, tidying them
+func (f *Feedback) validate() error {
+    f.Leftovers = strings.ToLower(strings.TrimSpace(f.Leftovers))
+    f.Comment = strings.TrimSpace(f.Comment)
+    if f.Rating < 0 || f.Rating > 5 {
+        return fmt.Errorf("rating must be from 1 to 5")
+    }
+    switch f.Leftovers {
+    case "", LeftoversNone, LeftoversSome, LeftoversPlenty:
+    default:
+        return fmt.Errorf("unknown leftovers %q (want none, some or plenty)", f.Leftovers)
+    }
+    if f.Rating == 0 && f.Leftovers == "" && f.Comment == "" && !f.Veto {
+        return fmt.Errorf("no feedback given: send a rating, leftovers, comment or veto")
+    }
+    return nil
+}
+
+// GiveFeedback records feedback on a day of the current week that has been
+// eaten. A day with no outcome yet is taken as cooked, and its ingredients
+// come out of the pantry. The week's reviewed days go into history straight
+// away, so the rating counts towards the learned taste; the open ones follow
+// when the week is over.
+func (s *WeekState) GiveFeedback(day string, feedback Feedback, pantry *Pantry, now time.Time) (PlanDay, error) {
+    if err := feedback.validate(); err != nil {
+        return PlanDay{}, err
+    }
+    if s.Plan == nil {
+        return PlanDay{}, fmt.Errorf("no dinners planned for this week yet")
+    }
+    var entry *PlanDay
+    for i := range s.Plan.Days {
+        if s.Plan.Days[i].Day == day {
+            entry = &s.Plan.Days[i]
+        }
+    }
+    if entry == nil {
+        return PlanDay{}, fmt.Errorf("nothing planned for %s", day)
+    }
+    if entry.Date.After(now) {
+        return PlanDay{}, fmt.Errorf("%s hasn't come yet", day)
+    }
+    if entry.Outcome == OutcomeSkipped {
+        return PlanDay{}, fmt.Errorf("%s was recorded as skipped, change that with \"review\" first", day)
+    }
+
+    if entry.Outcome == "" {
+        entry.Outcome = OutcomeCooked
+        if !entry.IsLeftovers() {
+            pantry.Consume(entry.Dinner)
+        }
+    }
+    if feedback.Rating > 0 {
+        entry.Rating = feedback.Rating
+    }
+    if feedback.Leftovers != "" {
+        entry.Leftovers = feedback.Leftovers
+    }
+    if feedback.Comment != "" {
+        entry.Comment = feedback.Comment
+    }
+    if feedback.Veto {
+        if s.Preferences == nil {
+            s.Preferences = &Preferences{}
+        }
+        if !s.Preferences.isVetoed(entry.Dinner.Name) {
+            s.Preferences.Vetoed = append(s.Preferences.Vetoed, entry.Dinner.Name)
+        }
+    }
+
+    s.Plan.Revision++
+    s.RecordWeek(reviewedSoFar(s.Plan, s.Note))
+    return *entry, nil
+}
+
+// summary describes feedback for the journal, e.g. "4/5, some left over"
+func (f Feedback) summary() string {
+    parts := HistoryDay{Rating: f.Rating, Leftovers: f.Leftovers, Comment: f.Comment}.describe()
+    if f.Veto {
+        if parts != "" {
+            parts += ", "
+        }
+        parts += "vetoed"
+    }
+    return parts
+}
@@ -1,3 +1,5 @@
 module dinner-picker
 
 go 1.24.4
+
+require github.com/mattn/go-sqlite3 v1.14.32
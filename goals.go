@@ -0,0 +1,294 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// Goal is a weekly target for dinners with a tag, protein or category, such
+// as at least 2 vegetarian or at most 1 takeout
+type Goal struct {
+    Name     string `json:"name,omitempty"`
+    Tag      string `json:"tag,omitempty"`
+    Protein  string `json:"protein,omitempty"`
+    Category string `json:"category,omitempty"`
+    Min      int    `json:"min,omitempty"`
+    Max      *int   `json:"max,omitempty"`
+}
+
+// validate checks the goal names exactly one thing to count and a target
+func (g Goal) validate() error {
+    set := 0
+    for _, field := range []string{g.Tag, g.Protein, g.Category} {
+        if field != "" {
+            set++
+        }
+    }
+    if set != 1 {
+        return fmt.Errorf("goal %q: set exactly one of tag, protein or category", g.label())
+    }
+    if g.Min <= 0 && g.Max == nil {
+        return fmt.Errorf("goal %q: set min and/or max", g.label())
+    }
+    if g.Max != nil && *g.Max < g.Min {
+        return fmt.Errorf("goal %q: max is below min", g.label())
+    }
+    return nil
+}
+
+// subject is what the goal counts, e.g. "vegetarian"
+func (g Goal) subject() string {
+    if g.Name != "" {
+        return g.Name
+    }
+    return g.Tag + g.Protein + g.Category
+}
+
+// label describes the goal, e.g. "2+ vegetarian" or "at most 1 takeout"
+func (g Goal) label() string {
+    switch {
+    case g.Max == nil:
+        return fmt.Sprintf("%d+ %s", g.Min, g.subject())
+    case g.Min == 0:
+        return fmt.Sprintf("at most %d %s", *g.Max, g.subject())
+    case g.Min == *g.Max:
+        return fmt.Sprintf("%d %s", g.Min, g.subject())
+    }
+    return fmt.Sprintf("%d-%d %s", g.Min, *g.Max, g.subject())
+}
+
+// matches reports whether a dinner counts towards the goal
+func (g Goal) matches(dinner Dinner) bool {
+    switch {
+    case g.Tag != "":
+        return dinner.HasTag(g.Tag)
+    case g.Protein != "":
+        return strings.EqualFold(dinner.MainProtein(), g.Protein)
+    }
+    return strings.EqualFold(dinner.Category, g.Category)
+}
+
+// field is the dinner field the goal counts by
+func (g Goal) field() string {
+    switch {
+    case g.Tag != "":
+        return "tags"
+    case g.Protein != "":
+        return "protein"
+    }
+    return "category"
+}
+
+// unknownIn lists the dinners that can't be told apart for the goal because
+// the field it counts by isn't filled in
+func (g Goal) unknownIn(dinners []Dinner) []string {
+    var names []string
+    for _, dinner := range dinners {
+        switch {
+        case g.Tag != "" && len(dinner.Tags) == 0, g.Protein != "" && dinner.MainProtein() == "":
+            names = append(names, dinner.Name)
+        }
+    }
+    return names
+}
+
+// matchesText reports whether a free-text substitute like "takeout pizza" counts
+func (g Goal) matchesText(text string) bool {
+    return strings.Contains(strings.ToLower(text), strings.ToLower(g.Tag+g.Protein+g.Category))
+}
+
+// met reports whether a week's count reaches the goal
+func (g Goal) met(count int) bool {
+    return count >= g.Min && (g.Max == nil || count <= *g.Max)
+}
+
+// count tallies the planned dinners that count towards the goal
+func (g Goal) count(plan *Plan) int {
+    count := 0
+    for _, dinner := range plan.Dinners() {
+        if g.matches(dinner) {
+            count++
+        }
+    }
+    return count
+}
+
+// ensureGoals swaps days within their categories until the plan meets each
+// goal, without breaking the others. Goals the catalog can't meet are noted.
+func ensureGoals(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    // keeps reports whether swapping current for candidate leaves every other goal met
+    keeps := func(skip int, current, candidate Dinner) bool {
+        for i, other := range opts.Goals {
+            if i == skip {
+                continue
+            }
+            count := other.count(plan)
+            switch {
+            case other.matches(current) && !other.matches(candidate) && count <= other.Min:
+                return false
+            case !other.matches(current) && other.matches(candidate) && other.Max != nil && count >= *other.Max:
+                return false
+            }
+        }
+        return keepsProtein(plan, opts, current, candidate)
+    }
+
+    for i, goal := range opts.Goals {
+        for goal.count(plan) < goal.Min {
+            if !replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+                return !goal.matches(current) && goal.matches(candidate) && keeps(i, current, candidate)
+            }) {
+                break
+            }
+        }
+        for goal.Max != nil && goal.count(plan) > *goal.Max {
+            if !replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+                return goal.matches(current) && !goal.matches(candidate) && keeps(i, current, candidate)
+            }) {
+                break
+            }
+        }
+        if count := goal.count(plan); !goal.met(count) {
+            note := fmt.Sprintf("Goal %s: %d planned", goal.label(), count)
+            if unknown := goal.unknownIn(plan.Dinners()); len(unknown) > 0 && count < goal.Min {
+                note += ", " + insufficientData(goal.field(), unknown)
+            }
+            plan.Notes = append(plan.Notes, note)
+        }
+    }
+}
+
+// printGoalSummary prints how the plan does against the weekly goals
+func printGoalSummary(plan *Plan, goals []Goal) {
+    if len(goals) == 0 {
+        return
+    }
+    var parts []string
+    for _, goal := range goals {
+        count := goal.count(plan)
+        mark := "ok"
+        if !goal.met(count) {
+            mark = "missed"
+            // Dinners it can't tell about might make up a shortfall, though not an excess
+            if unknown := goal.unknownIn(plan.Dinners()); len(unknown) > 0 && count < goal.Min {
+                mark = fmt.Sprintf("missed, but %s", insufficientData(goal.field(), unknown))
+            }
+        }
+        parts = append(parts, fmt.Sprintf("%s %d (%s)", goal.label(), count, mark))
+    }
+    fmt.Printf("Goals: %s\n", strings.Join(parts, ", "))
+}
+
+// eatenCount tallies what was actually eaten in a past week towards a goal:
+// skipped days don't count, and substitutes count by their own dinner or,
+// failing that, by their description
+func (g Goal) eatenCount(week HistoryWeek, dinners *DinnerData) int {
+    count := 0
+    for _, day := range week.Days {
+        name := day.Dinner
+        switch day.Outcome {
+        case OutcomeSkipped:
+            continue
+        case OutcomeSubstituted:
+            name = day.Substitute
+        }
+        dinner, ok := dinners.FindDinner(name)
+        switch {
+        case ok:
+            if g.matches(dinner) {
+                count++
+            }
+        case day.Outcome == OutcomeSubstituted:
+            if g.matchesText(name) {
+                count++
+            }
+        case g.Category != "" && strings.EqualFold(day.Category, g.Category):
+            // A dinner since removed from the catalog still has its category
+            count++
+        }
+    }
+    return count
+}
+
+// recentWeeks returns the finished weeks with a plan from the last weeks weeks
+func recentWeeks(state *WeekState, weeks int) []HistoryWeek {
+    since := state.WeekStart.AddDate(0, 0, -7*weeks)
+    var recent []HistoryWeek
+    for _, week := range state.History {
+        if !week.WeekStart.Before(since) && week.WeekStart.Before(state.WeekStart) && len(week.Days) > 0 {
+            recent = append(recent, week)
+        }
+    }
+    sort.Slice(recent, func(i, j int) bool {
+        return recent[i].WeekStart.Before(recent[j].WeekStart)
+    })
+    return recent
+}
+
+// runStatsCommand handles "stats goals|veggies|trends|badges [--weeks 13]": a
+// scorecard of how many recent weeks met each goal, the vegetables eaten
+// each week, trends worth a nudge, or the badges earned
+func runStatsCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker stats goals|veggies|trends|badges [--weeks 13]")
+    if len(args) == 0 || (args[0] != "goals" && args[0] != "veggies" && args[0] != "trends" && args[0] != "badges") {
+        return usage
+    }
+    fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+    weeks := fs.Int("weeks", 13, "how many past weeks to score")
+    if _, err := parseArgs(fs, args[1:]); err != nil {
+        return err
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    if args[0] == "goals" && len(config.Goals) == 0 {
+        return fmt.Errorf("no goals in %s yet", ConfigFileName)
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    if args[0] == "badges" {
+        printBadges(Badges(state, dinners))
+        return nil
+    }
+
+    recent := recentWeeks(state, *weeks)
+    if len(recent) == 0 {
+        fmt.Println("No finished weeks to score yet")
+        return nil
+    }
+    if args[0] == "veggies" {
+        printVeggieReport(recent, dinners, config.MinVeggieServings)
+        return nil
+    }
+    if args[0] == "trends" {
+        printTrendReport(recent, dinners, state.WeekStart)
+        return nil
+    }
+
+    fmt.Printf("Last %d weeks (since %s), oldest first:\n", len(recent), recent[0].WeekStart.Format("2006-01-02"))
+    for _, goal := range config.Goals {
+        met := 0
+        var marks strings.Builder
+        for _, week := range recent {
+            if goal.met(goal.eatenCount(week, dinners)) {
+                met++
+                marks.WriteString("+")
+            } else {
+                marks.WriteString(".")
+            }
+        }
+        fmt.Printf("  %-22s %2d/%-2d %s\n", goal.label(), met, len(recent), marks.String())
+    }
+    return nil
+}
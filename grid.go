@@ -0,0 +1,180 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+const (
+    defaultGridWidth = 80
+    gridLabelWidth   = 8
+    gridNameLines    = 2
+)
+
+// terminalWidth returns the width from $COLUMNS, or a standard 80 columns
+func terminalWidth() int {
+    if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+        return n
+    }
+    return defaultGridWidth
+}
+
+// runeWidth is how many terminal columns a rune takes: two for emoji and
+// wide East Asian characters, none for joiners and variation selectors
+func runeWidth(r rune) int {
+    switch {
+    case r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F):
+        return 0
+    case r >= 0x1F000 || (r >= 0x2600 && r <= 0x27BF) || (r >= 0x1100 && r <= 0x115F) ||
+        (r >= 0x2E80 && r <= 0xA4CF) || (r >= 0xAC00 && r <= 0xD7A3) || (r >= 0xF900 && r <= 0xFAFF) || (r >= 0xFF00 && r <= 0xFF60):
+        return 2
+    }
+    return 1
+}
+
+// displayWidth is how many terminal columns s takes
+func displayWidth(s string) int {
+    width := 0
+    for _, r := range s {
+        width += runeWidth(r)
+    }
+    return width
+}
+
+// truncate shortens s to at most width columns, marking the cut with an ellipsis
+func truncate(s string, width int) string {
+    if displayWidth(s) <= width {
+        return s
+    }
+    ellipsis := "…"
+    if width <= 1 {
+        ellipsis = ""
+    }
+    room := width - displayWidth(ellipsis)
+    used := 0
+    for i, r := range s {
+        if used+runeWidth(r) > room {
+            return s[:i] + ellipsis
+        }
+        used += runeWidth(r)
+    }
+    return s
+}
+
+// wrap splits s into at most maxLines lines of width runes, truncating the last line if needed
+func wrap(s string, width, maxLines int) []string {
+    var lines []string
+    line := ""
+    for _, word := range strings.Fields(s) {
+        if line == "" {
+            line = word
+            continue
+        }
+        if len([]rune(line))+1+len([]rune(word)) <= width {
+            line += " " + word
+            continue
+        }
+        lines = append(lines, line)
+        line = word
+    }
+    if line != "" {
+        lines = append(lines, line)
+    }
+
+    if len(lines) > maxLines {
+        lines[maxLines-1] = strings.Join(lines[maxLines-1:], " ")
+        lines = lines[:maxLines]
+    }
+    for i := range lines {
+        lines[i] = truncate(lines[i], width)
+    }
+    return lines
+}
+
+// pad right-pads s with spaces to width columns
+func pad(s string, width int) string {
+    if n := displayWidth(s); n < width {
+        return s + strings.Repeat(" ", width-n)
+    }
+    return s
+}
+
+// RenderGrid writes the planned days as columns with name, category and time rows
+func RenderGrid(w io.Writer, plan *Plan, width int, styles CategoryStyles) {
+    if plan.IsEmpty() {
+        return
+    }
+    var days []string
+    for _, entry := range plan.Days {
+        days = append(days, entry.Day)
+    }
+
+    // Each column costs its width plus " | ", the label column likewise
+    colWidth := (width - gridLabelWidth - 1) / len(days) - 3
+    if colWidth < 3 {
+        colWidth = 3
+    }
+
+    separator := strings.Repeat("-", gridLabelWidth+2)
+    for range days {
+        separator += "+" + strings.Repeat("-", colWidth+2)
+    }
+
+    row := func(label string, cells []string) {
+        line := " " + pad(label, gridLabelWidth) + " "
+        for _, cell := range cells {
+            line += "| " + pad(truncate(cell, colWidth), colWidth) + " "
+        }
+        fmt.Fprintln(w, strings.TrimRight(line, " "))
+    }
+
+    row("", days)
+    fmt.Fprintln(w, separator)
+
+    // Names wrap onto a second line before being truncated
+    wrapped := make([][]string, len(days))
+    for i, entry := range plan.Days {
+        wrapped[i] = wrap(entry.title(), colWidth, gridNameLines)
+    }
+    for line := 0; line < gridNameLines; line++ {
+        label := ""
+        if line == 0 {
+            label = "Dinner"
+        }
+        var cells []string
+        empty := true
+        for i := range days {
+            cell := ""
+            if line < len(wrapped[i]) {
+                cell = wrapped[i][line]
+                empty = false
+            }
+            cells = append(cells, cell)
+        }
+        if empty && line > 0 {
+            break
+        }
+        row(label, cells)
+    }
+
+    var categories, times []string
+    for _, entry := range plan.Days {
+        dinner := entry.Dinner
+        categories = append(categories, styles.Label(dinner.Category, dinner.Category))
+        switch {
+        case entry.IsLeftovers():
+            times = append(times, "reheat")
+        case dinner.NoCook:
+            times = append(times, "no cook")
+        case dinner.CookTime > 0:
+            times = append(times, fmt.Sprintf("%d min", dinner.CookTime))
+        default:
+            times = append(times, "-")
+        }
+    }
+    row("Category", categories)
+    row("Time", times)
+}
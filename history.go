@@ -0,0 +1,261 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Day outcomes recorded after the fact
+const (
+    OutcomeCooked      = "cooked"
+    OutcomeSkipped     = "skipped"
+    OutcomeSubstituted = "substituted"
+)
+
+// HistoryWeek is a past week's plan with what actually happened
+type HistoryWeek struct {
+    WeekStart time.Time    `json:"week_start"`
+    Note      string       `json:"note,omitempty"`
+    Pattern   string       `json:"pattern,omitempty"`
+    Days      []HistoryDay `json:"days"`
+
+    // Seed is the seed the week was planned with
+    Seed int64 `json:"seed,omitempty"`
+}
+
+// HistoryDay records one planned evening
+type HistoryDay struct {
+    Day        string    `json:"day"`
+    Date       time.Time `json:"date"`
+    Dinner     string    `json:"dinner"`
+    Category   string    `json:"category"`
+    Outcome    string    `json:"outcome,omitempty"`
+    Substitute string    `json:"substitute,omitempty"`
+    Rating     int       `json:"rating,omitempty"`
+    Leftovers  string    `json:"leftovers,omitempty"`
+    Comment    string    `json:"comment,omitempty"`
+}
+
+// historyFromPlan snapshots a plan and its outcomes
+func historyFromPlan(plan *Plan, note string) HistoryWeek {
+    week := HistoryWeek{WeekStart: plan.WeekStart, Note: note, Pattern: plan.Pattern, Seed: plan.Seed}
+    for _, entry := range plan.Days {
+        week.Days = append(week.Days, HistoryDay{
+            Day:        entry.Day,
+            Date:       entry.Date,
+            Dinner:     entry.Dinner.Name,
+            Category:   entry.Dinner.Category,
+            Outcome:    entry.Outcome,
+            Substitute: entry.Substitute,
+            Rating:     entry.Rating,
+            Leftovers:  entry.Leftovers,
+            Comment:    entry.Comment,
+        })
+    }
+    return week
+}
+
+// reviewedSoFar snapshots the days of a week still under way that have an
+// outcome; the others go into history when the week is over
+func reviewedSoFar(plan *Plan, note string) HistoryWeek {
+    week := historyFromPlan(plan, note)
+    var days []HistoryDay
+    for _, day := range week.Days {
+        if day.Outcome != "" {
+            days = append(days, day)
+        }
+    }
+    week.Days = days
+    return week
+}
+
+// RecordWeek stores the week in history, replacing an earlier record of the same week
+func (s *WeekState) RecordWeek(week HistoryWeek) {
+    for i := range s.History {
+        if s.History[i].WeekStart.Equal(week.WeekStart) {
+            s.History[i] = week
+            return
+        }
+    }
+    s.History = append(s.History, week)
+}
+
+// migratePreviousWeek records the previous week of older state files, which
+// kept only its dinners, in the history
+func (s *WeekState) migratePreviousWeek() {
+    s.recordLegacyWeek(s.WeekStart.AddDate(0, 0, -7), s.PreviousWeek)
+}
+
+// migrateCurrentWeek records the week of older state files that kept its
+// dinners without a plan in the history at the week it was, once that week
+// is over, and clears it, so the rollover doesn't take it for the week just
+// gone
+func (s *WeekState) migrateCurrentWeek() {
+    if len(s.CurrentWeek) == 0 || !s.Plan.IsEmpty() || !s.WeekStart.Before(GetCurrentWeekStart()) {
+        return
+    }
+    s.recordLegacyWeek(s.WeekStart, s.CurrentWeek)
+    s.CurrentWeek = []Dinner{}
+}
+
+// recordLegacyWeek records a week of an older state file, unless history
+// already has it. Its days weren't stored, so they're taken to be the
+// default plan days in the order the dinners were picked.
+func (s *WeekState) recordLegacyWeek(start time.Time, dinners []Dinner) {
+    if len(dinners) == 0 {
+        return
+    }
+    for _, week := range s.History {
+        if week.WeekStart.Equal(start) {
+            return
+        }
+    }
+    week := HistoryWeek{WeekStart: start}
+    for i, dinner := range dinners {
+        day := "Saturday"
+        if i < len(defaultPlanDays) {
+            day = defaultPlanDays[i]
+        }
+        week.Days = append(week.Days, HistoryDay{
+            Day:      day,
+            Date:     start.AddDate(0, 0, dayIndex(day)),
+            Dinner:   dinner.Name,
+            Category: dinner.Category,
+        })
+    }
+    s.RecordWeek(week)
+}
+
+// defaultRepeatDays is how long a dinner rests after it was eaten unless config says otherwise
+const defaultRepeatDays = 10
+
+// LastEaten returns the latest date a dinner was eaten: marked cooked on or
+// before today, or planned in a week that is over and had no outcome marked.
+// Days still to come, and open days of this week, don't count.
+func (s *WeekState) LastEaten(name string) (time.Time, bool) {
+    now := time.Now()
+    var last time.Time
+    found := false
+    for _, week := range s.History {
+        over := !week.WeekStart.AddDate(0, 0, 7).After(now)
+        for _, day := range week.Days {
+            if day.Dinner != name || day.Date.After(now) {
+                continue
+            }
+            if day.Outcome != OutcomeCooked && (day.Outcome != "" || !over) {
+                continue
+            }
+            if !found || day.Date.After(last) {
+                last, found = day.Date, true
+            }
+        }
+    }
+    return last, found
+}
+
+// TooRecent reports whether a dinner was eaten fewer than days days before
+// date; with days 0 the rule is off. Dinners tagged always-ok never are.
+func (s *WeekState) TooRecent(candidate Dinner, date time.Time, days int) bool {
+    if candidate.HasTag(AlwaysOKTag) || days <= 0 {
+        return false
+    }
+    last, ok := s.LastEaten(candidate.Name)
+    return ok && date.Before(last.AddDate(0, 0, days))
+}
+
+// describe formats what happened on a day, e.g. "skipped" or "had takeout instead, 4/5"
+func (d HistoryDay) describe() string {
+    var parts []string
+    switch d.Outcome {
+    case OutcomeSubstituted:
+        parts = append(parts, "had "+d.Substitute+" instead")
+    case "":
+    default:
+        parts = append(parts, d.Outcome)
+    }
+    if d.Rating > 0 {
+        parts = append(parts, fmt.Sprintf("%d/5", d.Rating))
+    }
+    if d.Leftovers != "" {
+        parts = append(parts, d.Leftovers+" left over")
+    }
+    if d.Comment != "" {
+        parts = append(parts, fmt.Sprintf("%q", d.Comment))
+    }
+    return strings.Join(parts, ", ")
+}
+
+// runHistoryCommand handles "history [--weeks 4]", printing past weeks newest
+// first, as well as "history compact" and "history months"
+func runHistoryCommand(args []string) error {
+    if len(args) > 0 && args[0] == "compact" {
+        return runHistoryCompactCommand(args[1:])
+    }
+    if len(args) > 0 && args[0] == "months" {
+        state, err := LoadState()
+        if err != nil {
+            return err
+        }
+        printMonthlyHistory(state)
+        return nil
+    }
+    fs := flag.NewFlagSet("history", flag.ContinueOnError)
+    weeks := fs.Int("weeks", 4, "how many past weeks to show")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    var past []HistoryWeek
+    for _, week := range state.History {
+        if week.WeekStart.Before(state.WeekStart) {
+            past = append(past, week)
+        }
+    }
+    if len(past) == 0 {
+        fmt.Println("No past weeks yet")
+        if len(state.MonthlyHistory) > 0 {
+            fmt.Println("Earlier months are summarised, see history months")
+        }
+        return nil
+    }
+    sort.Slice(past, func(i, j int) bool {
+        return past[i].WeekStart.After(past[j].WeekStart)
+    })
+    if *weeks > 0 && len(past) > *weeks {
+        past = past[:*weeks]
+    }
+
+    for i, week := range past {
+        if i > 0 {
+            fmt.Println()
+        }
+        title := "Week of " + week.WeekStart.Format("January 2, 2006")
+        if week.Pattern != "" {
+            title += fmt.Sprintf(" (%s)", week.Pattern)
+        }
+        fmt.Println(title)
+        if week.Note != "" {
+            fmt.Printf("  Note: %s\n", week.Note)
+        }
+        if len(week.Days) == 0 {
+            fmt.Println("  Nothing planned")
+        }
+        for _, day := range week.Days {
+            line := fmt.Sprintf("  %-9s %s", day.Day, day.Dinner)
+            if what := day.describe(); what != "" {
+                line += " (" + what + ")"
+            }
+            fmt.Println(line)
+        }
+    }
+    return nil
+}
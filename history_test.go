@@ -0,0 +1,116 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+// Only dinners cooked by today, or planned in a week that's over and was
+// never reviewed, count as eaten for the no-repeat rule
+func TestLastEaten(t *testing.T) {
+    now := time.Now()
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+    thisWeek := GetCurrentWeekStart()
+    lastWeek := thisWeek.AddDate(0, 0, -7)
+
+    tests := []struct {
+        name      string
+        weekStart time.Time
+        date      time.Time
+        outcome   string
+        eaten     bool
+    }{
+        {"planned tomorrow", thisWeek, today.AddDate(0, 0, 1), "", false},
+        {"planned today, not marked yet", thisWeek, today, "", false},
+        {"cooked today", thisWeek, today, OutcomeCooked, true},
+        {"marked cooked ahead of time", thisWeek, today.AddDate(0, 0, 1), OutcomeCooked, false},
+        {"skipped", thisWeek, today, OutcomeSkipped, false},
+        {"last week, never reviewed", lastWeek, lastWeek.AddDate(0, 0, 2), "", true},
+        {"last week, substituted", lastWeek, lastWeek.AddDate(0, 0, 2), OutcomeSubstituted, false},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            state := &WeekState{WeekStart: thisWeek, History: []HistoryWeek{{
+                WeekStart: test.weekStart,
+                Days:      []HistoryDay{{Day: test.date.Weekday().String(), Date: test.date, Dinner: "Tacos", Category: "mexican", Outcome: test.outcome}},
+            }}}
+            last, eaten := state.LastEaten("Tacos")
+            if eaten != test.eaten {
+                t.Fatalf("eaten = %v (%s), want %v", eaten, last.Format("January 2"), test.eaten)
+            }
+            if eaten && !last.Equal(test.date) {
+                t.Errorf("last eaten %s, want %s", last.Format("January 2"), test.date.Format("January 2"))
+            }
+        })
+    }
+}
+
+// A dinner planned later this week isn't resting from a meal nobody has had,
+// so planning the week again can still pick it
+func TestPlannedButNotEatenIsNotTooRecent(t *testing.T) {
+    thisWeek := GetCurrentWeekStart()
+    tacos := Dinner{Name: "Tacos", Category: "mexican"}
+    plan := &Plan{WeekStart: thisWeek, Days: []PlanDay{{Day: "Saturday", Date: thisWeek.AddDate(0, 0, 6), Dinner: tacos}}}
+    state := &WeekState{WeekStart: thisWeek}
+    state.RecordWeek(historyFromPlan(plan, ""))
+
+    if last, ok := state.LastEaten(tacos.Name); ok {
+        t.Fatalf("Tacos planned for Saturday counted as eaten on %s", last.Format("January 2"))
+    }
+    if state.TooRecent(tacos, thisWeek.AddDate(0, 0, 7), 10) {
+        t.Error("Tacos kept off next week by a dinner that hasn't been eaten")
+    }
+}
+
+// Older state files kept only this and last week's dinners; loading them
+// moves those weeks into history at the weeks they were
+func TestMigrateLegacyWeeks(t *testing.T) {
+    thisWeek := GetCurrentWeekStart()
+    stew := Dinner{Name: "Stew", Category: "soup"}
+    pasta := Dinner{Name: "Pasta", Category: "pasta"}
+
+    tests := []struct {
+        name      string
+        state     WeekState
+        weeks     []time.Time
+        firstDay  string
+        keepsWeek bool
+    }{
+        {"previous week", WeekState{WeekStart: thisWeek, PreviousWeek: []Dinner{stew, pasta}}, []time.Time{thisWeek.AddDate(0, 0, -7)}, "Sunday", false},
+        {"current week still on", WeekState{WeekStart: thisWeek, CurrentWeek: []Dinner{stew}}, nil, "", true},
+        {"current week over", WeekState{WeekStart: thisWeek.AddDate(0, 0, -14), CurrentWeek: []Dinner{stew}}, []time.Time{thisWeek.AddDate(0, 0, -14)}, "Sunday", false},
+        {"both over", WeekState{WeekStart: thisWeek.AddDate(0, 0, -7), PreviousWeek: []Dinner{pasta}, CurrentWeek: []Dinner{stew}},
+            []time.Time{thisWeek.AddDate(0, 0, -14), thisWeek.AddDate(0, 0, -7)}, "Sunday", false},
+        {"already in history", WeekState{WeekStart: thisWeek, PreviousWeek: []Dinner{stew},
+            History: []HistoryWeek{{WeekStart: thisWeek.AddDate(0, 0, -7), Days: []HistoryDay{{Day: "Friday", Dinner: "Pasta"}}}}},
+            []time.Time{thisWeek.AddDate(0, 0, -7)}, "Friday", false},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            state := test.state
+            state.migratePreviousWeek()
+            state.migrateCurrentWeek()
+
+            var weeks []time.Time
+            for _, week := range state.History {
+                weeks = append(weeks, week.WeekStart)
+            }
+            if len(weeks) != len(test.weeks) {
+                t.Fatalf("history has weeks %v, want %v", weeks, test.weeks)
+            }
+            for i := range weeks {
+                if !weeks[i].Equal(test.weeks[i]) {
+                    t.Fatalf("history has weeks %v, want %v", weeks, test.weeks)
+                }
+            }
+            if len(state.History) > 0 {
+                if first := state.History[0].Days[0]; first.Day != test.firstDay {
+                    t.Errorf("first day recorded as %s, want %s", first.Day, test.firstDay)
+                }
+            }
+            if kept := len(state.CurrentWeek) > 0; kept != test.keepsWeek {
+                t.Errorf("current week kept = %v, want %v", kept, test.keepsWeek)
+            }
+        })
+    }
+}
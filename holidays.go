@@ -0,0 +1,203 @@
+package main
+
+import (
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// DayProject marks a day with time for a long, involved dinner
+const DayProject DayMode = "project"
+
+// ProjectTag marks dinners worth saving for a day off, whatever their cook time
+const ProjectTag = "project"
+
+// IsProject reports whether a dinner suits a day off: tagged project or slow to make
+func (d Dinner) IsProject(minMinutes int) bool {
+    return !d.NoCook && (d.HasTag(ProjectTag) || d.CookTime >= minMinutes)
+}
+
+// Holiday is a public holiday on a date
+type Holiday struct {
+    Date string `json:"date"`
+    Name string `json:"name"`
+}
+
+// HolidayProvider supplies a region's public holidays for a year
+type HolidayProvider interface {
+    Name() string
+    Holidays(region string, year int) ([]Holiday, error)
+}
+
+// HolidayConfig picks the region whose holidays the planner works around
+type HolidayConfig struct {
+    Region string `json:"region"`
+
+    // LongWeekend is what happens to a holiday on a Monday or Friday: "project"
+    // (the default) plans a slow dinner, "skip" leaves the day out and "none"
+    // only notes it
+    LongWeekend string `json:"long_weekend,omitempty"`
+
+    // ProjectMinutes is the cook time that makes a dinner a project (default 90)
+    ProjectMinutes int `json:"project_minutes,omitempty"`
+
+    // API looks up regions and years the built-in list doesn't cover; APIURL
+    // defaults to the Nager.Date public holiday API
+    API    bool   `json:"api,omitempty"`
+    APIURL string `json:"api_url,omitempty"`
+}
+
+// validate checks the region and long weekend setting
+func (h *HolidayConfig) validate() error {
+    if h.Region == "" {
+        return fmt.Errorf("holidays: region is required, e.g. \"NL\"")
+    }
+    switch h.LongWeekend {
+    case "", "project", "skip", "none":
+    default:
+        return fmt.Errorf("holidays: unknown long_weekend %q (want project, skip or none)", h.LongWeekend)
+    }
+    return nil
+}
+
+// projectMinutes returns the cook time that makes a dinner a project
+func (h *HolidayConfig) projectMinutes() int {
+    if h == nil || h.ProjectMinutes == 0 {
+        return 90
+    }
+    return h.ProjectMinutes
+}
+
+// HolidayProviders returns the providers to ask, built-in list first
+func (h *HolidayConfig) HolidayProviders() []HolidayProvider {
+    providers := []HolidayProvider{embeddedHolidays{}}
+    if h.API {
+        url := h.APIURL
+        if url == "" {
+            url = "https://date.nager.at/api/v3/PublicHolidays"
+        }
+        providers = append(providers, holidayAPI{URL: url})
+    }
+    return providers
+}
+
+//go:embed holidays.json
+var holidayData []byte
+
+// embeddedHolidays is the built-in list of national holidays
+type embeddedHolidays struct{}
+
+// Name identifies the provider in warnings
+func (embeddedHolidays) Name() string {
+    return "built-in holidays"
+}
+
+// Holidays returns the region's holidays in a year, or none if the list doesn't cover it
+func (embeddedHolidays) Holidays(region string, year int) ([]Holiday, error) {
+    var regions map[string][]Holiday
+    if err := json.Unmarshal(holidayData, &regions); err != nil {
+        return nil, fmt.Errorf("error parsing built-in holidays: %w", err)
+    }
+    var holidays []Holiday
+    for _, holiday := range regions[strings.ToUpper(region)] {
+        if strings.HasPrefix(holiday.Date, fmt.Sprintf("%d-", year)) {
+            holidays = append(holidays, holiday)
+        }
+    }
+    return holidays, nil
+}
+
+// holidayAPI looks holidays up from a Nager.Date compatible API
+type holidayAPI struct {
+    URL string
+}
+
+// Name identifies the provider in warnings
+func (holidayAPI) Name() string {
+    return "holiday API"
+}
+
+// Holidays fetches <URL>/<year>/<region>
+func (a holidayAPI) Holidays(region string, year int) ([]Holiday, error) {
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Get(fmt.Sprintf("%s/%d/%s", strings.TrimRight(a.URL, "/"), year, strings.ToUpper(region)))
+    if err != nil {
+        return nil, fmt.Errorf("error fetching holidays: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("error fetching holidays: %s", resp.Status)
+    }
+
+    var entries []struct {
+        Date string `json:"date"`
+        Name string `json:"name"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+        return nil, fmt.Errorf("error parsing holidays: %w", err)
+    }
+    var holidays []Holiday
+    for _, entry := range entries {
+        holidays = append(holidays, Holiday{Date: entry.Date, Name: entry.Name})
+    }
+    return holidays, nil
+}
+
+// HolidaysIn returns the holidays in the week starting weekStart by weekday,
+// asking each provider in turn until one knows the region's year
+func (h *HolidayConfig) HolidaysIn(weekStart time.Time) map[string]Holiday {
+    found := make(map[string]Holiday)
+    years := map[int]bool{weekStart.Year(): true, weekStart.AddDate(0, 0, 6).Year(): true}
+    for year := range years {
+        for _, provider := range h.HolidayProviders() {
+            holidays, err := provider.Holidays(h.Region, year)
+            if err != nil {
+                warnf("ignoring %s: %v", provider.Name(), err)
+                continue
+            }
+            if len(holidays) == 0 {
+                continue
+            }
+            for i := 0; i < 7; i++ {
+                date := weekStart.AddDate(0, 0, i)
+                for _, holiday := range holidays {
+                    if holiday.Date == date.Format("2006-01-02") {
+                        found[date.Weekday().String()] = holiday
+                    }
+                }
+            }
+            break
+        }
+    }
+    return found
+}
+
+// DayModes plans around the week's holidays: a Monday or Friday off makes a
+// long weekend, which gets a project dinner or a skip unless the calendar
+// already made the day quick or skipped it. Holidays are returned as notes for
+// the top of the plan.
+func (h *HolidayConfig) DayModes(holidays map[string]Holiday, modes map[string]DayMode) []string {
+    var notes []string
+    for _, day := range weekDays {
+        holiday, ok := holidays[day]
+        if !ok {
+            continue
+        }
+        if (day != "Monday" && day != "Friday") || h.LongWeekend == "none" {
+            notes = append(notes, fmt.Sprintf("%s: %s (holiday)", day, holiday.Name))
+            continue
+        }
+        switch {
+        case modes[day] != DayNormal:
+        case h.LongWeekend == "skip":
+            modes[day] = DaySkip
+        default:
+            modes[day] = DayProject
+        }
+        notes = append(notes, fmt.Sprintf("%s: %s, long weekend (%s)", day, holiday.Name, modes[day]))
+    }
+    return notes
+}
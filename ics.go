@@ -0,0 +1,200 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// dinnerEventMinutes is how long a dinner's calendar event lasts
+const dinnerEventMinutes = 60
+
+// ICSOptions says how a plan is written as a calendar
+type ICSOptions struct {
+    Styles CategoryStyles
+    Config *Config
+
+    // At puts every dinner at this time ("18:30") instead of the configured
+    // dinner_hour and reminders.eat_at
+    At string
+}
+
+// eatAt returns when a day's dinner is eaten
+func (o ICSOptions) eatAt(entry PlanDay) time.Time {
+    _, eat, _ := o.Config.Reminder(entry.Date, entry.Dinner)
+    if o.At != "" {
+        midnight := time.Date(entry.Date.Year(), entry.Date.Month(), entry.Date.Day(), 0, 0, 0, 0, entry.Date.Location())
+        eat = midnight.Add(time.Duration(parseClock(o.At, 19*60)) * time.Minute)
+    }
+    return eat
+}
+
+// icsEscape escapes text for an ICS property value
+func icsEscape(text string) string {
+    return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// icsFold splits a content line into 75-octet pieces, continuation lines
+// starting with a space, without breaking a UTF-8 character
+func icsFold(line string) []string {
+    var lines []string
+    for len(line) > 75 {
+        cut := 75
+        for cut > 0 && !utf8.RuneStart(line[cut]) {
+            cut--
+        }
+        lines = append(lines, line[:cut])
+        line = " " + line[cut:]
+    }
+    return append(lines, line)
+}
+
+// WritePlanICS writes the plan as an iCalendar file with an event per dinner
+// at dinner time, carrying its category's icon and colour and the
+// ingredients. Each event's UID is made from the week and the day, so
+// importing the week again updates the events instead of adding more.
+func WritePlanICS(w io.Writer, plan *Plan, opts ICSOptions) error {
+    lines := []string{
+        "BEGIN:VCALENDAR",
+        "VERSION:2.0",
+        "PRODID:-//dinner-picker//EN",
+        "CALSCALE:GREGORIAN",
+    }
+    stamp := time.Now().UTC().Format("20060102T150405Z")
+    for _, entry := range plan.Days {
+        category := entry.Dinner.Category
+        start := opts.eatAt(entry)
+        lines = append(lines,
+            "BEGIN:VEVENT",
+            fmt.Sprintf("UID:%s-%s@dinner-picker", plan.WeekStart.Format("20060102"), strings.ToLower(entry.Day)),
+            "DTSTAMP:"+stamp,
+            fmt.Sprintf("SEQUENCE:%d", plan.Revision),
+            "DTSTART:"+start.Format("20060102T150405"),
+            "DTEND:"+start.Add(dinnerEventMinutes*time.Minute).Format("20060102T150405"),
+            "SUMMARY:"+icsEscape(opts.Styles.Label(category, "Dinner: "+entry.title())),
+        )
+        if ingredients := IngredientLines(entry.Dinner.Ingredients); len(ingredients) > 0 {
+            lines = append(lines, "DESCRIPTION:"+icsEscape("Ingredients:\n- "+strings.Join(ingredients, "\n- ")))
+        }
+        if category != "" {
+            lines = append(lines, "CATEGORIES:"+icsEscape(category))
+        }
+        if color := opts.Styles.Style(category).Color; color != "" {
+            lines = append(lines, "COLOR:"+color)
+        }
+        lines = append(lines, "END:VEVENT")
+    }
+    lines = append(lines, "END:VCALENDAR")
+
+    var folded []string
+    for _, line := range lines {
+        folded = append(folded, icsFold(line)...)
+    }
+    _, err := io.WriteString(w, strings.Join(folded, "\r\n")+"\r\n")
+    return err
+}
+
+// planFromHistory turns a past week back into a plan of what was eaten,
+// leaving out skipped days
+func planFromHistory(week HistoryWeek, dinners *DinnerData) *Plan {
+    plan := &Plan{WeekStart: week.WeekStart}
+    for _, day := range week.Days {
+        name := day.Dinner
+        switch day.Outcome {
+        case OutcomeSkipped:
+            continue
+        case OutcomeSubstituted:
+            name = day.Substitute
+        }
+        dinner, ok := dinners.FindDinner(name)
+        if !ok {
+            dinner = Dinner{Name: name, Category: day.Category}
+        }
+        plan.Days = append(plan.Days, PlanDay{Day: day.Day, Date: day.Date, Dinner: dinner})
+    }
+    return plan
+}
+
+// selectWeek returns this week's plan, or the past week containing the
+// given date ("2026-10-04", or "last" for last week)
+func selectWeek(state *WeekState, week string) (*Plan, error) {
+    if week == "" || week == "this" {
+        if state.Plan.IsEmpty() {
+            return nil, fmt.Errorf("no dinners planned for this week yet")
+        }
+        return state.Plan, nil
+    }
+    start := state.WeekStart.AddDate(0, 0, -7)
+    if week != "last" {
+        date, err := time.ParseInLocation("2006-01-02", week, time.Local)
+        if err != nil {
+            return nil, fmt.Errorf("invalid week %q, want this, last or a date like 2026-10-04", week)
+        }
+        start = date.AddDate(0, 0, -int(date.Weekday()))
+        if !start.Before(state.WeekStart) {
+            return selectWeek(state, "")
+        }
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil, err
+    }
+    for _, past := range state.History {
+        if past.WeekStart.Format("2006-01-02") == start.Format("2006-01-02") {
+            return planFromHistory(past, dinners), nil
+        }
+    }
+    return nil, fmt.Errorf("no history for the week of %s", start.Format("January 2, 2006"))
+}
+
+// exportICS handles "export ics [file] [--week this|last|date] [--at 18:30]"
+func exportICS(args []string) error {
+    fs := flag.NewFlagSet("export ics", flag.ContinueOnError)
+    week := fs.String("week", "", "week to export: this, last or a date in it like 2026-10-04")
+    at := fs.String("at", "", "put every dinner at this time instead of dinner_hour")
+    rest, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(rest) > 1 {
+        return fmt.Errorf("usage: dinner-picker export ics [file] [--week this|last|2026-10-04] [--at 18:30]")
+    }
+    if *at != "" {
+        if _, err := time.Parse("15:04", *at); err != nil {
+            return fmt.Errorf("invalid --at %q, want a time like 18:30", *at)
+        }
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    plan, err := selectWeek(state, *week)
+    if err != nil {
+        return err
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    opts := ICSOptions{Styles: loadStyles(), Config: config, At: *at}
+
+    if len(rest) == 0 {
+        return WritePlanICS(os.Stdout, plan, opts)
+    }
+    file, err := os.Create(rest[0])
+    if err != nil {
+        return fmt.Errorf("error writing calendar: %w", err)
+    }
+    defer file.Close()
+    if err := WritePlanICS(file, plan, opts); err != nil {
+        return fmt.Errorf("error writing calendar: %w", err)
+    }
+    logf("Exported %d dinners to %s\n", len(plan.Days), rest[0])
+    return nil
+}
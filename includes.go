@@ -0,0 +1,197 @@
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// includedParts is what a catalog took from its included files: dinners by
+// lowercased name, category styles and renames, and the catalog's own
+// dinners that included ones replaced, by category
+type includedParts struct {
+    files    []string
+    dinners  map[string]bool
+    styles   map[string]bool
+    renames  map[string]bool
+    tags     map[string]bool
+    shadowed map[string][]Dinner
+}
+
+// mergeIncludes merges the files listed under "include" over the catalog, in
+// order, each pattern's matches in name order. Paths are relative to the
+// catalog's directory. A dinner in a later file replaces the one with the
+// same name, wherever it was; category styles, renames and ingredient tags
+// only fill gaps.
+func (d *DinnerData) mergeIncludes(filename string) error {
+    if len(d.Include) == 0 {
+        return nil
+    }
+    d.included = &includedParts{
+        dinners:  make(map[string]bool),
+        styles:   make(map[string]bool),
+        renames:  make(map[string]bool),
+        tags:     make(map[string]bool),
+        shadowed: make(map[string][]Dinner),
+    }
+    if d.Dinners == nil {
+        d.Dinners = make(map[string][]Dinner)
+    }
+    dir := filepath.Dir(filename)
+    seen := map[string]bool{filepath.Clean(filename): true}
+    for _, pattern := range d.Include {
+        path := pattern
+        if !filepath.IsAbs(path) {
+            path = filepath.Join(dir, path)
+        }
+        matches, err := filepath.Glob(path)
+        if err != nil {
+            return fmt.Errorf("invalid include %q: %w", pattern, err)
+        }
+        if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+            return fmt.Errorf("included dinners file %s not found", path)
+        }
+        for _, match := range matches {
+            if seen[filepath.Clean(match)] {
+                continue
+            }
+            seen[filepath.Clean(match)] = true
+            if ext := strings.ToLower(filepath.Ext(match)); ext == ".yaml" || ext == ".yml" {
+                return fmt.Errorf("included file %s: only JSON dinners files are supported", match)
+            }
+            other, err := loadDinnersFile(match)
+            if err != nil {
+                return err
+            }
+            if len(other.Include) > 0 {
+                return fmt.Errorf("included file %s has includes of its own, list them all in %s", match, filepath.Base(filename))
+            }
+            d.merge(other)
+            d.included.files = append(d.included.files, match)
+        }
+    }
+    return nil
+}
+
+// Files returns the catalog's file and every file merged into it
+func (d *DinnerData) Files(filename string) []string {
+    files := []string{filename}
+    if d.included != nil {
+        files = append(files, d.included.files...)
+    }
+    return files
+}
+
+// merge adds another file's dinners, replacing any with the same name
+func (d *DinnerData) merge(other *DinnerData) {
+    var categories []string
+    for category := range other.Dinners {
+        categories = append(categories, category)
+    }
+    sort.Strings(categories)
+    for _, category := range categories {
+        for _, dinner := range other.Dinners[category] {
+            d.remove(dinner.Name)
+            d.Dinners[category] = append(d.Dinners[category], dinner)
+            d.included.dinners[strings.ToLower(dinner.Name)] = true
+        }
+    }
+    for name, style := range other.Categories {
+        if _, ok := d.Categories[name]; !ok {
+            if d.Categories == nil {
+                d.Categories = make(map[string]CategoryStyle)
+            }
+            d.Categories[name] = style
+            d.included.styles[name] = true
+        }
+    }
+    for old, name := range other.Renamed {
+        if _, ok := d.Renamed[old]; !ok {
+            if d.Renamed == nil {
+                d.Renamed = make(map[string]string)
+            }
+            d.Renamed[old] = name
+            d.included.renames[old] = true
+        }
+    }
+    for ingredient, tags := range other.IngredientTags {
+        if _, ok := d.IngredientTags[ingredient]; !ok {
+            if d.IngredientTags == nil {
+                d.IngredientTags = make(map[string][]string)
+            }
+            d.IngredientTags[ingredient] = tags
+            d.included.tags[ingredient] = true
+        }
+    }
+}
+
+// remove takes a dinner out of the catalog by name, keeping it aside if it's
+// the catalog's own, and dropping a category it leaves empty
+func (d *DinnerData) remove(name string) {
+    for category, list := range d.Dinners {
+        for i, dinner := range list {
+            if !strings.EqualFold(dinner.Name, name) {
+                continue
+            }
+            if !d.included.dinners[strings.ToLower(dinner.Name)] {
+                d.included.shadowed[category] = append(d.included.shadowed[category], dinner)
+            }
+            list = append(list[:i:i], list[i+1:]...)
+            if len(list) == 0 {
+                delete(d.Dinners, category)
+            } else {
+                d.Dinners[category] = list
+            }
+            return
+        }
+    }
+}
+
+// own returns the catalog as its own file has it, without anything merged in
+// from included files, for saving
+func (d *DinnerData) own() *DinnerData {
+    if d.included == nil {
+        return d
+    }
+    own := &DinnerData{Dinners: make(map[string][]Dinner), Include: d.Include, order: d.order}
+    for category, list := range d.Dinners {
+        var kept []Dinner
+        for _, dinner := range list {
+            if !d.included.dinners[strings.ToLower(dinner.Name)] {
+                kept = append(kept, dinner)
+            }
+        }
+        if len(kept) > 0 || len(list) == 0 {
+            own.Dinners[category] = append([]Dinner{}, kept...)
+        }
+    }
+    for category, list := range d.included.shadowed {
+        own.Dinners[category] = append(own.Dinners[category], list...)
+    }
+    for name, style := range d.Categories {
+        if !d.included.styles[name] {
+            if own.Categories == nil {
+                own.Categories = make(map[string]CategoryStyle)
+            }
+            own.Categories[name] = style
+        }
+    }
+    for old, name := range d.Renamed {
+        if !d.included.renames[old] {
+            if own.Renamed == nil {
+                own.Renamed = make(map[string]string)
+            }
+            own.Renamed[old] = name
+        }
+    }
+    for ingredient, tags := range d.IngredientTags {
+        if !d.included.tags[ingredient] {
+            if own.IngredientTags == nil {
+                own.IngredientTags = make(map[string][]string)
+            }
+            own.IngredientTags[ingredient] = tags
+        }
+    }
+    return own
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Ingredient is one ingredient of a dinner. In dinners.json it's either a
+// line of text ("200 g flour", parsed on load) or an object
+// {"name": "flour", "quantity": 200, "unit": "g"}; it's saved the way it was
+// written. Quantity is 0 when no amount is given.
+type Ingredient struct {
+    Name     string  `json:"name"`
+    Quantity float64 `json:"quantity,omitempty"`
+    Unit     string  `json:"unit,omitempty"`
+    Optional bool    `json:"optional,omitempty"`
+
+    // Cost is what the amount listed costs, for budgets
+    Cost float64 `json:"cost,omitempty"`
+
+    // text is the line as written, for ingredients given as text
+    text string
+}
+
+// UnmarshalJSON accepts the text and object forms
+func (i *Ingredient) UnmarshalJSON(data []byte) error {
+    var text string
+    if err := json.Unmarshal(data, &text); err == nil {
+        *i = ParseIngredient(text)
+        return nil
+    }
+    type plain Ingredient
+    var object plain
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&object); err != nil {
+        return fmt.Errorf("an ingredient is text or {\"name\", \"quantity\", \"unit\"}: %w", err)
+    }
+    if strings.TrimSpace(object.Name) == "" {
+        return fmt.Errorf("ingredient has no name")
+    }
+    if object.Quantity < 0 {
+        return fmt.Errorf("ingredient %s has a negative quantity", object.Name)
+    }
+    if object.Cost < 0 {
+        return fmt.Errorf("ingredient %s has a negative cost", object.Name)
+    }
+    *i = Ingredient(object)
+    return nil
+}
+
+// MarshalJSON writes the ingredient back in the form it was read
+func (i Ingredient) MarshalJSON() ([]byte, error) {
+    if i.Cost == 0 && (i.text != "" || (i.Quantity == 0 && i.Unit == "" && !i.Optional)) {
+        return json.Marshal(i.String())
+    }
+    type plain Ingredient
+    return json.Marshal(plain(i))
+}
+
+// String writes the ingredient as a line of text, e.g. "200 g flour"
+func (i Ingredient) String() string {
+    if i.text != "" {
+        return i.text
+    }
+    line := i.Name
+    switch {
+    case i.Quantity > 1 && i.Unit == "" && singular(i.Name) == i.Name:
+        line = formatNumber(i.Quantity) + " " + plural(i.Name)
+    case i.Quantity > 0:
+        line = strings.TrimSpace(formatNumber(i.Quantity)+" "+i.Unit) + " " + i.Name
+    }
+    if i.Optional {
+        line += " (optional)"
+    }
+    return line
+}
+
+// Key is what an ingredient is matched on across dinners: its name without
+// amount or unit, lowercased and made singular
+func (i Ingredient) Key() string {
+    return singular(strings.ToLower(strings.TrimSpace(i.Name)))
+}
+
+// base returns the quantity in its unit's base unit (g, ml or tsp), so grams
+// and kilos, or teaspoons and tablespoons, can be added up. Other units are
+// returned as they are, made singular.
+func (i Ingredient) base() (float64, string) {
+    name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(i.Unit)), ".")
+    if u, ok := units[name]; ok {
+        return i.Quantity * u.factor, u.base
+    }
+    return i.Quantity, singular(name)
+}
+
+// Scaled returns the ingredient with its quantity multiplied by factor
+func (i Ingredient) Scaled(factor float64) Ingredient {
+    if i.Quantity == 0 || factor == 1 {
+        return i
+    }
+    scaled := i
+    scaled.Quantity *= factor
+    scaled.text = ""
+    return scaled
+}
+
+// TextIngredients turns lines of text into ingredients, e.g. from a recipe import
+func TextIngredients(lines []string) []Ingredient {
+    var ingredients []Ingredient
+    for _, line := range lines {
+        ingredients = append(ingredients, ParseIngredient(line))
+    }
+    return ingredients
+}
+
+// IngredientLines writes ingredients as lines of text
+func IngredientLines(ingredients []Ingredient) []string {
+    var lines []string
+    for _, ingredient := range ingredients {
+        lines = append(lines, ingredient.String())
+    }
+    return lines
+}
+
+// unit is a canonical unit and how many of its base unit it is, so grams and
+// kilos can be added up
+type unit struct {
+    base   string
+    factor float64
+}
+
+// units maps the ways recipes write units to their canonical form
+var units = map[string]unit{
+    "g": {"g", 1}, "gr": {"g", 1}, "gram": {"g", 1}, "grams": {"g", 1},
+    "kg": {"g", 1000}, "kilo": {"g", 1000}, "kilos": {"g", 1000},
+    "ml": {"ml", 1}, "cl": {"ml", 10}, "dl": {"ml", 100}, "l": {"ml", 1000}, "liter": {"ml", 1000}, "litre": {"ml", 1000},
+    "tsp": {"tsp", 1}, "teaspoon": {"tsp", 1}, "teaspoons": {"tsp", 1},
+    "tbsp": {"tsp", 3}, "tablespoon": {"tsp", 3}, "tablespoons": {"tsp", 3},
+    "cup": {"cup", 1}, "cups": {"cup", 1},
+    "oz": {"oz", 1}, "lb": {"lb", 1}, "lbs": {"lb", 1},
+    "clove": {"clove", 1}, "cloves": {"clove", 1},
+    "can": {"can", 1}, "cans": {"can", 1}, "tin": {"can", 1}, "tins": {"can", 1},
+    "jar": {"jar", 1}, "jars": {"jar", 1},
+    "pack": {"pack", 1}, "packs": {"pack", 1}, "packet": {"pack", 1}, "packets": {"pack", 1},
+    "bunch": {"bunch", 1}, "bunches": {"bunch", 1},
+    "handful": {"handful", 1}, "handfuls": {"handful", 1},
+    "pinch": {"pinch", 1}, "pinches": {"pinch", 1},
+    "slice": {"slice", 1}, "slices": {"slice", 1},
+    "sprig": {"sprig", 1}, "sprigs": {"sprig", 1},
+    "stalk": {"stalk", 1}, "stalks": {"stalk", 1},
+}
+
+// fractions are the vulgar fraction characters recipes use
+var fractions = map[string]float64{"½": 0.5, "⅓": 1.0 / 3, "⅔": 2.0 / 3, "¼": 0.25, "¾": 0.75, "⅛": 0.125}
+
+// amountPattern matches a leading amount: "2", "1.5", "1/2", "1 1/2", "½",
+// "1½" or a range like "2-3"
+var amountPattern = regexp.MustCompile(`^(\d+/\d+|\d+(?:[.,]\d+)?(?:\s*-\s*\d+(?:[.,]\d+)?)?(?:\s+\d+/\d+)?)?\s*([½⅓⅔¼¾⅛])?`)
+
+// ParseIngredient reads a line of text like "200 g flour" or "2 onions" into
+// an ingredient. The name is lowercased and loses any optional marker, which
+// sets Optional. A line it can't read an amount from is all name.
+func ParseIngredient(line string) Ingredient {
+    text := normalizeIngredient(line)
+    ingredient := Ingredient{Name: text, Optional: isOptional(line), text: strings.TrimSpace(line)}
+
+    match := amountPattern.FindStringSubmatch(text)
+    amount := parseAmount(match[1]) + fractions[match[2]]
+    rest := strings.TrimSpace(text[len(match[0]):])
+    if amount == 0 {
+        // "a lemon" or "an apple" is one of them, "half a cabbage" half of one
+        for _, article := range []struct {
+            prefix   string
+            quantity float64
+        }{{"a ", 1}, {"an ", 1}, {"half a ", 0.5}, {"half an ", 0.5}} {
+            if strings.HasPrefix(text, article.prefix) {
+                ingredient.Quantity, ingredient.Name = article.quantity, strings.TrimSpace(text[len(article.prefix):])
+            }
+        }
+        return ingredient
+    }
+    if rest == "" {
+        return ingredient
+    }
+
+    ingredient.Quantity, ingredient.Name = amount, rest
+    word, after, _ := strings.Cut(rest, " ")
+    if _, ok := units[strings.TrimSuffix(word, ".")]; ok && after != "" {
+        ingredient.Unit = strings.TrimSuffix(word, ".")
+        ingredient.Name = strings.TrimPrefix(strings.TrimSpace(after), "of ")
+    }
+    return ingredient
+}
+
+// parseAmount reads "2", "1,5", "1 1/2" or "2-3" (taking the larger), or 0
+func parseAmount(text string) float64 {
+    if text == "" {
+        return 0
+    }
+    if _, high, ok := strings.Cut(text, "-"); ok {
+        return parseAmount(strings.TrimSpace(high))
+    }
+    total := 0.0
+    for _, part := range strings.Fields(text) {
+        if num, den, ok := strings.Cut(part, "/"); ok {
+            n, _ := strconv.ParseFloat(num, 64)
+            d, _ := strconv.ParseFloat(den, 64)
+            if d != 0 {
+                total += n / d
+            }
+            continue
+        }
+        n, _ := strconv.ParseFloat(strings.Replace(part, ",", ".", 1), 64)
+        total += n
+    }
+    return total
+}
+
+// singular makes the last word of a name singular for matching, so "onions"
+// and "onion" are the same ingredient
+func singular(name string) string {
+    switch {
+    case strings.HasSuffix(name, "ies") && len(name) > 4:
+        return strings.TrimSuffix(name, "ies") + "y"
+    case strings.HasSuffix(name, "oes"), strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "sses"):
+        return strings.TrimSuffix(name, "es")
+    case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && !strings.HasSuffix(name, "us") && !strings.HasSuffix(name, "is"):
+        return strings.TrimSuffix(name, "s")
+    }
+    return name
+}
+
+// plural makes the last word of a name plural, for names only seen singular
+func plural(name string) string {
+    switch {
+    case len(name) > 1 && strings.HasSuffix(name, "y") && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiou"):
+        return strings.TrimSuffix(name, "y") + "ies"
+    case strings.HasSuffix(name, "o"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "s"):
+        return name + "es"
+    }
+    return name + "s"
+}
+
+// GroceryItem is one line of the shopping list with the amounts of every
+// dinner that needs it added up
+type GroceryItem struct {
+    Key string
+
+    amounts    map[string]float64
+    unmeasured bool
+
+    // The recipes' own spellings, to write the item the way they do
+    first, singular, plural string
+}
+
+// add counts another dinner's ingredient towards the item
+func (g *GroceryItem) add(ingredient Ingredient) {
+    if amount, unit := ingredient.base(); amount == 0 {
+        g.unmeasured = true
+    } else {
+        g.amounts[unit] += amount
+    }
+    name := strings.ToLower(strings.TrimSpace(ingredient.Name))
+    if g.first == "" {
+        g.first = name
+    }
+    if singular(name) == name {
+        if g.singular == "" {
+            g.singular = name
+        }
+    } else if g.plural == "" {
+        g.plural = name
+    }
+}
+
+// name is how the item is written: plural when more than one is counted,
+// otherwise as the first recipe wrote it
+func (g *GroceryItem) name() string {
+    count, counted := g.amounts[""]
+    switch {
+    case counted && count > 1 && g.plural != "":
+        return g.plural
+    case counted && count > 1:
+        return plural(g.singular)
+    case counted && g.singular != "":
+        return g.singular
+    }
+    return g.first
+}
+
+// String writes the item with its total, e.g. "3 onions", "1.5 kg potatoes"
+// or "2 cups + 200 g flour"
+func (g *GroceryItem) String() string {
+    var parts []string
+    var measures []string
+    for unit := range g.amounts {
+        measures = append(measures, unit)
+    }
+    sort.Strings(measures)
+    for _, unit := range measures {
+        parts = append(parts, formatAmount(g.amounts[unit], unit))
+    }
+    if len(parts) == 0 {
+        return g.name()
+    }
+    line := strings.Join(parts, " + ") + " " + g.name()
+    if g.unmeasured {
+        line += " (+ more)"
+    }
+    return line
+}
+
+// formatAmount writes an amount in a base unit, moving up to kg, l and tbsp
+// when that's tidier, e.g. "1.5 kg", "2 tbsp", "3 cloves" or just "2"
+func formatAmount(amount float64, unit string) string {
+    switch {
+    case unit == "g" && amount >= 1000:
+        amount, unit = amount/1000, "kg"
+    case unit == "ml" && amount >= 1000:
+        amount, unit = amount/1000, "l"
+    case unit == "tsp" && amount >= 3 && amount == float64(int(amount)) && int(amount)%3 == 0:
+        amount, unit = amount/3, "tbsp"
+    }
+    number := formatNumber(amount)
+    switch {
+    case unit == "":
+        return number
+    case amount != 1 && units[plural(unit)].base == unit:
+        return fmt.Sprintf("%s %s", number, plural(unit))
+    }
+    return fmt.Sprintf("%s %s", number, unit)
+}
+
+// formatNumber writes a quantity with at most two decimals, e.g. "2" or "0.33"
+func formatNumber(n float64) string {
+    number := strconv.FormatFloat(n, 'f', 2, 64)
+    return strings.TrimRight(strings.TrimRight(number, "0"), ".")
+}
+
+// AggregateIngredients merges the dinners' ingredients into one item per
+// ingredient, adding up amounts where the units allow
+func AggregateIngredients(dinners []Dinner) map[string]*GroceryItem {
+    items := make(map[string]*GroceryItem)
+    for _, dinner := range dinners {
+        for _, ingredient := range dinner.Ingredients {
+            key := ingredient.Key()
+            if key == "" {
+                continue
+            }
+            item, ok := items[key]
+            if !ok {
+                item = &GroceryItem{Key: key, amounts: make(map[string]float64)}
+                items[key] = item
+            }
+            item.add(ingredient)
+        }
+    }
+    return items
+}
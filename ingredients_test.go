@@ -0,0 +1,159 @@
+package main
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+// The shopping list adds up each ingredient's amounts across the week's
+// dinners, however the recipes wrote them
+func TestAggregateIngredients(t *testing.T) {
+    tests := []struct {
+        name  string
+        lines [][]string
+        key   string
+        want  string
+    }{
+        {"counts", [][]string{{"2 onions"}, {"1 onion"}}, "onion", "3 onions"},
+        {"singular only", [][]string{{"1 lemon"}, {"a lemon"}}, "lemon", "2 lemons"},
+        {"grams into kilos", [][]string{{"500g potatoes"}, {"1 kg potatoes"}}, "potato", "1.5 kg potatoes"},
+        {"fractions", [][]string{{"1/2 cup rice"}, {"1 ½ cups rice"}}, "rice", "2 cups rice"},
+        {"ranges count the larger", [][]string{{"2-3 carrots"}, {"1 carrot"}}, "carrot", "4 carrots"},
+        {"units that don't add up", [][]string{{"2 cups flour"}, {"200 g flour"}}, "flour", "2 cups + 200 g flour"},
+        {"some unmeasured", [][]string{{"2 tomatoes"}, {"tomatoes"}}, "tomato", "2 tomatoes (+ more)"},
+        {"none measured", [][]string{{"fresh basil"}, {"Fresh basil"}}, "fresh basil", "fresh basil"},
+        {"within one dinner", [][]string{{"1 tbsp olive oil", "1 tsp olive oil"}}, "olive oil", "4 tsp olive oil"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var dinners []Dinner
+            for _, lines := range test.lines {
+                dinners = append(dinners, Dinner{Name: "Dinner", Ingredients: TextIngredients(lines)})
+            }
+            items := AggregateIngredients(dinners)
+            if len(items) != 1 {
+                t.Fatalf("got %d items, want 1", len(items))
+            }
+            item, ok := items[test.key]
+            if !ok {
+                t.Fatalf("no item %q in %v", test.key, items)
+            }
+            if got := item.String(); got != test.want {
+                t.Errorf("got %q, want %q", got, test.want)
+            }
+        })
+    }
+}
+
+// An ingredient in dinners.json is a line of text or an object, and is saved
+// the way it was written
+func TestIngredientJSON(t *testing.T) {
+    tests := []struct {
+        name  string
+        input string
+        want  Ingredient
+        err   bool
+        saved string // when it's saved differently from the input
+    }{
+        {"text", `"200 g flour"`, Ingredient{Name: "flour", Quantity: 200, Unit: "g"}, false, ""},
+        {"text without amount", `"salt"`, Ingredient{Name: "salt"}, false, ""},
+        {"object", `{"name": "flour", "quantity": 200, "unit": "g"}`, Ingredient{Name: "flour", Quantity: 200, Unit: "g"}, false, ""},
+        {"object with only a name", `{"name": "salt"}`, Ingredient{Name: "salt"}, false, `"salt"`},
+        {"optional object", `{"name": "chili", "quantity": 1, "optional": true}`, Ingredient{Name: "chili", Quantity: 1, Optional: true}, false, ""},
+        {"no name", `{"quantity": 2}`, Ingredient{}, true, ""},
+        {"blank name", `{"name": " "}`, Ingredient{}, true, ""},
+        {"negative quantity", `{"name": "flour", "quantity": -1}`, Ingredient{}, true, ""},
+        {"unknown field", `{"name": "flour", "amount": 2}`, Ingredient{}, true, ""},
+        {"neither", `3`, Ingredient{}, true, ""},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var got Ingredient
+            err := json.Unmarshal([]byte(test.input), &got)
+            if test.err {
+                if err == nil {
+                    t.Fatalf("got %+v, want an error", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got.Name != test.want.Name || got.Quantity != test.want.Quantity ||
+                got.Unit != test.want.Unit || got.Optional != test.want.Optional {
+                t.Errorf("got %+v, want %+v", got, test.want)
+            }
+            saved, err := json.Marshal(got)
+            if err != nil {
+                t.Fatal(err)
+            }
+            want := test.input
+            if test.saved != "" {
+                want = test.saved
+            }
+            var before, after interface{}
+            json.Unmarshal([]byte(want), &before)
+            json.Unmarshal(saved, &after)
+            if !reflect.DeepEqual(before, after) {
+                t.Errorf("saved as %s, want %s", saved, want)
+            }
+        })
+    }
+}
+
+// Lines of text are split into an amount, a unit and a name
+func TestParseIngredient(t *testing.T) {
+    tests := []struct {
+        line     string
+        quantity float64
+        unit     string
+        name     string
+    }{
+        {"200 g flour", 200, "g", "flour"},
+        {"200g flour", 200, "g", "flour"},
+        {"1.5 kg potatoes", 1.5, "kg", "potatoes"},
+        {"2 onions", 2, "", "onions"},
+        {"a lemon", 1, "", "lemon"},
+        {"1/2 cup milk", 0.5, "cup", "milk"},
+        {"1 ½ cups rice", 1.5, "cups", "rice"},
+        {"2-3 carrots", 3, "", "carrots"},
+        {"salt", 0, "", "salt"},
+    }
+    for _, test := range tests {
+        t.Run(test.line, func(t *testing.T) {
+            got := ParseIngredient(test.line)
+            if got.Quantity != test.quantity || got.Unit != test.unit || got.Name != test.name {
+                t.Errorf("got %v %q %q, want %v %q %q", got.Quantity, got.Unit, got.Name,
+                    test.quantity, test.unit, test.name)
+            }
+            if got.String() != test.line {
+                t.Errorf("written back as %q", got.String())
+            }
+        })
+    }
+}
+
+// Amounts in related units are converted to their base unit to be added up
+func TestIngredientBase(t *testing.T) {
+    tests := []struct {
+        ingredient Ingredient
+        amount     float64
+        unit       string
+    }{
+        {Ingredient{Name: "flour", Quantity: 1.5, Unit: "kg"}, 1500, "g"},
+        {Ingredient{Name: "flour", Quantity: 200, Unit: "g"}, 200, "g"},
+        {Ingredient{Name: "milk", Quantity: 0.5, Unit: "l"}, 500, "ml"},
+        {Ingredient{Name: "oil", Quantity: 2, Unit: "tbsp"}, 6, "tsp"},
+        {Ingredient{Name: "rice", Quantity: 2, Unit: "cups"}, 2, "cup"},
+        {Ingredient{Name: "onion", Quantity: 2}, 2, ""},
+    }
+    for _, test := range tests {
+        t.Run(test.ingredient.String(), func(t *testing.T) {
+            amount, unit := test.ingredient.base()
+            if amount != test.amount || unit != test.unit {
+                t.Errorf("got %v %q, want %v %q", amount, unit, test.amount, test.unit)
+            }
+        })
+    }
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+    "sort"
+    "strings"
+    "sync/atomic"
+    "unicode"
+)
+
+// ContainsTagPrefix starts the tags dinners get from their ingredients, e.g.
+// "contains-nuts" for a dinner with cashews
+const ContainsTagPrefix = "contains-"
+
+// defaultIngredientTags says what common ingredients contain, by the words
+// in their names. A longer phrase is matched before the words in it, so
+// "peanut butter" isn't dairy and "coconut milk" isn't either; an empty list
+// marks a phrase as containing nothing. The catalog's ingredient_tags add to
+// and override these.
+var defaultIngredientTags = map[string][]string{
+    // Nuts and peanuts
+    "almond": {"nuts"}, "cashew": {"nuts"}, "walnut": {"nuts"}, "pecan": {"nuts"},
+    "hazelnut": {"nuts"}, "pistachio": {"nuts"}, "macadamia": {"nuts"}, "pine nut": {"nuts"},
+    "nut": {"nuts"}, "almond milk": {"nuts"}, "pesto": {"nuts", "dairy"},
+    "peanut": {"peanuts"}, "peanut butter": {"peanuts"}, "satay": {"peanuts"},
+
+    // Dairy
+    "milk": {"dairy"}, "butter": {"dairy"}, "cream": {"dairy"}, "sour cream": {"dairy"},
+    "cheese": {"dairy"}, "parmesan": {"dairy"}, "mozzarella": {"dairy"}, "feta": {"dairy"},
+    "ricotta": {"dairy"}, "mascarpone": {"dairy"}, "cheddar": {"dairy"}, "halloumi": {"dairy"},
+    "paneer": {"dairy"}, "yoghurt": {"dairy"}, "yogurt": {"dairy"}, "creme fraiche": {"dairy"},
+    "ghee": {"dairy"},
+    "coconut milk": {}, "coconut cream": {}, "coconut yoghurt": {}, "soy milk": {"soy"},
+    "oat milk": {}, "cream of tartar": {},
+
+    // Gluten
+    "bread": {"gluten"}, "pasta": {"gluten"}, "spaghetti": {"gluten"}, "flour": {"gluten"},
+    "couscous": {"gluten"}, "pita": {"gluten"}, "wrap": {"gluten"}, "bun": {"gluten"},
+    "breadcrumb": {"gluten"}, "panko": {"gluten"}, "noodle": {"gluten"}, "dumpling": {"gluten"},
+    "tortilla": {"gluten"}, "lasagne": {"gluten"}, "gnocchi": {"gluten"},
+    "rice noodle": {}, "rice paper": {}, "corn tortilla": {}, "egg noodle": {"eggs", "gluten"},
+
+    // Eggs
+    "egg": {"eggs"}, "mayo": {"eggs"}, "mayonnaise": {"eggs"}, "aioli": {"eggs"},
+
+    // Fish and shellfish
+    "fish": {"fish"}, "fish sauce": {"fish"}, "tuna": {"fish"}, "salmon": {"fish"},
+    "anchovy": {"fish"}, "cod": {"fish"}, "sardine": {"fish"}, "mackerel": {"fish"},
+    "prawn": {"shellfish"}, "shrimp": {"shellfish"}, "crab": {"shellfish"}, "lobster": {"shellfish"},
+    "mussel": {"shellfish"}, "clam": {"shellfish"}, "oyster": {"shellfish"}, "oyster sauce": {"shellfish"},
+    "squid": {"shellfish"},
+
+    // Soy and sesame
+    "soy": {"soy"}, "soy sauce": {"soy", "gluten"}, "tofu": {"soy"}, "edamame": {"soy"},
+    "miso": {"soy"}, "tempeh": {"soy"},
+    "sesame": {"sesame"}, "tahini": {"sesame"},
+
+    // Meat, for vegetarian rules
+    "chicken": {"meat"}, "beef": {"meat"}, "pork": {"meat"}, "lamb": {"meat"},
+    "sausage": {"meat"}, "bacon": {"meat"}, "pancetta": {"meat"}, "chorizo": {"meat"},
+    "ham": {"meat"}, "mince": {"meat"}, "turkey": {"meat"}, "hotdog": {"meat"},
+    "salami": {"meat"}, "prosciutto": {"meat"}, "schnitty": {"meat"},
+    "chicken broth": {"meat"}, "chicken stock": {"meat"}, "beef stock": {"meat"},
+    "broth": {"meat"}, "stock": {"meat"}, "bone broth": {"meat"},
+    "vegetable broth": {}, "vegetable stock": {}, "veggie stock": {}, "miso broth": {"soy"},
+}
+
+// dietTags are the diet tags dinners get from their ingredients, each with
+// what its ingredients mustn't contain
+var dietTags = map[string][]string{
+    "vegetarian":  {"meat", "fish", "shellfish"},
+    "pescatarian": {"meat"},
+    "vegan":       {"meat", "fish", "shellfish", "dairy", "eggs"},
+    "gluten-free": {"gluten"},
+    "dairy-free":  {"dairy"},
+}
+
+// ingredientTagger finds what ingredients contain from their names
+type ingredientTagger struct {
+    // phrases are the known ingredients as matchWords spells them, longest first
+    phrases []string
+    tags    map[string][]string
+
+    // names are what the known ingredients can contain, e.g. "meat"
+    names map[string]bool
+}
+
+// newIngredientTagger combines the defaults with a catalog's own tags
+func newIngredientTagger(overrides map[string][]string) *ingredientTagger {
+    t := &ingredientTagger{tags: make(map[string][]string)}
+    for _, table := range []map[string][]string{defaultIngredientTags, overrides} {
+        for ingredient, tags := range table {
+            phrase := matchWords(ingredient)
+            if strings.TrimSpace(phrase) == "" {
+                continue
+            }
+            t.tags[phrase] = tags
+        }
+    }
+    t.names = make(map[string]bool)
+    for phrase, tags := range t.tags {
+        t.phrases = append(t.phrases, phrase)
+        for _, tag := range tags {
+            t.names[tag] = true
+        }
+    }
+    sort.Slice(t.phrases, func(i, j int) bool {
+        if len(t.phrases[i]) != len(t.phrases[j]) {
+            return len(t.phrases[i]) > len(t.phrases[j])
+        }
+        return t.phrases[i] < t.phrases[j]
+    })
+    return t
+}
+
+// currentTagger is the tagger for the catalog last loaded; loadCatalog
+// replaces it, which a server may do while requests are using it
+var currentTagger atomic.Pointer[ingredientTagger]
+
+// tagger returns the ingredient tagger in use, the defaults before a catalog is loaded
+func tagger() *ingredientTagger {
+    if t := currentTagger.Load(); t != nil {
+        return t
+    }
+    t := newIngredientTagger(nil)
+    currentTagger.CompareAndSwap(nil, t)
+    return t
+}
+
+// useIngredientTags makes the catalog's ingredient tags the ones dinners are
+// judged by
+func (d *DinnerData) useIngredientTags() {
+    currentTagger.Store(newIngredientTagger(d.IngredientTags))
+}
+
+// matchWords spells an ingredient as lowercase words between spaces, singular
+// as far as a plain "s" goes, so "Cashews/peanuts" becomes " cashew peanut "
+func matchWords(name string) string {
+    words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+    for i, word := range words {
+        switch {
+        case len(word) > 4 && strings.HasSuffix(word, "ies"):
+            words[i] = strings.TrimSuffix(word, "ies") + "y"
+        case len(word) > 4 && strings.HasSuffix(word, "oes"):
+            words[i] = strings.TrimSuffix(word, "es")
+        case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+            words[i] = strings.TrimSuffix(word, "s")
+        }
+    }
+    return " " + strings.Join(words, " ") + " "
+}
+
+// contains returns what an ingredient contains, matching the longest known
+// phrases first and each word only once
+func (t *ingredientTagger) contains(name string) []string {
+    words := matchWords(name)
+    var found []string
+    for _, phrase := range t.phrases {
+        if !strings.Contains(words, phrase) {
+            continue
+        }
+        found = append(found, t.tags[phrase]...)
+        words = strings.ReplaceAll(words, phrase, "  ")
+    }
+    return found
+}
+
+// DerivedAllergens returns what the dinner's ingredients contain by the
+// ingredient tags, sorted, e.g. "dairy", "gluten"; it's worked out afresh
+// each time, so it follows every edit to the ingredients
+func (d Dinner) DerivedAllergens() []string {
+    t := tagger()
+    seen := make(map[string]bool)
+    var derived []string
+    for _, ingredient := range d.Ingredients {
+        for _, tag := range t.contains(ingredient.Name) {
+            if !seen[tag] {
+                seen[tag] = true
+                derived = append(derived, tag)
+            }
+        }
+    }
+    sort.Strings(derived)
+    return derived
+}
+
+// DerivedDietTags returns the diet tags the dinner's ingredients earn it,
+// sorted, e.g. "gluten-free", "vegetarian": those whose exclusions it
+// neither lists nor has in its ingredients. A dinner with no ingredients
+// earns none, as there's nothing to go by.
+func (d Dinner) DerivedDietTags() []string {
+    if len(d.Ingredients) == 0 {
+        return nil
+    }
+    var derived []string
+    for tag, excluded := range dietTags {
+        earned := true
+        for _, allergen := range excluded {
+            earned = earned && !d.HasAllergen(allergen)
+        }
+        if earned {
+            derived = append(derived, tag)
+        }
+    }
+    sort.Strings(derived)
+    return derived
+}
+
+// derivedTag reports whether the dinner earns a tag from its ingredients: a
+// diet tag, or one naming what ingredients contain, like "meat" for
+// "contains-meat"
+func (d Dinner) derivedTag(tag string) bool {
+    tag = strings.ToLower(strings.TrimSpace(tag))
+    if _, ok := dietTags[tag]; ok {
+        return containsString(d.DerivedDietTags(), tag)
+    }
+    for name := range tagger().names {
+        if sameAllergen(name, tag) {
+            return d.HasAllergen(tag)
+        }
+    }
+    return false
+}
+
+// unlistedAllergens returns the derived allergens the dinner doesn't list
+// itself, for showing where they came from
+func (d Dinner) unlistedAllergens() []string {
+    var unlisted []string
+    for _, allergen := range d.DerivedAllergens() {
+        listed := false
+        for _, own := range d.Allergens {
+            listed = listed || sameAllergen(own, allergen)
+        }
+        if !listed {
+            unlisted = append(unlisted, allergen)
+        }
+    }
+    return unlisted
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "os/user"
+    "strings"
+    "time"
+)
+
+const JournalFileName = "dinner_journal.jsonl"
+
+// JournalEntry is one state change, written before the state file itself so
+// an interrupted save can be replayed
+type JournalEntry struct {
+    Seq     int             `json:"seq"`
+    Time    time.Time       `json:"time"`
+    User    string          `json:"user,omitempty"`
+    Action  string          `json:"action"`
+    Summary string          `json:"summary,omitempty"`
+    State   json.RawMessage `json:"state"`
+}
+
+// currentUser names whoever is running the command
+func currentUser() string {
+    if u, err := user.Current(); err == nil && u.Username != "" {
+        return u.Username
+    }
+    return os.Getenv("USER")
+}
+
+// ReadJournal returns every entry in the journal, oldest first
+func ReadJournal() ([]JournalEntry, error) {
+    file, err := os.Open(dataPath(JournalFileName))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading journal: %w", err)
+    }
+    defer file.Close()
+
+    var entries []JournalEntry
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(nil, 64<<20)
+    for scanner.Scan() {
+        line := bytes.TrimSpace(scanner.Bytes())
+        if len(line) == 0 {
+            continue
+        }
+        var entry JournalEntry
+        if err := json.Unmarshal(line, &entry); err != nil {
+            // A torn last line from a crash mid-append is skipped
+            continue
+        }
+        entries = append(entries, entry)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading journal: %w", err)
+    }
+    return entries, nil
+}
+
+// lastJournalEntry returns the newest entry, reading the journal backwards from
+// the end so commands don't slow down as it grows. It returns nil for an empty
+// or missing journal.
+func lastJournalEntry() (*JournalEntry, error) {
+    file, err := os.Open(dataPath(JournalFileName))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading journal: %w", err)
+    }
+    defer file.Close()
+    info, err := file.Stat()
+    if err != nil {
+        return nil, fmt.Errorf("error reading journal: %w", err)
+    }
+    size := info.Size()
+
+    for window := int64(64 << 10); ; window *= 2 {
+        if window > size {
+            window = size
+        }
+        buf := make([]byte, window)
+        if _, err := file.ReadAt(buf, size-window); err != nil {
+            return nil, fmt.Errorf("error reading journal: %w", err)
+        }
+        lines := bytes.Split(buf, []byte("\n"))
+        if window < size {
+            // The first line is probably cut off by the window
+            lines = lines[1:]
+        }
+        for i := len(lines) - 1; i >= 0; i-- {
+            line := bytes.TrimSpace(lines[i])
+            if len(line) == 0 {
+                continue
+            }
+            var entry JournalEntry
+            if err := json.Unmarshal(line, &entry); err == nil {
+                return &entry, nil
+            }
+            // A torn last line from a crash mid-append is skipped
+        }
+        if window == size {
+            return nil, nil
+        }
+    }
+}
+
+// appendJournal writes an entry and syncs it to disk
+func appendJournal(entry JournalEntry) error {
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return fmt.Errorf("error marshaling journal entry: %w", err)
+    }
+    file, err := os.OpenFile(dataPath(JournalFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+    if err != nil {
+        return fmt.Errorf("error opening journal: %w", err)
+    }
+    defer file.Close()
+    if _, err := file.Write(append(line, '\n')); err != nil {
+        return fmt.Errorf("error writing journal: %w", err)
+    }
+    if err := file.Sync(); err != nil {
+        return fmt.Errorf("error writing journal: %w", err)
+    }
+    return nil
+}
+
+// Record journals a change and then saves the state, holding the data lock so
+// concurrent commands can't interleave their entries
+func (s *WeekState) Record(action, summary string) error {
+    return s.RecordIfCurrent(action, summary, -1)
+}
+
+// ConflictError is returned when a change was based on an older version of
+// the state than the one saved
+type ConflictError struct {
+    BasedOn, Current int
+}
+
+// Error says what changed and what to do about it
+func (e *ConflictError) Error() string {
+    return fmt.Sprintf("the plan changed since revision %d (now %d), reload and try again", e.BasedOn, e.Current)
+}
+
+// RecordIfCurrent records a change like Record, but only if nothing else was
+// recorded since revision basedOn (the JournalSeq the change started from),
+// so two people editing at once can't silently overwrite each other. A
+// negative basedOn records unconditionally.
+func (s *WeekState) RecordIfCurrent(action, summary string, basedOn int) error {
+    unlock, err := lockData()
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    last, err := lastJournalEntry()
+    if err != nil {
+        return err
+    }
+    if basedOn >= 0 {
+        current := 0
+        if last != nil {
+            current = last.Seq
+        }
+        if current != basedOn {
+            return &ConflictError{BasedOn: basedOn, Current: current}
+        }
+    }
+    s.JournalSeq = 1
+    if last != nil {
+        s.JournalSeq = last.Seq + 1
+    }
+
+    data, err := json.Marshal(s)
+    if err != nil {
+        return fmt.Errorf("error marshaling state: %w", err)
+    }
+    entry := JournalEntry{
+        Seq:     s.JournalSeq,
+        Time:    time.Now(),
+        User:    currentUser(),
+        Action:  action,
+        Summary: summary,
+        State:   data,
+    }
+    if err := appendJournal(entry); err != nil {
+        return err
+    }
+    return s.SaveState()
+}
+
+// recoverFromJournal replaces the state with the journal's latest snapshot
+// when the state file is missing that change, e.g. after a crash mid-save.
+// state may be nil when the state file couldn't be read at all.
+func recoverFromJournal(state *WeekState) (*WeekState, error) {
+    last, err := lastJournalEntry()
+    if err != nil || last == nil {
+        return state, err
+    }
+    if state != nil && state.JournalSeq >= last.Seq {
+        return state, nil
+    }
+
+    var recovered WeekState
+    if err := json.Unmarshal(last.State, &recovered); err != nil {
+        return state, fmt.Errorf("error parsing journal state: %w", err)
+    }
+    logf("Recovered state from the journal (%s at %s)\n", last.Action, last.Time.Format("2006-01-02 15:04"))
+    if err := recovered.SaveState(); err != nil {
+        return nil, err
+    }
+    return &recovered, nil
+}
+
+// runAuditCommand handles "audit [--limit N]", listing recent state changes
+func runAuditCommand(args []string) error {
+    fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+    limit := fs.Int("limit", 20, "number of changes to show, 0 for all")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    entries, err := ReadJournal()
+    if err != nil {
+        return err
+    }
+    if len(entries) == 0 {
+        fmt.Println("No changes recorded yet")
+        return nil
+    }
+    if *limit > 0 && len(entries) > *limit {
+        entries = entries[len(entries)-*limit:]
+    }
+    for _, entry := range entries {
+        line := fmt.Sprintf("%s  %-8s  %-12s  %s", entry.Time.Format("2006-01-02 15:04"), entry.User, entry.Action, entry.Summary)
+        fmt.Println(strings.TrimRight(line, " "))
+    }
+    return nil
+}
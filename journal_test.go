@@ -0,0 +1,58 @@
+package main
+
+import (
+    "encoding/json"
+    "os"
+    "strings"
+    "testing"
+    "time"
+)
+
+// The journal is read from its end, skipping a last entry cut off by a crash
+// mid-append, however much of the file the entries before it take up
+func TestLastJournalEntryAfterTornAppend(t *testing.T) {
+    tests := []struct {
+        name    string
+        entries int
+        note    int
+        torn    string
+    }{
+        {"torn entry", 3, 10, `{"seq":4,"time":"2026-10-16T18:`},
+        {"torn entry without a newline", 3, 10, `{"seq":4`},
+        {"torn entry over the first window", 3, 40 << 10, `{"seq":4,"action":"plan","state":{"note":"` + strings.Repeat("x", 70<<10)},
+        {"blank lines after it", 3, 10, "\n\n"},
+        {"nothing whole", 0, 10, `{"seq":1,"act`},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            useRepoData(t)
+            var journal []byte
+            for seq := 1; seq <= test.entries; seq++ {
+                state, err := json.Marshal(WeekState{Note: strings.Repeat("x", test.note)})
+                if err != nil {
+                    t.Fatal(err)
+                }
+                line, err := json.Marshal(JournalEntry{Seq: seq, Time: time.Now(), Action: "plan", State: state})
+                if err != nil {
+                    t.Fatal(err)
+                }
+                journal = append(append(journal, line...), '\n')
+            }
+            journal = append(journal, test.torn...)
+            if err := os.WriteFile(dataPath(JournalFileName), journal, 0644); err != nil {
+                t.Fatal(err)
+            }
+
+            last, err := lastJournalEntry()
+            if err != nil {
+                t.Fatal(err)
+            }
+            switch {
+            case test.entries == 0 && last != nil:
+                t.Fatalf("got entry %d from a journal with none whole", last.Seq)
+            case test.entries > 0 && (last == nil || last.Seq != test.entries):
+                t.Fatalf("got %+v, want entry %d", last, test.entries)
+            }
+        })
+    }
+}
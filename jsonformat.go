@@ -0,0 +1,207 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// jsonNode is a parsed JSON value that remembers the order of object keys
+type jsonNode struct {
+    object bool
+    array  bool
+    keys   []string
+    items  []jsonNode
+    scalar string
+}
+
+// encodeScalar writes a string, number, bool or null as JSON, leaving <, >
+// and & as they are
+func encodeScalar(value interface{}) (string, error) {
+    switch v := value.(type) {
+    case json.Number:
+        return v.String(), nil
+    case nil:
+        return "null", nil
+    }
+    var buf bytes.Buffer
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    if err := enc.Encode(value); err != nil {
+        return "", err
+    }
+    return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// parseJSONNode reads the next value from the decoder
+func parseJSONNode(dec *json.Decoder) (jsonNode, error) {
+    token, err := dec.Token()
+    if err != nil {
+        return jsonNode{}, err
+    }
+    delim, ok := token.(json.Delim)
+    if !ok {
+        scalar, err := encodeScalar(token)
+        return jsonNode{scalar: scalar}, err
+    }
+    node := jsonNode{object: delim == '{', array: delim == '['}
+    for dec.More() {
+        if node.object {
+            key, err := dec.Token()
+            if err != nil {
+                return jsonNode{}, err
+            }
+            encoded, err := encodeScalar(key)
+            if err != nil {
+                return jsonNode{}, err
+            }
+            node.keys = append(node.keys, encoded)
+        }
+        item, err := parseJSONNode(dec)
+        if err != nil {
+            return jsonNode{}, err
+        }
+        node.items = append(node.items, item)
+    }
+    // The closing delimiter
+    if _, err := dec.Token(); err != nil {
+        return jsonNode{}, err
+    }
+    return node, nil
+}
+
+// isScalar reports whether the node is a string, number, bool or null
+func (n jsonNode) isScalar() bool {
+    return !n.object && !n.array
+}
+
+// write indents the node the way dinners.json is written by hand: two spaces
+// per level, with lists of plain values such as ingredients kept on one line
+func (n jsonNode) write(buf *bytes.Buffer, indent string) {
+    switch {
+    case n.isScalar():
+        buf.WriteString(n.scalar)
+        return
+    case len(n.items) == 0 && n.object:
+        buf.WriteString("{}")
+        return
+    case len(n.items) == 0:
+        buf.WriteString("[]")
+        return
+    }
+    if n.array {
+        inline := true
+        for _, item := range n.items {
+            inline = inline && item.isScalar()
+        }
+        if inline {
+            var values []string
+            for _, item := range n.items {
+                values = append(values, item.scalar)
+            }
+            buf.WriteString("[" + strings.Join(values, ", ") + "]")
+            return
+        }
+    }
+
+    open, close := "[", "]"
+    if n.object {
+        open, close = "{", "}"
+    }
+    buf.WriteString(open + "\n")
+    for i, item := range n.items {
+        buf.WriteString(indent + "  ")
+        if n.object {
+            buf.WriteString(n.keys[i] + ": ")
+        }
+        item.write(buf, indent+"  ")
+        if i < len(n.items)-1 {
+            buf.WriteString(",")
+        }
+        buf.WriteString("\n")
+    }
+    buf.WriteString(indent + close)
+}
+
+// prettyJSON reformats a JSON document in the hand-written style
+func prettyJSON(data []byte) ([]byte, error) {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    node, err := parseJSONNode(dec)
+    if err != nil {
+        return nil, err
+    }
+    var buf bytes.Buffer
+    node.write(&buf, "")
+    buf.WriteString("\n")
+    return buf.Bytes(), nil
+}
+
+// categoryOrder lists the categories in the order the file had them, with
+// any new ones after, alphabetically
+func (d *DinnerData) categoryOrder() []string {
+    seen := make(map[string]bool)
+    var order, added []string
+    for _, category := range d.order {
+        if _, ok := d.Dinners[category]; ok && !seen[category] {
+            order = append(order, category)
+            seen[category] = true
+        }
+    }
+    for category := range d.Dinners {
+        if !seen[category] {
+            added = append(added, category)
+        }
+    }
+    sort.Strings(added)
+    return append(order, added...)
+}
+
+// encodeCatalog writes the catalog as dinners.json, keeping the categories in
+// their order and the layout people write by hand
+func encodeCatalog(d *DinnerData) ([]byte, error) {
+    var buf bytes.Buffer
+    buf.WriteString(`{"dinners": {`)
+    for i, category := range d.categoryOrder() {
+        if i > 0 {
+            buf.WriteString(",")
+        }
+        name, err := encodeScalar(category)
+        if err != nil {
+            return nil, err
+        }
+        list, err := json.Marshal(d.Dinners[category])
+        if err != nil {
+            return nil, fmt.Errorf("error marshaling %s dinners: %w", category, err)
+        }
+        buf.WriteString(name + ": ")
+        buf.Write(list)
+    }
+    buf.WriteString("}")
+
+    rest := []struct {
+        key   string
+        value interface{}
+        empty bool
+    }{
+        {"renamed_categories", d.Renamed, len(d.Renamed) == 0},
+        {"categories", d.Categories, len(d.Categories) == 0},
+        {"ingredient_tags", d.IngredientTags, len(d.IngredientTags) == 0},
+        {"include", d.Include, len(d.Include) == 0},
+    }
+    for _, field := range rest {
+        if field.empty {
+            continue
+        }
+        value, err := json.Marshal(field.value)
+        if err != nil {
+            return nil, fmt.Errorf("error marshaling %s: %w", field.key, err)
+        }
+        buf.WriteString(`, "` + field.key + `": `)
+        buf.Write(value)
+    }
+    buf.WriteString("}")
+    return prettyJSON(buf.Bytes())
+}
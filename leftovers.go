@@ -0,0 +1,198 @@
+package main
+
+import "fmt"
+
+// lunchDays are the dinners whose leftovers become the next weekday's lunch
+var lunchDays = map[string]bool{
+    "Sunday":    true,
+    "Monday":    true,
+    "Tuesday":   true,
+    "Wednesday": true,
+    "Thursday":  true,
+}
+
+// LunchCoverage counts the weekday lunches covered by big-batch dinners
+func LunchCoverage(plan *Plan) int {
+    if plan == nil {
+        return 0
+    }
+    covered := 0
+    for _, entry := range plan.Days {
+        if lunchDays[entry.Day] && entry.Dinner.MakesLeftovers {
+            covered++
+        }
+    }
+    return covered
+}
+
+// ensureLunchCoverage swaps days for big-batch dinners until the lunch target is
+// met or no more swaps are possible
+func ensureLunchCoverage(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    for LunchCoverage(plan) < opts.LunchTarget {
+        replaced := replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+            if !lunchDays[day] || current.MakesLeftovers || !candidate.MakesLeftovers {
+                return false
+            }
+            // Don't give up the week's required protein for a lunch
+            if opts.Protein != nil && opts.Protein.isRequired(current) && !opts.Protein.isRequired(candidate) {
+                return false
+            }
+            counts := ProteinCounts(plan)
+            if protein := current.MainProtein(); protein != "" {
+                counts[protein]--
+            }
+            return opts.Protein.allows(candidate, counts)
+        })
+        if !replaced {
+            return
+        }
+    }
+}
+
+// printLunchCoverage reports how many weekday lunches the leftovers cover
+func printLunchCoverage(plan *Plan, target int) {
+    covered := LunchCoverage(plan)
+    if target == 0 && covered == 0 {
+        return
+    }
+
+    if target > 0 {
+        fmt.Printf("Lunch coverage: %d/%d weekday lunches from leftovers\n", covered, target)
+        if covered < target {
+            warnf("not enough big-batch dinners to reach the lunch target")
+        }
+        return
+    }
+    fmt.Printf("Lunch coverage: %d weekday lunches from leftovers\n", covered)
+}
+
+// leftoverKeepDays is how many days after the batch is cooked its leftovers
+// can still be dinner
+const leftoverKeepDays = 2
+
+// leftoverReheatMinutes is how long before dinner the reminder for
+// leftovers goes out
+const leftoverReheatMinutes = 20
+
+// feedsTwice reports whether a dinner makes enough for a second dinner:
+// marked makes_leftovers, or written for twice the household
+func (d Dinner) feedsTwice(household int) bool {
+    if household <= 0 {
+        household = defaultServings
+    }
+    return d.MakesLeftovers || d.Servings >= 2*household
+}
+
+// IsLeftovers reports whether the day eats an earlier day's batch rather
+// than cooking
+func (e PlanDay) IsLeftovers() bool {
+    return e.LeftoversFrom != ""
+}
+
+// title is the day's dinner as menus and messages name it
+func (e PlanDay) title() string {
+    if e.IsLeftovers() {
+        return "Leftovers: " + e.Dinner.Name
+    }
+    return e.Dinner.Name
+}
+
+// countLeftoverNights counts the days eating an earlier day's batch
+func countLeftoverNights(plan *Plan) int {
+    n := 0
+    for _, entry := range plan.Days {
+        if entry.IsLeftovers() {
+            n++
+        }
+    }
+    return n
+}
+
+// ensureLeftoverNights cooks once and eats twice: it makes an early day's
+// dinner a big batch, from the same category, and turns a day or two later
+// into leftovers of it, until the plan has as many leftover nights as asked
+// for. Busy days are the first to get leftovers; project days, days with
+// guests and no-cook nights keep their own dinner. It runs last, so nothing
+// replaces a batch once its leftovers are planned.
+func ensureLeftoverNights(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    want := opts.LeftoverNights - countLeftoverNights(plan)
+    if want <= 0 {
+        return
+    }
+    batches := make(map[string]bool)
+    for _, entry := range plan.Days {
+        if entry.IsLeftovers() {
+            batches[entry.LeftoversFrom] = true
+        }
+    }
+    // A day can take leftovers, or cook the batch, if it's cooking an
+    // ordinary dinner of its own
+    takesLeftovers := func(entry PlanDay) bool {
+        if entry.IsLeftovers() || batches[entry.Day] || entry.Dinner.NoCook || opts.Modes[entry.Day] == DayProject || opts.fixed(entry.Day) {
+            return false
+        }
+        _, guests := opts.Guests[entry.Day]
+        return !guests
+    }
+
+    for i := 0; i < len(plan.Days) && want > 0; i++ {
+        cook := &plan.Days[i]
+        // The batch is cooked on an ordinary day too
+        if !takesLeftovers(*cook) {
+            continue
+        }
+
+        // The leftovers go to a busy day within reach if there is one
+        later := -1
+        for j := i + 1; j < len(plan.Days); j++ {
+            days := int(plan.Days[j].Date.Sub(cook.Date).Hours() / 24)
+            if days < 1 || days > leftoverKeepDays || !takesLeftovers(plan.Days[j]) {
+                continue
+            }
+            if later < 0 || opts.Modes[plan.Days[j].Day] == DayQuick && opts.Modes[plan.Days[later].Day] != DayQuick {
+                later = j
+            }
+        }
+        if later < 0 {
+            continue
+        }
+        eat := &plan.Days[later]
+
+        batch := cook.Dinner
+        if !batch.feedsTwice(opts.Household) || !observancesPermit(opts.Observances, eat.Date, batch) {
+            var candidates []Dinner
+            category, _ := dinners.ResolveCategory(batch.Category, opts.Fallbacks)
+            for _, dinner := range dinners.Dinners[category] {
+                if !dinner.feedsTwice(opts.Household) || dinner.NoCook || state.IsAlreadySelected(dinner) || state.IsBanned(dinner) || state.TooRecent(dinner, cook.Date, opts.RepeatDays) {
+                    continue
+                }
+                if opts.Modes[cook.Day] == DayQuick && !dinner.IsQuick(opts.QuickMinutes) {
+                    continue
+                }
+                if !observancesPermit(opts.Observances, cook.Date, dinner) || !opts.Equipment.Permits(cook.Date, dinner) {
+                    continue
+                }
+                if !observancesPermit(opts.Observances, eat.Date, dinner) {
+                    continue
+                }
+                candidates = append(candidates, dinner)
+            }
+            if len(candidates) == 0 {
+                continue
+            }
+            batch = opts.chooseOn(cook.Day)(candidates)
+            state.RemoveSelection(cook.Dinner)
+            state.AddSelection(batch)
+            cook.Dinner = batch
+        }
+
+        state.RemoveSelection(eat.Dinner)
+        eat.Dinner, eat.LeftoversFrom = batch, cook.Day
+        batches[cook.Day] = true
+        plan.Notes = append(plan.Notes, fmt.Sprintf("%s: a big batch of %s, eaten again %s", cook.Day, batch.Name, eat.Day))
+        want--
+    }
+    if want > 0 {
+        plan.Notes = append(plan.Notes, fmt.Sprintf("Planned %d of %d leftover nights - mark more dinners \"makes_leftovers\": true", opts.LeftoverNights-want, opts.LeftoverNights))
+    }
+}
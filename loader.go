@@ -0,0 +1,391 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+// LoadProblem is one thing wrong with a JSON file, located by line and column
+type LoadProblem struct {
+    Line    int
+    Column  int
+    Path    string
+    Message string
+}
+
+// LoadErrors collects every problem found in a JSON file
+type LoadErrors struct {
+    File     string
+    Problems []LoadProblem
+}
+
+// Error lists the problems one per line after a summary
+func (e *LoadErrors) Error() string {
+    noun := "problems"
+    if len(e.Problems) == 1 {
+        noun = "problem"
+    }
+    lines := []string{fmt.Sprintf("%d %s in %s:", len(e.Problems), noun, e.File)}
+    for _, p := range e.Problems {
+        line := fmt.Sprintf("  line %d, column %d", p.Line, p.Column)
+        if p.Path != "" {
+            line += " (" + p.Path + ")"
+        }
+        lines = append(lines, line+": "+p.Message)
+    }
+    return strings.Join(lines, "\n")
+}
+
+// strictDecoder checks a document against a Go type, recording every unknown
+// field and wrongly typed value rather than stopping at the first
+type strictDecoder struct {
+    data     []byte
+    problems []LoadProblem
+}
+
+// add records a problem at a byte offset into the document
+func (d *strictDecoder) add(offset int, path, message string) {
+    line, column := 1, 1
+    for _, b := range d.data[:offset] {
+        if b == '\n' {
+            line++
+            column = 1
+        } else {
+            column++
+        }
+    }
+    d.problems = append(d.problems, LoadProblem{Line: line, Column: column, Path: path, Message: message})
+}
+
+// skipSeparators moves past whitespace, colons and commas to the start of the next value
+func skipSeparators(raw []byte, i int) int {
+    for i < len(raw) && strings.IndexByte(" \t\r\n:,", raw[i]) >= 0 {
+        i++
+    }
+    return i
+}
+
+// jsonKind names the kind of JSON value raw holds
+func jsonKind(raw []byte) string {
+    switch raw[0] {
+    case '{':
+        return "an object"
+    case '[':
+        return "a list"
+    case '"':
+        return "a string"
+    case 't', 'f':
+        return "a boolean"
+    }
+    return "a number"
+}
+
+// describe turns a decoding error into a short message
+func describe(err error) string {
+    var typeErr *json.UnmarshalTypeError
+    if errors.As(err, &typeErr) {
+        return fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)
+    }
+    return strings.TrimPrefix(err.Error(), "json: ")
+}
+
+// jsonField finds the struct field a JSON key decodes into, matching the way
+// encoding/json does (exact tag first, then case-insensitively)
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+    var fold reflect.StructField
+    found := false
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if !field.IsExported() {
+            continue
+        }
+        name := strings.Split(field.Tag.Get("json"), ",")[0]
+        if name == "-" {
+            continue
+        }
+        if name == "" {
+            name = field.Name
+        }
+        if name == key {
+            return field, true
+        }
+        if !found && strings.EqualFold(name, key) {
+            fold, found = field, true
+        }
+    }
+    return fold, found
+}
+
+var (
+    unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+    stringListType  = reflect.TypeOf([]string{})
+)
+
+// check walks the value at raw, which starts at offset in the document, against type t
+func (d *strictDecoder) check(raw []byte, offset int, t reflect.Type, path string) {
+    if bytes.Equal(raw, []byte("null")) {
+        return
+    }
+    if t == reflect.TypeOf(LazyList{}) {
+        // Checked in full here, since loading only checks it's a list
+        t = stringListType
+    }
+    if t.Kind() == reflect.Ptr && !t.Implements(unmarshalerType) {
+        d.check(raw, offset, t.Elem(), path)
+        return
+    }
+
+    object := t.Kind() == reflect.Struct || (t.Kind() == reflect.Map && t.Key().Kind() == reflect.String)
+    array := t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+    if reflect.PtrTo(t).Implements(unmarshalerType) || (!object && !array) {
+        if err := json.Unmarshal(raw, reflect.New(t).Interface()); err != nil {
+            d.add(offset, path, describe(err))
+        }
+        return
+    }
+
+    dec := json.NewDecoder(bytes.NewReader(raw))
+    token, _ := dec.Token()
+    if object && token != json.Delim('{') {
+        d.add(offset, path, "expected an object, got "+jsonKind(raw))
+        return
+    }
+    if array && token != json.Delim('[') {
+        d.add(offset, path, "expected a list, got "+jsonKind(raw))
+        return
+    }
+
+    for i := 0; dec.More(); i++ {
+        var elemType reflect.Type
+        if t.Kind() != reflect.Struct {
+            elemType = t.Elem()
+        }
+        elemPath := fmt.Sprintf("%s[%d]", path, i)
+        if object {
+            keyOffset := skipSeparators(raw, int(dec.InputOffset()))
+            token, _ := dec.Token()
+            key, _ := token.(string)
+            elemPath = key
+            if path != "" {
+                elemPath = path + "." + key
+            }
+            if t.Kind() == reflect.Struct {
+                if field, ok := jsonField(t, key); ok {
+                    elemType = field.Type
+                } else {
+                    d.add(offset+keyOffset, elemPath, fmt.Sprintf("unknown field %q", key))
+                }
+            }
+        }
+
+        start := skipSeparators(raw, int(dec.InputOffset()))
+        var value json.RawMessage
+        if err := dec.Decode(&value); err != nil {
+            return
+        }
+        if elemType != nil {
+            d.check(value, offset+start, elemType, elemPath)
+        }
+    }
+}
+
+// decodeStrict decodes a whole document into target, returning a *LoadErrors
+// listing syntax errors, trailing data, unknown fields and wrong types
+func decodeStrict(file string, data []byte, target interface{}) error {
+    d := &strictDecoder{data: data}
+
+    dec := json.NewDecoder(bytes.NewReader(data))
+    var raw json.RawMessage
+    if err := dec.Decode(&raw); err != nil {
+        var syntaxErr *json.SyntaxError
+        offset := len(data)
+        if errors.As(err, &syntaxErr) {
+            offset = int(syntaxErr.Offset)
+        }
+        d.add(offset, "", describe(err))
+        return &LoadErrors{File: file, Problems: d.problems}
+    }
+    rest := data[dec.InputOffset():]
+    if trimmed := bytes.TrimLeft(rest, " \t\r\n"); len(trimmed) > 0 {
+        d.add(len(data)-len(trimmed), "", "unexpected data after the end of the document")
+    }
+
+    start := skipSeparators(data, 0)
+    d.check(raw, start, reflect.TypeOf(target).Elem(), "")
+    if len(d.problems) > 0 {
+        sort.SliceStable(d.problems, func(i, j int) bool {
+            if d.problems[i].Line != d.problems[j].Line {
+                return d.problems[i].Line < d.problems[j].Line
+            }
+            return d.problems[i].Column < d.problems[j].Column
+        })
+        return &LoadErrors{File: file, Problems: d.problems}
+    }
+    return json.Unmarshal(raw, target)
+}
+
+// LazyList is a list of strings kept as raw JSON until it's used, so loading a
+// large catalog doesn't build every dinner's method just to plan a week
+type LazyList struct {
+    raw   json.RawMessage
+    items []string
+}
+
+// NewLazyList wraps an already decoded list
+func NewLazyList(items []string) LazyList {
+    return LazyList{items: items}
+}
+
+// Items decodes the list, skipping it if it doesn't hold strings
+func (l LazyList) Items() []string {
+    items, _ := l.decode()
+    return items
+}
+
+// decode decodes the list, reporting values that aren't strings
+func (l LazyList) decode() ([]string, error) {
+    if l.raw == nil {
+        return l.items, nil
+    }
+    var items []string
+    if err := json.Unmarshal(l.raw, &items); err != nil {
+        return nil, err
+    }
+    return items, nil
+}
+
+// Len is the number of items in the list
+func (l LazyList) Len() int {
+    return len(l.Items())
+}
+
+// IsZero reports whether the list is empty, so omitzero leaves it out
+func (l LazyList) IsZero() bool {
+    return l.raw == nil && len(l.items) == 0
+}
+
+// MarshalJSON writes the raw JSON back untouched if the list was never decoded
+func (l LazyList) MarshalJSON() ([]byte, error) {
+    if l.raw != nil {
+        return l.raw, nil
+    }
+    if l.items == nil {
+        return []byte("[]"), nil
+    }
+    return json.Marshal(l.items)
+}
+
+// UnmarshalJSON keeps a compacted copy of the list's JSON after a cheap check
+// that it is a list; compacting keeps equal lists equal however they were indented
+func (l *LazyList) UnmarshalJSON(data []byte) error {
+    if bytes.Equal(data, []byte("null")) {
+        *l = LazyList{}
+        return nil
+    }
+    if data[0] != '[' {
+        return &json.UnmarshalTypeError{Value: strings.TrimPrefix(jsonKind(data), "a "), Type: stringListType}
+    }
+    var raw bytes.Buffer
+    if err := json.Compact(&raw, data); err != nil {
+        return err
+    }
+    *l = LazyList{raw: raw.Bytes()}
+    return nil
+}
+
+// expectDelim reads the next token and checks it's the given delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+    token, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    if token != delim {
+        return fmt.Errorf("expected %v, got %v", delim, token)
+    }
+    return nil
+}
+
+// decodeDinners is the fast path for loading dinners.json: one streaming pass
+// that decodes a dinner at a time. Anything unexpected makes it give up, and
+// the caller falls back to decodeStrict for a full report.
+func decodeDinners(r io.Reader) (*DinnerData, error) {
+    dec := json.NewDecoder(bufio.NewReaderSize(r, 1<<16))
+    dec.DisallowUnknownFields()
+    data := &DinnerData{Dinners: make(map[string][]Dinner)}
+
+    if err := expectDelim(dec, '{'); err != nil {
+        return nil, err
+    }
+    for dec.More() {
+        token, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        switch token {
+        case "dinners":
+            if err := expectDelim(dec, '{'); err != nil {
+                return nil, err
+            }
+            for dec.More() {
+                token, err := dec.Token()
+                if err != nil {
+                    return nil, err
+                }
+                category, _ := token.(string)
+                if err := expectDelim(dec, '['); err != nil {
+                    return nil, err
+                }
+                if _, ok := data.Dinners[category]; !ok {
+                    data.order = append(data.order, category)
+                }
+                list := data.Dinners[category]
+                for dec.More() {
+                    var dinner Dinner
+                    if err := dec.Decode(&dinner); err != nil {
+                        return nil, err
+                    }
+                    list = append(list, dinner)
+                }
+                data.Dinners[category] = list
+                if err := expectDelim(dec, ']'); err != nil {
+                    return nil, err
+                }
+            }
+            if err := expectDelim(dec, '}'); err != nil {
+                return nil, err
+            }
+        case "renamed_categories":
+            if err := dec.Decode(&data.Renamed); err != nil {
+                return nil, err
+            }
+        case "categories":
+            if err := dec.Decode(&data.Categories); err != nil {
+                return nil, err
+            }
+        case "ingredient_tags":
+            if err := dec.Decode(&data.IngredientTags); err != nil {
+                return nil, err
+            }
+        case "include":
+            if err := dec.Decode(&data.Include); err != nil {
+                return nil, err
+            }
+        default:
+            return nil, fmt.Errorf("unexpected field %v", token)
+        }
+    }
+    if err := expectDelim(dec, '}'); err != nil {
+        return nil, err
+    }
+    if _, err := dec.Token(); err != io.EOF {
+        return nil, fmt.Errorf("unexpected data after the end of the document")
+    }
+    return data, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// A catalog the streaming decoder gives up on is reported by the strict
+// decoder, with the same problems as reading it strictly from the start
+func TestLoadErrorsThroughStreamingPath(t *testing.T) {
+    tests := []struct {
+        name   string
+        data   string
+        line   int
+        column int
+    }{
+        {"syntax error", "{\n  \"dinners\": {\n    \"soup\": [\n      {\"name\": \"Tomato soup\",}\n    ]\n  }\n}\n", 4, 31},
+        {"unknown field", "{\n  \"dinners\": {\n    \"soup\": [\n      {\"name\": \"Tomato soup\", \"cook_tme\": 30}\n    ]\n  }\n}\n", 4, 31},
+        {"wrong type", "{\n  \"dinners\": {\n    \"soup\": [\n      {\"name\": \"Tomato soup\", \"cook_time\": \"30\"}\n    ]\n  }\n}\n", 4, 44},
+        {"steps not a list", "{\n  \"dinners\": {\n    \"soup\": [\n      {\"name\": \"Tomato soup\",\n       \"steps\": \"boil\"}\n    ]\n  }\n}\n", 5, 17},
+        {"unknown top-level field", "{\n  \"diners\": {}\n}\n", 2, 3},
+        {"trailing data", "{\"dinners\": {}}\n{}\n", 2, 1},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            file := filepath.Join(t.TempDir(), DinnersFileName)
+            if err := os.WriteFile(file, []byte(test.data), 0644); err != nil {
+                t.Fatal(err)
+            }
+            if _, err := decodeDinners(strings.NewReader(test.data)); err == nil {
+                t.Fatal("the streaming decoder took it as it is")
+            }
+            _, loaded := loadDinnersFile(file)
+            strict := decodeStrict(file, []byte(test.data), &DinnerData{})
+
+            var got, want *LoadErrors
+            if !errors.As(loaded, &got) || !errors.As(strict, &want) {
+                t.Fatalf("loading gave %v, strict decoding %v; want *LoadErrors from both", loaded, strict)
+            }
+            if !reflect.DeepEqual(got, want) {
+                t.Fatalf("loading reported\n%v\nstrict decoding\n%v", got, want)
+            }
+            if p := got.Problems[0]; p.Line != test.line || p.Column != test.column {
+                t.Errorf("first problem at line %d, column %d, want line %d, column %d: %s", p.Line, p.Column, test.line, test.column, p.Message)
+            }
+        })
+    }
+}
+
+// A lazy list is written back as it was read, compacted, and survives a
+// round trip through a dinner
+func TestLazyListRoundTrip(t *testing.T) {
+    tests := []struct {
+        name string
+        data string
+        want string
+    }{
+        {"compact", `["Boil the pasta","Stir in the sauce"]`, `["Boil the pasta","Stir in the sauce"]`},
+        {"indented", "[\n  \"Boil the pasta\",\n  \"Stir in the sauce\"\n]", `["Boil the pasta","Stir in the sauce"]`},
+        {"escapes", `["Heat to 180°C","Add \"a pinch\""]`, `["Heat to 180°C","Add \"a pinch\""]`},
+        {"empty", `[]`, `[]`},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var list LazyList
+            if err := json.Unmarshal([]byte(test.data), &list); err != nil {
+                t.Fatal(err)
+            }
+            data, err := json.Marshal(list)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if string(data) != test.want {
+                t.Fatalf("marshaled %s, want %s", data, test.want)
+            }
+
+            var again LazyList
+            if err := json.Unmarshal(data, &again); err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(again.Items(), list.Items()) {
+                t.Errorf("items %q after a round trip, want %q", again.Items(), list.Items())
+            }
+        })
+    }
+
+    dinner := Dinner{Name: "Pasta", Category: "pasta", Steps: NewLazyList([]string{"Boil the pasta", "Stir in the sauce"})}
+    data, err := json.Marshal(dinner)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var decoded Dinner
+    if err := json.Unmarshal(data, &decoded); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(decoded.Steps.Items(), dinner.Steps.Items()) {
+        t.Errorf("steps %q after a round trip, want %q", decoded.Steps.Items(), dinner.Steps.Items())
+    }
+
+    data, err = json.Marshal(Dinner{Name: "Toast", Category: "bread-y"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(data, &fields); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := fields["steps"]; ok {
+        t.Errorf("a dinner without steps is written with %s", data)
+    }
+}
+
+// The strict decoder reports every problem in a document, in order, each
+// where it is
+func TestDecodeStrictReportsEveryProblem(t *testing.T) {
+    tests := []struct {
+        name string
+        data string
+        want []LoadProblem
+    }{
+        {"valid", `{"dinners": {"soup": [{"name": "Tomato soup", "category": "soup"}]}}`, nil},
+        {
+            "typo and wrong type",
+            "{\"dinners\": {\"soup\": [\n  {\"name\": \"Tomato soup\", \"cooktime\": 30},\n  {\"name\": 7}\n]}}",
+            []LoadProblem{
+                {Line: 2, Column: 27, Path: "dinners.soup[0].cooktime", Message: `unknown field "cooktime"`},
+                {Line: 3, Column: 12, Path: "dinners.soup[1].name", Message: "expected string, got number"},
+            },
+        },
+        {
+            "object where a list goes",
+            "{\"dinners\": {\"soup\": {\"name\": \"Tomato soup\"}}}",
+            []LoadProblem{{Line: 1, Column: 22, Path: "dinners.soup", Message: "expected a list, got an object"}},
+        },
+        {
+            "trailing garbage",
+            "{\"dinners\": {}}\ngarbage",
+            []LoadProblem{{Line: 2, Column: 1, Message: "unexpected data after the end of the document"}},
+        },
+        {
+            "cut off",
+            "{\"dinners\": {\"soup\": [",
+            []LoadProblem{{Line: 1, Column: 23, Message: "unexpected EOF"}},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            err := decodeStrict(DinnersFileName, []byte(test.data), &DinnerData{})
+            if test.want == nil {
+                if err != nil {
+                    t.Fatal(err)
+                }
+                return
+            }
+            var loadErrors *LoadErrors
+            if !errors.As(err, &loadErrors) {
+                t.Fatalf("got %v, want *LoadErrors", err)
+            }
+            if !reflect.DeepEqual(loadErrors.Problems, test.want) {
+                t.Fatalf("got\n%v\nwant problems %+v", loadErrors, test.want)
+            }
+            summary := strings.SplitN(loadErrors.Error(), "\n", 2)[0]
+            if !strings.HasPrefix(summary, fmt.Sprint(len(test.want))+" problem") || !strings.HasSuffix(summary, DinnersFileName+":") {
+                t.Errorf("summary %q doesn't count the problems in %s", summary, DinnersFileName)
+            }
+        })
+    }
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+const LockFileName = "dinner-picker.lock"
+
+// lockStaleAfter is how old a lock file must be before it's assumed to be left
+// over from a crashed process; no write holds the lock nearly this long
+const lockStaleAfter = 30 * time.Second
+
+// lockData takes the data directory's lock so writes from a running daemon,
+// the server and the command line don't interleave. It uses a lock file created
+// exclusively, which works the same on Windows where there's no flock.
+func lockData() (unlock func(), err error) {
+    path := dataPath(LockFileName)
+    deadline := time.Now().Add(10 * time.Second)
+    for {
+        file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+        if err == nil {
+            fmt.Fprintf(file, "%d\n", os.Getpid())
+            file.Close()
+            return func() { os.Remove(path) }, nil
+        }
+        if !errors.Is(err, os.ErrExist) {
+            return nil, fmt.Errorf("error locking data: %w", err)
+        }
+
+        if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStaleAfter {
+            os.Remove(path)
+            continue
+        }
+        if time.Now().After(deadline) {
+            holder, _ := os.ReadFile(path)
+            return nil, fmt.Errorf("data is locked by process %s (remove %s if it isn't running)", trimPID(holder), path)
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+}
+
+// trimPID returns the process ID written in a lock file
+func trimPID(data []byte) string {
+    if pid := strings.TrimSpace(string(data)); pid != "" {
+        return pid
+    }
+    return "unknown"
+}
+
+// writeFileAtomic writes a file by way of a temporary file and a rename, so a
+// crash or a reader never sees it half written
+func writeFileAtomic(path string, data []byte) error {
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        os.Remove(tmp.Name())
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        os.Remove(tmp.Name())
+        return err
+    }
+    os.Chmod(tmp.Name(), 0644)
+
+    // On Windows the rename fails while another process briefly has the file
+    // open, so give readers a moment
+    for attempt := 0; ; attempt++ {
+        err = os.Rename(tmp.Name(), path)
+        if err == nil || attempt == 20 {
+            break
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+    if err != nil {
+        os.Remove(tmp.Name())
+    }
+    return err
+}
@@ -1,85 +1,302 @@
 package main
 
 import (
-    "encoding/json"
+    "errors"
+    "flag"
     "fmt"
     "math/rand"
     "os"
+    "sort"
+    "strings"
     "time"
 )
 
 type Dinner struct {
-    Name        string   `json:"name"`
-    Category    string   `json:"category"`
-    Ingredients []string `json:"ingredients"`
+    Name           string       `json:"name"`
+    Category       string       `json:"category"`
+    Ingredients    []Ingredient `json:"ingredients"`
+    CookTime       int          `json:"cook_time,omitempty"`
+    Source         *Source      `json:"source,omitempty"`
+    Tags           []string     `json:"tags,omitempty"`
+    Protein        string       `json:"protein,omitempty"`
+    MakesLeftovers bool         `json:"makes_leftovers,omitempty"`
+    PrepDays       int          `json:"prep_days,omitempty"`
+    Steps          LazyList     `json:"steps,omitzero"`
+    Equipment      []string     `json:"equipment,omitempty"`
+    Origin         *Origin      `json:"origin,omitempty"`
+
+    // Servings is how many the recipe feeds as written; ScalesWell false or
+    // MaxServings mark dishes that fail when scaled up much further
+    Servings    int   `json:"servings,omitempty"`
+    ScalesWell  *bool `json:"scales_well,omitempty"`
+    MaxServings int   `json:"max_servings,omitempty"`
+
+    // VeggieServings estimates the servings of vegetables in one portion
+    VeggieServings float64 `json:"veggie_servings,omitempty"`
+
+    // PreferredDays are the days the dinner suits best ("Sunday", "weekday",
+    // "weekend"); the planner leans towards them but doesn't insist
+    PreferredDays []string `json:"preferred_days,omitempty"`
+
+    // Seasons are when the dinner is at its best, e.g. "winter", "fall";
+    // none means any time of year
+    Seasons []string `json:"seasons,omitempty"`
+
+    // NoCook marks assembly meals like sandwiches or a charcuterie board:
+    // they're kept out of the cooking rotation and only fill no-cook nights
+    NoCook bool `json:"no_cook,omitempty"`
+
+    // Allergens lists what the dinner contains that someone may need to
+    // avoid, e.g. "peanuts", "gluten"
+    Allergens []string `json:"allergens,omitempty"`
+
+    // Cost is what making the recipe as written costs, for budgets; without
+    // it the ingredients' costs are added up
+    Cost float64 `json:"cost,omitempty"`
+
+    // Nutrition is what a portion gives, for the menu's totals and the
+    // planner's calorie limit
+    Nutrition *Nutrition `json:"nutrition,omitempty"`
+}
+
+// AlwaysOKTag marks staples that may be picked again the week after they were eaten
+const AlwaysOKTag = "always-ok"
+
+// HasTag reports whether the dinner carries a tag, ignoring case. The
+// "contains-" tags come from the allergens, listed or found in the
+// ingredients, so "contains-dairy" needs no tagging by hand, and plain
+// "dairy" is the same. Diet tags like "vegetarian" come from the
+// ingredients too.
+func (d Dinner) HasTag(tag string) bool {
+    for _, t := range d.Tags {
+        if strings.EqualFold(t, tag) {
+            return true
+        }
+    }
+    if len(tag) > len(ContainsTagPrefix) && strings.EqualFold(tag[:len(ContainsTagPrefix)], ContainsTagPrefix) {
+        return d.HasAllergen(tag[len(ContainsTagPrefix):])
+    }
+    return d.derivedTag(tag)
+}
+
+// Source records where a recipe came from: a cookbook page, a website, or a person
+type Source struct {
+    Book string `json:"book,omitempty"`
+    Page int    `json:"page,omitempty"`
+    URL  string `json:"url,omitempty"`
+    Note string `json:"note,omitempty"`
+}
+
+// String formats the source for display, e.g. "Simple, p. 112"
+func (s *Source) String() string {
+    if s == nil {
+        return ""
+    }
+    var parts []string
+    if s.Book != "" {
+        book := s.Book
+        if s.Page > 0 {
+            book = fmt.Sprintf("%s, p. %d", s.Book, s.Page)
+        }
+        parts = append(parts, book)
+    }
+    if s.URL != "" {
+        parts = append(parts, s.URL)
+    }
+    if s.Note != "" {
+        parts = append(parts, s.Note)
+    }
+    return strings.Join(parts, " - ")
 }
 
 type DinnerData struct {
     Dinners map[string][]Dinner `json:"dinners"`
+
+    // Renamed maps old category names to new ones so older plans still resolve
+    Renamed map[string]string `json:"renamed_categories,omitempty"`
+
+    // Categories holds each category's display icon and colour
+    Categories map[string]CategoryStyle `json:"categories,omitempty"`
+
+    // IngredientTags adds to or corrects what ingredients are taken to
+    // contain; see ingredienttags.go
+    IngredientTags map[string][]string `json:"ingredient_tags,omitempty"`
+
+    // Include lists more dinners files, or glob patterns, merged over this
+    // one in order; see includes.go
+    Include []string `json:"include,omitempty"`
+
+    // included remembers what came from included files, so saving leaves it
+    // out, and order the categories' order in the file, so saving keeps it
+    included *includedParts
+    order    []string
 }
 
 type WeekState struct {
-    WeekStart    time.Time `json:"week_start"`
-    CurrentWeek  []Dinner  `json:"current_week"`
-    PreviousWeek []Dinner  `json:"previous_week"`
+    WeekStart    time.Time     `json:"week_start"`
+    CurrentWeek  []Dinner      `json:"current_week"`
+    PreviousWeek []Dinner      `json:"previous_week"`
+    Note         string        `json:"note,omitempty"`
+    Plan         *Plan         `json:"plan,omitempty"`
+    History      []HistoryWeek `json:"history,omitempty"`
+
+    // MonthlyHistory is what's left of weeks "history compact" folded away
+    MonthlyHistory []MonthSummary `json:"monthly_history,omitempty"`
+
+    Preferences *Preferences `json:"preferences,omitempty"`
+    JournalSeq  int          `json:"journal_seq,omitempty"`
+
+    // Pins fix dinners to days of this week's or next week's plan, and Bans
+    // keep dinners out of every plan until they're unbanned
+    Pins []Pin    `json:"pins,omitempty"`
+    Bans []string `json:"bans,omitempty"`
+
+    // Sync is what phones changed offline this week, with their stamps
+    Sync *SyncState `json:"sync,omitempty"`
+
+    // LegacySelections is the day-to-dinner map older state files stored
+    // instead of a plan; it's converted on load
+    LegacySelections map[string]Dinner `json:"selections,omitempty"`
 }
 
 const StateFileName = "dinner_state.json"
 
-// LoadDinners reads the JSON file and returns the dinner data
+var weekDays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+// LoadDinners reads the JSON file and the files it includes and returns the
+// dinner data, reporting every problem in a file at once
 func LoadDinners(filename string) (*DinnerData, error) {
+    data, err := loadDinnersFile(filename)
+    if err != nil {
+        return nil, err
+    }
+    if err := data.mergeIncludes(filename); err != nil {
+        return nil, err
+    }
+    return data, nil
+}
+
+// errNoDinners is what loading the catalog answers before there is one
+var errNoDinners = errors.New("no dinners yet")
+
+// loadDinnersFile reads one dinners file, without its includes
+func loadDinnersFile(filename string) (*DinnerData, error) {
+    stream, err := os.Open(filename)
+    if os.IsNotExist(err) {
+        return nil, fmt.Errorf("%w: create %s (see the README for the format) or add some with \"import url\" or \"import recipe-json\"", errNoDinners, filename)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading file: %w", err)
+    }
+    fast, err := decodeDinners(stream)
+    stream.Close()
+    if err == nil {
+        return fast, nil
+    }
+
+    // Something is off, so read it again the slow way to report every problem
     file, err := os.ReadFile(filename)
     if err != nil {
         return nil, fmt.Errorf("error reading file: %w", err)
     }
 
     var data DinnerData
-    err = json.Unmarshal(file, &data)
+    err = decodeStrict(filename, file, &data)
     if err != nil {
-        return nil, fmt.Errorf("error parsing JSON: %w", err)
+        return nil, err
     }
 
     return &data, nil
 }
 
-// LoadState reads the state file, creating a new one if it doesn't exist
-func LoadState() (*WeekState, error) {
-    if _, err := os.Stat(StateFileName); os.IsNotExist(err) {
-        state := &WeekState{
-            WeekStart:    GetCurrentWeekStart(),
-            CurrentWeek:  []Dinner{},
-            PreviousWeek: []Dinner{},
-        }
-        return state, nil
+// SaveDinners writes the dinner data back to file
+func SaveDinners(filename string, data *DinnerData) error {
+    file, err := encodeCatalog(data.own())
+    if err != nil {
+        return fmt.Errorf("error marshaling dinners: %w", err)
     }
 
-    file, err := os.ReadFile(StateFileName)
+    err = writeFileAtomic(filename, file)
     if err != nil {
-        return nil, fmt.Errorf("error reading state file: %w", err)
+        return fmt.Errorf("error writing file: %w", err)
     }
 
-    var state WeekState
-    err = json.Unmarshal(file, &state)
-    if err != nil {
-        return nil, fmt.Errorf("error parsing state JSON: %w", err)
+    return nil
+}
+
+// AllDinners returns every dinner across categories, sorted by category then name
+func (d *DinnerData) AllDinners() []Dinner {
+    var all []Dinner
+    for _, dinners := range d.Dinners {
+        all = append(all, dinners...)
     }
+    sort.Slice(all, func(i, j int) bool {
+        if all[i].Category != all[j].Category {
+            return all[i].Category < all[j].Category
+        }
+        return all[i].Name < all[j].Name
+    })
+    return all
+}
 
-    return &state, nil
+// FindDinner looks up a dinner by name, ignoring case
+func (d *DinnerData) FindDinner(name string) (Dinner, bool) {
+    for _, dinners := range d.Dinners {
+        for _, dinner := range dinners {
+            if strings.EqualFold(dinner.Name, name) {
+                return dinner, true
+            }
+        }
+    }
+    return Dinner{}, false
 }
 
-// SaveState writes the current state to file
-func (s *WeekState) SaveState() error {
-    data, err := json.MarshalIndent(s, "", "  ")
+// LoadState reads the saved state, creating a new one if there isn't any
+func LoadState() (*WeekState, error) {
+    s, err := store()
     if err != nil {
-        return fmt.Errorf("error marshaling state: %w", err)
+        return nil, err
     }
-
-    err = os.WriteFile(StateFileName, data, 0644)
+    state, err := s.ReadState()
+    var damaged *damagedStateError
+    if err != nil && !errors.As(err, &damaged) {
+        return nil, err
+    }
+    // A state that is missing, damaged or behind the journal is replaced by
+    // the journal's latest snapshot
+    state, err = recoverFromJournal(state)
     if err != nil {
-        return fmt.Errorf("error writing state file: %w", err)
+        return nil, err
+    }
+    if state == nil && damaged != nil {
+        return nil, damaged
+    }
+    if state == nil {
+        return &WeekState{
+            WeekStart:    GetCurrentWeekStart(),
+            CurrentWeek:  []Dinner{},
+            PreviousWeek: []Dinner{},
+        }, nil
     }
+    
+    if state.Plan == nil && len(state.LegacySelections) > 0 {
+        state.Plan = planFromSelections(state.WeekStart, state.LegacySelections)
+    }
+    state.LegacySelections = nil
+    state.migratePreviousWeek()
+    state.migrateCurrentWeek()
 
-    return nil
+    return state, nil
+}
+
+// SaveState saves the state. Changes made by commands go through Record
+// so they are journaled first.
+func (s *WeekState) SaveState() error {
+    st, err := store()
+    if err != nil {
+        return err
+    }
+    return st.WriteState(s)
 }
 
 // CheckNewWeek determines if we've moved to a new week and updates state accordingly
@@ -87,9 +304,17 @@ func (s *WeekState) CheckNewWeek() {
     currentWeekStart := GetCurrentWeekStart()
     
     if !s.WeekStart.Equal(currentWeekStart) {
+        // Weeks a rotation left unplanned are kept too, to show whose week it was
+        if !s.Plan.IsEmpty() || (s.Plan != nil && s.Plan.Pattern != "") {
+            s.RecordWeek(historyFromPlan(s.Plan, s.Note))
+        }
         s.PreviousWeek = s.CurrentWeek
         s.CurrentWeek = []Dinner{}
         s.WeekStart = currentWeekStart
+        s.Note = ""
+        s.Plan = nil
+        s.Sync = nil
+        s.dropPins(func(pin Pin) bool { return !pin.WeekStart.Before(currentWeekStart) })
     }
 }
 
@@ -101,15 +326,11 @@ func GetCurrentWeekStart() time.Time {
     return time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, weekStart.Location())
 }
 
-// IsAlreadySelected checks if a dinner was selected this week or last week
-func (s *WeekState) IsAlreadySelected(dinnerName string) bool {
+// IsAlreadySelected checks if a dinner was selected this week. Earlier weeks
+// are handled by TooRecent.
+func (s *WeekState) IsAlreadySelected(candidate Dinner) bool {
     for _, dinner := range s.CurrentWeek {
-        if dinner.Name == dinnerName {
-            return true
-        }
-    }
-    for _, dinner := range s.PreviousWeek {
-        if dinner.Name == dinnerName {
+        if dinner.Name == candidate.Name {
             return true
         }
     }
@@ -121,100 +342,557 @@ func (s *WeekState) AddSelection(dinner Dinner) {
     s.CurrentWeek = append(s.CurrentWeek, dinner)
 }
 
-// PickRandomDinner selects a random dinner from a category
-func PickRandomDinner(dinners *DinnerData, categoryName string) Dinner {
-    dinnerSlice := dinners.Dinners[categoryName]
-    if len(dinnerSlice) == 0 {
-        panic(fmt.Sprintf("No dinners available in category: %s", categoryName))
+// RemoveSelection drops the most recent occurrence of a dinner from the current week
+func (s *WeekState) RemoveSelection(dinner Dinner) {
+    for i := len(s.CurrentWeek) - 1; i >= 0; i-- {
+        if s.CurrentWeek[i].Name == dinner.Name {
+            s.CurrentWeek = append(s.CurrentWeek[:i], s.CurrentWeek[i+1:]...)
+            return
+        }
     }
-    i := rand.Intn(len(dinnerSlice))
-    return dinnerSlice[i]
 }
 
-// pickDinnerFromCategory picks a dinner that hasn't been used recently
-func pickDinnerFromCategory(dinners *DinnerData, state *WeekState, category string) Dinner {
-    for {
-        randomDinner := PickRandomDinner(dinners, category)
-        if !state.IsAlreadySelected(randomDinner.Name) {
-            return randomDinner
+// pickDinner picks a dinner that hasn't been used recently and passes require,
+// preferring ones that also pass prefer. It reports false if nothing passes require.
+func pickDinner(dinners *DinnerData, state *WeekState, category string, require, prefer func(Dinner) bool, choose func([]Dinner) Dinner) (Dinner, bool) {
+    var allowed, preferred []Dinner
+    for _, dinner := range dinners.Dinners[category] {
+        if state.IsAlreadySelected(dinner) || state.IsBanned(dinner) || !require(dinner) {
+            continue
+        }
+        allowed = append(allowed, dinner)
+        if prefer(dinner) {
+            preferred = append(preferred, dinner)
         }
     }
+    if len(preferred) > 0 {
+        return choose(preferred), true
+    }
+    if len(allowed) > 0 {
+        return choose(allowed), true
+    }
+    return Dinner{}, false
+}
+
+// PlanOptions carries the per-week adjustments applied while selecting dinners
+type PlanOptions struct {
+    Modes          map[string]DayMode
+    QuickMinutes   int
+    Bias           CategoryBias
+    Protein        *ProteinRules
+    Days           []string
+    LunchTarget    int
+    Observances    []Observance
+    Fallbacks      map[string][]string
+    Equipment      *EquipmentConfig
+    RepeatDays     int
+    ProjectMinutes int
+    Goals          []Goal
+    Guests         map[string]int
+    Household      int
+    MinVeggies     float64
+    NoCookNights   int
+    LeftoverNights int
+    Schedule       *ScheduleConfig
+    Choose         func([]Dinner) Dinner
+
+    // Budget is the most the week's dinners should cost, priced as Spend
+    // says where they have no cost of their own; 0 for no budget
+    Budget float64
+    Spend  *SpendConfig
+
+    // UseUp are what the week's dinners should use up, from plan --use-up
+    UseUp []Ingredient
+
+    // Season is the season to plan for from --season, "" to go by the date
+    // as Seasons says
+    Season  string
+    Seasons *SeasonConfig
+
+    // Pins are the dinners fixed to days, which are planned as they are
+    // and never replaced
+    Pins map[string]Dinner
+
+    // Keep are the days of the plan so far that stay as they are, outcomes
+    // and all, with only the days missing from it picked
+    Keep map[string]PlanDay
+
+    // Rand is where every random choice comes from, so a plan made from the
+    // same seed comes out the same; nil draws from a fresh random seed
+    Rand *rand.Rand
+}
+
+// fixed reports whether a day was pinned or kept from the plan so far, so
+// nothing may replace its dinner
+func (o PlanOptions) fixed(day string) bool {
+    _, pinned := o.Pins[day]
+    _, kept := o.Keep[day]
+    return pinned || kept
+}
+
+// newRand returns a source of randomness seeded with seed, or with the
+// time when seed is 0
+func newRand(seed int64) *rand.Rand {
+    if seed == 0 {
+        seed = time.Now().UnixNano()
+    }
+    return rand.New(rand.NewSource(seed))
+}
+
+// random returns the options' source of randomness, or a fresh one
+func (o PlanOptions) random() *rand.Rand {
+    if o.Rand == nil {
+        return newRand(0)
+    }
+    return o.Rand
+}
+
+// choose picks one of the candidates with the configured fairness, uniformly by default
+func (o PlanOptions) choose(candidates []Dinner) Dinner {
+    if o.Choose == nil {
+        return candidates[o.random().Intn(len(candidates))]
+    }
+    return o.Choose(candidates)
 }
 
-// SelectWeeklyDinners picks 5 dinners for the week
-func SelectWeeklyDinners(dinners *DinnerData, state *WeekState) map[string]Dinner {
-    selections := make(map[string]Dinner)
+// defaultPlanDays are the days of the default schedule
+var defaultPlanDays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday"}
+
+// SelectWeeklyDinners picks dinners for the planned days (the schedule's,
+// unless opts.Days says otherwise) from the categories the schedule gives
+// them. Days marked skip are left out, days marked quick only get quick
+// dinners where the category has one, shuffled categories are arranged to
+// suit the bias and protein rules are applied.
+func SelectWeeklyDinners(dinners *DinnerData, state *WeekState, opts PlanOptions) *Plan {
+    plan := NewPlan(state.WeekStart)
+    opts.Rand = opts.random()
     
-    // Sunday - always soup
-    sundayDinner := pickDinnerFromCategory(dinners, state, "soup")
-    selections["Sunday"] = sundayDinner
-    state.AddSelection(sundayDinner)
+    // Days that couldn't be filled, and the categories that ran short
+    wanted := 0
+    var short []string
+    unplanned := func(category string) {
+        short = append(short, category)
+    }
     
-    // Monday-Thursday - pick from remaining categories
-    categories := []string{"noodles-rice", "pasta", "bread-y", "Salad"}
-    days := []string{"Monday", "Tuesday", "Wednesday", "Thursday"}
+    pick := func(day, category string) {
+        if entry, ok := opts.Keep[day]; ok {
+            wanted++
+            plan.Put(entry)
+            return
+        }
+        if dinner, ok := opts.Pins[day]; ok {
+            wanted++
+            mode := opts.Modes[day]
+            if mode == DaySkip {
+                mode = DayNormal
+            }
+            plan.Set(day, dinner, mode)
+            plan.Notes = append(plan.Notes, fmt.Sprintf("%s: %s, pinned", day, dinner.Name))
+            return
+        }
+        if opts.Modes[day] == DaySkip {
+            return
+        }
+        wanted++
+        
+        // Categories may have been renamed or emptied since the planner was written
+        resolved, note := dinners.ResolveCategory(category, opts.Fallbacks)
+        if resolved == "" {
+            plan.Notes = append(plan.Notes, fmt.Sprintf("%s: %s, left unplanned", day, note))
+            unplanned(category)
+            return
+        }
+        if note != "" {
+            plan.Notes = append(plan.Notes, fmt.Sprintf("%s: %s", day, note))
+        }
+        category = resolved
+        
+        // Observances are hard rules: rather no dinner than one that breaks them
+        date := plan.WeekStart.AddDate(0, 0, dayIndex(day))
+        active := activeObservances(opts.Observances, date)
+        for _, o := range active {
+            if !o.adHoc {
+                plan.Notes = append(plan.Notes, fmt.Sprintf("%s: %s", day, o.Name))
+            }
+        }
+        outages := opts.Equipment.UnavailableOn(date)
+        for _, outage := range outages {
+            plan.Notes = append(plan.Notes, fmt.Sprintf("%s: no %s", day, outage.schedule().Name))
+        }
+        season := opts.seasonOn(date)
+        permitted := func(dinner Dinner) bool {
+            if opts.Seasons.strict() && !dinner.InSeason(season) {
+                return false
+            }
+            return observancesPermit(opts.Observances, date, dinner) && opts.Equipment.Permits(date, dinner)
+        }
+        require := func(dinner Dinner) bool {
+            return !state.TooRecent(dinner, date, opts.RepeatDays) && permitted(dinner)
+        }
+        
+        counts := ProteinCounts(plan)
+        prefer := func(dinner Dinner) bool {
+            if opts.Modes[day] == DayQuick && !dinner.IsQuick(opts.QuickMinutes) {
+                return false
+            }
+            if opts.Modes[day] == DayProject && !dinner.IsProject(opts.ProjectMinutes) {
+                return false
+            }
+            if people, ok := opts.Guests[day]; ok && !dinner.scalesTo(people, opts.Household) {
+                return false
+            }
+            if prepBlocked(day, dinner.PrepDays, opts.Modes) {
+                return false
+            }
+            // No-cook dinners are for no-cook nights, which are filled after
+            if dinner.NoCook {
+                return false
+            }
+            if !dinner.InSeason(season) {
+                return false
+            }
+            return opts.Protein.allows(dinner, counts)
+        }
+        dinner, ok := pickDinner(dinners, state, category, require, prefer, opts.chooseOn(day))
+        if !ok {
+            fallback, note, err := pickFallback(dinners, state, opts, day, category, dayRules{permitted, require, prefer})
+            if err != nil {
+                plan.Notes = append(plan.Notes, err.Error())
+                unplanned(category)
+                return
+            }
+            plan.Notes = append(plan.Notes, note)
+            dinner = fallback
+        }
+        plan.Set(day, dinner, opts.Modes[day])
+        state.AddSelection(dinner)
+    }
     
-    // Shuffle categories for variety
-    rand.Shuffle(len(categories), func(i, j int) {
-        categories[i], categories[j] = categories[j], categories[i]
+    schedule := opts.schedule()
+    planDays := opts.Days
+    if len(planDays) == 0 {
+        planDays = schedule.planDays()
+    }
+    
+    // Pinned and kept days are planned even if the schedule leaves them
+    // out, and don't draw a category
+    planDays = append([]string(nil), planDays...)
+    for _, day := range weekDays {
+        if opts.fixed(day) && !containsString(planDays, day) {
+            planDays = append(planDays, day)
+        }
+    }
+    sort.Slice(planDays, func(i, j int) bool {
+        return dayIndex(planDays[i]) < dayIndex(planDays[j])
     })
+    var drawn []string
+    for _, day := range planDays {
+        if !opts.fixed(day) {
+            drawn = append(drawn, day)
+        }
+    }
+
+    // Pinned and kept dinners are taken before any day draws, so an earlier
+    // day can't draw one of them too
+    for _, day := range planDays {
+        if entry, ok := opts.Keep[day]; ok && !entry.IsLeftovers() && !state.IsAlreadySelected(entry.Dinner) {
+            state.AddSelection(entry.Dinner)
+        } else if dinner, ok := opts.Pins[day]; ok && !state.IsAlreadySelected(dinner) {
+            state.AddSelection(dinner)
+        }
+    }
     
-    for i, day := range days {
-        dinner := pickDinnerFromCategory(dinners, state, categories[i])
-        selections[day] = dinner
-        state.AddSelection(dinner)
+    // Each day draws from its scheduled categories
+    categories, score, notes := schedule.Assign(dinners, drawn, opts.Bias, opts.Rand)
+    plan.Score = score
+    plan.Notes = append(plan.Notes, notes...)
+    for _, day := range planDays {
+        pick(day, categories[day])
+    }
+    
+    if opts.Protein != nil {
+        opts.Protein.ensureRequiredProtein(dinners, state, plan, opts)
     }
+    ensureLunchCoverage(dinners, state, plan, opts)
+    ensureGoals(dinners, state, plan, opts)
+    ensureVeggies(dinners, state, plan, opts)
+    ensureNoCookNights(dinners, state, plan, opts)
+    ensureLeftoverNights(dinners, state, plan, opts)
+    ensureBudget(dinners, state, plan, opts)
+    ensureUseUp(dinners, state, plan, opts)
     
-    return selections
+    if len(short) > 0 {
+        plan.Notes = append(plan.Notes, fmt.Sprintf("Planned %d of %d days - add more dinners to %s to fill the rest", len(plan.Days), wanted, strings.Join(uniqueStrings(short), ", ")))
+    }
+    return plan
 }
 
-// PrintWeeklyMenu prints the selected dinners with ingredients
-func PrintWeeklyMenu(selections map[string]Dinner) {
-    days := []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday"}
-    
-    fmt.Printf("=== DINNER PLAN FOR WEEK OF %s ===\n\n", time.Now().Format("January 2, 2006"))
+// replaceOneDay swaps a single day's dinner for another from the same category
+// that the want function accepts, trying days in random order. It reports
+// whether a day was replaced.
+func replaceOneDay(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions, want func(day string, current, candidate Dinner) bool) bool {
+    var days []string
+    for _, entry := range plan.Days {
+        if !opts.fixed(entry.Day) {
+            days = append(days, entry.Day)
+        }
+    }
+    opts.random().Shuffle(len(days), func(i, j int) {
+        days[i], days[j] = days[j], days[i]
+    })
     
     for _, day := range days {
-        dinner := selections[day]
-        fmt.Printf("%s - %s\n", day, dinner.Name)
-        for _, ingredient := range dinner.Ingredients {
-            fmt.Printf("  %s\n", ingredient)
+        current, _ := plan.Dinner(day)
+        category, _ := dinners.ResolveCategory(current.Category, opts.Fallbacks)
+        
+        var candidates []Dinner
+        for _, dinner := range dinners.Dinners[category] {
+            if state.IsAlreadySelected(dinner) || state.IsBanned(dinner) || dinner.NoCook != current.NoCook || !want(day, current, dinner) {
+                continue
+            }
+            if opts.Modes[day] == DayQuick && !dinner.IsQuick(opts.QuickMinutes) {
+                continue
+            }
+            if prepBlocked(day, dinner.PrepDays, opts.Modes) {
+                continue
+            }
+            date := plan.WeekStart.AddDate(0, 0, dayIndex(day))
+            if state.TooRecent(dinner, date, opts.RepeatDays) {
+                continue
+            }
+            if !observancesPermit(opts.Observances, date, dinner) || !opts.Equipment.Permits(date, dinner) {
+                continue
+            }
+            // Nothing out of season comes in for a dinner that's in season
+            if season := opts.seasonOn(date); !dinner.InSeason(season) && current.InSeason(season) {
+                continue
+            }
+            candidates = append(candidates, dinner)
+        }
+        if len(candidates) == 0 {
+            continue
+        }
+        
+        replacement := opts.chooseOn(day)(candidates)
+        state.RemoveSelection(current)
+        state.AddSelection(replacement)
+        plan.Replace(day, replacement)
+        return true
+    }
+    return false
+}
+
+// keepsProtein reports whether swapping current for candidate leaves the
+// plan within the protein rules
+func keepsProtein(plan *Plan, opts PlanOptions, current, candidate Dinner) bool {
+    counts := ProteinCounts(plan)
+    if protein := current.MainProtein(); protein != "" {
+        counts[protein]--
+    }
+    return opts.Protein.allows(candidate, counts)
+}
+
+// keepsRules reports whether swapping current for candidate keeps the goals
+// and protein rules as they were, for the passes that swap days for
+// something else: every goal matches both dinners or neither
+func keepsRules(plan *Plan, opts PlanOptions, current, candidate Dinner) bool {
+    for _, goal := range opts.Goals {
+        if goal.matches(current) != goal.matches(candidate) {
+            return false
+        }
+    }
+    return keepsProtein(plan, opts, current, candidate)
+}
+
+// PrintPlanSummary prints the notes that follow the menu: protein spread, lunch coverage, goals, veggies and cost
+func PrintPlanSummary(plan *Plan, config *Config) {
+    printProteinSummary(plan, config.Protein)
+    printLunchCoverage(plan, config.LunchTarget)
+    printGoalSummary(plan, config.Goals)
+    printVeggieSummary(plan, config.MinVeggieServings)
+    printCostSummary(plan, config)
+    printNutritionSummary(plan)
+    printUseUpSummary(plan)
+}
+
+// PrintWeeklyMenu prints the selected dinners with as many ingredients as the menu mode asks for
+func PrintWeeklyMenu(plan *Plan, note string, menu MenuOptions) {
+    textMenu{}.WriteMenu(os.Stdout, plan, note, menu)
+}
+
+// subcommand is a command: its name, any other names it answers to, and a
+// one-line summary for help
+type subcommand struct {
+    name    string
+    aliases []string
+    summary string
+    run     func(args []string) error
+}
+
+// commands lists the subcommands in the order help shows them
+var commands = []subcommand{
+    {"plan", []string{"pick"}, "pick dinners for the week", runPlanCommand},
+    {"show", nil, "re-print this week's plan without re-rolling", runShowCommand},
+    {"swap", []string{"repick"}, "re-roll one day of the plan", runSwapCommand},
+    {"week", nil, "attach a note to the week", runWeekCommand},
+    {"today", nil, "tonight's dinner and tomorrow's prep", runTodayCommand},
+    {"next", nil, "the upcoming dinner, on one line", runNextCommand},
+    {"cooked", nil, "mark a dinner cooked", runCookedCommand},
+    {"review", nil, "record how the week went", runReviewCommand},
+    {"history", nil, "past weeks and what happened", runHistoryCommand},
+    {"preferences", nil, "what ratings and skips have taught it", runPreferencesCommand},
+    {"rate", nil, "give a dinner 1 to 5 stars", runRateCommand},
+    {"template", []string{"templates"}, "list the theme weeks plan --template can use", runTemplateCommand},
+    {"pin", nil, "fix a dinner to a day of the coming plan", runPinCommand},
+    {"unpin", nil, "let the planner choose a pinned day again", runUnpinCommand},
+    {"ban", nil, "keep a dinner out of plans until it's unbanned", runBanCommand},
+    {"unban", nil, "let the planner pick a banned dinner again", runUnbanCommand},
+    {"why-not", nil, "what keeps a dinner off this week's plan", runWhyNotCommand},
+    {"shopping-list", []string{"grocery"}, "this week's ingredients, amounts added up", runShoppingListCommand},
+    {"pantry", nil, "record what's in stock", runPantryCommand},
+    {"staples", nil, "what's always stocked and left off the list", runStaplesCommand},
+    {"recipe", nil, "show one dinner in full", runRecipeCommand},
+    {"search", nil, "find dinners by name, ingredient or source", runSearchCommand},
+    {"list", nil, "the catalog", runListCommand},
+    {"dinner", nil, "add, edit, remove or move a dinner", runDinnerCommand},
+    {"category", nil, "list or rename categories", runCategoryCommand},
+    {"import", nil, "import recipes", runImportCommand},
+    {"export", nil, "export recipes, prep cards or the week as a calendar", runExportCommand},
+    {"export-all", nil, "archive all data", runExportAllCommand},
+    {"import-all", nil, "restore an archive", runImportAllCommand},
+    {"validate", []string{"doctor"}, "check dinners and config for mistakes, and field coverage", runValidateCommand},
+    {"stats", nil, "how recent weeks met the goals, veggies eaten, trends and badges", runStatsCommand},
+    {"budget", nil, "what recent weeks' dinners cost against the budget", runBudgetCommand},
+    {"report", nil, "a year in dinners: most cooked, new favourites, categories and spend", runReportCommand},
+    {"audit", nil, "who changed the plan, and when", runAuditCommand},
+    {"migrate", nil, "move the catalog and state into SQLite, or back to JSON", runMigrateCommand},
+    {"daemon", nil, "send cooking reminders", runDaemonCommand},
+    {"outbox", nil, "messages waiting to be sent, and retrying them", runOutboxCommand},
+    {"serve", nil, "serve the JSON API", runServeCommand},
+    {"profile", []string{"profiles"}, "list, create and delete households' profiles", runProfileCommand},
+    {"demo", nil, "try it out on sample data", runDemoCommand},
+    {"self-update", nil, "install the latest release", runSelfUpdateCommand},
+}
+
+// runCommand dispatches to the named subcommand
+func runCommand(name string, args []string) error {
+    if name == "help" || name == "-h" || name == "--help" {
+        printHelp()
+        return nil
+    }
+    for _, cmd := range commands {
+        if cmd.name == name {
+            return cmd.run(args)
+        }
+        for _, alias := range cmd.aliases {
+            if alias == name {
+                return cmd.run(args)
+            }
+        }
+    }
+    return fmt.Errorf("unknown command: %s (see dinner-picker help)", name)
+}
+
+// takeGlobalFlag removes --name value (or --name=value) from the command
+// line, wherever it comes before a "--", for flags any command takes
+func takeGlobalFlag(args []string, name string) (string, []string, error) {
+    var value string
+    var rest []string
+    for i := 0; i < len(args); i++ {
+        arg := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+        flagged := strings.HasPrefix(args[i], "-")
+        switch {
+        case args[i] == "--":
+            rest = append(rest, args[i:]...)
+            i = len(args)
+        case flagged && arg == name:
+            if i+1 >= len(args) {
+                return "", nil, fmt.Errorf("--%s needs a value", name)
+            }
+            value = args[i+1]
+            i++
+        case flagged && strings.HasPrefix(arg, name+"="):
+            value = arg[strings.Index(arg, "=")+1:]
+        default:
+            rest = append(rest, args[i])
         }
-        fmt.Println()
     }
+    return value, rest, nil
 }
 
+// takeGlobalSwitch takes a flag with no value, like --sandbox, out of the
+// arguments before the subcommand parses them, and reports whether it was there
+func takeGlobalSwitch(args []string, name string) (bool, []string) {
+    var on bool
+    var rest []string
+    for i, arg := range args {
+        if arg == "--" {
+            return on, append(rest, args[i:]...)
+        }
+        if arg == "-"+name || arg == "--"+name {
+            on = true
+            continue
+        }
+        rest = append(rest, arg)
+    }
+    return on, rest
+}
+
+// printHelp lists the subcommands
+func printHelp() {
+    fmt.Println("usage: dinner-picker <command> [flags]")
+    fmt.Println()
+    for _, cmd := range commands {
+        name := cmd.name
+        if len(cmd.aliases) > 0 {
+            name += " (" + strings.Join(cmd.aliases, ", ") + ")"
+        }
+        fmt.Printf("  %-22s %s\n", name, cmd.summary)
+    }
+    fmt.Println()
+    fmt.Println("With no command, plan. Run a command with -h for its flags.")
+    fmt.Println("Add --profile <name> to use another profile's dinners, plans and config,")
+    fmt.Println("and --data-dir <dir> to keep all files in one directory of your choosing.")
+    fmt.Println("Add --sandbox to try a command on a copy of your data and see what it would change.")
+}
+
+// main runs the command and exits with status 1 if it fails
 func main() {
-    // Seed random number generator
-    rand.Seed(time.Now().UnixNano())
-    
-    // Load dinner data
-    dinners, err := LoadDinners("dinners.json")
-    if err != nil {
-        fmt.Printf("Error loading dinners: %v\n", err)
-        return
+    if err := run(os.Args[1:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+        logf("Error: %v\n", err)
+        os.Exit(1)
     }
-    
-    // Load state
-    state, err := LoadState()
-    if err != nil {
-        fmt.Printf("Error loading state: %v\n", err)
-        return
+}
+
+// run applies the global flags and runs the command args name, plan if none
+func run(args []string) error {
+    dataDir, args, err := takeGlobalFlag(args, "data-dir")
+    var profile string
+    if err == nil {
+        profile, args, err = takeGlobalFlag(args, "profile")
+    }
+    if err == nil {
+        baseDirs, err = resolveDataDirs(dataDir)
+    }
+    if err == nil {
+        err = useProfile(profile)
+    }
+    var trial bool
+    trial, args = takeGlobalSwitch(args, "sandbox")
+    var box *sandbox
+    if err == nil && trial {
+        box, err = openSandbox()
     }
-    
-    // Check if it's a new week
-    state.CheckNewWeek()
-    
-    // Select dinners for the week
-    selections := SelectWeeklyDinners(dinners, state)
-    
-    // Save updated state
-    err = state.SaveState()
     if err != nil {
-        fmt.Printf("Error saving state: %v\n", err)
-        return
+        return err
+    }
+    if box != nil {
+        defer box.close(messages)
     }
     
-    // Print the menu
-    PrintWeeklyMenu(selections)
+    command := "plan"
+    if len(args) > 0 {
+        command, args = args[0], args[1:]
+    }
+    return runCommand(command, args)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Menu display modes
+const (
+    MenuNames = "names"
+    MenuShort = "short"
+    MenuFull  = "full"
+)
+
+// shortMenuIngredients is how many ingredients the short menu lists per dinner
+const shortMenuIngredients = 3
+
+// MenuOptions controls how much of each dinner the menu prints
+type MenuOptions struct {
+    Mode    string
+    Staples []string
+    Styles  CategoryStyles
+}
+
+// NewMenuOptions picks the flag's mode over the config's, defaulting to full,
+// and reads the category styles from the catalog
+func NewMenuOptions(flagMode string, config *Config) (MenuOptions, error) {
+    mode := flagMode
+    if mode == "" {
+        mode = config.MenuMode
+    }
+    if mode == "" {
+        mode = MenuFull
+    }
+    if mode != MenuNames && mode != MenuShort && mode != MenuFull {
+        return MenuOptions{}, fmt.Errorf("unknown menu mode %q (want names, short or full)", mode)
+    }
+    return MenuOptions{Mode: mode, Staples: config.Staples, Styles: loadStyles()}, nil
+}
+
+// isStaple reports whether an ingredient is on the staples list. "olive oil"
+// counts as the staple "oil".
+func isStaple(ingredient string, staples []string) bool {
+    item := normalizeIngredient(ingredient)
+    for _, staple := range staples {
+        staple = normalizeIngredient(staple)
+        if staple != "" && (item == staple || strings.HasSuffix(item, " "+staple)) {
+            return true
+        }
+    }
+    return false
+}
+
+// menuLines returns the ingredient lines to print for a dinner in the given mode
+func (o MenuOptions) menuLines(dinner Dinner) []string {
+    if o.Mode == MenuNames {
+        return nil
+    }
+
+    var main, staples []string
+    for _, ingredient := range dinner.Ingredients {
+        if isStaple(ingredient.Name, o.Staples) {
+            staples = append(staples, ingredient.String())
+        } else {
+            main = append(main, ingredient.String())
+        }
+    }
+
+    if o.Mode == MenuShort {
+        if len(main) > shortMenuIngredients {
+            more := len(main) - shortMenuIngredients
+            return append(main[:shortMenuIngredients:shortMenuIngredients], fmt.Sprintf("(+%d more)", more))
+        }
+        return main
+    }
+
+    if len(staples) > 0 {
+        main = append(main, "+ staples: "+strings.Join(staples, ", "))
+    }
+    return main
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+)
+
+const MenuCardVersion = 1
+
+// MenuCard is a week's plan with every dinner in full, ingredients and steps
+// included, so someone else can cook the same week from their own catalog
+type MenuCard struct {
+    Version    int           `json:"version"`
+    ExportedAt time.Time     `json:"exported_at"`
+    WeekStart  string        `json:"week_start"`
+    Note       string        `json:"note,omitempty"`
+    Days       []MenuCardDay `json:"days"`
+}
+
+// MenuCardDay is one evening on a menu card; a leftovers day names the day
+// whose batch it eats again
+type MenuCardDay struct {
+    Day           string `json:"day"`
+    Dinner        Dinner `json:"dinner"`
+    LeftoversFrom string `json:"leftovers_from,omitempty"`
+}
+
+// NewMenuCard describes a week's plan as a menu card. Dinners are taken from
+// the catalog where they're still in it, for their steps, and lose where they
+// came from, which means nothing on another machine.
+func NewMenuCard(plan *Plan, note string, dinners *DinnerData) *MenuCard {
+    card := &MenuCard{
+        Version:    MenuCardVersion,
+        ExportedAt: time.Now(),
+        WeekStart:  plan.WeekStart.Format("2006-01-02"),
+        Note:       note,
+    }
+    for _, entry := range plan.Days {
+        dinner := entry.Dinner
+        if current, ok := dinners.FindDinner(dinner.Name); ok {
+            dinner = current
+        }
+        dinner.Origin = nil
+        card.Days = append(card.Days, MenuCardDay{Day: entry.Day, Dinner: dinner, LeftoversFrom: entry.LeftoversFrom})
+    }
+    return card
+}
+
+// LoadMenuCard reads a menu card file and checks its version and days
+func LoadMenuCard(filename string) (*MenuCard, error) {
+    file, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error reading menu card: %w", err)
+    }
+    var card MenuCard
+    if err := json.Unmarshal(file, &card); err != nil {
+        return nil, fmt.Errorf("error parsing menu card JSON: %w", err)
+    }
+    if card.Version < 1 || card.Version > MenuCardVersion {
+        return nil, fmt.Errorf("unsupported menu card version %d", card.Version)
+    }
+    if len(card.Days) == 0 {
+        return nil, fmt.Errorf("menu card has no days")
+    }
+    for i, entry := range card.Days {
+        day, ok := normalizeDay(entry.Day)
+        if !ok {
+            return nil, fmt.Errorf("menu card: unknown day %q", entry.Day)
+        }
+        card.Days[i].Day = day
+        if entry.Dinner.Name == "" && entry.LeftoversFrom == "" {
+            return nil, fmt.Errorf("menu card: no dinner on %s", day)
+        }
+    }
+    return &card, nil
+}
+
+// runExportCardCommand handles "plan export-card [file]", writing this week's
+// plan as a menu card to a file or stdout
+func runExportCardCommand(args []string) error {
+    if len(args) > 1 {
+        return fmt.Errorf("usage: dinner-picker plan export-card [file]")
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    if state.Plan.IsEmpty() {
+        return fmt.Errorf("no dinners planned for this week yet")
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+
+    data, err := json.MarshalIndent(NewMenuCard(state.Plan, state.Note, dinners), "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling menu card: %w", err)
+    }
+    if len(args) == 0 {
+        fmt.Println(string(data))
+        return nil
+    }
+    if err := os.WriteFile(args[0], data, 0644); err != nil {
+        return fmt.Errorf("error writing menu card: %w", err)
+    }
+    logf("Exported the week of %s to %s\n", state.WeekStart.Format("January 2"), args[0])
+    return nil
+}
+
+// askCardDinner asks what to do with a dinner from a menu card that isn't in
+// the catalog: add it, cook one of ours instead, or leave the day out. It
+// returns the dinner to plan, whether it's new, and false to skip the day.
+func askCardDinner(in *bufio.Reader, dinners *DinnerData, dinner Dinner) (Dinner, bool, bool, error) {
+    for {
+        answer, err := prompt(in, fmt.Sprintf("%s isn't in your dinners: [a]dd it, cook one of [y]ours instead, or [s]kip the day? [a]: ", dinner.Name))
+        if err != nil {
+            return Dinner{}, false, false, err
+        }
+        switch strings.ToLower(answer) {
+        case "", "a", "add":
+            if category, ok := dinners.hasCategory(dinner.Category); ok {
+                dinner.Category = category
+                return dinner, true, true, nil
+            }
+            category, err := askCategory(in, dinners, dinner, dinners.SuggestCategory(dinner))
+            if err != nil || category == "" {
+                return Dinner{}, false, false, err
+            }
+            dinner.Category = category
+            return dinner, true, true, nil
+        case "y", "yours":
+            name, err := prompt(in, "  Which of yours? ")
+            if err != nil {
+                return Dinner{}, false, false, err
+            }
+            if ours, ok := dinners.FindDinner(name); ok {
+                return ours, false, true, nil
+            }
+            fmt.Printf("  No dinner named %q\n", name)
+        case "s", "skip":
+            return Dinner{}, false, false, nil
+        default:
+            logf("Please answer a, y or s\n")
+        }
+    }
+}
+
+// runImportCardCommand handles "plan import-card <file> [--add-all]", making
+// a menu card this week's plan. Dinners the catalog has are planned as we
+// know them; others are added, swapped for one of ours or left out, as asked.
+func runImportCardCommand(args []string) error {
+    fs := flag.NewFlagSet("plan import-card", flag.ContinueOnError)
+    addAll := fs.Bool("add-all", false, "add the card's new dinners to the catalog without asking")
+    output := fs.String("output", "text", "menu format: text, json or markdown")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 {
+        return fmt.Errorf("usage: dinner-picker plan import-card <file> [--add-all]")
+    }
+    formatter, err := menuFormatter(*output)
+    if err != nil {
+        return err
+    }
+    card, err := LoadMenuCard(positional[0])
+    if err != nil {
+        return err
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    if !state.Plan.IsEmpty() {
+        for _, entry := range state.Plan.Days {
+            if entry.Outcome != "" {
+                return fmt.Errorf("%s is already marked %s this week, so the plan can't be replaced", entry.Day, entry.Outcome)
+            }
+        }
+    }
+
+    // Map the card's dinners onto ours, once each
+    in := bufio.NewReader(os.Stdin)
+    interactive := !*addAll && stdinIsTerminal()
+    mapped := make(map[string]Dinner)
+    skipped := make(map[string]bool)
+    var added []Dinner
+    for _, entry := range card.Days {
+        name := entry.Dinner.Name
+        if entry.LeftoversFrom != "" || name == "" {
+            continue
+        }
+        if _, ok := mapped[name]; ok || skipped[name] {
+            continue
+        }
+        if ours, ok := dinners.FindDinner(name); ok {
+            mapped[name] = ours
+            continue
+        }
+        dinner, isNew, keep := entry.Dinner, true, true
+        switch {
+        case interactive:
+            dinner, isNew, keep, err = askCardDinner(in, dinners, entry.Dinner)
+            if errors.Is(err, io.EOF) {
+                return fmt.Errorf("no answer for %s, rerun with --add-all", name)
+            }
+            if err != nil {
+                return err
+            }
+        case *addAll:
+            if category, ok := dinners.hasCategory(dinner.Category); ok {
+                dinner.Category = category
+            } else if suggestion := dinners.SuggestCategory(dinner); suggestion != "" {
+                fmt.Printf("Filing %s under %s\n", dinner.Name, suggestion)
+                dinner.Category = suggestion
+            } else {
+                logf("Note: %s is in a new category %s\n", dinner.Name, dinner.Category)
+            }
+        default:
+            return fmt.Errorf("%s isn't in your dinners; rerun at a terminal to choose, or with --add-all", name)
+        }
+        if !keep {
+            skipped[name] = true
+            continue
+        }
+        if isNew {
+            dinner.Origin = importedOrigin("plan import-card", "")
+            added = append(added, dinner)
+        }
+        mapped[name] = dinner
+    }
+    if names, _ := importDinners(dinners, added); len(names) > 0 {
+        if err := saveCatalog(dinners); err != nil {
+            return err
+        }
+        for _, name := range names {
+            fmt.Printf("Added %s\n", name)
+        }
+    }
+
+    // Lay the card's days on this week, leftovers following their batch
+    for _, dinner := range state.Plan.Dinners() {
+        state.RemoveSelection(dinner)
+    }
+    plan := NewPlan(state.WeekStart)
+    for _, entry := range card.Days {
+        if entry.LeftoversFrom != "" {
+            continue
+        }
+        dinner, ok := mapped[entry.Dinner.Name]
+        if !ok {
+            fmt.Printf("Leaving %s open: %s was skipped\n", entry.Day, entry.Dinner.Name)
+            continue
+        }
+        plan.Set(entry.Day, dinner, DayNormal)
+        state.AddSelection(dinner)
+    }
+    for _, entry := range card.Days {
+        if entry.LeftoversFrom == "" {
+            continue
+        }
+        batch, ok := plan.Dinner(entry.LeftoversFrom)
+        if !ok {
+            fmt.Printf("Leaving %s open: its leftovers come from %s, which isn't planned\n", entry.Day, entry.LeftoversFrom)
+            continue
+        }
+        plan.Set(entry.Day, batch, DayNormal)
+        for i := range plan.Days {
+            if plan.Days[i].Day == entry.Day {
+                plan.Days[i].LeftoversFrom = entry.LeftoversFrom
+            }
+        }
+    }
+    if plan.IsEmpty() {
+        return fmt.Errorf("nothing left to plan from %s", positional[0])
+    }
+    plan.Notes = append(plan.Notes, fmt.Sprintf("From a menu card for the week of %s", card.WeekStart))
+    annotateServings(dinners, plan, nil, config.Household)
+    if state.Plan != nil {
+        plan.Revision = state.Plan.Revision
+    }
+    plan.Revision++
+    state.Plan = plan
+    if state.Note == "" {
+        state.Note = card.Note
+    }
+    if err := state.Record("plan import-card", planSummary(plan)+" from "+positional[0]); err != nil {
+        return err
+    }
+
+    menu, err := NewMenuOptions("", config)
+    if err != nil {
+        return err
+    }
+    return printPlan(formatter, state, config, menu)
+}
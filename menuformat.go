@@ -0,0 +1,202 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// MenuFormatter writes the week's menu in one output format. Adding a format
+// means implementing it and listing it in menuFormats.
+type MenuFormatter interface {
+    WriteMenu(w io.Writer, plan *Plan, note string, menu MenuOptions) error
+}
+
+// menuFormats are the formats --output accepts
+var menuFormats = map[string]MenuFormatter{
+    "text":     textMenu{},
+    "json":     jsonMenu{},
+    "markdown": markdownMenu{},
+    "md":       markdownMenu{},
+}
+
+// menuFormatter looks up an --output format, defaulting to text
+func menuFormatter(name string) (MenuFormatter, error) {
+    if name == "" {
+        name = "text"
+    }
+    formatter, ok := menuFormats[strings.ToLower(name)]
+    if !ok {
+        var names []string
+        for name := range menuFormats {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        return nil, fmt.Errorf("unknown output format %q (want %s)", name, strings.Join(names, ", "))
+    }
+    return formatter, nil
+}
+
+// printPlan prints the menu in the chosen format; the text format is followed
+// by the plan summary, the others are meant for other programs and stand alone
+func printPlan(formatter MenuFormatter, state *WeekState, config *Config, menu MenuOptions) error {
+    if err := formatter.WriteMenu(os.Stdout, state.Plan, state.Note, menu); err != nil {
+        return err
+    }
+    if _, ok := formatter.(textMenu); ok {
+        PrintPlanSummary(state.Plan, config)
+        if state.Plan.Seed != 0 {
+            fmt.Printf("Seed: %d (plan --force --seed %d plans this week the same way again)\n", state.Plan.Seed, state.Plan.Seed)
+        }
+    }
+    return nil
+}
+
+// textMenu is the menu as the terminal shows it
+type textMenu struct{}
+
+func (textMenu) WriteMenu(w io.Writer, plan *Plan, note string, menu MenuOptions) error {
+    fmt.Fprintf(w, "=== DINNER PLAN FOR WEEK OF %s ===\n", plan.WeekStart.Format("January 2, 2006"))
+    if note != "" {
+        fmt.Fprintf(w, "Note: %s\n", note)
+    }
+    for _, n := range plan.Notes {
+        fmt.Fprintln(w, n)
+    }
+    fmt.Fprintln(w)
+
+    for _, entry := range plan.Days {
+        dinner := entry.Dinner
+        fmt.Fprintf(w, "%s - %s%s\n", entry.Day, menu.Styles.Paint(dinner.Category, menu.Styles.Label(dinner.Category, entry.title())), noCookMark(dinner))
+        if menu.Mode == MenuNames {
+            continue
+        }
+        if entry.IsLeftovers() {
+            fmt.Fprintf(w, "  from %s's batch\n", entry.LeftoversFrom)
+            writeNutrition(w, dinner)
+            fmt.Fprintln(w)
+            continue
+        }
+        for _, line := range menu.menuLines(dinner) {
+            fmt.Fprintf(w, "  %s\n", line)
+        }
+        writeNutrition(w, dinner)
+        fmt.Fprintln(w)
+    }
+    if menu.Mode == MenuNames {
+        fmt.Fprintln(w)
+    }
+    return nil
+}
+
+// noCookMark sets no-cook nights apart in the menu
+func noCookMark(dinner Dinner) string {
+    if dinner.NoCook {
+        return " (no cook)"
+    }
+    return ""
+}
+
+// jsonMenuDay is one day's dinner in the JSON menu
+type jsonMenuDay struct {
+    Date        string   `json:"date"`
+    Dinner      string   `json:"dinner"`
+    Category    string   `json:"category"`
+    NoCook      bool     `json:"no_cook,omitempty"`
+    Leftovers   string   `json:"leftovers_from,omitempty"`
+    Ingredients []string `json:"ingredients,omitempty"`
+    Servings    int      `json:"servings,omitempty"`
+    Mode        DayMode  `json:"mode,omitempty"`
+    Holiday     string   `json:"holiday,omitempty"`
+    Nutrition   *Nutrition `json:"nutrition,omitempty"`
+}
+
+// jsonMenu is the menu as JSON: the week and a map of day to dinner. It
+// lists every ingredient whatever the menu mode, leaving out only staples.
+type jsonMenu struct{}
+
+func (jsonMenu) WriteMenu(w io.Writer, plan *Plan, note string, menu MenuOptions) error {
+    out := struct {
+        WeekStart  string                 `json:"week_start"`
+        Note       string                 `json:"note,omitempty"`
+        Notes      []string               `json:"notes,omitempty"`
+        Seed       int64                  `json:"seed,omitempty"`
+        Selections map[string]jsonMenuDay `json:"selections"`
+    }{
+        WeekStart:  plan.WeekStart.Format("2006-01-02"),
+        Note:       note,
+        Notes:      plan.Notes,
+        Seed:       plan.Seed,
+        Selections: make(map[string]jsonMenuDay),
+    }
+    for _, entry := range plan.Days {
+        day := jsonMenuDay{
+            Date:      entry.Date.Format("2006-01-02"),
+            Dinner:    entry.Dinner.Name,
+            Category:  entry.Dinner.Category,
+            NoCook:    entry.Dinner.NoCook,
+            Leftovers: entry.LeftoversFrom,
+            Servings:  entry.Servings,
+            Mode:      entry.Mode,
+            Holiday:   entry.Holiday,
+            Nutrition: entry.Dinner.Nutrition,
+        }
+        for _, ingredient := range entry.Dinner.Ingredients {
+            if !isStaple(ingredient.Name, menu.Staples) && !entry.IsLeftovers() {
+                day.Ingredients = append(day.Ingredients, ingredient.String())
+            }
+        }
+        out.Selections[entry.Day] = day
+    }
+    data, err := json.MarshalIndent(out, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling menu: %w", err)
+    }
+    _, err = fmt.Fprintln(w, string(data))
+    return err
+}
+
+// markdownMenu is the menu as a Markdown table, for pasting into notes apps
+type markdownMenu struct{}
+
+// markdownCell escapes text for a table cell
+func markdownCell(text string) string {
+    return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
+
+func (markdownMenu) WriteMenu(w io.Writer, plan *Plan, note string, menu MenuOptions) error {
+    fmt.Fprintf(w, "## Dinner plan for the week of %s\n\n", plan.WeekStart.Format("January 2, 2006"))
+    if note != "" {
+        fmt.Fprintf(w, "> %s\n\n", markdownCell(note))
+    }
+    for _, n := range plan.Notes {
+        fmt.Fprintf(w, "- %s\n", n)
+    }
+    if len(plan.Notes) > 0 {
+        fmt.Fprintln(w)
+    }
+
+    header, rule := "| Day | Date | Dinner | Category |", "|---|---|---|---|"
+    if menu.Mode != MenuNames {
+        header, rule = header+" Ingredients |", rule+"---|"
+    }
+    fmt.Fprintln(w, header)
+    fmt.Fprintln(w, rule)
+    for _, entry := range plan.Days {
+        dinner := entry.Dinner
+        row := fmt.Sprintf("| %s | %s | %s | %s |", entry.Day, entry.Date.Format("Jan 2"),
+            markdownCell(menu.Styles.Label(dinner.Category, entry.title())+noCookMark(dinner)), markdownCell(dinner.Category))
+        if menu.Mode != MenuNames {
+            lines := menu.menuLines(dinner)
+            if entry.IsLeftovers() {
+                lines = []string{"from " + entry.LeftoversFrom + "'s batch"}
+            }
+            row += " " + markdownCell(strings.Join(lines, ", ")) + " |"
+        }
+        fmt.Fprintln(w, row)
+    }
+    return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+)
+
+// countNoCook counts the plan's no-cook nights
+func countNoCook(plan *Plan) int {
+    n := 0
+    for _, entry := range plan.Days {
+        if entry.Dinner.NoCook {
+            n++
+        }
+    }
+    return n
+}
+
+// ensureNoCookNights swaps days for no-cook dinners, from any category, until
+// the plan has as many no-cook nights as the config asks for. Busy days go
+// first; project days and days with guests are left alone. Like the other
+// swaps it keeps goals, protein rules and seasons as they were.
+func ensureNoCookNights(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    want := opts.NoCookNights - countNoCook(plan)
+    if want <= 0 {
+        return
+    }
+    var days []string
+    for _, entry := range plan.Days {
+        _, guests := opts.Guests[entry.Day]
+        if !entry.Dinner.NoCook && opts.Modes[entry.Day] != DayProject && !guests && !opts.fixed(entry.Day) {
+            days = append(days, entry.Day)
+        }
+    }
+    opts.random().Shuffle(len(days), func(i, j int) {
+        days[i], days[j] = days[j], days[i]
+    })
+    sort.SliceStable(days, func(i, j int) bool {
+        return opts.Modes[days[i]] == DayQuick && opts.Modes[days[j]] != DayQuick
+    })
+
+    for _, day := range days {
+        if want == 0 {
+            return
+        }
+        date := plan.WeekStart.AddDate(0, 0, dayIndex(day))
+        season := opts.seasonOn(date)
+        current, _ := plan.Dinner(day)
+        var candidates []Dinner
+        for _, dinner := range dinners.AllDinners() {
+            if !dinner.NoCook || state.IsAlreadySelected(dinner) || state.IsBanned(dinner) || state.TooRecent(dinner, date, opts.RepeatDays) {
+                continue
+            }
+            if !observancesPermit(opts.Observances, date, dinner) || !opts.Equipment.Permits(date, dinner) {
+                continue
+            }
+            // Out of season only when seasons aren't strict and the day's
+            // dinner is out of season too, as with any swap
+            if !dinner.InSeason(season) && (opts.Seasons.strict() || current.InSeason(season)) {
+                continue
+            }
+            if !keepsRules(plan, opts, current, dinner) {
+                continue
+            }
+            candidates = append(candidates, dinner)
+        }
+        if len(candidates) == 0 {
+            continue
+        }
+        replacement := opts.chooseOn(day)(candidates)
+        state.RemoveSelection(current)
+        state.AddSelection(replacement)
+        plan.Replace(day, replacement)
+        plan.Notes = append(plan.Notes, fmt.Sprintf("%s: no-cook night", day))
+        want--
+    }
+    if want > 0 {
+        plan.Notes = append(plan.Notes, fmt.Sprintf("Planned %d of %d no-cook nights - mark more dinners \"no_cook\": true", opts.NoCookNights-want, opts.NoCookNights))
+    }
+}
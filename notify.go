@@ -0,0 +1,55 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// TelegramConfig is a bot that posts messages to a chat
+type TelegramConfig struct {
+    BotToken string `json:"bot_token"`
+    ChatID   string `json:"chat_id"`
+}
+
+// telegramAPI is the Bot API base URL
+var telegramAPI = "https://api.telegram.org"
+
+// Send posts a message to the chat
+func (t *TelegramConfig) Send(text string) error {
+    if t == nil || t.BotToken == "" || t.ChatID == "" {
+        return fmt.Errorf("telegram needs bot_token and chat_id")
+    }
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.PostForm(telegramAPI+"/bot"+t.BotToken+"/sendMessage", url.Values{
+        "chat_id": {t.ChatID},
+        "text":    {text},
+    })
+    if err != nil {
+        // The URL contains the token, so don't echo it back
+        return fmt.Errorf("error sending telegram message: request failed")
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("error sending telegram message: %s", resp.Status)
+    }
+    return nil
+}
+
+// planMessage is a short plain-text version of the plan for chat messages
+func planMessage(plan *Plan, note string, styles CategoryStyles) string {
+    lines := []string{"Dinners for the week of " + plan.WeekStart.Format("January 2")}
+    if note != "" {
+        lines = append(lines, note)
+    }
+    for _, entry := range plan.Days {
+        line := entry.Day + ": " + styles.Label(entry.Dinner.Category, entry.title())
+        if entry.Holiday != "" {
+            line += " (" + entry.Holiday + ")"
+        }
+        lines = append(lines, line)
+    }
+    return strings.Join(lines, "\n")
+}
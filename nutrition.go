@@ -0,0 +1,367 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Nutrition is what one portion of a dinner gives: energy in kcal, and
+// protein, carbs and fat in grams
+type Nutrition struct {
+    Calories float64 `json:"calories"`
+    Protein  float64 `json:"protein,omitempty"`
+    Carbs    float64 `json:"carbs,omitempty"`
+    Fat      float64 `json:"fat,omitempty"`
+}
+
+// String writes the nutrition on one line, e.g. "520 kcal, 32 g protein,
+// 60 g carbs, 18 g fat", leaving out what's unknown
+func (n Nutrition) String() string {
+    parts := []string{fmt.Sprintf("%.0f kcal", n.Calories)}
+    for _, macro := range []struct {
+        name  string
+        grams float64
+    }{{"protein", n.Protein}, {"carbs", n.Carbs}, {"fat", n.Fat}} {
+        if macro.grams > 0 {
+            parts = append(parts, fmt.Sprintf("%.0f g %s", macro.grams, macro.name))
+        }
+    }
+    return strings.Join(parts, ", ")
+}
+
+// plus returns the two added up
+func (n Nutrition) plus(other Nutrition) Nutrition {
+    return Nutrition{
+        Calories: n.Calories + other.Calories,
+        Protein:  n.Protein + other.Protein,
+        Carbs:    n.Carbs + other.Carbs,
+        Fat:      n.Fat + other.Fat,
+    }
+}
+
+// times returns the nutrition multiplied by factor, rounded to whole numbers
+func (n Nutrition) times(factor float64) Nutrition {
+    return Nutrition{
+        Calories: math.Round(n.Calories * factor),
+        Protein:  math.Round(n.Protein * factor),
+        Carbs:    math.Round(n.Carbs * factor),
+        Fat:      math.Round(n.Fat * factor),
+    }
+}
+
+// parseNutrition reads "calories,protein,carbs,fat" as given to --nutrition;
+// the macros may be left off
+func parseNutrition(value string) (*Nutrition, error) {
+    fields := strings.Split(value, ",")
+    if len(fields) > 4 {
+        return nil, fmt.Errorf("--nutrition is calories,protein,carbs,fat")
+    }
+    var numbers [4]float64
+    for i, field := range fields {
+        n, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+        if err != nil || n < 0 {
+            return nil, fmt.Errorf("--nutrition: invalid number %q", field)
+        }
+        numbers[i] = n
+    }
+    if numbers[0] == 0 {
+        return nil, fmt.Errorf("--nutrition needs the calories")
+    }
+    return &Nutrition{Calories: numbers[0], Protein: numbers[1], Carbs: numbers[2], Fat: numbers[3]}, nil
+}
+
+// weekNutrition adds up a portion of every planned day's dinner, leftover
+// days included as they're eaten too, and lists the dinners with no
+// nutrition
+func weekNutrition(plan *Plan) (Nutrition, int, []string) {
+    var total Nutrition
+    counted := 0
+    var unknown []string
+    for _, entry := range plan.Days {
+        if entry.Outcome == OutcomeSkipped {
+            continue
+        }
+        if entry.Dinner.Nutrition == nil {
+            unknown = append(unknown, entry.Dinner.Name)
+            continue
+        }
+        total = total.plus(*entry.Dinner.Nutrition)
+        counted++
+    }
+    return total, counted, uniqueStrings(unknown)
+}
+
+// printNutritionSummary prints the week's nutrition per person, once any
+// planned dinner has some
+func printNutritionSummary(plan *Plan) {
+    total, counted, unknown := weekNutrition(plan)
+    if counted == 0 {
+        return
+    }
+    line := fmt.Sprintf("Nutrition per person: %s over %d dinners, %.0f kcal a day", total, counted, total.Calories/float64(counted))
+    if len(unknown) > 0 {
+        line += ", " + insufficientData("nutrition", unknown)
+    }
+    fmt.Println(line)
+}
+
+// Food is an entry of a nutrition database: what 100 g of it gives, and
+// what one weighs for ingredients counted rather than weighed, like eggs
+type Food struct {
+    Name      string
+    Per100g   Nutrition
+    GramsEach float64
+}
+
+// nutritionColumns are the headers a nutrition database's columns go by
+var nutritionColumns = map[string][]string{
+    "name":       {"name", "food", "description", "product"},
+    "calories":   {"calories", "kcal", "energy", "energy_kcal", "energy (kcal)"},
+    "protein":    {"protein", "protein_g", "protein (g)", "proteins"},
+    "carbs":      {"carbs", "carbohydrate", "carbohydrates", "carbohydrate_g", "carbohydrate (g)"},
+    "fat":        {"fat", "fat_g", "fat (g)", "total fat", "lipids"},
+    "grams_each": {"grams_each", "unit_weight", "piece_g", "grams per piece"},
+}
+
+// LoadFoods reads a nutrition database: a CSV file with a header row naming
+// the columns, or a JSON list of objects, each with a name and its calories,
+// protein, carbs and fat per 100 g, and optionally grams_each
+func LoadFoods(filename string) ([]Food, error) {
+    file, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error reading nutrition database: %w", err)
+    }
+    var rows []map[string]string
+    if strings.EqualFold(filepath.Ext(filename), ".json") {
+        var objects []map[string]any
+        if err := json.Unmarshal(file, &objects); err != nil {
+            return nil, fmt.Errorf("error reading nutrition database: %w", err)
+        }
+        for _, object := range objects {
+            row := make(map[string]string)
+            for key, value := range object {
+                row[strings.ToLower(key)] = fmt.Sprint(value)
+            }
+            rows = append(rows, row)
+        }
+    } else {
+        reader := csv.NewReader(strings.NewReader(string(file)))
+        reader.FieldsPerRecord = -1
+        records, err := reader.ReadAll()
+        if err != nil {
+            return nil, fmt.Errorf("error reading nutrition database: %w", err)
+        }
+        if len(records) == 0 {
+            return nil, fmt.Errorf("%s is empty", filename)
+        }
+        header := records[0]
+        for _, record := range records[1:] {
+            row := make(map[string]string)
+            for i, value := range record {
+                if i < len(header) {
+                    row[strings.ToLower(strings.TrimSpace(header[i]))] = strings.TrimSpace(value)
+                }
+            }
+            rows = append(rows, row)
+        }
+    }
+
+    column := func(row map[string]string, field string) string {
+        for _, name := range nutritionColumns[field] {
+            if value, ok := row[name]; ok {
+                return value
+            }
+        }
+        return ""
+    }
+    number := func(row map[string]string, field string) float64 {
+        n, _ := strconv.ParseFloat(strings.ReplaceAll(column(row, field), ",", "."), 64)
+        return n
+    }
+    var foods []Food
+    for _, row := range rows {
+        name := column(row, "name")
+        if name == "" || column(row, "calories") == "" {
+            continue
+        }
+        foods = append(foods, Food{
+            Name:      name,
+            Per100g:   Nutrition{Calories: number(row, "calories"), Protein: number(row, "protein"), Carbs: number(row, "carbs"), Fat: number(row, "fat")},
+            GramsEach: number(row, "grams_each"),
+        })
+    }
+    if len(foods) == 0 {
+        return nil, fmt.Errorf("no foods with a name and calories in %s", filename)
+    }
+    return foods, nil
+}
+
+// foodIndex finds an ingredient's food by the words in its name, trying the
+// longest food names first so "coconut milk" wins over "milk"
+type foodIndex struct {
+    foods []Food
+    words []string
+}
+
+// newFoodIndex indexes foods by their names' words
+func newFoodIndex(foods []Food) *foodIndex {
+    sorted := append([]Food(nil), foods...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        return len(matchWords(sorted[i].Name)) > len(matchWords(sorted[j].Name))
+    })
+    index := &foodIndex{foods: sorted}
+    for _, food := range sorted {
+        index.words = append(index.words, matchWords(food.Name))
+    }
+    return index
+}
+
+// find returns the food an ingredient is, if any
+func (f *foodIndex) find(ingredient Ingredient) (Food, bool) {
+    words := matchWords(ingredient.Name)
+    for i, food := range f.foods {
+        if strings.Contains(words, f.words[i]) {
+            return food, true
+        }
+    }
+    return Food{}, false
+}
+
+// grams returns how much of the food an ingredient is: its weight, or its
+// volume as if it were water, or its count times the food's grams_each
+func (ingredient Ingredient) grams(food Food) (float64, bool) {
+    amount, unit := ingredient.base()
+    switch {
+    case amount == 0:
+        return 0, false
+    case unit == "g" || unit == "ml":
+        return amount, true
+    case unit == "" && food.GramsEach > 0:
+        return amount * food.GramsEach, true
+    }
+    return 0, false
+}
+
+// nutritionFrom works out a portion's nutrition from the dinner's ingredients,
+// or says which ingredients it couldn't: ones not in the database, and ones
+// with no amount it can weigh. Optional ingredients are left out.
+func (d Dinner) nutritionFrom(index *foodIndex, household int) (*Nutrition, []string) {
+    var total Nutrition
+    var missing []string
+    for _, ingredient := range d.Ingredients {
+        if ingredient.Optional {
+            continue
+        }
+        food, ok := index.find(ingredient)
+        if !ok {
+            missing = append(missing, ingredient.Name+" (not in the database)")
+            continue
+        }
+        grams, ok := ingredient.grams(food)
+        if !ok {
+            missing = append(missing, ingredient.Name+" (no amount in g or ml)")
+            continue
+        }
+        total = total.plus(food.Per100g.times(grams / 100))
+    }
+    if len(missing) > 0 {
+        return nil, missing
+    }
+    portion := total.times(1 / float64(d.servings(household)))
+    return &portion, nil
+}
+
+// importNutrition handles "import nutrition <file> [--overwrite]", working
+// out each dinner's nutrition from its ingredients with a nutrition database
+func importNutrition(args []string) error {
+    fs := flag.NewFlagSet("import nutrition", flag.ContinueOnError)
+    overwrite := fs.Bool("overwrite", false, "replace nutrition dinners already have")
+    verbose := fs.Bool("verbose", false, "list what kept each skipped dinner from being worked out")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 {
+        return fmt.Errorf("usage: dinner-picker import nutrition <file.csv|file.json> [--overwrite] [--verbose]")
+    }
+    foods, err := LoadFoods(positional[0])
+    if err != nil {
+        return err
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    index := newFoodIndex(foods)
+
+    var updated, kept int
+    skipped := make(map[string][]string)
+    for _, category := range dinners.categoryOrder() {
+        for i := range dinners.Dinners[category] {
+            dinner := &dinners.Dinners[category][i]
+            if dinner.Nutrition != nil && !*overwrite {
+                kept++
+                continue
+            }
+            if _, _, err := dinners.editable(dinner.Name); err != nil {
+                continue
+            }
+            nutrition, missing := dinner.nutritionFrom(index, config.Household)
+            if nutrition == nil {
+                skipped[dinner.Name] = missing
+                continue
+            }
+            dinner.Nutrition = nutrition
+            dinner.markEdited()
+            updated++
+            fmt.Printf("%s: %s a portion\n", dinner.Name, nutrition)
+        }
+    }
+    if updated > 0 {
+        if err := saveCatalog(dinners); err != nil {
+            return err
+        }
+    }
+
+    fmt.Printf("Worked out the nutrition of %d dinner(s) from %d foods", updated, len(foods))
+    if kept > 0 {
+        fmt.Printf(", kept %d that had it (--overwrite replaces it)", kept)
+    }
+    fmt.Println()
+    if len(skipped) == 0 {
+        return nil
+    }
+    var names []string
+    for name := range skipped {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    if !*verbose {
+        fmt.Printf("Skipped %d dinner(s) with ingredients it couldn't count: %s (--verbose says which)\n", len(names), strings.Join(names, ", "))
+        return nil
+    }
+    for _, name := range names {
+        fmt.Printf("Skipped %s: %s\n", name, strings.Join(skipped[name], ", "))
+    }
+    return nil
+}
+
+// writeNutrition writes a dinner's nutrition line for the menu, if it has one
+func writeNutrition(w io.Writer, dinner Dinner) {
+    if dinner.Nutrition != nil {
+        fmt.Fprintf(w, "  %s\n", dinner.Nutrition)
+    }
+}
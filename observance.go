@@ -0,0 +1,134 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Observance is a dietary rule that applies on certain dates or weekdays, like
+// meatless Fridays or a vegetarian Lent
+type Observance struct {
+    Name               string   `json:"name"`
+    From               string   `json:"from,omitempty"`
+    To                 string   `json:"to,omitempty"`
+    Days               []string `json:"days,omitempty"`
+    ExcludeIngredients []string `json:"exclude_ingredients,omitempty"`
+    ExcludeProteins    []string `json:"exclude_proteins,omitempty"`
+    RequireTags        []string `json:"require_tags,omitempty"`
+    ExcludeTags        []string `json:"exclude_tags,omitempty"`
+    ExcludeAllergens   []string `json:"exclude_allergens,omitempty"`
+
+    // MaxCalories caps a portion's calories; dinners with no nutrition
+    // aren't held to it
+    MaxCalories float64 `json:"max_calories,omitempty"`
+
+    // adHoc marks the rule plan or swap were given on the command line, which
+    // is noted once for the week rather than on every day
+    adHoc bool
+}
+
+// validate checks the date range and weekday names
+func (o Observance) validate() error {
+    for _, date := range []string{o.From, o.To} {
+        if date == "" {
+            continue
+        }
+        if _, err := time.Parse("2006-01-02", date); err != nil {
+            return fmt.Errorf("observance %q: invalid date %q, want YYYY-MM-DD", o.Name, date)
+        }
+    }
+    for _, day := range o.Days {
+        if _, ok := normalizeDay(day); !ok {
+            return fmt.Errorf("observance %q: unknown day %q", o.Name, day)
+        }
+    }
+    if o.MaxCalories < 0 {
+        return fmt.Errorf("observance %q: max_calories can't be negative", o.Name)
+    }
+    return nil
+}
+
+// ActiveOn reports whether the rule applies on a date. From and To are inclusive
+// and either may be left open.
+func (o Observance) ActiveOn(date time.Time) bool {
+    day := date.Format("2006-01-02")
+    if o.From != "" && day < o.From {
+        return false
+    }
+    if o.To != "" && day > o.To {
+        return false
+    }
+    if len(o.Days) == 0 {
+        return true
+    }
+    for _, d := range o.Days {
+        if name, ok := normalizeDay(d); ok && name == date.Weekday().String() {
+            return true
+        }
+    }
+    return false
+}
+
+// Permits reports whether a dinner is compatible with the rule
+func (o Observance) Permits(dinner Dinner) bool {
+    return o.Conflict(dinner) == ""
+}
+
+// Conflict says how a dinner breaks the rule, e.g. "contains peanuts", or
+// returns "" when it doesn't
+func (o Observance) Conflict(dinner Dinner) string {
+    for _, protein := range o.ExcludeProteins {
+        if strings.EqualFold(protein, dinner.MainProtein()) {
+            return "its main protein is " + dinner.MainProtein()
+        }
+    }
+    for _, excluded := range o.ExcludeIngredients {
+        excluded = normalizeIngredient(excluded)
+        for _, ingredient := range dinner.Ingredients {
+            if excluded != "" && strings.Contains(normalizeIngredient(ingredient.Name), excluded) {
+                return "it has " + ingredient.Name
+            }
+        }
+    }
+    for _, tag := range o.RequireTags {
+        if !dinner.HasTag(tag) {
+            return "it isn't tagged " + tag
+        }
+    }
+    for _, tag := range o.ExcludeTags {
+        if dinner.HasTag(tag) {
+            return "it's tagged " + tag
+        }
+    }
+    for _, allergen := range o.ExcludeAllergens {
+        if dinner.HasAllergen(allergen) {
+            return "it contains " + allergen
+        }
+    }
+    if o.MaxCalories > 0 && dinner.Nutrition != nil && dinner.Nutrition.Calories > o.MaxCalories {
+        return fmt.Sprintf("it has %.0f kcal a portion", dinner.Nutrition.Calories)
+    }
+    return ""
+}
+
+// activeObservances returns the rules in force on a date
+func activeObservances(observances []Observance, date time.Time) []Observance {
+    var active []Observance
+    for _, o := range observances {
+        if o.ActiveOn(date) {
+            active = append(active, o)
+        }
+    }
+    return active
+}
+
+// observancesPermit reports whether a dinner satisfies every rule active on a date
+func observancesPermit(observances []Observance, date time.Time, dinner Dinner) bool {
+    for _, o := range activeObservances(observances, date) {
+        if !o.Permits(dinner) {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "time"
+)
+
+const OutboxFileName = "dinner_outbox.json"
+
+// weekTelegram is the destination name of the "week" routine's Telegram step,
+// which can be a different chat from the reminders'
+const weekTelegram = "week-telegram"
+
+// sendRetries are the pauses between the attempts made straight away, before
+// a message goes into the outbox
+var sendRetries = []time.Duration{2 * time.Second, 5 * time.Second}
+
+// maxOutboxAttempts is how often a queued message is tried before it's given up on
+const maxOutboxAttempts = 8
+
+// OutboxMessage is a message that couldn't be delivered yet
+type OutboxMessage struct {
+    ID          int       `json:"id"`
+    To          string    `json:"to"`
+    Text        string    `json:"text"`
+    Queued      time.Time `json:"queued"`
+    Attempts    int       `json:"attempts"`
+    NextAttempt time.Time `json:"next_attempt"`
+    LastError   string    `json:"last_error,omitempty"`
+
+    // GaveUp is set once every attempt has failed; "outbox send" still tries it
+    GaveUp bool `json:"gave_up,omitempty"`
+}
+
+// Outbox is every message waiting to go out, kept on disk so none is lost
+// when the network is down or the process stops
+type Outbox struct {
+    NextID   int             `json:"next_id"`
+    Messages []OutboxMessage `json:"messages"`
+}
+
+// LoadOutbox reads the outbox file, returning an empty outbox if it doesn't exist
+func LoadOutbox() (*Outbox, error) {
+    file, err := os.ReadFile(dataPath(OutboxFileName))
+    if os.IsNotExist(err) {
+        return &Outbox{NextID: 1}, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading outbox file: %w", err)
+    }
+    var outbox Outbox
+    if err := json.Unmarshal(file, &outbox); err != nil {
+        return nil, fmt.Errorf("error parsing outbox JSON: %w", err)
+    }
+    return &outbox, nil
+}
+
+// SaveOutbox writes the outbox file
+func (o *Outbox) SaveOutbox() error {
+    data, err := json.MarshalIndent(o, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling outbox: %w", err)
+    }
+    if err := writeFileAtomic(dataPath(OutboxFileName), data); err != nil {
+        return fmt.Errorf("error writing outbox file: %w", err)
+    }
+    return nil
+}
+
+// updateOutbox loads the outbox under the data lock, changes it and saves it.
+// Nothing is sent while the lock is held, since a send can take a while.
+func updateOutbox(change func(o *Outbox)) error {
+    unlock, err := lockData()
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    outbox, err := LoadOutbox()
+    if err != nil {
+        return err
+    }
+    change(outbox)
+    return outbox.SaveOutbox()
+}
+
+// retryDelay is how long to wait after a message's nth failed attempt:
+// a minute, doubling each time up to an hour
+func retryDelay(attempts int) time.Duration {
+    delay := time.Minute
+    for i := 1; i < attempts && delay < time.Hour; i++ {
+        delay *= 2
+    }
+    if delay > time.Hour {
+        delay = time.Hour
+    }
+    return delay
+}
+
+// destination finds the configured notifier a message is addressed to
+func (c *Config) destination(name string) (Notifier, bool) {
+    if sandboxed {
+        if _, ok := c.configuredDestination(name); ok {
+            return sandboxNotifier{name}, true
+        }
+        return nil, false
+    }
+    return c.configuredDestination(name)
+}
+
+// configuredDestination finds the notifier as configured
+func (c *Config) configuredDestination(name string) (Notifier, bool) {
+    if name == weekTelegram {
+        if c.Week == nil || c.Week.Telegram == nil {
+            return nil, false
+        }
+        return &c.Week.Telegram.TelegramConfig, true
+    }
+    for _, notifier := range c.Notifiers() {
+        if notifier.Name() == name {
+            return notifier, true
+        }
+    }
+    return nil, false
+}
+
+// deliver sends a message to a destination, retrying a couple of times
+// straight away. If it still can't get through the message goes into the
+// outbox for the daemon, the next "week" run or "outbox send" to retry, and
+// only an error saving it there is returned.
+func deliver(config *Config, to, text string) error {
+    notifier, ok := config.destination(to)
+    if !ok {
+        return fmt.Errorf("%s isn't configured", to)
+    }
+    err := notifier.Send(text)
+    for _, pause := range sendRetries {
+        if err == nil {
+            return nil
+        }
+        time.Sleep(pause)
+        err = notifier.Send(text)
+    }
+    if err == nil {
+        return nil
+    }
+
+    attempts := len(sendRetries) + 1
+    now := time.Now()
+    if err := updateOutbox(func(o *Outbox) {
+        if o.NextID == 0 {
+            o.NextID = 1
+        }
+        o.Messages = append(o.Messages, OutboxMessage{
+            ID:          o.NextID,
+            To:          to,
+            Text:        text,
+            Queued:      now,
+            Attempts:    attempts,
+            NextAttempt: now.Add(retryDelay(attempts)),
+            LastError:   err.Error(),
+        })
+        o.NextID++
+    }); err != nil {
+        return fmt.Errorf("couldn't send to %s or queue the message: %w", to, err)
+    }
+    warnf("%s: %v; queued in the outbox to try again from %s", to, err, now.Add(retryDelay(attempts)).Format("15:04"))
+    return nil
+}
+
+// flushOutbox tries the queued messages that are due, or every one with all,
+// and returns how many went and how many are still waiting
+func flushOutbox(config *Config, now time.Time, all bool) (sent, waiting int, err error) {
+    outbox, err := LoadOutbox()
+    if err != nil {
+        return 0, 0, err
+    }
+    results := make(map[int]error)
+    for _, message := range outbox.Messages {
+        if !all && (message.GaveUp || message.NextAttempt.After(now)) {
+            continue
+        }
+        notifier, ok := config.destination(message.To)
+        if !ok {
+            results[message.ID] = fmt.Errorf("%s isn't configured any more", message.To)
+            continue
+        }
+        results[message.ID] = notifier.Send(message.Text)
+    }
+    if len(results) == 0 {
+        return 0, len(outbox.Messages), nil
+    }
+
+    // Messages queued while these were being sent are kept as they are
+    err = updateOutbox(func(o *Outbox) {
+        var kept []OutboxMessage
+        for _, message := range o.Messages {
+            result, tried := results[message.ID]
+            if tried && result == nil {
+                sent++
+                continue
+            }
+            if tried {
+                message.Attempts++
+                message.LastError = result.Error()
+                message.NextAttempt = now.Add(retryDelay(message.Attempts))
+                if message.Attempts >= maxOutboxAttempts && !message.GaveUp {
+                    message.GaveUp = true
+                    warnf("gave up sending message %d to %s after %d attempts: %s", message.ID, message.To, message.Attempts, message.LastError)
+                }
+            }
+            kept = append(kept, message)
+        }
+        o.Messages = kept
+        waiting = len(kept)
+    })
+    return sent, waiting, err
+}
+
+// runOutboxCommand handles "outbox [list|send|clear]": what's waiting to be
+// delivered, trying all of it now, or dropping it
+func runOutboxCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker outbox [list|send|clear]")
+    action := "list"
+    if len(args) > 0 {
+        action = args[0]
+    }
+    if len(args) > 1 {
+        return usage
+    }
+
+    switch action {
+    case "list":
+        outbox, err := LoadOutbox()
+        if err != nil {
+            return err
+        }
+        if len(outbox.Messages) == 0 {
+            fmt.Println("Nothing waiting to be sent")
+            return nil
+        }
+        for _, message := range outbox.Messages {
+            next := "next try " + message.NextAttempt.Format("Mon 15:04")
+            if message.GaveUp {
+                next = "given up"
+            }
+            fmt.Printf("%d  to %s, queued %s, %d attempts, %s\n", message.ID, message.To, message.Queued.Format("Mon 15:04"), message.Attempts, next)
+            fmt.Printf("   %s\n", message.LastError)
+        }
+        return nil
+
+    case "send":
+        config, err := LoadConfig()
+        if err != nil {
+            return err
+        }
+        sent, waiting, err := flushOutbox(config, time.Now(), true)
+        if err != nil {
+            return err
+        }
+        fmt.Printf("Sent %d, %d still waiting\n", sent, waiting)
+        if waiting > 0 {
+            return fmt.Errorf("%d messages couldn't be sent, see dinner-picker outbox", waiting)
+        }
+        return nil
+
+    case "clear":
+        dropped := 0
+        if err := updateOutbox(func(o *Outbox) {
+            dropped = len(o.Messages)
+            o.Messages = nil
+        }); err != nil {
+            return err
+        }
+        fmt.Printf("Dropped %d messages\n", dropped)
+        return nil
+    }
+    return usage
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+)
+
+// messages is where the tool talks to whoever runs it: warnings, errors,
+// questions and progress like "Wrote shopping.txt". They go to stderr so
+// stdout carries only what a command produces, and "plan --output json" or
+// "shopping-list" can be piped without anything mixed in.
+var messages io.Writer = os.Stderr
+
+// logf prints a progress or status message
+func logf(format string, args ...any) {
+    fmt.Fprintf(messages, format, args...)
+}
+
+// warnf prints a warning, one line
+func warnf(format string, args ...any) {
+    fmt.Fprintf(messages, "Warning: "+format+"\n", args...)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const PantryFileName = "pantry.json"
+
+// Pantry is what's already in the cupboards
+type Pantry struct {
+    Items []PantryItem `json:"items"`
+}
+
+// PantryItem is one stocked ingredient. Items without a unit are counted
+// (3 onions); items with a unit (500 g) are used up by dinners that say how
+// much they need in a unit that converts (kg, g, l, ml, tbsp, tsp).
+type PantryItem struct {
+    Name     string  `json:"name"`
+    Quantity float64 `json:"quantity"`
+    Unit     string  `json:"unit,omitempty"`
+    Expires  string  `json:"expires,omitempty"`
+}
+
+// LoadPantry reads the pantry file, returning an empty pantry if it doesn't exist
+func LoadPantry() (*Pantry, error) {
+    file, err := os.ReadFile(dataPath(PantryFileName))
+    if os.IsNotExist(err) {
+        return &Pantry{}, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading pantry file: %w", err)
+    }
+
+    var pantry Pantry
+    err = json.Unmarshal(file, &pantry)
+    if err != nil {
+        return nil, fmt.Errorf("error parsing pantry JSON: %w", err)
+    }
+
+    return &pantry, nil
+}
+
+// SavePantry writes the pantry file
+func (p *Pantry) SavePantry() error {
+    data, err := json.MarshalIndent(p, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling pantry: %w", err)
+    }
+
+    err = writeFileAtomic(dataPath(PantryFileName), data)
+    if err != nil {
+        return fmt.Errorf("error writing pantry file: %w", err)
+    }
+
+    return nil
+}
+
+// sameIngredient matches ingredient names ignoring case and a plural "s"/"es"
+func sameIngredient(a, b string) bool {
+    forms := func(s string) []string {
+        s = normalizeIngredient(s)
+        return []string{s, strings.TrimSuffix(s, "s"), strings.TrimSuffix(s, "es")}
+    }
+    for _, x := range forms(a) {
+        for _, y := range forms(b) {
+            if x == y {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// Find returns the pantry item for an ingredient, or nil
+func (p *Pantry) Find(name string) *PantryItem {
+    for i := range p.Items {
+        if sameIngredient(p.Items[i].Name, name) {
+            return &p.Items[i]
+        }
+    }
+    return nil
+}
+
+// Consume takes what the dinner uses out of the pantry and returns what was
+// used: counted items go down by the dinner's quantity (one if it doesn't
+// say), measured items by its quantity once converted to the pantry's unit
+func (p *Pantry) Consume(dinner Dinner) []string {
+    var used []string
+    for _, ingredient := range dinner.Ingredients {
+        item := p.Find(ingredient.Name)
+        if item == nil || item.Quantity <= 0 {
+            continue
+        }
+        amount, ok := item.uses(ingredient)
+        if !ok {
+            continue
+        }
+        item.Quantity = math.Max(item.Quantity-amount, 0)
+        used = append(used, fmt.Sprintf("%s (%s left)", item.Name, strings.TrimSpace(formatQuantity(item.Quantity)+" "+item.Unit)))
+    }
+    return used
+}
+
+// uses returns how much of the item an ingredient takes, in the item's unit,
+// or false when the two can't be compared (a measured item and an ingredient
+// without an amount, or units that don't convert)
+func (item PantryItem) uses(ingredient Ingredient) (float64, bool) {
+    if item.Unit == "" {
+        if ingredient.Unit != "" {
+            return 0, false
+        }
+        if ingredient.Quantity == 0 {
+            return 1, true
+        }
+        return ingredient.Quantity, true
+    }
+    if ingredient.Quantity == 0 {
+        return 0, false
+    }
+    needed, unit := ingredient.base()
+    per, stocked := Ingredient{Quantity: 1, Unit: item.Unit}.base()
+    if unit != stocked {
+        return 0, false
+    }
+    return needed / per, true
+}
+
+// Stock says how much of a shopping list item the pantry holds, e.g. "1 kg",
+// and whether that's enough for everything the week needs. Stock that can't
+// be compared with what's needed is never enough, and an item needed without
+// an amount is covered by any stock at all.
+func (p *Pantry) Stock(g *GroceryItem) (string, bool) {
+    if p == nil {
+        return "", false
+    }
+    item := p.Find(g.Key)
+    if item == nil || item.Quantity <= 0 {
+        return "", false
+    }
+    stock := strings.TrimSpace(formatQuantity(item.Quantity) + " " + item.Unit)
+    needed := 0.0
+    for unit, amount := range g.amounts {
+        uses, ok := item.uses(Ingredient{Quantity: amount, Unit: unit})
+        if !ok {
+            return stock, false
+        }
+        needed += uses
+    }
+    return stock, needed <= item.Quantity
+}
+
+// formatQuantity prints whole numbers without decimals
+func formatQuantity(q float64) string {
+    return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// runCookedCommand handles "cooked [day]", marking a planned dinner as cooked and using up pantry stock
+func runCookedCommand(args []string) error {
+    day := time.Now().Weekday().String()
+    if len(args) > 0 {
+        d, ok := normalizeDay(args[0])
+        if !ok {
+            return fmt.Errorf("unknown day: %s", args[0])
+        }
+        day = d
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    if entry, ok := state.Plan.Entry(day); ok && entry.Outcome == OutcomeCooked {
+        fmt.Printf("%s's %s is already marked cooked\n", day, entry.Dinner.Name)
+        return nil
+    }
+    pantry, err := LoadPantry()
+    if err != nil {
+        return err
+    }
+    entry, used, err := state.markCooked(day, pantry)
+    if err != nil {
+        return err
+    }
+    if err := state.Record("cooked", fmt.Sprintf("%s: %s", day, entry.Dinner.Name)); err != nil {
+        return err
+    }
+    if err := pantry.SavePantry(); err != nil {
+        return err
+    }
+
+    fmt.Printf("Marked %s's %s as cooked\n", day, entry.Dinner.Name)
+    for _, item := range used {
+        fmt.Printf("  used %s\n", item)
+    }
+    return nil
+}
+
+// runPantryCommand handles "pantry list", "pantry set <item> <quantity> [unit]",
+// "pantry add <item> <amount> [unit]" after shopping, "pantry adjust <item>
+// <+/-amount>" for corrections, "pantry remove <item>" and "pantry consume
+// <dinner>" for a dinner cooked off the plan
+func runPantryCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker pantry list | set <item> <quantity> [unit] | add <item> <amount> [unit] | adjust <item> <+/-amount> | remove <item> | consume <dinner>")
+    if len(args) == 0 {
+        return usage
+    }
+
+    pantry, err := LoadPantry()
+    if err != nil {
+        return err
+    }
+
+    switch args[0] {
+    case "list":
+        if len(pantry.Items) == 0 {
+            fmt.Println("The pantry is empty")
+            return nil
+        }
+        items := append([]PantryItem(nil), pantry.Items...)
+        sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+        for _, item := range items {
+            line := fmt.Sprintf("%s: %s", item.Name, formatQuantity(item.Quantity))
+            if item.Unit != "" {
+                line += " " + item.Unit
+            }
+            if item.Expires != "" {
+                line += " (expires " + item.Expires + ")"
+            }
+            fmt.Println(line)
+        }
+        return nil
+
+    case "add":
+        if len(args) < 3 || len(args) > 4 {
+            return usage
+        }
+        amount, err := strconv.ParseFloat(args[2], 64)
+        if err != nil || amount <= 0 {
+            return fmt.Errorf("invalid amount %q", args[2])
+        }
+        unit := ""
+        if len(args) == 4 {
+            unit = args[3]
+        }
+
+        item := pantry.Find(args[1])
+        if item == nil {
+            pantry.Items = append(pantry.Items, PantryItem{Name: args[1], Unit: unit})
+            item = &pantry.Items[len(pantry.Items)-1]
+        } else if unit != item.Unit {
+            // Bought in another unit: add it in the unit the pantry counts in
+            converted, ok := item.uses(Ingredient{Name: args[1], Quantity: amount, Unit: unit})
+            if !ok {
+                return fmt.Errorf("the pantry has %s in %q, which %q doesn't convert to", item.Name, item.Unit, unit)
+            }
+            amount = converted
+        }
+        item.Quantity += amount
+
+        if err := pantry.SavePantry(); err != nil {
+            return err
+        }
+        fmt.Println(strings.TrimSpace(fmt.Sprintf("%s: %s %s", item.Name, formatQuantity(item.Quantity), item.Unit)))
+        return nil
+
+    case "remove":
+        if len(args) != 2 {
+            return usage
+        }
+        item := pantry.Find(args[1])
+        if item == nil {
+            return fmt.Errorf("no %s in the pantry", args[1])
+        }
+        name := item.Name
+        for i := range pantry.Items {
+            if &pantry.Items[i] == item {
+                pantry.Items = append(pantry.Items[:i:i], pantry.Items[i+1:]...)
+                break
+            }
+        }
+        if err := pantry.SavePantry(); err != nil {
+            return err
+        }
+        fmt.Printf("Removed %s from the pantry\n", name)
+        return nil
+
+    case "consume":
+        name := strings.TrimSpace(strings.Join(args[1:], " "))
+        if name == "" {
+            return usage
+        }
+        dinners, err := loadCatalog()
+        if err != nil {
+            return err
+        }
+        dinner, ok := dinners.FindDinner(name)
+        if !ok {
+            return fmt.Errorf("no dinner named %q", name)
+        }
+        used := pantry.Consume(dinner)
+        if err := pantry.SavePantry(); err != nil {
+            return err
+        }
+        if len(used) == 0 {
+            fmt.Printf("%s used nothing the pantry has\n", dinner.Name)
+            return nil
+        }
+        fmt.Printf("Took %s out of the pantry\n", dinner.Name)
+        for _, item := range used {
+            fmt.Printf("  used %s\n", item)
+        }
+        return nil
+
+    case "set", "adjust":
+        if len(args) < 3 || (args[0] == "adjust" && len(args) > 3) || len(args) > 4 {
+            return usage
+        }
+        amount, err := strconv.ParseFloat(args[2], 64)
+        if err != nil {
+            return fmt.Errorf("invalid amount %q", args[2])
+        }
+
+        item := pantry.Find(args[1])
+        if item == nil {
+            pantry.Items = append(pantry.Items, PantryItem{Name: args[1]})
+            item = &pantry.Items[len(pantry.Items)-1]
+        }
+        if args[0] == "set" {
+            item.Quantity = amount
+            if len(args) == 4 {
+                item.Unit = args[3]
+            }
+        } else {
+            item.Quantity += amount
+        }
+        if item.Quantity < 0 {
+            item.Quantity = 0
+        }
+
+        if err := pantry.SavePantry(); err != nil {
+            return err
+        }
+        fmt.Println(strings.TrimSpace(fmt.Sprintf("%s: %s %s", item.Name, formatQuantity(item.Quantity), item.Unit)))
+        return nil
+    }
+    return usage
+}
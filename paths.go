@@ -0,0 +1,267 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "runtime"
+    "sort"
+    "strings"
+)
+
+const DinnersFileName = "dinners.json"
+
+// appDirName is the directory the tool keeps its files in under each base directory
+const appDirName = "dinner-picker"
+
+// Dirs are where files are kept: config.json in Config; the catalog, pantry
+// and database in Data; and the week's state, journal, outbox and lock,
+// which change as the tool runs, in State
+type Dirs struct {
+    Config string
+    Data   string
+    State  string
+}
+
+// singleDir keeps every file in one directory, as $DINNER_PICKER_HOME and --data-dir do
+func singleDir(dir string) Dirs {
+    return Dirs{Config: dir, Data: dir, State: dir}
+}
+
+// join returns the directories with elem added to each
+func (d Dirs) join(elem ...string) Dirs {
+    sub := func(dir string) string {
+        return filepath.Join(append([]string{dir}, elem...)...)
+    }
+    return Dirs{Config: sub(d.Config), Data: sub(d.Data), State: sub(d.State)}
+}
+
+// all returns each distinct directory once
+func (d Dirs) all() []string {
+    var dirs []string
+    for _, dir := range []string{d.Config, d.Data, d.State} {
+        if !containsDir(dirs, dir) {
+            dirs = append(dirs, dir)
+        }
+    }
+    return dirs
+}
+
+// containsDir reports whether dirs has dir, however it's spelled
+func containsDir(dirs []string, dir string) bool {
+    for _, d := range dirs {
+        if filepath.Clean(d) == filepath.Clean(dir) {
+            return true
+        }
+    }
+    return false
+}
+
+// dirFor returns the directory a data file belongs in
+func (d Dirs) dirFor(name string) string {
+    switch name {
+    case ConfigFileName:
+        return d.Config
+    case StateFileName, JournalFileName, OutboxFileName, LockFileName:
+        return d.State
+    }
+    return d.Data
+}
+
+// String lists the directories, once each
+func (d Dirs) String() string {
+    if len(d.all()) == 1 {
+        return d.Data
+    }
+    return fmt.Sprintf("config %s, data %s, state %s", d.Config, d.Data, d.State)
+}
+
+// dataDirs are the directories this run reads and writes; resolved once at startup
+var dataDirs = singleDir(".")
+
+// dataPath returns the full path of a data file
+func dataPath(name string) string {
+    return filepath.Join(dataDirs.dirFor(name), name)
+}
+
+// xdgDirs returns the platform's directories: $XDG_CONFIG_HOME,
+// $XDG_DATA_HOME and $XDG_STATE_HOME where set, otherwise ~/.config,
+// ~/.local/share and ~/.local/state; Application Support on macOS; and
+// %AppData%, with the state in %LocalAppData%, on Windows
+func xdgDirs() (Dirs, error) {
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return Dirs{}, fmt.Errorf("error finding the home directory: %w", err)
+    }
+    var base Dirs
+    switch runtime.GOOS {
+    case "darwin":
+        support := filepath.Join(home, "Library", "Application Support")
+        base = Dirs{Config: support, Data: support, State: support}
+    case "windows":
+        roaming, local := os.Getenv("AppData"), os.Getenv("LocalAppData")
+        if roaming == "" {
+            roaming = filepath.Join(home, "AppData", "Roaming")
+        }
+        if local == "" {
+            local = filepath.Join(home, "AppData", "Local")
+        }
+        base = Dirs{Config: roaming, Data: roaming, State: local}
+    default:
+        base = Dirs{
+            Config: filepath.Join(home, ".config"),
+            Data:   filepath.Join(home, ".local", "share"),
+            State:  filepath.Join(home, ".local", "state"),
+        }
+    }
+    // The XDG variables win wherever they're set, as they're set on purpose
+    for _, v := range []struct {
+        env string
+        dir *string
+    }{{"XDG_CONFIG_HOME", &base.Config}, {"XDG_DATA_HOME", &base.Data}, {"XDG_STATE_HOME", &base.State}} {
+        if dir := os.Getenv(v.env); filepath.IsAbs(dir) {
+            *v.dir = dir
+        }
+    }
+    return base.join(appDirName), nil
+}
+
+// resolveDataDirs picks the directories: override (from --data-dir) or
+// $DINNER_PICKER_HOME if set, keeping everything in one directory, otherwise
+// the platform's config, data and state directories, created on first run.
+// Files left by earlier versions in the working directory, or all together
+// in the config directory, are moved over on the way.
+func resolveDataDirs(override string) (Dirs, error) {
+    if override == "" {
+        override = os.Getenv("DINNER_PICKER_HOME")
+    }
+    if override != "" {
+        return singleDir(override), nil
+    }
+
+    dirs, err := xdgDirs()
+    if err != nil {
+        return Dirs{}, err
+    }
+    _, statErr := os.Stat(filepath.Join(dirs.Data, DinnersFileName))
+    fresh := os.IsNotExist(statErr)
+    for _, dir := range dirs.all() {
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            return Dirs{}, fmt.Errorf("error creating data directory: %w", err)
+        }
+    }
+    if !fresh {
+        return dirs, nil
+    }
+
+    for _, old := range []string{".", dirs.Config} {
+        if _, err := os.Stat(filepath.Join(old, DinnersFileName)); err != nil || containsDir([]string{dirs.Data}, old) {
+            continue
+        }
+        moved, err := migrateDataFiles(old, dirs)
+        if err != nil {
+            return Dirs{}, err
+        }
+        if abs, err := filepath.Abs(old); err == nil {
+            old = abs
+        }
+        logf("Moved %s from %s to %s\n", strings.Join(moved, ", "), old, dirs)
+        return dirs, nil
+    }
+    logf("First run on %s/%s: storing data in %s\n", runtime.GOOS, runtime.GOARCH, dirs)
+    return dirs, nil
+}
+
+// migrateDataFiles moves the files an earlier version kept together in old,
+// and its profiles', to where they belong now, and returns what it moved
+func migrateDataFiles(old string, dirs Dirs) ([]string, error) {
+    names := []string{DinnersFileName, ConfigFileName, StateFileName, JournalFileName, OutboxFileName, PantryFileName, DatabaseFileName}
+    var moved []string
+    move := func(from string, to Dirs) error {
+        for _, name := range names {
+            source := filepath.Join(from, name)
+            if _, err := os.Stat(source); err != nil {
+                continue
+            }
+            target := filepath.Join(to.dirFor(name), name)
+            if filepath.Clean(source) == filepath.Clean(target) {
+                continue
+            }
+            if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+                return fmt.Errorf("error creating data directory: %w", err)
+            }
+            if err := moveFile(source, target); err != nil {
+                return fmt.Errorf("error moving %s: %w", source, err)
+            }
+            if !containsString(moved, name) {
+                moved = append(moved, name)
+            }
+        }
+        return nil
+    }
+    if err := move(old, dirs); err != nil {
+        return nil, err
+    }
+
+    entries, _ := os.ReadDir(filepath.Join(old, ProfilesDirName))
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            continue
+        }
+        from := filepath.Join(old, ProfilesDirName, entry.Name())
+        if err := move(from, dirs.join(ProfilesDirName, entry.Name())); err != nil {
+            return nil, err
+        }
+        os.Remove(from)
+    }
+    if len(entries) > 0 {
+        os.Remove(filepath.Join(old, ProfilesDirName))
+        moved = append(moved, ProfilesDirName)
+    }
+
+    var data struct {
+        Include []string `json:"include"`
+    }
+    if file, err := os.ReadFile(filepath.Join(dirs.Data, DinnersFileName)); err == nil && json.Unmarshal(file, &data) == nil && len(data.Include) > 0 {
+        warnf("%s includes %s, which stayed in %s; move them to %s or make the paths absolute", DinnersFileName, strings.Join(data.Include, ", "), old, dirs.Data)
+    }
+    sort.Strings(moved)
+    return moved, nil
+}
+
+// containsString reports whether list has s
+func containsString(list []string, s string) bool {
+    for _, item := range list {
+        if item == s {
+            return true
+        }
+    }
+    return false
+}
+
+// moveFile renames a file, copying it across file systems where renaming can't
+func moveFile(source, target string) error {
+    if err := os.Rename(source, target); err == nil {
+        return nil
+    }
+    in, err := os.Open(source)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        os.Remove(target)
+        return err
+    }
+    if err := out.Close(); err != nil {
+        return err
+    }
+    return os.Remove(source)
+}
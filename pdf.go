@@ -0,0 +1,117 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// pdfLine is a line of a PDF document; a zero Size is a blank line
+type pdfLine struct {
+    Text string
+    Size float64
+}
+
+// A4 in points, with its margins and the gap between lines
+const (
+    pdfWidth   = 595
+    pdfHeight  = 842
+    pdfMargin  = 56
+    pdfLeading = 1.45
+)
+
+// pdfText spells text for a PDF string in Helvetica's WinAnsi encoding:
+// Latin-1 as is, the euro and quotes mapped, anything else as "?"
+func pdfText(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        switch {
+        case r == '(' || r == ')' || r == '\\':
+            b.WriteByte('\\')
+            b.WriteRune(r)
+        case r == '€':
+            b.WriteString(`\200`)
+        case r == '‘' || r == '’':
+            b.WriteByte('\'')
+        case r == '“' || r == '”':
+            b.WriteByte('"')
+        case r == '–' || r == '—':
+            b.WriteByte('-')
+        case r >= 32 && r < 127:
+            b.WriteRune(r)
+        case r >= 160 && r < 256:
+            fmt.Fprintf(&b, `\%03o`, r)
+        default:
+            b.WriteByte('?')
+        }
+    }
+    return b.String()
+}
+
+// writePDF writes lines of text as a PDF document, A4 pages of Helvetica,
+// lines larger than body text in bold. It's enough for a report without
+// bringing in a PDF library.
+func writePDF(w io.Writer, title string, lines []pdfLine) error {
+    // Lay the lines out on pages first
+    var pages []string
+    var page strings.Builder
+    y := float64(pdfHeight - pdfMargin)
+    for _, line := range lines {
+        size := line.Size
+        if size == 0 {
+            size = 8
+        }
+        if y-size*pdfLeading < pdfMargin {
+            pages = append(pages, page.String())
+            page.Reset()
+            y = pdfHeight - pdfMargin
+        }
+        y -= size * pdfLeading
+        if line.Text == "" {
+            continue
+        }
+        font := "F1"
+        if size > 12 {
+            font = "F2"
+        }
+        fmt.Fprintf(&page, "BT /%s %.0f Tf %d %.1f Td (%s) Tj ET\n", font, size, pdfMargin, y, pdfText(line.Text))
+    }
+    pages = append(pages, page.String())
+
+    // Objects 1 to 4 are the catalog, page tree, fonts and document info;
+    // each page is then its page object and its content stream
+    var objects []string
+    var kids []string
+    for i := range pages {
+        kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+    }
+    objects = append(objects,
+        "<< /Type /Catalog /Pages 2 0 R >>",
+        fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+        "<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >> /F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >> >>",
+        fmt.Sprintf("<< /Title (%s) /Producer (dinner-picker) >>", pdfText(title)),
+    )
+    for i, content := range pages {
+        objects = append(objects,
+            fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font 3 0 R >> /Contents %d 0 R >>", pdfWidth, pdfHeight, 6+2*i),
+            fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+        )
+    }
+
+    var doc bytes.Buffer
+    doc.WriteString("%PDF-1.4\n")
+    offsets := make([]int, len(objects))
+    for i, object := range objects {
+        offsets[i] = doc.Len()
+        fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, object)
+    }
+    xref := doc.Len()
+    fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+    for _, offset := range offsets {
+        fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+    }
+    fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+    _, err := w.Write(doc.Bytes())
+    return err
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Pin fixes a dinner to a day of one week's plan, whatever the planner
+// would have drawn
+type Pin struct {
+    WeekStart time.Time `json:"week_start"`
+    Day       string    `json:"day"`
+    Dinner    string    `json:"dinner"`
+}
+
+// IsBanned reports whether a dinner is kept out of plans by "ban"
+func (s *WeekState) IsBanned(dinner Dinner) bool {
+    for _, name := range s.Bans {
+        if strings.EqualFold(name, dinner.Name) {
+            return true
+        }
+    }
+    return false
+}
+
+// pinnedDinners returns this week's pins as day to dinner, as the catalog
+// has them now, with notes about pins that can't be kept
+func (s *WeekState) pinnedDinners(dinners *DinnerData) (map[string]Dinner, []string) {
+    pins := make(map[string]Dinner)
+    var notes []string
+    for _, pin := range s.Pins {
+        if !pin.WeekStart.Equal(s.WeekStart) {
+            continue
+        }
+        dinner, ok := dinners.FindDinner(pin.Dinner)
+        if !ok {
+            notes = append(notes, fmt.Sprintf("%s: %s is pinned but no longer in the catalog", pin.Day, pin.Dinner))
+            continue
+        }
+        pins[pin.Day] = dinner
+    }
+    return pins, notes
+}
+
+// dropPins removes the pins keep says no to, and reports how many went
+func (s *WeekState) dropPins(keep func(Pin) bool) int {
+    var kept []Pin
+    for _, pin := range s.Pins {
+        if keep(pin) {
+            kept = append(kept, pin)
+        }
+    }
+    dropped := len(s.Pins) - len(kept)
+    s.Pins = kept
+    return dropped
+}
+
+// weekLabel names a pin's week for listing
+func weekLabel(weekStart, current time.Time) string {
+    if weekStart.Equal(current) {
+        return "this week"
+    }
+    return "week of " + weekStart.Format("January 2")
+}
+
+// printPinsAndBans lists the pins still to come and the bans
+func printPinsAndBans(state *WeekState) {
+    if len(state.Pins) == 0 && len(state.Bans) == 0 {
+        fmt.Println("Nothing pinned or banned")
+        return
+    }
+    pins := append([]Pin(nil), state.Pins...)
+    sort.SliceStable(pins, func(i, j int) bool {
+        if !pins[i].WeekStart.Equal(pins[j].WeekStart) {
+            return pins[i].WeekStart.Before(pins[j].WeekStart)
+        }
+        return dayIndex(pins[i].Day) < dayIndex(pins[j].Day)
+    })
+    for _, pin := range pins {
+        fmt.Printf("Pinned  %-9s %s (%s)\n", pin.Day, pin.Dinner, weekLabel(pin.WeekStart, state.WeekStart))
+    }
+    for _, name := range state.Bans {
+        fmt.Printf("Banned  %s\n", name)
+    }
+}
+
+// pinWeek returns the week a pin command is about: this one, or the next
+// with --next
+func pinWeek(state *WeekState, next bool) time.Time {
+    if next {
+        return state.WeekStart.AddDate(0, 0, 7)
+    }
+    return state.WeekStart
+}
+
+// runPinCommand handles "pin [<day> <dinner>] [--next]", fixing a dinner to a
+// day of this week's plan, or the next week's, or listing what's pinned and
+// banned
+func runPinCommand(args []string) error {
+    fs := flag.NewFlagSet("pin", flag.ContinueOnError)
+    next := fs.Bool("next", false, "pin it for next week rather than this one")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    if len(positional) == 0 {
+        printPinsAndBans(state)
+        return nil
+    }
+    if len(positional) < 2 {
+        return fmt.Errorf("usage: dinner-picker pin [<day> <dinner>] [--next]")
+    }
+    day, ok := normalizeDay(positional[0])
+    if !ok {
+        return fmt.Errorf("unknown day %q", positional[0])
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    name := strings.Join(positional[1:], " ")
+    dinner, ok := dinners.FindDinner(name)
+    if !ok {
+        return fmt.Errorf("no dinner named %q", name)
+    }
+    if state.IsBanned(dinner) {
+        return fmt.Errorf("%s is banned; unban it first", dinner.Name)
+    }
+
+    week := pinWeek(state, *next)
+    state.dropPins(func(pin Pin) bool {
+        return !(pin.WeekStart.Equal(week) && pin.Day == day)
+    })
+    state.Pins = append(state.Pins, Pin{WeekStart: week, Day: day, Dinner: dinner.Name})
+    if err := state.Record("pin", fmt.Sprintf("%s %s (%s)", day, dinner.Name, weekLabel(week, state.WeekStart))); err != nil {
+        return err
+    }
+    fmt.Printf("Pinned %s to %s (%s)\n", dinner.Name, day, weekLabel(week, state.WeekStart))
+    if planned, ok := state.Plan.Dinner(day); ok && !*next && planned.Name != dinner.Name {
+        fmt.Printf("%s has %s planned now; plan again to use the pin\n", day, planned.Name)
+    }
+    return nil
+}
+
+// runUnpinCommand handles "unpin <day> [--next]", letting the planner choose
+// the day again
+func runUnpinCommand(args []string) error {
+    fs := flag.NewFlagSet("unpin", flag.ContinueOnError)
+    next := fs.Bool("next", false, "unpin next week's day rather than this week's")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 {
+        return fmt.Errorf("usage: dinner-picker unpin <day> [--next]")
+    }
+    day, ok := normalizeDay(positional[0])
+    if !ok {
+        return fmt.Errorf("unknown day %q", positional[0])
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    week := pinWeek(state, *next)
+    if state.dropPins(func(pin Pin) bool { return !(pin.WeekStart.Equal(week) && pin.Day == day) }) == 0 {
+        return fmt.Errorf("nothing pinned on %s (%s)", day, weekLabel(week, state.WeekStart))
+    }
+    if err := state.Record("unpin", fmt.Sprintf("%s (%s)", day, weekLabel(week, state.WeekStart))); err != nil {
+        return err
+    }
+    fmt.Printf("Unpinned %s (%s)\n", day, weekLabel(week, state.WeekStart))
+    return nil
+}
+
+// runBanCommand handles "ban [dinner]", keeping a dinner out of every plan
+// until it's unbanned, or listing what's pinned and banned
+func runBanCommand(args []string) error {
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    if len(args) == 0 {
+        printPinsAndBans(state)
+        return nil
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    name := strings.Join(args, " ")
+    dinner, ok := dinners.FindDinner(name)
+    if !ok {
+        return fmt.Errorf("no dinner named %q", name)
+    }
+    if state.IsBanned(dinner) {
+        return fmt.Errorf("%s is already banned", dinner.Name)
+    }
+
+    state.Bans = append(state.Bans, dinner.Name)
+    sort.Strings(state.Bans)
+    unpinned := state.dropPins(func(pin Pin) bool { return !strings.EqualFold(pin.Dinner, dinner.Name) })
+    if err := state.Record("ban", dinner.Name); err != nil {
+        return err
+    }
+    fmt.Printf("Banned %s until you unban it\n", dinner.Name)
+    if unpinned > 0 {
+        fmt.Printf("Removed %d pin(s) of it\n", unpinned)
+    }
+    if state.Plan == nil {
+        return nil
+    }
+    for _, entry := range state.Plan.Days {
+        if entry.Dinner.Name == dinner.Name && entry.Outcome == "" && !entry.IsLeftovers() {
+            fmt.Printf("%s has it planned this week; swap %s to replace it\n", entry.Day, strings.ToLower(entry.Day))
+        }
+    }
+    return nil
+}
+
+// runUnbanCommand handles "unban <dinner>", letting the planner pick it again
+func runUnbanCommand(args []string) error {
+    if len(args) == 0 {
+        return fmt.Errorf("usage: dinner-picker unban <dinner>")
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    name := strings.Join(args, " ")
+    for i, banned := range state.Bans {
+        if !strings.EqualFold(banned, name) {
+            continue
+        }
+        state.Bans = append(state.Bans[:i], state.Bans[i+1:]...)
+        if err := state.Record("unban", banned); err != nil {
+            return err
+        }
+        fmt.Printf("Unbanned %s\n", banned)
+        return nil
+    }
+    return fmt.Errorf("%q isn't banned", name)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+// A dinner pinned late in the week is kept off the days before it, whatever
+// the seed
+func TestPinnedDinnerNotDrawnEarlier(t *testing.T) {
+    dinners := &DinnerData{Dinners: map[string][]Dinner{
+        "bread-y": {
+            {Name: "Hotdogs", Category: "bread-y"},
+            {Name: "Chicken burgers", Category: "bread-y"},
+            {Name: "Mexican wrap", Category: "bread-y"},
+            {Name: "Shakshuka", Category: "bread-y"},
+            {Name: "Shawarma", Category: "bread-y"},
+        },
+    }}
+    schedule := &ScheduleConfig{Days: map[string][]string{
+        "Monday":    {"bread-y"},
+        "Tuesday":   {"bread-y"},
+        "Wednesday": {"bread-y"},
+        "Thursday":  {"bread-y"},
+    }}
+    weekStart := time.Date(2026, 10, 11, 0, 0, 0, 0, time.Local)
+    for seed := int64(1); seed <= 50; seed++ {
+        state := &WeekState{WeekStart: weekStart}
+        plan := SelectWeeklyDinners(dinners, state, PlanOptions{
+            Schedule: schedule,
+            Pins:     map[string]Dinner{"Friday": dinners.Dinners["bread-y"][0]},
+            Rand:     newRand(seed),
+        })
+        for _, entry := range plan.Days {
+            if entry.Dinner.Name == "Hotdogs" && entry.Day != "Friday" {
+                t.Fatalf("seed %d: Hotdogs planned on %s as well as pinned to Friday", seed, entry.Day)
+            }
+        }
+        if dinner, ok := plan.Dinner("Friday"); !ok || dinner.Name != "Hotdogs" {
+            t.Fatalf("seed %d: Friday is %q, want the pinned Hotdogs", seed, dinner.Name)
+        }
+    }
+}
@@ -0,0 +1,168 @@
+package main
+
+import "time"
+
+// Plan is one week's planned dinners in day order
+type Plan struct {
+    WeekStart time.Time `json:"week_start"`
+    Days      []PlanDay `json:"days"`
+    Notes     []string  `json:"notes,omitempty"`
+    Score     float64   `json:"score,omitempty"`
+    Revision  int       `json:"revision"`
+
+    // Pattern is the rotation's week pattern the plan was made for
+    Pattern string `json:"pattern,omitempty"`
+
+    // Seed is what the planner's random choices were drawn from; planning
+    // with it again gives the same week from the same dinners and history
+    Seed int64 `json:"seed,omitempty"`
+
+    // Budget is what the week was planned to cost at most, if anything
+    Budget float64 `json:"budget,omitempty"`
+
+    // UseUp lists what the week was planned to use up, as given
+    UseUp []string `json:"use_up,omitempty"`
+
+    // Season is the season the week was planned for with --season, which
+    // swaps go by too
+    Season string `json:"season,omitempty"`
+}
+
+// PlanDay is a single planned evening
+type PlanDay struct {
+    Day    string    `json:"day"`
+    Date   time.Time `json:"date"`
+    Dinner Dinner    `json:"dinner"`
+    Mode   DayMode   `json:"mode,omitempty"`
+
+    // Holiday names the public holiday that falls on the day
+    Holiday string `json:"holiday,omitempty"`
+
+    // Servings is how many people are eating, when guests were planned for
+    Servings int `json:"servings,omitempty"`
+
+    // LeftoversFrom is the day whose big batch this day eats again, leaving
+    // nothing to cook or buy
+    LeftoversFrom string `json:"leftovers_from,omitempty"`
+
+    // Outcome, Substitute and Rating record what actually happened
+    Outcome    string `json:"outcome,omitempty"`
+    Substitute string `json:"substitute,omitempty"`
+    Rating     int    `json:"rating,omitempty"`
+
+    // Leftovers and Comment come from feedback given after the meal
+    Leftovers string `json:"leftovers,omitempty"`
+    Comment   string `json:"comment,omitempty"`
+}
+
+// NewPlan returns an empty plan for the week starting on weekStart
+func NewPlan(weekStart time.Time) *Plan {
+    return &Plan{WeekStart: weekStart}
+}
+
+// Dinner returns the dinner planned for a day
+func (p *Plan) Dinner(day string) (Dinner, bool) {
+    if p == nil {
+        return Dinner{}, false
+    }
+    for _, entry := range p.Days {
+        if entry.Day == day {
+            return entry.Dinner, true
+        }
+    }
+    return Dinner{}, false
+}
+
+// Entry returns the planned evening for a day
+func (p *Plan) Entry(day string) (PlanDay, bool) {
+    if p == nil {
+        return PlanDay{}, false
+    }
+    for _, entry := range p.Days {
+        if entry.Day == day {
+            return entry, true
+        }
+    }
+    return PlanDay{}, false
+}
+
+// Set plans a dinner for a day, replacing any existing one and keeping the days in week order
+func (p *Plan) Set(day string, dinner Dinner, mode DayMode) {
+    for i := range p.Days {
+        if p.Days[i].Day == day {
+            p.Days[i].Dinner = dinner
+            p.Days[i].Mode = mode
+            return
+        }
+    }
+
+    p.Put(PlanDay{
+        Day:    day,
+        Date:   p.WeekStart.AddDate(0, 0, dayIndex(day)),
+        Dinner: dinner,
+        Mode:   mode,
+    })
+}
+
+// Put adds a planned evening as it is, outcome and all, replacing what the
+// day had and keeping the days in week order
+func (p *Plan) Put(entry PlanDay) {
+    i := 0
+    for i < len(p.Days) && dayIndex(p.Days[i].Day) < dayIndex(entry.Day) {
+        i++
+    }
+    if i < len(p.Days) && p.Days[i].Day == entry.Day {
+        p.Days[i] = entry
+        return
+    }
+    p.Days = append(p.Days, PlanDay{})
+    copy(p.Days[i+1:], p.Days[i:])
+    p.Days[i] = entry
+}
+
+// Replace swaps the dinner planned for a day, keeping its mode. A day that
+// was leftovers gets cooked for, and days eating this day's leftovers follow
+// along. It reports whether the day was planned.
+func (p *Plan) Replace(day string, dinner Dinner) bool {
+    found := false
+    for i := range p.Days {
+        switch {
+        case p.Days[i].Day == day:
+            p.Days[i].Dinner = dinner
+            p.Days[i].LeftoversFrom = ""
+            found = true
+        case p.Days[i].LeftoversFrom == day:
+            p.Days[i].Dinner = dinner
+        }
+    }
+    return found
+}
+
+// IsEmpty reports whether nothing is planned
+func (p *Plan) IsEmpty() bool {
+    return p == nil || len(p.Days) == 0
+}
+
+// Dinners returns the dinners to cook in day order; days eating leftovers
+// are left out, as there's nothing more to buy for them
+func (p *Plan) Dinners() []Dinner {
+    if p == nil {
+        return nil
+    }
+    dinners := make([]Dinner, 0, len(p.Days))
+    for _, entry := range p.Days {
+        if !entry.IsLeftovers() {
+            dinners = append(dinners, entry.Dinner)
+        }
+    }
+    return dinners
+}
+
+// planFromSelections converts the day-to-dinner map used by older state files
+func planFromSelections(weekStart time.Time, selections map[string]Dinner) *Plan {
+    plan := NewPlan(weekStart)
+    for day, dinner := range selections {
+        plan.Set(day, dinner, DayNormal)
+    }
+    return plan
+}
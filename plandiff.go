@@ -0,0 +1,225 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// DayChange is one day whose dinner differs between two revisions. Before is
+// empty for a day that was added, After for a day that was dropped.
+type DayChange struct {
+    Day    string `json:"day"`
+    Date   string `json:"date"`
+    Change string `json:"change"`
+    Before string `json:"before,omitempty"`
+    After  string `json:"after,omitempty"`
+}
+
+// ItemChange is one shopping list item that was added, removed or whose
+// amount changed, with the item as the list writes it
+type ItemChange struct {
+    Item   string `json:"item"`
+    Before string `json:"before,omitempty"`
+    After  string `json:"after,omitempty"`
+}
+
+// ShoppingDelta is how the shopping list changed between two revisions
+type ShoppingDelta struct {
+    Added   []ItemChange `json:"added"`
+    Removed []ItemChange `json:"removed"`
+    Changed []ItemChange `json:"changed"`
+}
+
+// PlanDiff compares two revisions of the same week's plan
+type PlanDiff struct {
+    WeekStart    string        `json:"week_start"`
+    FromRevision int           `json:"from_revision"`
+    ToRevision   int           `json:"to_revision"`
+    Days         []DayChange   `json:"days"`
+    Shopping     ShoppingDelta `json:"shopping"`
+}
+
+// Empty reports whether nothing changed
+func (d PlanDiff) Empty() bool {
+    return len(d.Days) == 0 && len(d.Shopping.Added) == 0 && len(d.Shopping.Removed) == 0 && len(d.Shopping.Changed) == 0
+}
+
+// DiffPlans compares an earlier plan with a later one, day by day and item by
+// item on the shopping list. Staples are left off, as the list leaves them off.
+func DiffPlans(before, after *Plan, staples []string) PlanDiff {
+    diff := PlanDiff{
+        WeekStart:    after.WeekStart.Format("2006-01-02"),
+        FromRevision: before.Revision,
+        ToRevision:   after.Revision,
+        Days:         []DayChange{},
+        Shopping:     ShoppingDelta{Added: []ItemChange{}, Removed: []ItemChange{}, Changed: []ItemChange{}},
+    }
+
+    for _, day := range weekDays {
+        old, hadOld := before.Entry(day)
+        cur, hasCur := after.Entry(day)
+        change := DayChange{Day: day}
+        switch {
+        case hadOld && hasCur && !strings.EqualFold(old.Dinner.Name, cur.Dinner.Name):
+            change.Change, change.Date = "changed", cur.Date.Format("2006-01-02")
+            change.Before, change.After = old.Dinner.Name, cur.Dinner.Name
+        case hadOld && !hasCur:
+            change.Change, change.Date = "removed", old.Date.Format("2006-01-02")
+            change.Before = old.Dinner.Name
+        case !hadOld && hasCur:
+            change.Change, change.Date = "added", cur.Date.Format("2006-01-02")
+            change.After = cur.Dinner.Name
+        default:
+            continue
+        }
+        diff.Days = append(diff.Days, change)
+    }
+    // Days are listed by date, which for a plan starting midweek isn't the
+    // order of weekDays
+    sort.SliceStable(diff.Days, func(i, j int) bool {
+        return diff.Days[i].Date < diff.Days[j].Date
+    })
+
+    oldTotals := AggregateIngredients(before.Dinners())
+    newTotals := AggregateIngredients(after.Dinners())
+    for _, item := range ShoppingList(after.Dinners()) {
+        if isStaple(item, staples) {
+            continue
+        }
+        old, ok := oldTotals[item]
+        switch {
+        case !ok:
+            diff.Shopping.Added = append(diff.Shopping.Added, ItemChange{Item: item, After: newTotals[item].String()})
+        case old.String() != newTotals[item].String():
+            diff.Shopping.Changed = append(diff.Shopping.Changed, ItemChange{Item: item, Before: old.String(), After: newTotals[item].String()})
+        }
+    }
+    for _, item := range ShoppingList(before.Dinners()) {
+        if _, ok := newTotals[item]; !ok && !isStaple(item, staples) {
+            diff.Shopping.Removed = append(diff.Shopping.Removed, ItemChange{Item: item, Before: oldTotals[item].String()})
+        }
+    }
+    return diff
+}
+
+// earlierPlan finds a previous revision of the current week's plan in the
+// journal: the given revision, or with 0 the newest one before the current
+func earlierPlan(current *Plan, revision int) (*Plan, error) {
+    entries, err := ReadJournal()
+    if err != nil {
+        return nil, err
+    }
+    scrubbed := false
+    for i := len(entries) - 1; i >= 0; i-- {
+        if len(entries[i].State) == 0 || string(entries[i].State) == "null" {
+            scrubbed = true
+            continue
+        }
+        var snapshot WeekState
+        if err := json.Unmarshal(entries[i].State, &snapshot); err != nil {
+            continue
+        }
+        plan := snapshot.Plan
+        if plan == nil || !plan.WeekStart.Equal(current.WeekStart) {
+            continue
+        }
+        if revision > 0 && plan.Revision == revision || revision == 0 && plan.Revision < current.Revision {
+            return plan, nil
+        }
+    }
+    what := "no earlier revision of this week's plan"
+    if revision > 0 {
+        what = fmt.Sprintf("no revision %d of this week's plan", revision)
+    }
+    if scrubbed {
+        return nil, fmt.Errorf("%s in the journal (history compact clears older snapshots)", what)
+    }
+    return nil, fmt.Errorf("%s in the journal", what)
+}
+
+// writePlanDiff writes the diff for people to read
+func writePlanDiff(w io.Writer, diff PlanDiff) {
+    fmt.Fprintf(w, "Week of %s, revision %d -> %d\n", diff.WeekStart, diff.FromRevision, diff.ToRevision)
+    if diff.Empty() {
+        fmt.Fprintln(w, "No changes")
+        return
+    }
+    for _, day := range diff.Days {
+        switch day.Change {
+        case "added":
+            fmt.Fprintf(w, "  %s: + %s\n", day.Day, day.After)
+        case "removed":
+            fmt.Fprintf(w, "  %s: - %s\n", day.Day, day.Before)
+        default:
+            fmt.Fprintf(w, "  %s: %s -> %s\n", day.Day, day.Before, day.After)
+        }
+    }
+    shopping := diff.Shopping
+    if len(shopping.Added)+len(shopping.Removed)+len(shopping.Changed) == 0 {
+        return
+    }
+    fmt.Fprintln(w, "\nShopping list:")
+    for _, item := range shopping.Added {
+        fmt.Fprintf(w, "  + %s\n", item.After)
+    }
+    for _, item := range shopping.Removed {
+        fmt.Fprintf(w, "  - %s\n", item.Before)
+    }
+    for _, item := range shopping.Changed {
+        fmt.Fprintf(w, "  ~ %s (was %s)\n", item.After, item.Before)
+    }
+}
+
+// runPlanDiffCommand handles "plan diff [--against N] [--output json]",
+// comparing the current plan with the revision before it
+func runPlanDiffCommand(args []string) error {
+    fs := flag.NewFlagSet("plan diff", flag.ContinueOnError)
+    against := fs.Int("against", 0, "revision to compare with (default the one before the current)")
+    output := fs.String("output", "text", "diff format: text or json")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    if *output != "text" && *output != "json" {
+        return fmt.Errorf("unknown output format %q (want json, text)", *output)
+    }
+    if *against < 0 {
+        return fmt.Errorf("--against can't be negative")
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    if state.Plan.IsEmpty() {
+        return fmt.Errorf("no dinners planned for this week yet")
+    }
+    if *against > 0 && *against >= state.Plan.Revision {
+        return fmt.Errorf("--against %d isn't before the current revision %d", *against, state.Plan.Revision)
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    before, err := earlierPlan(state.Plan, *against)
+    if err != nil {
+        return err
+    }
+
+    diff := DiffPlans(before, state.Plan, config.Staples)
+    if *output == "json" {
+        data, err := json.MarshalIndent(diff, "", "  ")
+        if err != nil {
+            return fmt.Errorf("error marshaling plan diff: %w", err)
+        }
+        fmt.Println(string(data))
+        return nil
+    }
+    writePlanDiff(os.Stdout, diff)
+    return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// dayIndex returns the position of a day in the Sunday-based week, or -1
+func dayIndex(day string) int {
+    for i, d := range weekDays {
+        if d == day {
+            return i
+        }
+    }
+    return -1
+}
+
+// prepBlocked reports whether a dinner that must be started prepDays ahead
+// would need prepping on a skipped day, when nobody is home to start it
+func prepBlocked(day string, prepDays int, modes map[string]DayMode) bool {
+    i := dayIndex(day)
+    for offset := 1; offset <= prepDays; offset++ {
+        if i-offset >= 0 && modes[weekDays[i-offset]] == DaySkip {
+            return true
+        }
+    }
+    return false
+}
+
+// PrepTasks lists what needs starting on the given day for dinners later in the week
+func PrepTasks(plan *Plan, today string) []string {
+    if plan == nil {
+        return nil
+    }
+    i := dayIndex(today)
+    var tasks []string
+    for _, entry := range plan.Days {
+        day, dinner := entry.Day, entry.Dinner
+        if dinner.PrepDays == 0 || entry.IsLeftovers() {
+            continue
+        }
+        if dayIndex(day)-dinner.PrepDays != i {
+            continue
+        }
+        when := day + "'s"
+        if dayIndex(day) == i+1 {
+            when = "tomorrow's"
+        }
+        tasks = append(tasks, fmt.Sprintf("Start %s %s tonight", when, dinner.Name))
+    }
+    return tasks
+}
+
+// runTodayCommand handles "today", printing tonight's dinner and any prep to start,
+// and when to start cooking if the calendar has something on this evening
+func runTodayCommand(args []string) error {
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    now := time.Now()
+    today := now.Weekday().String()
+    if entry, ok := state.Plan.Entry(today); ok {
+        fmt.Printf("Tonight: %s\n", entry.title())
+        if entry.IsLeftovers() {
+            fmt.Printf("  from %s's batch\n", entry.LeftoversFrom)
+        }
+        if config.Calendar != nil && entry.Outcome == "" {
+            if events, err := FetchCalendar(config.Calendar.URL); err != nil {
+                warnf("ignoring calendar: %v", err)
+            } else if fit := tonightReminder(state, config, entry, events, now); fit.Before != "" || fit.Short {
+                fmt.Printf("  %s\n", strings.TrimPrefix(fit.Message, "Tonight: "))
+            }
+        }
+        for _, ingredient := range entry.Dinner.Ingredients {
+            if !entry.IsLeftovers() {
+                fmt.Printf("  %s\n", ingredient)
+            }
+        }
+    } else {
+        fmt.Println("Nothing planned for tonight")
+    }
+
+    for _, task := range PrepTasks(state.Plan, today) {
+        fmt.Println(task)
+    }
+    return nil
+}
+
+// runNextCommand handles "next", printing the upcoming dinner and tonight's prep on
+// one line for status bars: tonight's until the dinner hour (or once it's cooked),
+// then the next planned day's
+func runNextCommand(args []string) error {
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    now := time.Now()
+    today := dayIndex(now.Weekday().String())
+    first := today
+    if entry, ok := state.Plan.Entry(weekDays[today]); !ok || entry.Outcome != "" || now.Hour()*60+now.Minute() >= parseClock(config.DinnerHour, 19*60) {
+        first = today + 1
+    }
+
+    line := "Nothing planned"
+    for i := first; i < len(weekDays); i++ {
+        entry, ok := state.Plan.Entry(weekDays[i])
+        if !ok || entry.Outcome != "" {
+            continue
+        }
+        when := entry.Day
+        if i == today {
+            when = "Tonight"
+        } else if i == today+1 {
+            when = "Tomorrow"
+        }
+        line = when + ": " + entry.title()
+        break
+    }
+    for _, task := range PrepTasks(state.Plan, weekDays[today]) {
+        line += " | " + task
+    }
+    fmt.Println(line)
+    return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// ProfilesDirName is the directory under each of the config, data and state
+// directories that holds each named profile's own
+const ProfilesDirName = "profiles"
+
+// baseDirs are the directories the default profile uses and the others live under
+var baseDirs = singleDir(".")
+
+// currentProfile is the profile this run uses
+var currentProfile = DefaultProfile
+
+// profileNamePattern is what a profile may be called, so it makes a safe directory name
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// profileDirs returns the directories of a profile
+func profileDirs(name string) Dirs {
+    if name == DefaultProfile {
+        return baseDirs
+    }
+    return baseDirs.join(ProfilesDirName, name)
+}
+
+// profileExists reports whether a profile has been created
+func profileExists(name string) bool {
+    _, err := os.Stat(profileDirs(name).Data)
+    return name == DefaultProfile || err == nil
+}
+
+// checkProfileName checks a profile name makes a directory name
+func checkProfileName(name string) error {
+    if !profileNamePattern.MatchString(name) {
+        return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, - and _", name)
+    }
+    return nil
+}
+
+// useProfile makes a profile's directories the ones this run reads and
+// writes: name, $DINNER_PICKER_PROFILE, or the default
+func useProfile(name string) error {
+    if name == "" {
+        name = os.Getenv("DINNER_PICKER_PROFILE")
+    }
+    if name == "" {
+        name = DefaultProfile
+    }
+    if name != DefaultProfile {
+        if err := checkProfileName(name); err != nil {
+            return err
+        }
+        if !profileExists(name) {
+            return fmt.Errorf("no profile %q (create it with: dinner-picker profile create %s)", name, name)
+        }
+    }
+    currentProfile = name
+    dataDirs = profileDirs(name)
+    return nil
+}
+
+// profileNames lists the default profile and every named one, sorted
+func profileNames() ([]string, error) {
+    names := []string{DefaultProfile}
+    entries, err := os.ReadDir(filepath.Join(baseDirs.Data, ProfilesDirName))
+    if os.IsNotExist(err) {
+        return names, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading profiles: %w", err)
+    }
+    var named []string
+    for _, entry := range entries {
+        if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) && entry.Name() != DefaultProfile {
+            named = append(named, entry.Name())
+        }
+    }
+    sort.Strings(named)
+    return append(names, named...), nil
+}
+
+// inProfile runs f with another profile's directories in place of this run's
+func inProfile(name string, f func() error) error {
+    saved := dataDirs
+    dataDirs = profileDirs(name)
+    defer func() { dataDirs = saved }()
+    return f()
+}
+
+// runProfileCommand handles "profile list", "profile create <name> [--from
+// profile] [--empty]" and "profile delete <name> [--yes]"
+func runProfileCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker profile list|create|delete [name]")
+    if len(args) == 0 {
+        return runProfileList()
+    }
+    switch args[0] {
+    case "list", "ls":
+        return runProfileList()
+    case "create":
+        return runProfileCreate(args[1:])
+    case "delete", "rm":
+        return runProfileDelete(args[1:])
+    }
+    return usage
+}
+
+// runProfileList prints the profiles with how many dinners each has, marking the one in use
+func runProfileList() error {
+    names, err := profileNames()
+    if err != nil {
+        return err
+    }
+    for _, name := range names {
+        mark := " "
+        if name == currentProfile {
+            mark = "*"
+        }
+        count := "no dinners yet"
+        inProfile(name, func() error {
+            dinners, err := loadCatalog()
+            if err == nil {
+                count = fmt.Sprintf("%d dinners", len(dinners.AllDinners()))
+            }
+            return nil
+        })
+        fmt.Printf("%s %-20s %-16s %s\n", mark, name, count, profileDirs(name).Data)
+    }
+    return nil
+}
+
+// runProfileCreate makes a new profile, starting from a copy of another's
+// dinners and config, or from nothing
+func runProfileCreate(args []string) error {
+    fs := flag.NewFlagSet("profile create", flag.ContinueOnError)
+    from := fs.String("from", "", "profile to copy the dinners and config from (default the one in use)")
+    empty := fs.Bool("empty", false, "start with no dinners and the default config")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 || (*empty && *from != "") {
+        return fmt.Errorf("usage: dinner-picker profile create <name> [--from profile|--empty]")
+    }
+    name := positional[0]
+    if err := checkProfileName(name); err != nil {
+        return err
+    }
+    if name == DefaultProfile {
+        return fmt.Errorf("the %s profile always exists", DefaultProfile)
+    }
+    if profileExists(name) {
+        return fmt.Errorf("profile %q already exists", name)
+    }
+
+    source := *from
+    if source == "" {
+        source = currentProfile
+    }
+    dinners := &DinnerData{Dinners: make(map[string][]Dinner)}
+    config := &Config{}
+    if !*empty {
+        if !profileExists(source) {
+            return fmt.Errorf("no profile %q to copy from", source)
+        }
+        err := inProfile(source, func() error {
+            var err error
+            if dinners, err = loadCatalog(); err != nil {
+                return err
+            }
+            config, err = LoadConfig()
+            return err
+        })
+        if err != nil {
+            return err
+        }
+        // The copy keeps its own files, whatever the other profile is stored in
+        config.Storage = nil
+    }
+
+    dirs := profileDirs(name)
+    for _, dir := range dirs.all() {
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            return fmt.Errorf("error creating profile: %w", err)
+        }
+    }
+    err = inProfile(name, func() error {
+        if err := config.SaveConfig(); err != nil {
+            return err
+        }
+        return saveCatalog(dinners)
+    })
+    if err != nil {
+        for _, dir := range dirs.all() {
+            os.RemoveAll(dir)
+        }
+        return err
+    }
+    if *empty {
+        fmt.Printf("Created profile %s with no dinners yet\n", name)
+    } else {
+        fmt.Printf("Created profile %s with %s's %d dinners and config\n", name, source, len(dinners.AllDinners()))
+    }
+    fmt.Printf("Use it with: dinner-picker --profile %s <command>\n", name)
+    return nil
+}
+
+// runProfileDelete removes a profile and everything in it, after asking
+func runProfileDelete(args []string) error {
+    fs := flag.NewFlagSet("profile delete", flag.ContinueOnError)
+    yes := fs.Bool("yes", false, "don't ask first")
+    positional, err := parseArgs(fs, args)
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 {
+        return fmt.Errorf("usage: dinner-picker profile delete <name> [--yes]")
+    }
+    name := positional[0]
+    if err := checkProfileName(name); err != nil {
+        return err
+    }
+    switch name {
+    case DefaultProfile:
+        return fmt.Errorf("the %s profile can't be deleted", DefaultProfile)
+    case currentProfile:
+        return fmt.Errorf("profile %s is in use; delete it from another profile", name)
+    }
+    if !profileExists(name) {
+        return fmt.Errorf("no profile %q", name)
+    }
+
+    if !*yes {
+        if !stdinIsTerminal() {
+            return fmt.Errorf("deleting %s removes its dinners, plans and history; rerun with --yes", name)
+        }
+        answer, err := prompt(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete profile %s with its dinners, plans and history? [y/N]: ", name))
+        if err != nil {
+            return err
+        }
+        if !strings.EqualFold(answer, "y") {
+            fmt.Println("Nothing deleted")
+            return nil
+        }
+    }
+    for _, dir := range profileDirs(name).all() {
+        if err := os.RemoveAll(dir); err != nil {
+            return fmt.Errorf("error deleting profile: %w", err)
+        }
+    }
+    fmt.Printf("Deleted profile %s\n", name)
+    return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// ProteinRules limits how often a main protein appears and which proteins the
+// week must include at least once
+type ProteinRules struct {
+    MaxPerWeek int      `json:"max_per_week,omitempty"`
+    Require    []string `json:"require,omitempty"`
+}
+
+// ProteinCounts tallies the main proteins of the selected dinners
+func ProteinCounts(plan *Plan) map[string]int {
+    counts := make(map[string]int)
+    for _, dinner := range plan.Dinners() {
+        if protein := dinner.MainProtein(); protein != "" {
+            counts[protein]++
+        }
+    }
+    return counts
+}
+
+// MainProtein returns the normalized protein, or "" when unknown
+func (d Dinner) MainProtein() string {
+    return strings.ToLower(strings.TrimSpace(d.Protein))
+}
+
+// allows reports whether adding the dinner keeps every protein under the limit
+func (r *ProteinRules) allows(dinner Dinner, counts map[string]int) bool {
+    if r == nil || r.MaxPerWeek <= 0 {
+        return true
+    }
+    protein := dinner.MainProtein()
+    return protein == "" || counts[protein] < r.MaxPerWeek
+}
+
+// isRequired reports whether the dinner's protein satisfies the required list
+func (r *ProteinRules) isRequired(dinner Dinner) bool {
+    for _, protein := range r.Require {
+        if strings.EqualFold(protein, dinner.MainProtein()) {
+            return true
+        }
+    }
+    return false
+}
+
+// satisfied reports whether the week contains at least one required protein
+func (r *ProteinRules) satisfied(plan *Plan) bool {
+    if r == nil || len(r.Require) == 0 {
+        return true
+    }
+    for _, dinner := range plan.Dinners() {
+        if r.isRequired(dinner) {
+            return true
+        }
+    }
+    return false
+}
+
+// ensureRequiredProtein swaps one day for a dinner with a required protein when
+// the week has none, keeping the day's category and the per-protein limit
+func (r *ProteinRules) ensureRequiredProtein(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    if r.satisfied(plan) {
+        return
+    }
+
+    replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+        counts := ProteinCounts(plan)
+        if protein := current.MainProtein(); protein != "" {
+            counts[protein]--
+        }
+        return r.isRequired(candidate) && r.allows(candidate, counts)
+    })
+}
+
+// printProteinSummary prints the protein spread and any rules the week doesn't meet
+func printProteinSummary(plan *Plan, rules *ProteinRules) {
+    counts := ProteinCounts(plan)
+    if len(counts) == 0 {
+        return
+    }
+
+    var proteins []string
+    for protein := range counts {
+        proteins = append(proteins, protein)
+    }
+    sort.Strings(proteins)
+
+    var parts []string
+    for _, protein := range proteins {
+        parts = append(parts, fmt.Sprintf("%s %d", protein, counts[protein]))
+    }
+    unset := 0
+    for _, dinner := range plan.Dinners() {
+        if dinner.MainProtein() == "" {
+            unset++
+        }
+    }
+    if unset > 0 {
+        parts = append(parts, fmt.Sprintf("not set for %d", unset))
+    }
+    fmt.Printf("Proteins: %s\n", strings.Join(parts, ", "))
+
+    if rules == nil {
+        return
+    }
+    for _, protein := range proteins {
+        if rules.MaxPerWeek > 0 && counts[protein] > rules.MaxPerWeek {
+            warnf("%s appears %d times (limit %d)", protein, counts[protein], rules.MaxPerWeek)
+        }
+    }
+    if !rules.satisfied(plan) {
+        warnf("no %s night this week", strings.Join(rules.Require, " or "))
+    }
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Origin records how a dinner got into the catalog and when it last changed
+type Origin struct {
+    CreatedAt time.Time `json:"created_at,omitzero"`
+    Via       string    `json:"via,omitempty"`
+    ImportURL string    `json:"import_url,omitempty"`
+    EditedAt  time.Time `json:"edited_at,omitzero"`
+}
+
+// OriginManual is the origin of dinners typed into dinners.json by hand,
+// which is what any dinner without a recorded origin is assumed to be
+const OriginManual = "manual"
+
+// importedOrigin stamps a dinner brought in by a command
+func importedOrigin(via, url string) *Origin {
+    return &Origin{CreatedAt: time.Now(), Via: via, ImportURL: url}
+}
+
+// Imported reports whether a dinner came in through an import rather than by hand
+func (d Dinner) Imported() bool {
+    return d.Origin != nil && d.Origin.Via != "" && d.Origin.Via != OriginManual
+}
+
+// String formats the origin for display, e.g. "import recipe-json on 2026-03-02 from https://..."
+func (o *Origin) String() string {
+    if o == nil || o.Via == "" {
+        return "entered by hand"
+    }
+    line := o.Via
+    if o.Via == OriginManual {
+        line = "entered by hand"
+    }
+    if !o.CreatedAt.IsZero() {
+        line += " on " + o.CreatedAt.Format("2006-01-02")
+    }
+    if o.ImportURL != "" {
+        line += " from " + o.ImportURL
+    }
+    if !o.EditedAt.IsZero() {
+        line += ", last edited " + o.EditedAt.Format("2006-01-02")
+    }
+    return line
+}
+
+// markEdited records that a dinner was changed by a command
+func (d *Dinner) markEdited() {
+    if d.Origin == nil {
+        d.Origin = &Origin{Via: OriginManual}
+    }
+    d.Origin.EditedAt = time.Now()
+}
+
+// runListCommand handles "list [--category name] [--origin imported|manual] [--via command]",
+// listing the catalog with where each dinner came from
+func runListCommand(args []string) error {
+    fs := flag.NewFlagSet("list", flag.ContinueOnError)
+    category := fs.String("category", "", "only list this category")
+    origin := fs.String("origin", "", "only list imported or manual (hand-entered) dinners")
+    via := fs.String("via", "", "only list dinners added by this command, e.g. import-all")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    if *origin != "" && *origin != "imported" && *origin != OriginManual {
+        return fmt.Errorf("--origin must be imported or manual")
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    all := dinners.AllDinners()
+    sort.Slice(all, func(i, j int) bool {
+        if all[i].Category != all[j].Category {
+            return all[i].Category < all[j].Category
+        }
+        return all[i].Name < all[j].Name
+    })
+
+    found := 0
+    for _, dinner := range all {
+        if *category != "" && !strings.EqualFold(dinner.Category, *category) {
+            continue
+        }
+        if *origin != "" && dinner.Imported() != (*origin == "imported") {
+            continue
+        }
+        if *via != "" && (dinner.Origin == nil || !strings.EqualFold(dinner.Origin.Via, *via)) {
+            continue
+        }
+        found++
+        fmt.Printf("%s (%s) - %s\n", dinner.Name, dinner.Category, dinner.Origin)
+    }
+    if found == 0 {
+        fmt.Println("No matching dinners")
+    }
+    return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// defaultRatingWeights scale a dinner's chance by its star rating, 1 to 5:
+// a 1-star dinner is rarely picked and a 5-star one two and a half times as
+// often as one nobody has rated
+var defaultRatingWeights = []float64{0.05, 0.4, 1, 1.6, 2.5}
+
+// RatingsConfig is how much a dinner's star rating counts when picking
+type RatingsConfig struct {
+    // Weights multiply the chance of a dinner rated 1 to 5 stars, against 1
+    // for a dinner without a rating; a weight of 0 means never, unless
+    // nothing else fits
+    Weights []float64 `json:"weights,omitempty"`
+}
+
+// validate checks there's a weight for each star and none is negative
+func (r *RatingsConfig) validate() error {
+    if len(r.Weights) == 0 {
+        return nil
+    }
+    if len(r.Weights) != 5 {
+        return fmt.Errorf("ratings: weights needs 5 entries, for 1 to 5 stars, not %d", len(r.Weights))
+    }
+    for i, weight := range r.Weights {
+        if weight < 0 {
+            return fmt.Errorf("ratings: the weight for %d stars can't be negative", i+1)
+        }
+    }
+    return nil
+}
+
+// weight returns the multiplier for a rating, 1 for none
+func (r *RatingsConfig) weight(stars int) float64 {
+    if stars < 1 || stars > 5 {
+        return 1
+    }
+    weights := defaultRatingWeights
+    if r != nil && len(r.Weights) == 5 {
+        weights = r.Weights
+    }
+    return weights[stars-1]
+}
+
+// rating returns the stars a dinner was given with "rate", or 0
+func (p *Preferences) rating(name string) int {
+    if p == nil {
+        return 0
+    }
+    for rated, stars := range p.Ratings {
+        if strings.EqualFold(rated, name) {
+            return stars
+        }
+    }
+    return 0
+}
+
+// runRateCommand handles "rate <dinner> <1-5>", or "rate" to list the ratings given
+func runRateCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker rate [<dinner> <1-5>]")
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    if state.Preferences == nil {
+        state.Preferences = &Preferences{}
+    }
+    prefs := state.Preferences
+
+    if len(args) == 0 {
+        if len(prefs.Ratings) == 0 {
+            fmt.Println("No dinners rated yet")
+            return nil
+        }
+        var names []string
+        for name := range prefs.Ratings {
+            names = append(names, name)
+        }
+        sort.Slice(names, func(i, j int) bool {
+            if prefs.Ratings[names[i]] != prefs.Ratings[names[j]] {
+                return prefs.Ratings[names[i]] > prefs.Ratings[names[j]]
+            }
+            return names[i] < names[j]
+        })
+        for _, name := range names {
+            fmt.Printf("%-5s  %s\n", strings.Repeat("*", prefs.Ratings[name]), name)
+        }
+        return nil
+    }
+    if len(args) < 2 {
+        return usage
+    }
+    stars, err := strconv.Atoi(args[len(args)-1])
+    if err != nil || stars < 1 || stars > 5 {
+        return fmt.Errorf("the rating must be a number from 1 to 5")
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    name := strings.TrimSpace(strings.Join(args[:len(args)-1], " "))
+    dinner, ok := dinners.FindDinner(name)
+    if !ok {
+        return fmt.Errorf("no dinner named %q", name)
+    }
+    if prefs.Ratings == nil {
+        prefs.Ratings = make(map[string]int)
+    }
+    for rated := range prefs.Ratings {
+        if strings.EqualFold(rated, dinner.Name) {
+            delete(prefs.Ratings, rated)
+        }
+    }
+    prefs.Ratings[dinner.Name] = stars
+
+    if err := state.Record("rate", fmt.Sprintf("%s %d/5", dinner.Name, stars)); err != nil {
+        return err
+    }
+    fmt.Printf("Rated %s %d/5\n", dinner.Name, stars)
+    return nil
+}
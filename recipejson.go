@@ -0,0 +1,400 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// SchemaRecipe is the subset of a schema.org Recipe used by most meal planners
+// (Mealie, Paprika's HTML export, recipe websites) to exchange recipes
+type SchemaRecipe struct {
+    Context          string          `json:"@context,omitempty"`
+    Type             json.RawMessage `json:"@type"`
+    Name             string          `json:"name"`
+    RecipeCategory   json.RawMessage `json:"recipeCategory,omitempty"`
+    RecipeIngredient []string        `json:"recipeIngredient"`
+    CookTime         string          `json:"cookTime,omitempty"`
+    TotalTime        string          `json:"totalTime,omitempty"`
+    Keywords         json.RawMessage `json:"keywords,omitempty"`
+    URL              string          `json:"url,omitempty"`
+    Author           json.RawMessage `json:"author,omitempty"`
+    Instructions     json.RawMessage `json:"recipeInstructions,omitempty"`
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:\d+D)?T?(?:(\d+)H)?(?:(\d+)M)?(?:\d+S)?$`)
+
+// parseISODuration turns "PT1H30M" into 90 minutes, returning 0 when unparseable
+func parseISODuration(d string) int {
+    m := isoDurationPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(d)))
+    if m == nil {
+        return 0
+    }
+    hours, _ := strconv.Atoi(m[1])
+    minutes, _ := strconv.Atoi(m[2])
+    return hours*60 + minutes
+}
+
+// formatISODuration turns minutes into "PT1H30M"
+func formatISODuration(minutes int) string {
+    if minutes <= 0 {
+        return ""
+    }
+    d := "PT"
+    if minutes >= 60 {
+        d += fmt.Sprintf("%dH", minutes/60)
+    }
+    if minutes%60 > 0 {
+        d += fmt.Sprintf("%dM", minutes%60)
+    }
+    return d
+}
+
+// stringOrList decodes a JSON value that may be a string, a comma-separated
+// string or a list of strings
+func stringOrList(raw json.RawMessage) []string {
+    if len(raw) == 0 {
+        return nil
+    }
+    var list []string
+    if json.Unmarshal(raw, &list) == nil {
+        return list
+    }
+    var single string
+    if json.Unmarshal(raw, &single) == nil {
+        var parts []string
+        for _, part := range strings.Split(single, ",") {
+            if part = strings.TrimSpace(part); part != "" {
+                parts = append(parts, part)
+            }
+        }
+        return parts
+    }
+    return nil
+}
+
+// authorName extracts a name from an author given as a string, object or list
+func authorName(raw json.RawMessage) string {
+    if names := stringOrList(raw); len(names) > 0 {
+        return names[0]
+    }
+    var person struct {
+        Name string `json:"name"`
+    }
+    if json.Unmarshal(raw, &person) == nil && person.Name != "" {
+        return person.Name
+    }
+    var people []struct {
+        Name string `json:"name"`
+    }
+    if json.Unmarshal(raw, &people) == nil && len(people) > 0 {
+        return people[0].Name
+    }
+    return ""
+}
+
+// howToStep is a schema.org HowToStep or HowToSection
+type howToStep struct {
+    Text     string            `json:"text"`
+    Name     string            `json:"name"`
+    Elements []json.RawMessage `json:"itemListElement"`
+}
+
+// instructionSteps flattens recipeInstructions given as text, a list of
+// strings, or HowToSteps grouped in HowToSections
+func instructionSteps(raw json.RawMessage) []string {
+    if len(raw) == 0 {
+        return nil
+    }
+    var text string
+    if json.Unmarshal(raw, &text) == nil {
+        var steps []string
+        for _, line := range strings.Split(text, "\n") {
+            if line = strings.TrimSpace(line); line != "" {
+                steps = append(steps, line)
+            }
+        }
+        return steps
+    }
+    var items []json.RawMessage
+    if json.Unmarshal(raw, &items) != nil {
+        return nil
+    }
+    var steps []string
+    for _, item := range items {
+        var step howToStep
+        switch {
+        case json.Unmarshal(item, &text) == nil:
+            steps = append(steps, instructionSteps(item)...)
+        case json.Unmarshal(item, &step) != nil:
+        case len(step.Elements) > 0:
+            elements, _ := json.Marshal(step.Elements)
+            steps = append(steps, instructionSteps(elements)...)
+        case step.Text != "":
+            steps = append(steps, strings.TrimSpace(step.Text))
+        case step.Name != "":
+            steps = append(steps, strings.TrimSpace(step.Name))
+        }
+    }
+    return steps
+}
+
+// ToDinner converts a schema.org recipe, using fallbackCategory when it has none
+func (r SchemaRecipe) ToDinner(fallbackCategory string) Dinner {
+    dinner := Dinner{
+        Name:        strings.TrimSpace(r.Name),
+        Category:    fallbackCategory,
+        Ingredients: TextIngredients(r.RecipeIngredient),
+        Tags:        stringOrList(r.Keywords),
+        Steps:       NewLazyList(instructionSteps(r.Instructions)),
+    }
+    if categories := stringOrList(r.RecipeCategory); len(categories) > 0 && fallbackCategory == "" {
+        dinner.Category = categories[0]
+    }
+    dinner.CookTime = parseISODuration(r.TotalTime)
+    if dinner.CookTime == 0 {
+        dinner.CookTime = parseISODuration(r.CookTime)
+    }
+    if author := authorName(r.Author); r.URL != "" || author != "" {
+        dinner.Source = &Source{URL: r.URL, Note: author}
+    }
+    return dinner
+}
+
+// RecipeFromDinner converts a dinner into a schema.org recipe
+func RecipeFromDinner(dinner Dinner) SchemaRecipe {
+    recipe := SchemaRecipe{
+        Context:          "https://schema.org",
+        Type:             json.RawMessage(`"Recipe"`),
+        Name:             dinner.Name,
+        RecipeIngredient: IngredientLines(dinner.Ingredients),
+        TotalTime:        formatISODuration(dinner.CookTime),
+    }
+    recipe.RecipeCategory, _ = json.Marshal(dinner.Category)
+    if len(dinner.Tags) > 0 {
+        recipe.Keywords, _ = json.Marshal(strings.Join(dinner.Tags, ", "))
+    }
+    if items := dinner.Steps.Items(); len(items) > 0 {
+        var steps []map[string]string
+        for _, step := range items {
+            steps = append(steps, map[string]string{"@type": "HowToStep", "text": step})
+        }
+        recipe.Instructions, _ = json.Marshal(steps)
+    }
+    if dinner.Source != nil {
+        recipe.URL = dinner.Source.URL
+        if author := dinner.Source.Note; author != "" {
+            recipe.Author, _ = json.Marshal(map[string]string{"@type": "Person", "name": author})
+        }
+    }
+    return recipe
+}
+
+// ParseSchemaRecipes reads a single recipe, a list of recipes, or a JSON-LD
+// document with an @graph, ignoring anything that isn't a Recipe
+func ParseSchemaRecipes(data []byte) ([]SchemaRecipe, error) {
+    var raw json.RawMessage
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return nil, fmt.Errorf("error parsing recipe JSON: %w", err)
+    }
+
+    var items []json.RawMessage
+    if err := json.Unmarshal(raw, &items); err != nil {
+        items = []json.RawMessage{raw}
+    }
+
+    var recipes []SchemaRecipe
+    for _, item := range items {
+        var graph struct {
+            Graph []json.RawMessage `json:"@graph"`
+        }
+        if json.Unmarshal(item, &graph) == nil && len(graph.Graph) > 0 {
+            nested, err := ParseSchemaRecipes(mustMarshal(graph.Graph))
+            if err != nil {
+                return nil, err
+            }
+            recipes = append(recipes, nested...)
+            continue
+        }
+
+        var recipe SchemaRecipe
+        if err := json.Unmarshal(item, &recipe); err != nil {
+            continue
+        }
+        for _, t := range stringOrList(recipe.Type) {
+            if t == "Recipe" && recipe.Name != "" {
+                recipes = append(recipes, recipe)
+                break
+            }
+        }
+    }
+    return recipes, nil
+}
+
+// mustMarshal marshals values that are known to be valid JSON
+func mustMarshal(v any) []byte {
+    data, _ := json.Marshal(v)
+    return data
+}
+
+// importDinners adds new dinners to the catalog, skipping names it already has
+func importDinners(data *DinnerData, incoming []Dinner) (added, skipped []string) {
+    if data.Dinners == nil {
+        data.Dinners = make(map[string][]Dinner)
+    }
+    for _, dinner := range incoming {
+        if _, exists := data.FindDinner(dinner.Name); exists || dinner.Name == "" {
+            skipped = append(skipped, dinner.Name)
+            continue
+        }
+        data.Dinners[dinner.Category] = append(data.Dinners[dinner.Category], dinner)
+        added = append(added, dinner.Name)
+    }
+    return added, skipped
+}
+
+// runImportCommand handles "import recipe-json <file>" and "import url <link>",
+// with [--category name] [--auto-category]. Recipes without a category, or
+// with one the catalog doesn't have, get the most similar existing category
+// offered, asked for interactively on a terminal.
+func runImportCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker import recipe-json <file>|url <link> [--category name] [--auto-category]|nutrition <file> [--overwrite]")
+    if len(args) == 0 {
+        return usage
+    }
+    if args[0] == "nutrition" {
+        return importNutrition(args[1:])
+    }
+
+    fs := flag.NewFlagSet("import", flag.ContinueOnError)
+    category := fs.String("category", "", "category for imported dinners (default: the recipe's own)")
+    auto := fs.Bool("auto-category", false, "accept suggested categories without asking")
+    positional, err := parseArgs(fs, args[1:])
+    if err != nil {
+        return err
+    }
+    if len(positional) != 1 {
+        return usage
+    }
+
+    var recipes []SchemaRecipe
+    switch args[0] {
+    case "recipe-json":
+        file, err := os.ReadFile(positional[0])
+        if err != nil {
+            return fmt.Errorf("error reading recipes: %w", err)
+        }
+        if recipes, err = ParseSchemaRecipes(file); err != nil {
+            return err
+        }
+    case "url":
+        if recipes, err = recipesFromURL(positional[0]); err != nil {
+            return err
+        }
+    default:
+        return usage
+    }
+    via := "import " + args[0]
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+
+    in := bufio.NewReader(os.Stdin)
+    interactive := !*auto && stdinIsTerminal()
+    var incoming []Dinner
+    for _, recipe := range recipes {
+        dinner := recipe.ToDinner(*category)
+        if existing, ok := dinners.hasCategory(dinner.Category); ok {
+            dinner.Category = existing
+        } else if *category == "" {
+            suggestion := dinners.SuggestCategory(dinner)
+            switch {
+            case interactive:
+                chosen, err := askCategory(in, dinners, dinner, suggestion)
+                if errors.Is(err, io.EOF) {
+                    return fmt.Errorf("no category given for %s, rerun with --category or --auto-category", dinner.Name)
+                }
+                if err != nil {
+                    return err
+                }
+                if chosen == "" {
+                    fmt.Printf("Skipping %s\n", dinner.Name)
+                    continue
+                }
+                dinner.Category = chosen
+            case *auto && suggestion != "":
+                fmt.Printf("Filing %s under %s\n", dinner.Name, suggestion)
+                dinner.Category = suggestion
+            case dinner.Category == "":
+                fmt.Printf("Skipping %s: no category%s, rerun with --category or --auto-category\n", dinner.Name, looksLike(suggestion))
+                continue
+            default:
+                logf("Note: %s is in a new category %s%s\n", dinner.Name, dinner.Category, looksLike(suggestion))
+            }
+        }
+        dinner.Origin = importedOrigin(via, recipe.URL)
+        incoming = append(incoming, dinner)
+    }
+
+    added, skipped := importDinners(dinners, incoming)
+    if len(added) > 0 {
+        if err := saveCatalog(dinners); err != nil {
+            return err
+        }
+    }
+
+    for _, name := range added {
+        fmt.Printf("Imported %s\n", name)
+    }
+    for _, name := range skipped {
+        fmt.Printf("Skipped %s: already in the catalog\n", name)
+    }
+    return nil
+}
+
+// runExportCommand handles "export recipe-json [file]", writing every dinner as
+// schema.org recipes, "export cards [file] [--format markdown|pdf]" and
+// "export ics [file]"
+func runExportCommand(args []string) error {
+    if len(args) > 0 && args[0] == "ics" {
+        return exportICS(args[1:])
+    }
+    if len(args) > 0 && args[0] == "cards" {
+        return exportCards(args[1:])
+    }
+    if len(args) == 0 || len(args) > 2 || args[0] != "recipe-json" {
+        return fmt.Errorf("usage: dinner-picker export recipe-json|cards|ics [file]")
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    var recipes []SchemaRecipe
+    for _, dinner := range dinners.AllDinners() {
+        recipes = append(recipes, RecipeFromDinner(dinner))
+    }
+
+    data, err := json.MarshalIndent(recipes, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling recipes: %w", err)
+    }
+    if len(args) == 1 {
+        fmt.Println(string(data))
+        return nil
+    }
+    if err := os.WriteFile(args[1], data, 0644); err != nil {
+        return fmt.Errorf("error writing recipes: %w", err)
+    }
+    logf("Exported %d recipes to %s\n", len(recipes), args[1])
+    return nil
+}
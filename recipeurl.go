@@ -0,0 +1,173 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "html"
+    "io"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// maxRecipePage is the most of a recipe page that's read, well past any real one
+const maxRecipePage = 5 << 20
+
+var (
+    jsonLDPattern      = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+    recipeItemPattern  = regexp.MustCompile(`(?i)itemtype\s*=\s*["']https?://schema\.org/Recipe["']`)
+    itempropPattern    = regexp.MustCompile(`(?is)<([a-z][a-z0-9]*)\b[^>]*\bitemprop\s*=\s*["']([^"']+)["'][^>]*>`)
+    contentAttrPattern = regexp.MustCompile(`(?is)\b(?:content|datetime)\s*=\s*["']([^"']*)["']`)
+    tagPattern         = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// fetchRecipePage downloads a recipe page
+func fetchRecipePage(link string) ([]byte, error) {
+    parsed, err := url.Parse(link)
+    if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+        return nil, fmt.Errorf("%q isn't a web address", link)
+    }
+    request, err := http.NewRequest(http.MethodGet, link, nil)
+    if err != nil {
+        return nil, fmt.Errorf("error fetching %s: %w", link, err)
+    }
+    // Some recipe sites turn away clients that don't say what they are
+    request.Header.Set("User-Agent", "dinner-picker/"+version)
+    request.Header.Set("Accept", "text/html")
+
+    client := &http.Client{Timeout: 20 * time.Second}
+    resp, err := client.Do(request)
+    if err != nil {
+        return nil, fmt.Errorf("error fetching %s: %w", link, err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("error fetching %s: %s", link, resp.Status)
+    }
+    page, err := io.ReadAll(io.LimitReader(resp.Body, maxRecipePage))
+    if err != nil {
+        return nil, fmt.Errorf("error reading %s: %w", link, err)
+    }
+    return page, nil
+}
+
+// RecipesFromHTML finds the schema.org recipes on a web page, from its JSON-LD
+// scripts or, failing that, its microdata
+func RecipesFromHTML(page []byte) []SchemaRecipe {
+    var recipes []SchemaRecipe
+    for _, match := range jsonLDPattern.FindAllSubmatch(page, -1) {
+        // A script that isn't valid JSON is another site's problem, not ours
+        found, err := ParseSchemaRecipes(match[1])
+        if err != nil {
+            continue
+        }
+        recipes = append(recipes, found...)
+    }
+    if len(recipes) > 0 {
+        return recipes
+    }
+    if recipe, ok := microdataRecipe(string(page)); ok {
+        recipes = append(recipes, recipe)
+    }
+    return recipes
+}
+
+// microdataRecipe reads a recipe marked up with schema.org microdata
+// (itemprop attributes), taking the first name, which on recipe pages comes
+// before the author's
+func microdataRecipe(page string) (SchemaRecipe, bool) {
+    start := recipeItemPattern.FindStringIndex(page)
+    if start == nil {
+        return SchemaRecipe{}, false
+    }
+    page = page[start[0]:]
+
+    var recipe SchemaRecipe
+    var categories, keywords, steps []string
+    for _, match := range itempropPattern.FindAllStringSubmatchIndex(page, -1) {
+        tag, name := page[match[2]:match[3]], page[match[4]:match[5]]
+        value := ""
+        if content := contentAttrPattern.FindStringSubmatch(page[match[0]:match[1]]); content != nil {
+            value = html.UnescapeString(content[1])
+        } else if end := strings.Index(strings.ToLower(page[match[1]:]), "</"+strings.ToLower(tag)); end >= 0 {
+            value = pageText(page[match[1] : match[1]+end])
+        }
+        value = strings.TrimSpace(value)
+        if value == "" {
+            continue
+        }
+
+        switch name {
+        case "name":
+            if recipe.Name == "" {
+                recipe.Name = value
+            }
+        case "recipeIngredient", "ingredients":
+            recipe.RecipeIngredient = append(recipe.RecipeIngredient, value)
+        case "recipeInstructions":
+            steps = append(steps, value)
+        case "recipeCategory":
+            categories = append(categories, value)
+        case "keywords":
+            keywords = append(keywords, value)
+        case "cookTime":
+            recipe.CookTime = value
+        case "totalTime":
+            recipe.TotalTime = value
+        case "url":
+            if recipe.URL == "" {
+                recipe.URL = value
+            }
+        }
+    }
+    if recipe.Name == "" || len(recipe.RecipeIngredient) == 0 {
+        return SchemaRecipe{}, false
+    }
+    recipe.Type = json.RawMessage(`"Recipe"`)
+    if len(categories) > 0 {
+        recipe.RecipeCategory = mustMarshal(categories)
+    }
+    if len(keywords) > 0 {
+        recipe.Keywords = mustMarshal(strings.Join(keywords, ", "))
+    }
+    if len(steps) > 0 {
+        recipe.Instructions = mustMarshal(steps)
+    }
+    return recipe, true
+}
+
+// pageText turns a piece of HTML into plain text, one line per block
+func pageText(fragment string) string {
+    for _, block := range []string{"<br", "<li", "<p", "</p", "<div", "</div"} {
+        fragment = strings.ReplaceAll(fragment, block, "\n"+block)
+    }
+    text := html.UnescapeString(tagPattern.ReplaceAllString(fragment, ""))
+    var lines []string
+    for _, line := range strings.Split(text, "\n") {
+        if line = strings.Join(strings.Fields(line), " "); line != "" {
+            lines = append(lines, line)
+        }
+    }
+    return strings.Join(lines, "\n")
+}
+
+// recipesFromURL fetches a recipe page and reads the recipes on it, giving
+// each the page's address if it doesn't carry its own
+func recipesFromURL(link string) ([]SchemaRecipe, error) {
+    page, err := fetchRecipePage(link)
+    if err != nil {
+        return nil, err
+    }
+    recipes := RecipesFromHTML(page)
+    if len(recipes) == 0 {
+        return nil, fmt.Errorf("no schema.org recipe found on %s", link)
+    }
+    for i := range recipes {
+        if recipes[i].URL == "" {
+            recipes[i].URL = link
+        }
+    }
+    return recipes, nil
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Notifier is a destination for reminder messages
+type Notifier interface {
+    Name() string
+    Send(text string) error
+}
+
+// RemindersConfig sets when the daemon reminds the household to start cooking
+type RemindersConfig struct {
+    Enabled bool `json:"enabled"`
+
+    // EatAt is when dinner should be on the table per weekday, e.g.
+    // {"Saturday": "18:00"}; other days use dinner_hour
+    EatAt map[string]string `json:"eat_at,omitempty"`
+
+    // BufferMinutes is added before the cook time; DefaultCookMinutes is used
+    // for dinners without a cook_time (default 30)
+    BufferMinutes      int `json:"buffer_minutes,omitempty"`
+    DefaultCookMinutes int `json:"default_cook_minutes,omitempty"`
+
+    // Telegram defaults to the week routine's bot; Webhook gets a JSON POST of {"text": ...}
+    Telegram *TelegramConfig `json:"telegram,omitempty"`
+    Webhook  string          `json:"webhook,omitempty"`
+}
+
+// validate checks the eating times
+func (r *RemindersConfig) validate() error {
+    for day, clock := range r.EatAt {
+        if dayIndex(day) < 0 {
+            return fmt.Errorf("reminders: unknown day %q", day)
+        }
+        if _, err := time.Parse("15:04", clock); err != nil {
+            return fmt.Errorf("reminders: %s eat_at %q is not HH:MM", day, clock)
+        }
+    }
+    return nil
+}
+
+// Name identifies the notifier in error messages
+func (t *TelegramConfig) Name() string {
+    return "telegram"
+}
+
+// WebhookNotifier posts messages as JSON to a URL
+type WebhookNotifier struct {
+    URL string
+}
+
+// Name identifies the notifier in error messages
+func (w WebhookNotifier) Name() string {
+    return "webhook"
+}
+
+// Send posts {"text": text} to the webhook
+func (w WebhookNotifier) Send(text string) error {
+    body, err := json.Marshal(map[string]string{"text": text})
+    if err != nil {
+        return fmt.Errorf("error marshaling webhook message: %w", err)
+    }
+    client := &http.Client{Timeout: 10 * time.Second}
+    resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("error sending webhook message: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return fmt.Errorf("error sending webhook message: %s", resp.Status)
+    }
+    return nil
+}
+
+// Notifiers returns the configured reminder destinations
+func (c *Config) Notifiers() []Notifier {
+    if c.Reminders == nil {
+        return nil
+    }
+    var notifiers []Notifier
+    telegram := c.Reminders.Telegram
+    if telegram == nil && c.Week != nil && c.Week.Telegram != nil {
+        telegram = &c.Week.Telegram.TelegramConfig
+    }
+    if telegram != nil && telegram.BotToken != "" {
+        notifiers = append(notifiers, telegram)
+    }
+    if c.Reminders.Webhook != "" {
+        notifiers = append(notifiers, WebhookNotifier{URL: c.Reminders.Webhook})
+    }
+    return notifiers
+}
+
+// cookMinutes is the cook time the reminder assumes for a dinner; none for
+// a no-cook dinner
+func (c *Config) cookMinutes(dinner Dinner) int {
+    if dinner.NoCook {
+        return 0
+    }
+    if dinner.CookTime > 0 {
+        return dinner.CookTime
+    }
+    if c.Reminders != nil && c.Reminders.DefaultCookMinutes > 0 {
+        return c.Reminders.DefaultCookMinutes
+    }
+    return 30
+}
+
+// Reminder works out when dinner on date should be eaten and when to start
+// cooking it, and the message to send
+func (c *Config) Reminder(date time.Time, dinner Dinner) (start, eat time.Time, message string) {
+    eatAt := parseClock(c.DinnerHour, 19*60)
+    buffer := 0
+    if c.Reminders != nil {
+        if clock, ok := c.Reminders.EatAt[date.Weekday().String()]; ok {
+            eatAt = parseClock(clock, eatAt)
+        }
+        buffer = c.Reminders.BufferMinutes
+    }
+    cook := c.cookMinutes(dinner)
+
+    midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+    eat = midnight.Add(time.Duration(eatAt) * time.Minute)
+    start = eat.Add(-time.Duration(cook+buffer) * time.Minute)
+    if dinner.NoCook {
+        return start, eat, fmt.Sprintf("Tonight: %s - nothing to cook, on the table by %s", dinner.Name, eat.Format("15:04"))
+    }
+    return start, eat, fmt.Sprintf("Tonight: %s - start by %s (cook time %dm)", dinner.Name, start.Format("15:04"), cook)
+}
+
+// EveningFit is a day's reminder fitted around its calendar events
+type EveningFit struct {
+    Start   time.Time
+    Eat     time.Time
+    Message string
+
+    // Before is the event dinner is eaten ahead of, e.g. "Soccer at 18:30",
+    // and Free when cooking can start, after home_from or a busy afternoon
+    Before string
+    Free   time.Time
+
+    // Short is set when the time free is less than the dinner needs, and
+    // Minutes is how long there is to cook
+    Short   bool
+    Minutes int
+}
+
+// FitEvening works out the day's reminder around the calendar. An afternoon
+// or evening event starting after home_from and still on at dinner time
+// brings dinner forward to before it, so cooking starts that much earlier;
+// one that ends before dinner keeps the cook busy until then. Without a
+// calendar it is just the Reminder.
+func (c *Config) FitEvening(date time.Time, dinner Dinner, events []Event) EveningFit {
+    start, eat, message := c.Reminder(date, dinner)
+    fit := EveningFit{Start: start, Eat: eat, Message: message}
+    if c.Calendar == nil {
+        return fit
+    }
+    need := eat.Sub(start)
+    midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+    fit.Free = midnight.Add(time.Duration(parseClock(c.Calendar.HomeFrom, 17*60)) * time.Minute)
+    evening := eveningEvents(events, date)
+    for _, event := range evening {
+        if event.Start.After(fit.Free) && event.Start.Before(fit.Eat) && event.End.After(eat) {
+            fit.Eat = event.Start
+            fit.Before = fmt.Sprintf("%s at %s", event.Summary, event.Start.Format("15:04"))
+        }
+    }
+    busy := ""
+    for _, event := range evening {
+        if event.End.After(fit.Free) && !event.End.After(fit.Eat) && event.Start.Before(fit.Eat) {
+            fit.Free = event.End
+            busy = event.Summary
+        }
+    }
+    if fit.Before == "" && busy == "" {
+        return fit
+    }
+
+    cook := c.cookMinutes(dinner)
+    fit.Start = fit.Eat.Add(-need)
+    switch {
+    case dinner.NoCook && fit.Before != "":
+        fit.Message = fmt.Sprintf("Tonight: %s - nothing to cook, on the table by %s, before %s", dinner.Name, fit.Eat.Format("15:04"), fit.Before)
+    case fit.Before != "":
+        fit.Message = fmt.Sprintf("Tonight: %s - start by %s to eat before %s (cook time %dm)", dinner.Name, fit.Start.Format("15:04"), fit.Before, cook)
+    }
+    if !fit.Start.Before(fit.Free) {
+        return fit
+    }
+    fit.Short = true
+    fit.Minutes = int(fit.Eat.Sub(fit.Free).Minutes()) - int(need.Minutes()) + cook
+    from := "from " + fit.Free.Format("15:04")
+    if busy != "" {
+        from = fmt.Sprintf("after %s at %s", busy, fit.Free.Format("15:04"))
+    }
+    fit.Message += fmt.Sprintf("; only %dm free %s, it needs %dm", int(fit.Eat.Sub(fit.Free).Minutes()), from, int(need.Minutes()))
+    return fit
+}
+
+// quickerSwaps returns up to two dinners swap could put on a day that cook
+// within minutes, the longest first: from the day's category, not already
+// planned or banned, and allowed by the day's dietary rules and equipment
+func quickerSwaps(dinners *DinnerData, state *WeekState, config *Config, day string, minutes int) []Dinner {
+    current, ok := state.Plan.Dinner(day)
+    if !ok {
+        return nil
+    }
+    category, _ := dinners.ResolveCategory(current.Category, config.CategoryFallbacks)
+    date := state.Plan.WeekStart.AddDate(0, 0, dayIndex(day))
+    season := state.Plan.Season
+    if season == "" {
+        season = config.Seasons.On(date)
+    }
+    var quicker []Dinner
+    for _, dinner := range dinners.Dinners[category] {
+        if dinner.Name == current.Name || state.IsAlreadySelected(dinner) || state.IsBanned(dinner) || config.cookMinutes(dinner) > minutes {
+            continue
+        }
+        if config.Seasons.strict() && !dinner.InSeason(season) {
+            continue
+        }
+        if observancesPermit(config.Observances, date, dinner) && config.Equipment.Permits(date, dinner) {
+            quicker = append(quicker, dinner)
+        }
+    }
+    sort.SliceStable(quicker, func(i, j int) bool {
+        return config.cookMinutes(quicker[i]) > config.cookMinutes(quicker[j])
+    })
+    if len(quicker) > 2 {
+        quicker = quicker[:2]
+    }
+    return quicker
+}
+
+// tonightReminder works out a planned day's reminder around the calendar's
+// events, suggesting a quicker swap when the dinner doesn't fit the time
+// free. Leftovers only want warming through.
+func tonightReminder(state *WeekState, config *Config, entry PlanDay, events []Event, date time.Time) EveningFit {
+    fit := config.FitEvening(date, entry.Dinner, events)
+    if entry.IsLeftovers() {
+        fit.Start = fit.Eat.Add(-time.Duration(leftoverReheatMinutes) * time.Minute)
+        fit.Message = fmt.Sprintf("Tonight: leftover %s from %s - reheat by %s", entry.Dinner.Name, entry.LeftoversFrom, fit.Eat.Format("15:04"))
+        if fit.Before != "" {
+            fit.Message += ", before " + fit.Before
+        }
+        fit.Short = false
+        return fit
+    }
+    if !fit.Short {
+        return fit
+    }
+    if fit.Minutes <= 0 {
+        fit.Message += ", no time to cook"
+        return fit
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        warnf("%v", err)
+        return fit
+    }
+    var names []string
+    for _, dinner := range quickerSwaps(dinners, state, config, entry.Day, fit.Minutes) {
+        names = append(names, fmt.Sprintf("%s (%dm)", dinner.Name, config.cookMinutes(dinner)))
+    }
+    if len(names) == 0 {
+        fit.Message += fmt.Sprintf(", and nothing else in %s cooks in %dm", entry.Dinner.Category, fit.Minutes)
+        return fit
+    }
+    fit.Message += fmt.Sprintf("; %s would fit: dinner-picker swap %s --max-minutes %d", strings.Join(names, " or "), entry.Day, fit.Minutes)
+    return fit
+}
+
+// dueReminder returns tonight's reminder if it's between the start and eating
+// times and the dinner isn't already cooked or skipped
+func dueReminder(state *WeekState, config *Config, styles CategoryStyles, events []Event, now time.Time) (key, message string, ok bool) {
+    state.CheckNewWeek()
+    entry, ok := state.Plan.Entry(now.Weekday().String())
+    if !ok || entry.Outcome != "" {
+        return "", "", false
+    }
+    fit := tonightReminder(state, config, entry, events, now)
+    if now.Before(fit.Start) || !now.Before(fit.Eat) {
+        return "", "", false
+    }
+    return now.Format("2006-01-02") + " " + entry.Dinner.Name, styles.Label(entry.Dinner.Category, fit.Message), true
+}
+
+// runDaemonCommand handles "daemon [--interval 30s]", staying in the foreground and
+// sending each evening's cooking reminder to the configured destinations
+func runDaemonCommand(args []string) error {
+    fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+    interval := fs.Duration("interval", 30*time.Second, "how often to check the plan")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    if config.Reminders == nil || !config.Reminders.Enabled {
+        return fmt.Errorf("reminders are not enabled in %s", ConfigFileName)
+    }
+    notifiers := config.Notifiers()
+    if len(notifiers) == 0 {
+        return fmt.Errorf("reminders need a telegram bot or a webhook to send to")
+    }
+    var names []string
+    for _, notifier := range notifiers {
+        names = append(names, notifier.Name())
+    }
+    fmt.Printf("Sending dinner reminders to %s\n", strings.Join(names, ", "))
+
+    sent := make(map[string]bool)
+    var events []Event
+    var fetched time.Time
+    for {
+        // The calendar is read again every quarter of an hour, and reminders
+        // go by the plain dinner time while it can't be
+        if config.Calendar != nil && time.Since(fetched) >= 15*time.Minute {
+            fetched = time.Now()
+            if events, err = FetchCalendar(config.Calendar.URL); err != nil {
+                warnf("ignoring calendar: %v", err)
+            }
+        }
+        // The plan is reloaded every time so swaps and cooked marks are picked up
+        state, err := LoadState()
+        if err != nil {
+            warnf("%v", err)
+        } else if key, message, ok := dueReminder(state, config, loadStyles(), events, time.Now()); ok && !sent[key] {
+            sent[key] = true
+            for _, notifier := range notifiers {
+                if err := deliver(config, notifier.Name(), message); err != nil {
+                    warnf("%s: %v", notifier.Name(), err)
+                }
+            }
+            fmt.Println(message)
+        }
+        // Whatever failed before, here or in another command, is retried as it falls due
+        if sent, _, err := flushOutbox(config, time.Now(), false); err != nil {
+            warnf("%v", err)
+        } else if sent > 0 {
+            fmt.Printf("Sent %d queued messages from the outbox\n", sent)
+        }
+        time.Sleep(*interval)
+    }
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "html/template"
+    "io"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// countedName is a dinner or category and how often it came up
+type countedName struct {
+    Name  string
+    Count int
+}
+
+// newcomer is a dinner first eaten in the report's year, with its average rating
+type newcomer struct {
+    Name   string
+    Rating float64
+    Eaten  int
+}
+
+// YearReport is a year's recap from the history: what was eaten, how often,
+// what was new, and roughly what it cost
+type YearReport struct {
+    Year        int
+    Weeks       int
+    Planned     int
+    Eaten       int
+    Skipped     int
+    Substituted int
+    Takeout     int
+    Unique      int
+    TopDinners  []countedName
+    Categories  []countedName
+    Newcomers   []newcomer
+
+    // Spend is the estimate, empty when no dinner has a price
+    Spend string
+}
+
+// reportTopDinners and reportNewcomers are how many of each the report lists
+const (
+    reportTopDinners = 10
+    reportNewcomers  = 5
+)
+
+// historyDate returns the day's date, worked out from its week for records
+// that have none
+func historyDate(week HistoryWeek, day HistoryDay) time.Time {
+    if !day.Date.IsZero() {
+        return day.Date
+    }
+    return week.WeekStart.AddDate(0, 0, dayIndex(day.Day))
+}
+
+// eatenName returns what was eaten on a day, counted as goals count it:
+// nothing when skipped, the substitute when there was one
+func eatenName(day HistoryDay) (string, bool) {
+    switch day.Outcome {
+    case OutcomeSkipped:
+        return "", false
+    case OutcomeSubstituted:
+        return day.Substitute, day.Substitute != ""
+    }
+    return day.Dinner, true
+}
+
+// sortCounted orders names by count, most first, then by name
+func sortCounted(counts map[string]*countedName) []countedName {
+    var sorted []countedName
+    for _, c := range counts {
+        sorted = append(sorted, *c)
+    }
+    sort.Slice(sorted, func(i, j int) bool {
+        if sorted[i].Count != sorted[j].Count {
+            return sorted[i].Count > sorted[j].Count
+        }
+        return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+    })
+    return sorted
+}
+
+// NewYearReport sums up the days of a year in the history. Newcomers are
+// dinners eaten that year and never before, best rated first, by the stars
+// given in review or with "rate".
+func NewYearReport(year int, state *WeekState, dinners *DinnerData, config *Config) *YearReport {
+    report := &YearReport{Year: year}
+    firstEaten := make(map[string]time.Time)
+    dinnerCounts := make(map[string]*countedName)
+    categoryCounts := make(map[string]*countedName)
+    stars := make(map[string][]int)
+    spent, homeCooked := 0.0, 0
+
+    for _, week := range state.History {
+        inYear := false
+        for _, day := range week.Days {
+            date := historyDate(week, day)
+            name, eaten := eatenName(day)
+            key := strings.ToLower(name)
+            if eaten && (firstEaten[key].IsZero() || date.Before(firstEaten[key])) {
+                firstEaten[key] = date
+            }
+            if date.Year() != year {
+                continue
+            }
+            inYear = true
+            report.Planned++
+            switch day.Outcome {
+            case OutcomeSkipped:
+                report.Skipped++
+            case OutcomeSubstituted:
+                report.Substituted++
+            }
+            if !eaten {
+                continue
+            }
+            report.Eaten++
+            cost, _ := dayCost(day, dinners, config.Household, config.Spend)
+            spent += cost
+            dinner, found := dinners.FindDinner(name)
+            if isTakeout(dinner, found, name) {
+                report.Takeout++
+                continue
+            }
+            homeCooked++
+            category := day.Category
+            if found {
+                name, category = dinner.Name, dinner.Category
+            } else if day.Outcome == OutcomeSubstituted {
+                category = "other"
+            }
+            if dinnerCounts[key] == nil {
+                dinnerCounts[key] = &countedName{Name: name}
+            }
+            dinnerCounts[key].Count++
+            if categoryCounts[strings.ToLower(category)] == nil {
+                categoryCounts[strings.ToLower(category)] = &countedName{Name: category}
+            }
+            categoryCounts[strings.ToLower(category)].Count++
+            if day.Rating > 0 && day.Outcome != OutcomeSubstituted {
+                stars[key] = append(stars[key], day.Rating)
+            }
+        }
+        if inYear {
+            report.Weeks++
+        }
+    }
+
+    report.Unique = len(dinnerCounts)
+    report.Categories = sortCounted(categoryCounts)
+    report.TopDinners = sortCounted(dinnerCounts)
+    for _, dinner := range report.TopDinners {
+        key := strings.ToLower(dinner.Name)
+        if firstEaten[key].Year() != year {
+            continue
+        }
+        given := stars[key]
+        if rated := state.Preferences.rating(dinner.Name); rated > 0 {
+            given = append(given, rated)
+        }
+        if len(given) == 0 {
+            continue
+        }
+        sum := 0
+        for _, s := range given {
+            sum += s
+        }
+        report.Newcomers = append(report.Newcomers, newcomer{Name: dinner.Name, Rating: float64(sum) / float64(len(given)), Eaten: dinner.Count})
+    }
+    sort.SliceStable(report.Newcomers, func(i, j int) bool {
+        return report.Newcomers[i].Rating > report.Newcomers[j].Rating
+    })
+    if len(report.Newcomers) > reportNewcomers {
+        report.Newcomers = report.Newcomers[:reportNewcomers]
+    }
+    if len(report.TopDinners) > reportTopDinners {
+        report.TopDinners = report.TopDinners[:reportTopDinners]
+    }
+
+    if spent > 0 || config.Spend != nil {
+        report.Spend = fmt.Sprintf("about %s (%d home-cooked dinners", config.Spend.format(spent), homeCooked)
+        if report.Takeout > 0 {
+            report.Spend += fmt.Sprintf(", %d takeaway nights", report.Takeout)
+        }
+        report.Spend += ")"
+    }
+    return report
+}
+
+// reportSection is a heading and its lines, shared by the text, HTML and
+// PDF reports so they say the same
+type reportSection struct {
+    Heading string
+    Lines   []string
+}
+
+// Title is the report's heading
+func (r *YearReport) Title() string {
+    return fmt.Sprintf("%d in dinners", r.Year)
+}
+
+// Sections lays the report out for reading
+func (r *YearReport) Sections() []reportSection {
+    overview := reportSection{Heading: "The year", Lines: []string{
+        fmt.Sprintf("%d dinners eaten over %d weeks, %d different ones", r.Eaten, r.Weeks, r.Unique),
+        fmt.Sprintf("%d of %d planned days skipped, %d swapped for something else", r.Skipped, r.Planned, r.Substituted),
+        fmt.Sprintf("%d takeaway nights", r.Takeout),
+    }}
+    if r.Spend != "" {
+        overview.Lines = append(overview.Lines, "Spent "+r.Spend)
+    } else {
+        overview.Lines = append(overview.Lines, fmt.Sprintf("Give dinners a cost, or set spend.per_serving in %s, for a spend estimate", ConfigFileName))
+    }
+    sections := []reportSection{overview}
+
+    top := reportSection{Heading: "Most cooked"}
+    for i, dinner := range r.TopDinners {
+        top.Lines = append(top.Lines, fmt.Sprintf("%d. %s, %d times", i+1, dinner.Name, dinner.Count))
+    }
+    categories := reportSection{Heading: "By category"}
+    for _, category := range r.Categories {
+        categories.Lines = append(categories.Lines, fmt.Sprintf("%s: %d (%d%%)", category.Name, category.Count, category.Count*100/max(r.Eaten-r.Takeout, 1)))
+    }
+    newcomers := reportSection{Heading: "Best new dinners"}
+    for _, dinner := range r.Newcomers {
+        newcomers.Lines = append(newcomers.Lines, fmt.Sprintf("%s, %s stars, eaten %d times", dinner.Name, formatServings(dinner.Rating), dinner.Eaten))
+    }
+    if len(newcomers.Lines) == 0 {
+        newcomers.Lines = []string{"No rated dinners new this year; rate them in review to see them here"}
+    }
+    for _, section := range []reportSection{top, categories, newcomers} {
+        if len(section.Lines) > 0 {
+            sections = append(sections, section)
+        }
+    }
+    return sections
+}
+
+// WriteText writes the report as plain text
+func (r *YearReport) WriteText(w io.Writer) error {
+    fmt.Fprintf(w, "=== %s ===\n", strings.ToUpper(r.Title()))
+    for _, section := range r.Sections() {
+        fmt.Fprintf(w, "\n%s\n", section.Heading)
+        for _, line := range section.Lines {
+            fmt.Fprintf(w, "  %s\n", line)
+        }
+    }
+    return nil
+}
+
+// reportPage is the HTML report, one page that prints well too
+var reportPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+h1 { font-size: 2.2rem; margin-bottom: 0.2rem; }
+h2 { font-size: 1.2rem; border-bottom: 1px solid #ddd; padding-bottom: 0.2rem; margin-top: 2rem; }
+li { margin: 0.3rem 0; }
+footer { margin-top: 3rem; color: #888; font-size: 0.85rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Sections}}<h2>{{.Heading}}</h2>
+<ul>
+{{range .Lines}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}<footer>Made by dinner-picker on {{.Made}}</footer>
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a web page
+func (r *YearReport) WriteHTML(w io.Writer) error {
+    return reportPage.Execute(w, struct {
+        *YearReport
+        Made string
+    }{r, time.Now().Format("January 2, 2006")})
+}
+
+// WritePDF writes the report as a PDF document
+func (r *YearReport) WritePDF(w io.Writer) error {
+    lines := []pdfLine{{Text: r.Title(), Size: 24}}
+    for _, section := range r.Sections() {
+        lines = append(lines, pdfLine{}, pdfLine{Text: section.Heading, Size: 15})
+        for _, line := range section.Lines {
+            lines = append(lines, pdfLine{Text: line, Size: 11})
+        }
+    }
+    return writePDF(w, r.Title(), lines)
+}
+
+// runReportCommand handles "report year [YYYY] [--format text|html|pdf]
+// [--out file]", a recap of the year's dinners from the history
+func runReportCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker report year [YYYY] [--format text|html|pdf] [--out file]")
+    if len(args) == 0 || args[0] != "year" {
+        return usage
+    }
+    fs := flag.NewFlagSet("report year", flag.ContinueOnError)
+    format := fs.String("format", "", "text, html or pdf (default from --out's extension, else text)")
+    out := fs.String("out", "", "write the report to a file instead of printing it")
+    positional, err := parseArgs(fs, args[1:])
+    if err != nil {
+        return err
+    }
+    year := time.Now().Year()
+    switch len(positional) {
+    case 0:
+    case 1:
+        if year, err = strconv.Atoi(positional[0]); err != nil || year < 1900 {
+            return fmt.Errorf("invalid year %q", positional[0])
+        }
+    default:
+        return usage
+    }
+    if *format == "" {
+        *format = "text"
+        for _, ext := range []string{"html", "pdf"} {
+            if strings.HasSuffix(strings.ToLower(*out), "."+ext) {
+                *format = ext
+            }
+        }
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    report := NewYearReport(year, state, dinners, config)
+    if report.Planned == 0 {
+        return fmt.Errorf("no history for %d", year)
+    }
+
+    var write func(io.Writer) error
+    switch *format {
+    case "text":
+        write = report.WriteText
+    case "html":
+        write = report.WriteHTML
+    case "pdf":
+        write = report.WritePDF
+    default:
+        return fmt.Errorf("unknown report format %q, want text, html or pdf", *format)
+    }
+    if *out == "" {
+        return write(os.Stdout)
+    }
+    file, err := os.Create(*out)
+    if err != nil {
+        return fmt.Errorf("error writing report: %w", err)
+    }
+    defer file.Close()
+    if err := write(file); err != nil {
+        return fmt.Errorf("error writing report: %w", err)
+    }
+    logf("Wrote the %d report to %s\n", year, *out)
+    return nil
+}
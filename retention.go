@@ -0,0 +1,253 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// defaultKeepMonths is how long week-by-week history is kept unless config says otherwise
+const defaultKeepMonths = 24
+
+// HistoryConfig is the retention policy for past weeks
+type HistoryConfig struct {
+    // KeepMonths is how many months of week-by-week history "history
+    // compact" keeps; older weeks are folded into monthly summaries
+    KeepMonths int `json:"keep_months,omitempty"`
+}
+
+// validate checks the retention period
+func (h *HistoryConfig) validate() error {
+    if h.KeepMonths < 0 {
+        return fmt.Errorf("history: keep_months can't be negative")
+    }
+    return nil
+}
+
+// keepMonths returns the retention period, with the default
+func (h *HistoryConfig) keepMonths() int {
+    if h == nil || h.KeepMonths == 0 {
+        return defaultKeepMonths
+    }
+    return h.KeepMonths
+}
+
+// MonthSummary is what's left of a month's weeks once they're compacted:
+// counts only, without dinner names, notes or what was had instead
+type MonthSummary struct {
+    Month       string `json:"month"`
+    Planned     int    `json:"planned"`
+    Cooked      int    `json:"cooked"`
+    Skipped     int    `json:"skipped"`
+    Substituted int    `json:"substituted"`
+    Takeout     int    `json:"takeout,omitempty"`
+
+    // Categories counts the dinners eaten from each category
+    Categories map[string]int `json:"categories,omitempty"`
+
+    // RatingSum and Rated add up the ratings given, for the average
+    RatingSum int `json:"rating_sum,omitempty"`
+    Rated     int `json:"rated,omitempty"`
+}
+
+// add folds one planned day into the summary. Days without an outcome count
+// as eaten, as they do everywhere else.
+func (m *MonthSummary) add(day HistoryDay, dinners *DinnerData) {
+    m.Planned++
+    switch day.Outcome {
+    case OutcomeSkipped:
+        m.Skipped++
+    case OutcomeSubstituted:
+        m.Substituted++
+        dinner, found := Dinner{}, false
+        if dinners != nil {
+            dinner, found = dinners.FindDinner(day.Substitute)
+        }
+        if isTakeout(dinner, found, day.Substitute) {
+            m.Takeout++
+        }
+    default:
+        if day.Outcome == OutcomeCooked {
+            m.Cooked++
+        }
+        if m.Categories == nil {
+            m.Categories = make(map[string]int)
+        }
+        m.Categories[day.Category]++
+    }
+    if day.Rating > 0 {
+        m.RatingSum += day.Rating
+        m.Rated++
+    }
+}
+
+// topCategories lists the most eaten categories, most first
+func (m MonthSummary) topCategories(n int) []string {
+    var names []string
+    for name := range m.Categories {
+        names = append(names, name)
+    }
+    sort.Slice(names, func(i, j int) bool {
+        if m.Categories[names[i]] != m.Categories[names[j]] {
+            return m.Categories[names[i]] > m.Categories[names[j]]
+        }
+        return names[i] < names[j]
+    })
+    if len(names) > n {
+        names = names[:n]
+    }
+    for i, name := range names {
+        names[i] = fmt.Sprintf("%s %d", name, m.Categories[name])
+    }
+    return names
+}
+
+// CompactHistory folds every week that ended before cutoff into monthly
+// summaries, by the month each day fell in, and drops the weeks. It returns
+// how many weeks went.
+func (s *WeekState) CompactHistory(cutoff time.Time, dinners *DinnerData) int {
+    months := make(map[string]*MonthSummary)
+    for _, summary := range s.MonthlyHistory {
+        categories := make(map[string]int)
+        for name, n := range summary.Categories {
+            categories[name] = n
+        }
+        summary.Categories = categories
+        months[summary.Month] = &summary
+    }
+    var kept []HistoryWeek
+    compacted := 0
+    for _, week := range s.History {
+        if week.WeekStart.AddDate(0, 0, 7).After(cutoff) {
+            kept = append(kept, week)
+            continue
+        }
+        compacted++
+        for _, day := range week.Days {
+            month := day.Date.Format("2006-01")
+            if months[month] == nil {
+                months[month] = &MonthSummary{Month: month}
+            }
+            months[month].add(day, dinners)
+        }
+    }
+    if compacted == 0 {
+        return 0
+    }
+
+    s.MonthlyHistory = nil
+    for _, summary := range months {
+        s.MonthlyHistory = append(s.MonthlyHistory, *summary)
+    }
+    sort.Slice(s.MonthlyHistory, func(i, j int) bool {
+        return s.MonthlyHistory[i].Month < s.MonthlyHistory[j].Month
+    })
+    s.History = kept
+    return compacted
+}
+
+// scrubJournal clears the state snapshots from every journal entry but the
+// last, which is all recovery needs, so history that was compacted away
+// doesn't live on in the journal. Who changed what, and when, stays for audit.
+func scrubJournal() error {
+    unlock, err := lockData()
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    entries, err := ReadJournal()
+    if err != nil || len(entries) == 0 {
+        return err
+    }
+    var buf bytes.Buffer
+    for i, entry := range entries {
+        if i < len(entries)-1 {
+            entry.State = nil
+        }
+        line, err := json.Marshal(entry)
+        if err != nil {
+            return fmt.Errorf("error marshaling journal entry: %w", err)
+        }
+        buf.Write(append(line, '\n'))
+    }
+    if err := writeFileAtomic(dataPath(JournalFileName), buf.Bytes()); err != nil {
+        return fmt.Errorf("error writing journal: %w", err)
+    }
+    return nil
+}
+
+// runHistoryCompactCommand handles "history compact [--keep-months N]
+// [--dry-run]", folding weeks older than the retention period into monthly
+// summaries
+func runHistoryCompactCommand(args []string) error {
+    fs := flag.NewFlagSet("history compact", flag.ContinueOnError)
+    keep := fs.Int("keep-months", 0, "months of week-by-week history to keep (default from config, else 24)")
+    dryRun := fs.Bool("dry-run", false, "say what would be compacted without changing anything")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    if *keep < 0 {
+        return fmt.Errorf("--keep-months can't be negative")
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    months := *keep
+    if months == 0 {
+        months = config.History.keepMonths()
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    // Without the catalog, takeaway is recognised by the substitute's description alone
+    dinners, _ := loadCatalog()
+
+    cutoff := state.WeekStart.AddDate(0, -months, 0)
+    if *dryRun {
+        // Compacting builds new summaries and a new list of weeks, so a copy
+        // of the state can be compacted without touching the real one
+        preview := *state
+        n := preview.CompactHistory(cutoff, dinners)
+        fmt.Printf("Would compact %d weeks from before %s into monthly summaries\n", n, cutoff.Format("January 2, 2006"))
+        return nil
+    }
+    n := state.CompactHistory(cutoff, dinners)
+    if n == 0 {
+        fmt.Printf("Nothing from before %s to compact\n", cutoff.Format("January 2, 2006"))
+        return nil
+    }
+    if err := state.Record("history compact", fmt.Sprintf("%d weeks before %s", n, cutoff.Format("2006-01-02"))); err != nil {
+        return err
+    }
+    if err := scrubJournal(); err != nil {
+        return err
+    }
+    fmt.Printf("Compacted %d weeks from before %s into monthly summaries, and cleared older snapshots from the journal\n", n, cutoff.Format("January 2, 2006"))
+    return nil
+}
+
+// printMonthlyHistory prints the compacted months, newest first, for "history months"
+func printMonthlyHistory(state *WeekState) {
+    if len(state.MonthlyHistory) == 0 {
+        fmt.Println("No compacted months yet (see history compact)")
+        return
+    }
+    fmt.Println("  Month    Planned  Cooked  Skipped  Instead  Rating  Most eaten")
+    for i := len(state.MonthlyHistory) - 1; i >= 0; i-- {
+        m := state.MonthlyHistory[i]
+        rating := "-"
+        if m.Rated > 0 {
+            rating = fmt.Sprintf("%.1f", float64(m.RatingSum)/float64(m.Rated))
+        }
+        fmt.Printf("  %s  %7d  %6d  %7d  %7d  %6s  %s\n", m.Month, m.Planned, m.Cooked, m.Skipped, m.Substituted, rating, strings.Join(m.topCategories(3), ", "))
+    }
+}
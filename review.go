@@ -0,0 +1,170 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// prompt prints a question and reads a trimmed line of input
+func prompt(in *bufio.Reader, question string) (string, error) {
+    fmt.Fprint(messages, question)
+    line, err := in.ReadString('\n')
+    if err != nil && (err != io.EOF || line == "") {
+        return "", err
+    }
+    return strings.TrimSpace(line), nil
+}
+
+// askOutcome asks what happened on a day until it gets a valid answer
+func askOutcome(in *bufio.Reader, day, dinner, current string) (string, error) {
+    for {
+        question := fmt.Sprintf("%s - %s: [c]ooked, [s]kipped, s[u]bstituted", day, dinner)
+        if current != "" {
+            question += fmt.Sprintf(" (enter keeps %s)", current)
+        } else {
+            question += " (enter to leave open)"
+        }
+        answer, err := prompt(in, question+": ")
+        if err != nil {
+            return "", err
+        }
+        switch strings.ToLower(answer) {
+        case "":
+            return current, nil
+        case "c", "cooked":
+            return OutcomeCooked, nil
+        case "s", "skipped":
+            return OutcomeSkipped, nil
+        case "u", "substituted":
+            return OutcomeSubstituted, nil
+        }
+        logf("Please answer c, s or u\n")
+    }
+}
+
+// askRating asks for a 1-5 rating, where enter keeps the current one
+func askRating(in *bufio.Reader, current int) (int, error) {
+    for {
+        answer, err := prompt(in, "  Rating 1-5 (enter to skip): ")
+        if err != nil {
+            return 0, err
+        }
+        if answer == "" {
+            return current, nil
+        }
+        if rating, err := strconv.Atoi(answer); err == nil && rating >= 1 && rating <= 5 {
+            return rating, nil
+        }
+        logf("  Please enter a number from 1 to 5\n")
+    }
+}
+
+// runReviewCommand handles "review", walking through the week's planned days
+// and recording what was cooked, skipped or substituted along with ratings.
+// Right after a new week starts it reviews the week that just ended.
+func runReviewCommand(args []string) error {
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+
+    plan := state.Plan
+    note := state.Note
+    current := true
+    if plan.IsEmpty() {
+        if len(state.History) == 0 {
+            return fmt.Errorf("nothing planned to review")
+        }
+        // Rebuild the last week's plan from history so it can be reviewed the same way
+        last := state.History[len(state.History)-1]
+        plan = NewPlan(last.WeekStart)
+        for _, day := range last.Days {
+            plan.Days = append(plan.Days, PlanDay{
+                Day:        day.Day,
+                Date:       day.Date,
+                Dinner:     Dinner{Name: day.Dinner, Category: day.Category},
+                Outcome:    day.Outcome,
+                Substitute: day.Substitute,
+                Rating:     day.Rating,
+                Leftovers:  day.Leftovers,
+                Comment:    day.Comment,
+            })
+        }
+        note = last.Note
+        current = false
+    }
+
+    pantry, err := LoadPantry()
+    if err != nil {
+        return err
+    }
+
+    logf("Reviewing the week of %s\n", plan.WeekStart.Format("January 2, 2006"))
+    if err := reviewDays(bufio.NewReader(os.Stdin), plan, pantry, current); err == io.EOF {
+        logf("\nReview cancelled, nothing saved\n")
+        return nil
+    } else if err != nil {
+        return err
+    }
+
+    // A week under way keeps only its reviewed days in history; the rest
+    // follow when the week is over, so open days don't count as eaten
+    if current {
+        plan.Revision++
+        if week := reviewedSoFar(plan, note); len(week.Days) > 0 {
+            state.RecordWeek(week)
+        }
+    } else {
+        state.RecordWeek(historyFromPlan(plan, note))
+    }
+    if err := state.Record("review", "week of "+plan.WeekStart.Format("2006-01-02")); err != nil {
+        return err
+    }
+    if err := pantry.SavePantry(); err != nil {
+        return err
+    }
+    logf("Saved the week to history\n")
+    return nil
+}
+
+// reviewDays asks what happened on each of the plan's days, taking dinners
+// newly cooked this week out of the pantry
+func reviewDays(in *bufio.Reader, plan *Plan, pantry *Pantry, current bool) error {
+    for i := range plan.Days {
+        entry := &plan.Days[i]
+        outcome, err := askOutcome(in, entry.Day, entry.Dinner.Name, entry.Outcome)
+        if err != nil {
+            return err
+        }
+
+        if outcome == OutcomeSubstituted {
+            substitute, err := prompt(in, "  What did you have instead? ")
+            if err != nil {
+                return err
+            }
+            if substitute != "" {
+                entry.Substitute = substitute
+            }
+        } else {
+            entry.Substitute = ""
+        }
+
+        if outcome == OutcomeCooked || outcome == OutcomeSubstituted {
+            if entry.Rating, err = askRating(in, entry.Rating); err != nil {
+                return err
+            }
+        }
+
+        // Only this week's dinners still draw on the pantry
+        if current && outcome == OutcomeCooked && entry.Outcome != OutcomeCooked && !entry.IsLeftovers() {
+            pantry.Consume(entry.Dinner)
+        }
+        entry.Outcome = outcome
+    }
+    return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// RotationConfig alternates between week patterns, e.g. for households where
+// the kids are home every other week
+type RotationConfig struct {
+    // Start is any date in the week that uses the first pattern
+    Start    string        `json:"start"`
+    Patterns []WeekPattern `json:"patterns"`
+}
+
+// WeekPattern is the days planned in one kind of week; no days means the
+// week isn't planned at all
+type WeekPattern struct {
+    Name string   `json:"name"`
+    Days []string `json:"days"`
+}
+
+// validate checks the start date, pattern names and days
+func (r *RotationConfig) validate() error {
+    if _, err := time.Parse("2006-01-02", r.Start); err != nil {
+        return fmt.Errorf("rotation: invalid start %q, want YYYY-MM-DD", r.Start)
+    }
+    if len(r.Patterns) == 0 {
+        return fmt.Errorf("rotation: no patterns")
+    }
+    seen := make(map[string]bool)
+    for _, pattern := range r.Patterns {
+        if pattern.Name == "" || seen[strings.ToLower(pattern.Name)] {
+            return fmt.Errorf("rotation: every pattern needs a unique name")
+        }
+        seen[strings.ToLower(pattern.Name)] = true
+        for _, day := range pattern.Days {
+            if _, ok := normalizeDay(day); !ok {
+                return fmt.Errorf("rotation: pattern %q has unknown day %q", pattern.Name, day)
+            }
+        }
+    }
+    return nil
+}
+
+// Find returns the pattern with a name, ignoring case
+func (r *RotationConfig) Find(name string) (WeekPattern, bool) {
+    for _, pattern := range r.Patterns {
+        if strings.EqualFold(pattern.Name, name) {
+            return pattern, true
+        }
+    }
+    return WeekPattern{}, false
+}
+
+// PatternFor returns the pattern whose turn it is in the week starting weekStart
+func (r *RotationConfig) PatternFor(weekStart time.Time) WeekPattern {
+    start, _ := time.Parse("2006-01-02", r.Start)
+    start = start.AddDate(0, 0, -int(start.Weekday()))
+    first := time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, time.UTC)
+
+    weeks := int(first.Sub(start).Hours()) / (24 * 7)
+    n := len(r.Patterns)
+    return r.Patterns[((weeks%n)+n)%n]
+}
+
+// planDays returns the pattern's days in week order
+func (p WeekPattern) planDays() []string {
+    var days []string
+    for _, day := range p.Days {
+        if d, ok := normalizeDay(day); ok {
+            days = append(days, d)
+        }
+    }
+    sort.Slice(days, func(i, j int) bool {
+        return dayIndex(days[i]) < dayIndex(days[j])
+    })
+    return uniqueStrings(days)
+}
+
+// weekPattern picks the pattern for the week: the named one if given, else
+// whichever the rotation says. Without a rotation it returns an empty pattern.
+func weekPattern(config *Config, weekStart time.Time, name string) (WeekPattern, error) {
+    if config.Rotation == nil {
+        if name != "" {
+            return WeekPattern{}, fmt.Errorf("--pattern needs a rotation in %s", ConfigFileName)
+        }
+        return WeekPattern{}, nil
+    }
+    if name == "" {
+        return config.Rotation.PatternFor(weekStart), nil
+    }
+    pattern, ok := config.Rotation.Find(name)
+    if !ok {
+        return WeekPattern{}, fmt.Errorf("no week pattern named %q", name)
+    }
+    return pattern, nil
+}
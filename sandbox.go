@@ -0,0 +1,362 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// sandboxed is set for a --sandbox run, which works on a throwaway copy of
+// the data and sends nothing
+var sandboxed bool
+
+// sandbox is the copy a --sandbox run works on, and what it held before the
+// command ran, to tell what would have changed
+type sandbox struct {
+    dir     string
+    catalog *DinnerData
+    config  *Config
+    state   *WeekState
+    files   map[string][]byte
+}
+
+// sandboxFiles are the files compared byte for byte, having no structure
+// worth comparing beyond changed or not
+var sandboxFiles = []string{PantryFileName, OutboxFileName}
+
+// openSandbox copies this run's data into a temporary directory and makes it
+// the one commands read and write. Included dinners files are read where
+// they are, as commands never write them.
+func openSandbox() (*sandbox, error) {
+    dir, err := os.MkdirTemp("", "dinner-picker-sandbox-")
+    if err != nil {
+        return nil, fmt.Errorf("error creating sandbox: %w", err)
+    }
+    box := &sandbox{dir: dir, files: make(map[string][]byte)}
+    if err := box.copyData(); err != nil {
+        os.RemoveAll(dir)
+        return nil, err
+    }
+    // The profile in use keeps its place under the sandbox, so the profile
+    // commands still find it
+    baseDirs = singleDir(dir)
+    dataDirs = profileDirs(currentProfile)
+    if err := box.moveCopies(); err != nil {
+        os.RemoveAll(dir)
+        return nil, err
+    }
+    sandboxed = true
+    box.catalog, box.config, box.state = box.snapshot()
+    for _, name := range sandboxFiles {
+        box.files[name], _ = os.ReadFile(dataPath(name))
+    }
+    return box, nil
+}
+
+// copyData copies the data files from where this run keeps them into the
+// sandbox directory, all together for now. A database is copied with its
+// write-ahead log, and an include path is made absolute so it still resolves.
+func (s *sandbox) copyData() error {
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    for _, name := range []string{DinnersFileName, ConfigFileName, StateFileName, JournalFileName, OutboxFileName, PantryFileName} {
+        if err := copyIfExists(dataPath(name), filepath.Join(s.dir, name)); err != nil {
+            return err
+        }
+    }
+    if config.Storage != nil && config.Storage.Backend == "sqlite" {
+        for _, suffix := range []string{"", "-wal", "-shm"} {
+            if err := copyIfExists(config.Storage.databasePath()+suffix, filepath.Join(s.dir, DatabaseFileName+suffix)); err != nil {
+                return err
+            }
+        }
+        config.Storage.Path = ""
+        if err := writeJSONFile(filepath.Join(s.dir, ConfigFileName), config); err != nil {
+            return err
+        }
+    }
+
+    path := filepath.Join(s.dir, DinnersFileName)
+    file, err := os.ReadFile(path)
+    if err != nil {
+        return nil
+    }
+    var fields map[string]json.RawMessage
+    var include []string
+    if json.Unmarshal(file, &fields) != nil || json.Unmarshal(fields["include"], &include) != nil || len(include) == 0 {
+        return nil
+    }
+    for i, pattern := range include {
+        if !filepath.IsAbs(pattern) {
+            include[i] = filepath.Join(dataDirs.Data, pattern)
+        }
+    }
+    fields["include"], _ = json.Marshal(include)
+    return writeJSONFile(path, fields)
+}
+
+// moveCopies moves the copied files to where the sandbox's directories,
+// now in use, keep them
+func (s *sandbox) moveCopies() error {
+    entries, err := os.ReadDir(s.dir)
+    if err != nil {
+        return fmt.Errorf("error reading sandbox: %w", err)
+    }
+    for _, entry := range entries {
+        if entry.IsDir() {
+            continue
+        }
+        target := dataPath(entry.Name())
+        if filepath.Clean(target) == filepath.Join(s.dir, entry.Name()) {
+            continue
+        }
+        if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+            return fmt.Errorf("error creating sandbox: %w", err)
+        }
+        if err := os.Rename(filepath.Join(s.dir, entry.Name()), target); err != nil {
+            return fmt.Errorf("error creating sandbox: %w", err)
+        }
+    }
+    return nil
+}
+
+// copyIfExists copies a file, if there is one to copy
+func copyIfExists(source, target string) error {
+    data, err := os.ReadFile(source)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("error copying %s into the sandbox: %w", source, err)
+    }
+    if err := os.WriteFile(target, data, 0644); err != nil {
+        return fmt.Errorf("error copying %s into the sandbox: %w", source, err)
+    }
+    return nil
+}
+
+// writeJSONFile writes a value as indented JSON
+func writeJSONFile(path string, v any) error {
+    data, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling %s: %w", filepath.Base(path), err)
+    }
+    return writeFileAtomic(path, data)
+}
+
+// snapshot reads the sandbox's catalog, config and state as they are now;
+// what can't be read is nil, as it is before a first run
+func (s *sandbox) snapshot() (*DinnerData, *Config, *WeekState) {
+    catalog, err := loadCatalog()
+    if err != nil {
+        catalog = nil
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        config = &Config{}
+    }
+    var state *WeekState
+    if hasState() {
+        if state, err = LoadState(); err != nil {
+            state = nil
+        }
+    }
+    return catalog, config, state
+}
+
+// close writes what the command would have changed and removes the sandbox
+func (s *sandbox) close(w io.Writer) {
+    defer os.RemoveAll(s.dir)
+    catalog, config, state := s.snapshot()
+    var changes []string
+    changes = append(changes, catalogChanges(s.catalog, catalog)...)
+    if keys := changedKeys(s.config, config); len(keys) > 0 {
+        changes = append(changes, "Config: "+strings.Join(keys, ", ")+" changed")
+    }
+    changes = append(changes, stateChanges(s.state, state, config.Staples)...)
+    for _, name := range sandboxFiles {
+        after, _ := os.ReadFile(dataPath(name))
+        if !bytes.Equal(s.files[name], after) {
+            changes = append(changes, name+" changed")
+        }
+    }
+
+    fmt.Fprintln(w)
+    if len(changes) == 0 {
+        fmt.Fprintln(w, "Sandbox: nothing would have changed")
+        return
+    }
+    fmt.Fprintln(w, "Sandbox: this would have changed, but nothing of yours was touched")
+    for _, change := range changes {
+        fmt.Fprintf(w, "  %s\n", change)
+    }
+}
+
+// changedKeys returns the JSON fields that differ between two values of the
+// same type, sorted
+func changedKeys(before, after any) []string {
+    fields := func(v any) map[string]json.RawMessage {
+        var m map[string]json.RawMessage
+        if data, err := json.Marshal(v); err == nil {
+            json.Unmarshal(data, &m)
+        }
+        return m
+    }
+    old, new := fields(before), fields(after)
+    var keys []string
+    for key, value := range new {
+        if !bytes.Equal(old[key], value) {
+            keys = append(keys, key)
+        }
+    }
+    for key := range old {
+        if _, ok := new[key]; !ok {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// catalogChanges lists the dinners added, removed and edited, with the
+// fields that changed
+func catalogChanges(before, after *DinnerData) []string {
+    index := func(data *DinnerData) map[string]Dinner {
+        dinners := make(map[string]Dinner)
+        if data != nil {
+            for _, list := range data.Dinners {
+                for _, dinner := range list {
+                    dinners[strings.ToLower(dinner.Name)] = dinner
+                }
+            }
+        }
+        return dinners
+    }
+    old, new := index(before), index(after)
+    var changes []string
+    for key, dinner := range new {
+        was, ok := old[key]
+        switch {
+        case !ok:
+            changes = append(changes, fmt.Sprintf("+ %s (%s)", dinner.Name, dinner.Category))
+        default:
+            if keys := changedKeys(was, dinner); len(keys) > 0 {
+                changes = append(changes, fmt.Sprintf("~ %s: %s", dinner.Name, strings.Join(keys, ", ")))
+            }
+        }
+    }
+    for key, dinner := range old {
+        if _, ok := new[key]; !ok {
+            changes = append(changes, fmt.Sprintf("- %s (%s)", dinner.Name, dinner.Category))
+        }
+    }
+    sort.Slice(changes, func(i, j int) bool {
+        return strings.ToLower(changes[i][2:]) < strings.ToLower(changes[j][2:])
+    })
+    for i := range changes {
+        changes[i] = "Dinner " + changes[i]
+    }
+    return changes
+}
+
+// stateChanges lists how the week's plan changed, day by day and on the
+// shopping list, which history weeks were added, changed or removed, and what else
+// in the state did
+func stateChanges(before, after *WeekState, staples []string) []string {
+    if after == nil {
+        return nil
+    }
+    if before == nil {
+        before = &WeekState{}
+    }
+    var changes []string
+    switch {
+    case before.Plan.IsEmpty() && !after.Plan.IsEmpty():
+        for _, entry := range after.Plan.Days {
+            changes = append(changes, fmt.Sprintf("Plan %s: + %s", entry.Day, entry.Dinner.Name))
+        }
+    case !before.Plan.IsEmpty() && after.Plan.IsEmpty():
+        changes = append(changes, "Plan cleared")
+    case !after.Plan.IsEmpty():
+        diff := DiffPlans(before.Plan, after.Plan, staples)
+        for _, day := range diff.Days {
+            switch day.Change {
+            case "added":
+                changes = append(changes, fmt.Sprintf("Plan %s: + %s", day.Day, day.After))
+            case "removed":
+                changes = append(changes, fmt.Sprintf("Plan %s: - %s", day.Day, day.Before))
+            default:
+                changes = append(changes, fmt.Sprintf("Plan %s: %s -> %s", day.Day, day.Before, day.After))
+            }
+        }
+        for _, item := range diff.Shopping.Added {
+            changes = append(changes, "Shopping list: + "+item.After)
+        }
+        for _, item := range diff.Shopping.Removed {
+            changes = append(changes, "Shopping list: - "+item.Before)
+        }
+        for _, item := range diff.Shopping.Changed {
+            changes = append(changes, fmt.Sprintf("Shopping list: ~ %s (was %s)", item.After, item.Before))
+        }
+    }
+
+    weeks := make(map[string]HistoryWeek)
+    for _, week := range before.History {
+        weeks[week.WeekStart.Format("2006-01-02")] = week
+    }
+    for _, week := range after.History {
+        start := week.WeekStart.Format("2006-01-02")
+        was, ok := weeks[start]
+        switch {
+        case !ok:
+            changes = append(changes, fmt.Sprintf("History: week of %s added", start))
+        case len(changedKeys(was, week)) > 0:
+            changes = append(changes, fmt.Sprintf("History: week of %s changed", start))
+        }
+        delete(weeks, start)
+    }
+    removed := make([]string, 0, len(weeks))
+    for start := range weeks {
+        removed = append(removed, start)
+    }
+    sort.Strings(removed)
+    for _, start := range removed {
+        changes = append(changes, fmt.Sprintf("History: week of %s removed", start))
+    }
+
+    var rest []string
+    for _, key := range changedKeys(before, after) {
+        switch key {
+        case "plan", "history", "journal_seq", "current_week", "previous_week":
+        default:
+            rest = append(rest, key)
+        }
+    }
+    if len(rest) > 0 {
+        changes = append(changes, "State: "+strings.Join(rest, ", ")+" changed")
+    }
+    return changes
+}
+
+// sandboxNotifier stands in for a notifier in a --sandbox run, saying what
+// it would have sent
+type sandboxNotifier struct {
+    name string
+}
+
+func (n sandboxNotifier) Name() string {
+    return n.name
+}
+
+func (n sandboxNotifier) Send(text string) error {
+    first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+    logf("Sandbox: would have sent to %s: %s\n", n.name, first)
+    return nil
+}
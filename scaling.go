@@ -0,0 +1,101 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// defaultServings is how many people a recipe feeds when neither it nor the
+// config's household says
+const defaultServings = 4
+
+// servings returns how many people the recipe feeds as written
+func (d Dinner) servings(household int) int {
+    if d.Servings > 0 {
+        return d.Servings
+    }
+    if household > 0 {
+        return household
+    }
+    return defaultServings
+}
+
+// servingLimit is the most people the dinner can be scaled up for: max_servings
+// if set, the recipe as written if it doesn't scale well, otherwise no limit (0)
+func (d Dinner) servingLimit(household int) int {
+    if d.MaxServings > 0 {
+        return d.MaxServings
+    }
+    if d.ScalesWell != nil && !*d.ScalesWell {
+        return d.servings(household)
+    }
+    return 0
+}
+
+// scalesTo reports whether the dinner can be made for people
+func (d Dinner) scalesTo(people, household int) bool {
+    limit := d.servingLimit(household)
+    return limit == 0 || people <= limit
+}
+
+// scaleAlternatives suggests up to three dinners that do scale to people,
+// from the same category first
+func scaleAlternatives(dinners *DinnerData, dinner Dinner, people, household int) []string {
+    var names []string
+    add := func(list []Dinner) {
+        for _, other := range list {
+            if len(names) < 3 && other.Name != dinner.Name && other.scalesTo(people, household) {
+                names = append(names, other.Name)
+            }
+        }
+    }
+    add(dinners.Dinners[dinner.Category])
+    if len(names) == 0 {
+        add(dinners.AllDinners())
+    }
+    return names
+}
+
+// scaleWarning explains that a dinner won't scale to people, with alternatives
+func scaleWarning(dinners *DinnerData, dinner Dinner, people, household int) string {
+    warning := fmt.Sprintf("%s doesn't scale well past %d servings (%d needed)", dinner.Name, dinner.servingLimit(household), people)
+    if alternatives := scaleAlternatives(dinners, dinner, people, household); len(alternatives) > 0 {
+        warning += " - try " + strings.Join(alternatives, ", ")
+    }
+    return warning
+}
+
+// parseGuests reads --guests "saturday=8,sunday=6" into people per day
+func parseGuests(value string) (map[string]int, error) {
+    guests := make(map[string]int)
+    if value == "" {
+        return guests, nil
+    }
+    for _, part := range strings.Split(value, ",") {
+        dayName, count, ok := strings.Cut(strings.TrimSpace(part), "=")
+        day, known := normalizeDay(dayName)
+        people, err := strconv.Atoi(count)
+        if !ok || !known || err != nil || people <= 0 {
+            return nil, fmt.Errorf("--guests wants day=people, e.g. saturday=8, got %q", part)
+        }
+        guests[day] = people
+    }
+    return guests, nil
+}
+
+// annotateServings records each day's headcount on the plan and notes dinners
+// that won't scale to it
+func annotateServings(dinners *DinnerData, plan *Plan, guests map[string]int, household int) {
+    for i := range plan.Days {
+        entry := &plan.Days[i]
+        people, ok := guests[entry.Day]
+        if !ok {
+            continue
+        }
+        entry.Servings = people
+        if !entry.Dinner.scalesTo(people, household) {
+            plan.Notes = append(plan.Notes, fmt.Sprintf("%s: %s", entry.Day, scaleWarning(dinners, entry.Dinner, people, household)))
+        }
+    }
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+    "strings"
+)
+
+// ScheduleConfig says which days are planned and which categories each day
+// draws from. Days listing the same categories take turns through them, so
+// none gets a category twice before every one has had a day.
+type ScheduleConfig struct {
+    Days map[string][]string `json:"days"`
+
+    // OtherDays is what days planned with --days or a week pattern, but not
+    // listed under Days, draw from (default every category in the catalog)
+    OtherDays []string `json:"other_days,omitempty"`
+
+    // Shuffle deals the categories out in random order (default true); off,
+    // they go to the days in the order listed
+    Shuffle *bool `json:"shuffle,omitempty"`
+
+    // Frequency sets how many days a week a category may get, e.g. pasta
+    // twice and salad not at all, on top of taking turns
+    Frequency map[string]CategoryFrequency `json:"frequency,omitempty"`
+}
+
+// CategoryFrequency is the fewest and most days a week a category gets. Max
+// left out means no limit; 0 means never.
+type CategoryFrequency struct {
+    Min int  `json:"min,omitempty"`
+    Max *int `json:"max,omitempty"`
+}
+
+// allows reports whether a category that has n days can take another
+func (f CategoryFrequency) allows(n int) bool {
+    return f.Max == nil || n < *f.Max
+}
+
+// weekdayCategories take turns on the default schedule's weekdays
+var weekdayCategories = []string{"noodles-rice", "pasta", "bread-y", "Salad"}
+
+// defaultSchedule is soup on Sunday and a shuffled round of the other
+// categories Monday to Thursday
+var defaultSchedule = &ScheduleConfig{
+    Days: map[string][]string{
+        "Sunday":    {"soup"},
+        "Monday":    weekdayCategories,
+        "Tuesday":   weekdayCategories,
+        "Wednesday": weekdayCategories,
+        "Thursday":  weekdayCategories,
+    },
+    OtherDays: weekdayCategories,
+}
+
+// validate checks the days and that every day has something to draw from
+func (s *ScheduleConfig) validate() error {
+    if len(s.Days) == 0 {
+        return fmt.Errorf("schedule: no days")
+    }
+    seen := make(map[string]bool)
+    for name, categories := range s.Days {
+        day, ok := normalizeDay(name)
+        if !ok {
+            return fmt.Errorf("schedule: unknown day %q", name)
+        }
+        if seen[day] {
+            return fmt.Errorf("schedule: %s is listed twice", day)
+        }
+        seen[day] = true
+        if len(categories) == 0 {
+            return fmt.Errorf("schedule: %s has no categories", day)
+        }
+        for _, category := range categories {
+            if strings.TrimSpace(category) == "" {
+                return fmt.Errorf("schedule: %s has a blank category", day)
+            }
+        }
+    }
+    for _, category := range s.OtherDays {
+        if strings.TrimSpace(category) == "" {
+            return fmt.Errorf("schedule: other_days has a blank category")
+        }
+    }
+
+    mins := 0
+    for category, frequency := range s.Frequency {
+        if frequency.Min < 0 || frequency.Max != nil && *frequency.Max < 0 {
+            return fmt.Errorf("schedule: %s frequency can't be negative", category)
+        }
+        if frequency.Max != nil && frequency.Min > *frequency.Max {
+            return fmt.Errorf("schedule: %s has a min of %d but a max of %d", category, frequency.Min, *frequency.Max)
+        }
+        if frequency.Min == 0 {
+            continue
+        }
+        days := 0
+        for _, categories := range s.Days {
+            for _, name := range categories {
+                if strings.EqualFold(name, category) {
+                    days++
+                    break
+                }
+            }
+        }
+        if days < frequency.Min {
+            return fmt.Errorf("schedule: %s needs %d days a week but only %d scheduled days draw from it", category, frequency.Min, days)
+        }
+        mins += frequency.Min
+    }
+    if mins > len(s.Days) {
+        return fmt.Errorf("schedule: the frequency minimums add up to %d days, but only %d are scheduled", mins, len(s.Days))
+    }
+    return nil
+}
+
+// frequency returns the limits set for a category, matched ignoring case
+func (s *ScheduleConfig) frequency(category string) CategoryFrequency {
+    for name, frequency := range s.Frequency {
+        if strings.EqualFold(name, category) {
+            return frequency
+        }
+    }
+    return CategoryFrequency{}
+}
+
+// schedule returns the configured schedule, or the default one
+func (o PlanOptions) schedule() *ScheduleConfig {
+    if o.Schedule == nil {
+        return defaultSchedule
+    }
+    return o.Schedule
+}
+
+// categories lists every category the schedule draws from
+func (s *ScheduleConfig) categories() []string {
+    var all []string
+    for _, categories := range s.Days {
+        all = append(all, categories...)
+    }
+    all = append(all, s.OtherDays...)
+    sort.Strings(all)
+    return uniqueStrings(all)
+}
+
+// planDays returns the scheduled days in week order
+func (s *ScheduleConfig) planDays() []string {
+    var days []string
+    for name := range s.Days {
+        if day, ok := normalizeDay(name); ok {
+            days = append(days, day)
+        }
+    }
+    sort.Slice(days, func(i, j int) bool {
+        return dayIndex(days[i]) < dayIndex(days[j])
+    })
+    return days
+}
+
+// categoriesOn returns what a day draws from: its own categories, or the
+// other days' ones when it isn't scheduled, spelled as in the catalog
+func (s *ScheduleConfig) categoriesOn(dinners *DinnerData, day string) []string {
+    spelled := func(categories []string) []string {
+        var names []string
+        for _, category := range categories {
+            if name, ok := dinners.hasCategory(category); ok {
+                category = name
+            }
+            names = append(names, category)
+        }
+        return names
+    }
+    for name, categories := range s.Days {
+        if d, _ := normalizeDay(name); d == day {
+            return spelled(categories)
+        }
+    }
+    if len(s.OtherDays) > 0 {
+        return spelled(s.OtherDays)
+    }
+    var all []string
+    for category := range dinners.Dinners {
+        all = append(all, category)
+    }
+    sort.Strings(all)
+    return all
+}
+
+// Assign deals categories out to the days: days drawing from the same
+// categories go round them together, shuffled unless the schedule says not
+// to. The deal is then evened out to the frequency limits and arranged to
+// suit the bias, shuffling with r. It returns the category for each day, the
+// bias score of the arrangement and notes on limits the days couldn't meet.
+func (s *ScheduleConfig) Assign(dinners *DinnerData, days []string, bias CategoryBias, r *rand.Rand) (map[string]string, float64, []string) {
+    var keys []string
+    grouped := make(map[string][]string)
+    for _, day := range days {
+        key := strings.Join(s.categoriesOn(dinners, day), "\x00")
+        if _, ok := grouped[key]; !ok {
+            keys = append(keys, key)
+        }
+        grouped[key] = append(grouped[key], day)
+    }
+
+    shuffle := s.Shuffle == nil || *s.Shuffle
+    dealt := make(map[string][]string)
+    for _, key := range keys {
+        groupDays := grouped[key]
+        var categories []string
+        for len(categories) < len(groupDays) {
+            round := s.dealable(strings.Split(key, "\x00"))
+            if shuffle {
+                r.Shuffle(len(round), func(i, j int) {
+                    round[i], round[j] = round[j], round[i]
+                })
+            }
+            categories = append(categories, round...)
+        }
+        dealt[key] = categories[:len(groupDays)]
+    }
+    notes := s.balance(keys, dealt)
+
+    assigned := make(map[string]string)
+    score := 0.0
+    for _, key := range keys {
+        groupDays, categories := grouped[key], dealt[key]
+        if shuffle {
+            categories = ArrangeCategories(groupDays, categories, bias)
+        }
+        score += arrangementScore(groupDays, categories, bias)
+        for i, day := range groupDays {
+            assigned[day] = categories[i]
+        }
+    }
+    return assigned, score, notes
+}
+
+// dealable leaves out the categories whose max is 0, unless that leaves nothing
+func (s *ScheduleConfig) dealable(categories []string) []string {
+    var kept []string
+    for _, category := range categories {
+        if frequency := s.frequency(category); frequency.allows(0) {
+            kept = append(kept, category)
+        }
+    }
+    if len(kept) == 0 {
+        return categories
+    }
+    return kept
+}
+
+// balance moves days from one category to another until every category is
+// within its frequency limits, as far as the categories each day may draw
+// from allow. dealt holds the categories dealt to each group of days, keyed
+// by the categories the group draws from. It returns notes on what couldn't
+// be met.
+func (s *ScheduleConfig) balance(keys []string, dealt map[string][]string) []string {
+    if len(s.Frequency) == 0 {
+        return nil
+    }
+    count := func(category string) int {
+        n := 0
+        for _, categories := range dealt {
+            for _, c := range categories {
+                if strings.EqualFold(c, category) {
+                    n++
+                }
+            }
+        }
+        return n
+    }
+    // switchDay gives one day of a category to another its group may draw
+    // from, choosing with better; it reports whether a day was found
+    switchDay := func(from func(category string) bool, to func(category string) bool, better func(a, b string) bool) bool {
+        bestKey, bestIndex, bestTo := "", -1, ""
+        for _, key := range keys {
+            for i, current := range dealt[key] {
+                if !from(current) {
+                    continue
+                }
+                for _, option := range strings.Split(key, "\x00") {
+                    if strings.EqualFold(option, current) || !to(option) {
+                        continue
+                    }
+                    if bestIndex < 0 || better(current, option) && !better(dealt[bestKey][bestIndex], bestTo) {
+                        bestKey, bestIndex, bestTo = key, i, option
+                    }
+                }
+            }
+        }
+        if bestIndex < 0 {
+            return false
+        }
+        dealt[bestKey][bestIndex] = bestTo
+        return true
+    }
+
+    var categories []string
+    for _, key := range keys {
+        categories = append(categories, strings.Split(key, "\x00")...)
+    }
+    categories = uniqueStrings(categories)
+    sort.Strings(categories)
+
+    var notes []string
+    // Too many days: hand the extra ones to the category furthest below its min
+    for _, category := range categories {
+        frequency := s.frequency(category)
+        for !frequency.allows(count(category) - 1) {
+            moved := switchDay(func(c string) bool {
+                return strings.EqualFold(c, category)
+            }, func(option string) bool {
+                return s.frequency(option).allows(count(option))
+            }, func(_, option string) bool {
+                return count(option) < s.frequency(option).Min
+            })
+            if !moved {
+                notes = append(notes, fmt.Sprintf("Schedule: %s gets %d day(s), more than its max of %d, as the days have nothing else to draw from", category, count(category), *frequency.Max))
+                break
+            }
+        }
+    }
+
+    // Too few days: take them from a category that can spare one
+    for _, category := range categories {
+        frequency := s.frequency(category)
+        for count(category) < frequency.Min && frequency.allows(count(category)) {
+            moved := switchDay(func(c string) bool {
+                return !strings.EqualFold(c, category) && count(c) > s.frequency(c).Min
+            }, func(option string) bool {
+                return strings.EqualFold(option, category)
+            }, func(current, _ string) bool {
+                return count(current) > 1
+            })
+            if !moved {
+                notes = append(notes, fmt.Sprintf("Schedule: %s gets %d day(s), fewer than its min of %d, as no other category could spare one", category, count(category), frequency.Min))
+                break
+            }
+        }
+    }
+    return notes
+}
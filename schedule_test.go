@@ -0,0 +1,97 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+// weekdays is a schedule of Monday to Friday, each drawing from categories
+func weekdays(categories ...string) map[string][]string {
+    days := make(map[string][]string)
+    for _, day := range []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"} {
+        days[day] = categories
+    }
+    return days
+}
+
+// Frequency limits are checked against the days that can meet them
+func TestValidateFrequency(t *testing.T) {
+    two, zero, minusOne := 2, 0, -1
+    tests := []struct {
+        name      string
+        days      map[string][]string
+        frequency map[string]CategoryFrequency
+        err       string
+    }{
+        {"no limits", weekdays("pasta", "soup"), nil, ""},
+        {"within the days", weekdays("pasta", "soup"), map[string]CategoryFrequency{"pasta": {Min: 2, Max: &two}, "soup": {Max: &zero}}, ""},
+        {"negative", weekdays("pasta"), map[string]CategoryFrequency{"pasta": {Max: &minusOne}}, "can't be negative"},
+        {"min over max", weekdays("pasta"), map[string]CategoryFrequency{"pasta": {Min: 3, Max: &two}}, "min of 3 but a max of 2"},
+        {"not enough days drawing from it", map[string][]string{"Sunday": {"soup"}, "Monday": {"pasta"}},
+            map[string]CategoryFrequency{"soup": {Min: 2}}, "soup needs 2 days a week but only 1"},
+        {"minimums over the week", weekdays("pasta", "soup"),
+            map[string]CategoryFrequency{"pasta": {Min: 3}, "soup": {Min: 3}}, "add up to 6 days, but only 5"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            err := (&ScheduleConfig{Days: test.days, Frequency: test.frequency}).validate()
+            if test.err == "" {
+                if err != nil {
+                    t.Fatal(err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("got %v, want %q", err, test.err)
+            }
+        })
+    }
+}
+
+// Dealing out the categories keeps every one within its limits, whatever
+// the shuffle, as far as the days allow
+func TestAssignFrequency(t *testing.T) {
+    one, zero := 1, 0
+    days := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}
+    tests := []struct {
+        name      string
+        days      map[string][]string
+        frequency map[string]CategoryFrequency
+        want      map[string][2]int // the fewest and most days for a category
+        note      string
+    }{
+        {"max", weekdays("pasta", "soup", "salad"),
+            map[string]CategoryFrequency{"pasta": {Max: &one}},
+            map[string][2]int{"pasta": {0, 1}, "soup": {1, 3}, "salad": {1, 3}}, ""},
+        {"never", weekdays("pasta", "soup", "salad"),
+            map[string]CategoryFrequency{"salad": {Max: &zero}},
+            map[string][2]int{"salad": {0, 0}}, ""},
+        {"min", weekdays("pasta", "soup", "salad"),
+            map[string]CategoryFrequency{"soup": {Min: 3}},
+            map[string][2]int{"soup": {3, 5}}, ""},
+        {"only from the day's own categories", map[string][]string{
+            "Monday": {"soup"}, "Tuesday": {"soup"}, "Wednesday": {"pasta"}, "Thursday": {"pasta"}, "Friday": {"pasta"},
+        }, map[string]CategoryFrequency{"soup": {Max: &one}},
+            map[string][2]int{"soup": {2, 2}, "pasta": {3, 3}}, "soup gets 2 day(s), more than its max of 1"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            schedule := &ScheduleConfig{Days: test.days, Frequency: test.frequency}
+            for seed := int64(1); seed <= 20; seed++ {
+                assigned, _, notes := schedule.Assign(&DinnerData{}, days, nil, newRand(seed))
+                counts := make(map[string]int)
+                for _, category := range assigned {
+                    counts[category]++
+                }
+                for category, limits := range test.want {
+                    if n := counts[category]; n < limits[0] || n > limits[1] {
+                        t.Fatalf("seed %d: %s got %d days, want %d to %d (%v)", seed, category, n, limits[0], limits[1], assigned)
+                    }
+                }
+                if got := strings.Join(notes, "\n"); !strings.Contains(got, test.note) || test.note == "" && got != "" {
+                    t.Fatalf("seed %d: notes %q, want %q", seed, got, test.note)
+                }
+            }
+        })
+    }
+}
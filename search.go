@@ -0,0 +1,227 @@
+package main
+
+import (
+    "os"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// Field weights used to rank search hits; a name match beats an ingredient match
+const (
+    weightName       = 3.0
+    weightTag        = 2.0
+    weightIngredient = 2.0
+    weightSource     = 1.0
+
+    // prefixPenalty scales hits where the query is only a prefix of the word
+    prefixPenalty = 0.7
+)
+
+// posting is one occurrence of a token in a dinner's field
+type posting struct {
+    doc    int
+    weight float64
+    text   string
+}
+
+// SearchIndex is an inverted index over dinner names, tags, ingredients and sources
+type SearchIndex struct {
+    dinners  []Dinner
+    postings map[string][]posting
+    tokens   []string
+}
+
+// SearchResult is a ranked hit with the field texts that matched
+type SearchResult struct {
+    Dinner  Dinner
+    Score   float64
+    Matches []string
+}
+
+// searchCache keeps the index for the last loaded catalog so long-running
+// processes don't rebuild it on every query
+var searchCache struct {
+    data  *DinnerData
+    index *SearchIndex
+}
+
+// IndexFor returns the search index for a catalog, building it on first use
+func IndexFor(data *DinnerData) *SearchIndex {
+    if searchCache.data != data {
+        searchCache.data = data
+        searchCache.index = BuildSearchIndex(data)
+    }
+    return searchCache.index
+}
+
+// tokenize splits text into lowercase words
+func tokenize(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+// BuildSearchIndex indexes every dinner in the catalog
+func BuildSearchIndex(data *DinnerData) *SearchIndex {
+    index := &SearchIndex{
+        dinners:  data.AllDinners(),
+        postings: make(map[string][]posting),
+    }
+
+    add := func(doc int, text string, weight float64) {
+        seen := make(map[string]bool)
+        for _, token := range tokenize(text) {
+            if seen[token] {
+                continue
+            }
+            seen[token] = true
+            index.postings[token] = append(index.postings[token], posting{doc: doc, weight: weight, text: text})
+        }
+    }
+    for doc, dinner := range index.dinners {
+        add(doc, dinner.Name, weightName)
+        for _, tag := range dinner.Tags {
+            add(doc, tag, weightTag)
+        }
+        for _, ingredient := range dinner.Ingredients {
+            add(doc, ingredient.Name, weightIngredient)
+        }
+        if dinner.Source != nil {
+            add(doc, dinner.Source.String(), weightSource)
+        }
+    }
+
+    for token := range index.postings {
+        index.tokens = append(index.tokens, token)
+    }
+    sort.Strings(index.tokens)
+    return index
+}
+
+// Search returns dinners matching every query term, best first. Terms match
+// whole words or word prefixes, so "tom" finds "tomato".
+func (idx *SearchIndex) Search(query string) []SearchResult {
+    terms := tokenize(query)
+    if len(terms) == 0 {
+        return nil
+    }
+
+    scores := make(map[int]float64)
+    matches := make(map[int][]string)
+    for i, term := range terms {
+        termScores := make(map[int]float64)
+        termMatches := make(map[int][]string)
+
+        start := sort.SearchStrings(idx.tokens, term)
+        for _, token := range idx.tokens[start:] {
+            if !strings.HasPrefix(token, term) {
+                break
+            }
+            factor := 1.0
+            if token != term {
+                factor = prefixPenalty
+            }
+            for _, p := range idx.postings[token] {
+                if score := p.weight * factor; score > termScores[p.doc] {
+                    termScores[p.doc] = score
+                }
+                termMatches[p.doc] = append(termMatches[p.doc], p.text)
+            }
+        }
+
+        // Every term has to match somewhere in the dinner
+        for doc := range scores {
+            if _, ok := termScores[doc]; !ok {
+                delete(scores, doc)
+            }
+        }
+        for doc, score := range termScores {
+            if i > 0 {
+                if _, ok := scores[doc]; !ok {
+                    continue
+                }
+            }
+            scores[doc] += score
+            matches[doc] = append(matches[doc], termMatches[doc]...)
+        }
+    }
+
+    var results []SearchResult
+    for doc, score := range scores {
+        results = append(results, SearchResult{
+            Dinner:  idx.dinners[doc],
+            Score:   score,
+            Matches: uniqueStrings(matches[doc]),
+        })
+    }
+    sort.Slice(results, func(i, j int) bool {
+        if results[i].Score != results[j].Score {
+            return results[i].Score > results[j].Score
+        }
+        return results[i].Dinner.Name < results[j].Dinner.Name
+    })
+    return results
+}
+
+// uniqueStrings drops repeated strings, keeping the first occurrence
+func uniqueStrings(items []string) []string {
+    seen := make(map[string]bool)
+    var unique []string
+    for _, item := range items {
+        if !seen[item] {
+            seen[item] = true
+            unique = append(unique, item)
+        }
+    }
+    return unique
+}
+
+// isTerminal reports whether stdout is an interactive terminal
+func isTerminal() bool {
+    info, err := os.Stdout.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether to style output: on a terminal that understands
+// escapes (switching them on in the Windows console) unless NO_COLOR is set
+func useColor() bool {
+    return isTerminal() && os.Getenv("NO_COLOR") == "" && enableEscapes(os.Stdout)
+}
+
+// Highlight wraps the words of text that start with a query term in bold,
+// leaving text untouched when output isn't a terminal
+func Highlight(text, query string) string {
+    if !useColor() {
+        return text
+    }
+    terms := tokenize(query)
+
+    var out strings.Builder
+    word := []rune{}
+    flush := func() {
+        if len(word) == 0 {
+            return
+        }
+        w := string(word)
+        lower := strings.ToLower(w)
+        for _, term := range terms {
+            if strings.HasPrefix(lower, term) {
+                w = "\033[1m" + w + "\033[0m"
+                break
+            }
+        }
+        out.WriteString(w)
+        word = word[:0]
+    }
+    for _, r := range text {
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            word = append(word, r)
+            continue
+        }
+        flush()
+        out.WriteRune(r)
+    }
+    flush()
+    return out.String()
+}
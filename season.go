@@ -0,0 +1,101 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// seasons are the names a dinner's seasons may use, in the year's order
+var seasons = []string{"spring", "summer", "fall", "winter"}
+
+// normalizeSeason spells a season as seasons does, taking "autumn" for fall
+func normalizeSeason(name string) (string, bool) {
+    name = strings.ToLower(strings.TrimSpace(name))
+    if name == "autumn" {
+        name = "fall"
+    }
+    return name, containsString(seasons, name)
+}
+
+// SeasonConfig says how dinners' seasons are judged
+type SeasonConfig struct {
+    // Hemisphere is "north" (the default) or "south", where July is winter
+    Hemisphere string `json:"hemisphere,omitempty"`
+
+    // Strict keeps dinners out of season off the plan altogether; otherwise
+    // they're only picked when nothing in season fits
+    Strict bool `json:"strict,omitempty"`
+}
+
+// validate checks the hemisphere is one there is
+func (c *SeasonConfig) validate() error {
+    switch strings.ToLower(c.Hemisphere) {
+    case "", "north", "south":
+        return nil
+    }
+    return fmt.Errorf("seasons: unknown hemisphere %q (want north or south)", c.Hemisphere)
+}
+
+// On returns the season a date falls in, by whole months: winter is
+// December to February up north, June to August down south
+func (c *SeasonConfig) On(date time.Time) string {
+    i := int(date.Month()) % 12 / 3 // 0 for Dec-Feb, 1 for Mar-May, ...
+    if c != nil && strings.EqualFold(c.Hemisphere, "south") {
+        i = (i + 2) % 4
+    }
+    return []string{"winter", "spring", "summer", "fall"}[i]
+}
+
+// strict reports whether dinners out of season are kept off the plan
+func (c *SeasonConfig) strict() bool {
+    return c != nil && c.Strict
+}
+
+// InSeason reports whether a dinner suits a season; dinners with no seasons
+// suit every one
+func (d Dinner) InSeason(season string) bool {
+    if len(d.Seasons) == 0 || season == "" {
+        return true
+    }
+    for _, s := range d.Seasons {
+        if name, _ := normalizeSeason(s); name == season {
+            return true
+        }
+    }
+    return false
+}
+
+// hasSeasons reports whether any dinner lists its seasons, so the planner
+// has something to go by
+func (d *DinnerData) hasSeasons() bool {
+    for _, list := range d.Dinners {
+        for _, dinner := range list {
+            if len(dinner.Seasons) > 0 {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// seasonOn is the season the planner goes by on a date: --season if given,
+// else the date's
+func (o PlanOptions) seasonOn(date time.Time) string {
+    if o.Season != "" {
+        return o.Season
+    }
+    return o.Seasons.On(date)
+}
+
+// seasonFlag reads --season, which may be empty for the date's season
+func seasonFlag(value string) (string, error) {
+    if value == "" {
+        return "", nil
+    }
+    season, ok := normalizeSeason(value)
+    if !ok {
+        return "", fmt.Errorf("unknown season %q (want %s)", value, strings.Join(seasons, ", "))
+    }
+    return season, nil
+}
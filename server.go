@@ -0,0 +1,888 @@
+package main
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "hash/fnv"
+    "net/http"
+    "net/url"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    defaultPageLimit = 50
+    maxPageLimit     = 500
+)
+
+// PageResponse is one page of a list endpoint. NextCursor is empty on the last page.
+type PageResponse struct {
+    Items      interface{} `json:"items"`
+    Total      int         `json:"total"`
+    NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// HistoryEntry is one past evening as served by /history
+type HistoryEntry struct {
+    WeekStart time.Time `json:"week_start"`
+    HistoryDay
+}
+
+// sortKeys maps a sort name to the key of item i; keys end in a unique id so
+// the order is total and cursors stay put when items are added
+type sortKeys map[string]func(i int) string
+
+// paginate orders n items by the "sort" parameter (prefix "-" for descending)
+// and returns the indexes on the requested page. A "cursor" from a previous
+// response continues after the item it names; otherwise "page" (from 1) is used.
+func paginate(n int, keys sortKeys, defaultSort string, query url.Values) ([]int, string, error) {
+    sortName := query.Get("sort")
+    if sortName == "" {
+        sortName = defaultSort
+    }
+    desc := strings.HasPrefix(sortName, "-")
+    key, ok := keys[strings.TrimPrefix(sortName, "-")]
+    if !ok {
+        var names []string
+        for name := range keys {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        return nil, "", fmt.Errorf("unknown sort %q (want %s, optionally prefixed with -)", sortName, strings.Join(names, ", "))
+    }
+
+    limit := defaultPageLimit
+    if value := query.Get("limit"); value != "" {
+        l, err := strconv.Atoi(value)
+        if err != nil || l < 1 || l > maxPageLimit {
+            return nil, "", fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+        }
+        limit = l
+    }
+
+    order := make([]int, n)
+    for i := range order {
+        order[i] = i
+    }
+    before := func(a, b string) bool {
+        if desc {
+            return a > b
+        }
+        return a < b
+    }
+    sort.Slice(order, func(i, j int) bool {
+        return before(key(order[i]), key(order[j]))
+    })
+
+    start := 0
+    if cursor := query.Get("cursor"); cursor != "" {
+        after, err := base64.RawURLEncoding.DecodeString(cursor)
+        if err != nil {
+            return nil, "", fmt.Errorf("invalid cursor")
+        }
+        start = sort.Search(len(order), func(i int) bool {
+            return before(string(after), key(order[i]))
+        })
+    } else if value := query.Get("page"); value != "" {
+        page, err := strconv.Atoi(value)
+        if err != nil || page < 1 {
+            return nil, "", fmt.Errorf("page must be a positive number")
+        }
+        start = (page - 1) * limit
+    }
+
+    if start > len(order) {
+        start = len(order)
+    }
+    end := start + limit
+    if end > len(order) {
+        end = len(order)
+    }
+    next := ""
+    if end < len(order) {
+        next = base64.RawURLEncoding.EncodeToString([]byte(key(order[end-1])))
+    }
+    return order[start:end], next, nil
+}
+
+// PlanResponse is the current week as served by /plan. StateRevision is what
+// changes to the plan must send back as their "revision".
+type PlanResponse struct {
+    *Plan
+    Note          string         `json:"note,omitempty"`
+    Categories    CategoryStyles `json:"categories,omitempty"`
+    StateRevision int            `json:"state_revision"`
+}
+
+// Mutation is the part every change request carries: the state_revision the
+// client last saw
+type Mutation struct {
+    Revision *int `json:"revision"`
+}
+
+// decodeMutation reads a change request's JSON body into v, answering 400 or
+// 428 itself when it's malformed or doesn't say which revision it's based on
+func decodeMutation(w http.ResponseWriter, r *http.Request, v interface{}, m *Mutation) bool {
+    if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+        http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+        return false
+    }
+    if m.Revision == nil {
+        http.Error(w, "revision is required: send the state_revision from GET /plan", http.StatusPreconditionRequired)
+        return false
+    }
+    return true
+}
+
+// currentRevision answers 409 straight away when the client's revision is
+// already out of date, before any work is done; RecordIfCurrent checks again
+// when the change is saved
+func currentRevision(w http.ResponseWriter, state *WeekState, m Mutation) bool {
+    if *m.Revision != state.JournalSeq {
+        writeRecordError(w, &ConflictError{BasedOn: *m.Revision, Current: state.JournalSeq})
+        return false
+    }
+    return true
+}
+
+// writeRecordError answers a failed RecordIfCurrent, with 409 and the current
+// revision when someone else changed the state first
+func writeRecordError(w http.ResponseWriter, err error) {
+    var conflict *ConflictError
+    if errors.As(err, &conflict) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusConflict)
+        json.NewEncoder(w).Encode(map[string]interface{}{"error": conflict.Error(), "state_revision": conflict.Current})
+        return
+    }
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// notModified sets the ETag header and reports whether the client already has
+// this version, in which case a 304 has been sent
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+    w.Header().Set("ETag", etag)
+    for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+        candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+        if candidate == etag || candidate == "*" {
+            w.WriteHeader(http.StatusNotModified)
+            return true
+        }
+    }
+    return false
+}
+
+// writeJSON sends a value as JSON
+func writeJSON(w http.ResponseWriter, value interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    encoder.Encode(value)
+}
+
+// dataStamp is what a cached response was made from: the day, which moves the
+// week on, and the size and modification time of each data file. Taking one
+// only stats the files, so a poll of unchanged data reads and decodes nothing.
+type dataStamp struct {
+    day   int64
+    files string
+}
+
+// currentStamp stamps the storage's files, the SQLite write-ahead log among
+// them, the config, the journal, which LoadState falls back on, and the
+// files the catalog includes
+func currentStamp(includes []string) (dataStamp, error) {
+    s, err := store()
+    if err != nil {
+        return dataStamp{}, err
+    }
+    now := time.Now()
+    stamp := dataStamp{day: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Unix()}
+    files := append(append(s.Files(), dataPath(ConfigFileName), dataPath(JournalFileName)), includes...)
+    var stamps strings.Builder
+    for _, file := range files {
+        // A missing file stamps as zero, so it's noticed when it appears
+        var size, modified int64
+        if info, err := os.Stat(file); err == nil {
+            size, modified = info.Size(), info.ModTime().UnixNano()
+        }
+        fmt.Fprintf(&stamps, "%d %d\n", size, modified)
+    }
+    stamp.files = stamps.String()
+    return stamp, nil
+}
+
+// includedFiles lists the files the catalog includes, which only change
+// along with the dinners file that names them
+func includedFiles() ([]string, error) {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil, err
+    }
+    return dinners.Files("")[1:], nil
+}
+
+// responseCache keeps the last body of a polled endpoint with its ETag, so a
+// kiosk polling an unchanged week is answered without loading the state
+type responseCache struct {
+    sync.Mutex
+    stamp    dataStamp
+    includes []string
+    etag     string
+    body     []byte
+}
+
+var planCache, todayCache, metricsCache responseCache
+
+// get returns the cached ETag and body while the data is unchanged, building
+// them afresh with build once it has
+func (c *responseCache) get(build func() (etag string, body []byte, err error)) (string, []byte, error) {
+    c.Lock()
+    defer c.Unlock()
+    stamp, err := currentStamp(c.includes)
+    if err != nil {
+        return "", nil, err
+    }
+    if c.body != nil && c.stamp == stamp {
+        return c.etag, c.body, nil
+    }
+
+    // The stamp is taken before building, so a change made meanwhile is
+    // picked up by the next poll
+    includes, err := includedFiles()
+    if err != nil {
+        return "", nil, err
+    }
+    if stamp, err = currentStamp(includes); err != nil {
+        return "", nil, err
+    }
+    etag, body, err := build()
+    if err != nil {
+        return "", nil, err
+    }
+    c.stamp, c.includes, c.etag, c.body = stamp, includes, etag, body
+    return etag, body, nil
+}
+
+// serveCached answers a GET from cache, with 304 when the client is current
+func serveCached(w http.ResponseWriter, r *http.Request, cache *responseCache, contentType string, build func() (string, []byte, error)) {
+    etag, body, err := cache.get(build)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if notModified(w, r, etag) {
+        return
+    }
+    w.Header().Set("Content-Type", contentType)
+    w.Write(body)
+}
+
+// marshalBody encodes a response as writeJSON does
+func marshalBody(value interface{}) ([]byte, error) {
+    body, err := json.MarshalIndent(value, "", "  ")
+    return append(body, '\n'), err
+}
+
+// handlePlan serves GET /plan, the current week's plan and note
+func handlePlan(w http.ResponseWriter, r *http.Request) {
+    serveCached(w, r, &planCache, "application/json", func() (string, []byte, error) {
+        state, err := LoadState()
+        if err != nil {
+            return "", nil, err
+        }
+        state.CheckNewWeek()
+
+        plan := state.Plan
+        if plan == nil {
+            plan = NewPlan(state.WeekStart)
+        }
+        body, err := marshalBody(PlanResponse{Plan: plan, Note: state.Note, Categories: loadStyles(), StateRevision: state.JournalSeq})
+        if err != nil {
+            return "", nil, err
+        }
+        hash := fnv.New32a()
+        hash.Write(body)
+        return fmt.Sprintf(`"plan-%s-%d-%d-%x"`, plan.WeekStart.Format("20060102"), plan.Revision, state.JournalSeq, hash.Sum32()), body, nil
+    })
+}
+
+// TodayResponse is tonight's evening, if one is planned, and the prep to
+// start for later in the week
+type TodayResponse struct {
+    Day           string   `json:"day"`
+    Tonight       *PlanDay `json:"tonight,omitempty"`
+    Prep          []string `json:"prep,omitempty"`
+    StateRevision int      `json:"state_revision"`
+}
+
+// handleToday serves GET /api/today, what "today" prints, for a kitchen
+// display to poll
+func handleToday(w http.ResponseWriter, r *http.Request) {
+    serveCached(w, r, &todayCache, "application/json", func() (string, []byte, error) {
+        state, err := LoadState()
+        if err != nil {
+            return "", nil, err
+        }
+        state.CheckNewWeek()
+
+        today := time.Now().Weekday().String()
+        response := TodayResponse{Day: today, Prep: PrepTasks(state.Plan, today), StateRevision: state.JournalSeq}
+        if entry, ok := state.Plan.Entry(today); ok {
+            response.Tonight = &entry
+        }
+        body, err := marshalBody(response)
+        if err != nil {
+            return "", nil, err
+        }
+        hash := fnv.New32a()
+        hash.Write(body)
+        return fmt.Sprintf(`"today-%x"`, hash.Sum32()), body, nil
+    })
+}
+
+// handleMetrics serves GET /metrics, the week's progress and the catalog's
+// size in Prometheus' text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+    serveCached(w, r, &metricsCache, "text/plain; version=0.0.4", func() (string, []byte, error) {
+        state, err := LoadState()
+        if err != nil {
+            return "", nil, err
+        }
+        state.CheckNewWeek()
+        dinners, err := loadCatalog()
+        if err != nil {
+            return "", nil, err
+        }
+
+        var planned, revision int
+        outcomes := map[string]int{OutcomeCooked: 0, OutcomeSkipped: 0, OutcomeSubstituted: 0}
+        if state.Plan != nil {
+            revision = state.Plan.Revision
+            for _, entry := range state.Plan.Days {
+                if entry.Dinner.Name != "" || entry.IsLeftovers() {
+                    planned++
+                }
+                if _, ok := outcomes[entry.Outcome]; ok {
+                    outcomes[entry.Outcome]++
+                }
+            }
+        }
+
+        var body strings.Builder
+        metric := func(name, help string, value int) {
+            fmt.Fprintf(&body, "# HELP dinner_picker_%s %s\n# TYPE dinner_picker_%s gauge\ndinner_picker_%s %d\n", name, help, name, name, value)
+        }
+        metric("state_revision", "Journal sequence of the saved state.", state.JournalSeq)
+        metric("plan_revision", "Revision of this week's plan.", revision)
+        metric("planned_days", "Evenings planned this week.", planned)
+        fmt.Fprintf(&body, "# HELP dinner_picker_outcome_days Evenings this week by what happened.\n# TYPE dinner_picker_outcome_days gauge\n")
+        for _, outcome := range []string{OutcomeCooked, OutcomeSkipped, OutcomeSubstituted} {
+            fmt.Fprintf(&body, "dinner_picker_outcome_days{outcome=%q} %d\n", outcome, outcomes[outcome])
+        }
+        metric("dinners", "Dinners in the catalog.", len(dinners.AllDinners()))
+        metric("history_weeks", "Past weeks kept in the history.", len(state.History))
+        hash := fnv.New32a()
+        hash.Write([]byte(body.String()))
+        return fmt.Sprintf(`"metrics-%x"`, hash.Sum32()), []byte(body.String()), nil
+    })
+}
+
+// handleNewPlan serves POST /plan {"revision": 12}, planning the week afresh
+// as "plan --force" does, keeping the days already eaten; "days": ["monday",
+// "tuesday"], "pattern" and "seed" are optional
+func handleNewPlan(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Mutation
+        Days    []string `json:"days"`
+        Pattern string   `json:"pattern"`
+        Seed    int64    `json:"seed"`
+    }
+    if !decodeMutation(w, r, &req, &req.Mutation) {
+        return
+    }
+    var days []string
+    for _, name := range req.Days {
+        day, ok := normalizeDay(name)
+        if !ok {
+            http.Error(w, fmt.Sprintf("unknown day: %q", name), http.StatusBadRequest)
+            return
+        }
+        days = append(days, day)
+    }
+
+    state, _, err := planWeek(PlanRequest{Days: days, Pattern: req.Pattern, Seed: req.Seed, Force: true, Draft: true})
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    if !currentRevision(w, state, req.Mutation) {
+        return
+    }
+    if err := state.RecordIfCurrent("plan", planSummary(state.Plan)+" (via API)", *req.Revision); err != nil {
+        writeRecordError(w, err)
+        return
+    }
+    writeJSON(w, PlanResponse{Plan: state.Plan, Note: state.Note, Categories: loadStyles(), StateRevision: state.JournalSeq})
+}
+
+// handleSwap serves POST /plan/swap {"day": "monday", "revision": 12,
+// "minimize_new_items": false}, re-rolling one day, and POST
+// /plan/{day}/repick, the same with the day in the path
+func handleSwap(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Mutation
+        Day      string `json:"day"`
+        Minimize bool   `json:"minimize_new_items"`
+    }
+    if !decodeMutation(w, r, &req, &req.Mutation) {
+        return
+    }
+    if path := r.PathValue("day"); path != "" {
+        req.Day = path
+    }
+    day, ok := normalizeDay(req.Day)
+    if !ok {
+        http.Error(w, fmt.Sprintf("unknown day: %q", req.Day), http.StatusBadRequest)
+        return
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state, err := LoadState()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state.CheckNewWeek()
+    if !currentRevision(w, state, req.Mutation) {
+        return
+    }
+
+    swap, err := swapDay(dinners, state, config, day, req.Minimize, config.RepeatDays(nil), 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    if err := state.RecordIfCurrent("swap", fmt.Sprintf("%s: %s -> %s (via API)", day, swap.Previous, swap.Replacement.Name), *req.Revision); err != nil {
+        writeRecordError(w, err)
+        return
+    }
+    writeJSON(w, struct {
+        *SwapResult
+        StateRevision int `json:"state_revision"`
+    }{swap, state.JournalSeq})
+}
+
+// handleNote serves PUT /plan/note {"note": "visitors", "revision": 12},
+// setting the week's note ("" clears it)
+func handleNote(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Mutation
+        Note string `json:"note"`
+    }
+    if !decodeMutation(w, r, &req, &req.Mutation) {
+        return
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state.CheckNewWeek()
+    if !currentRevision(w, state, req.Mutation) {
+        return
+    }
+    state.Note = strings.TrimSpace(req.Note)
+    if err := state.RecordIfCurrent("week note", state.Note+" (via API)", *req.Revision); err != nil {
+        writeRecordError(w, err)
+        return
+    }
+    writeJSON(w, map[string]interface{}{"note": state.Note, "state_revision": state.JournalSeq})
+}
+
+// handleFeedback serves POST /plan/{day}/feedback {"rating": 4, "leftovers":
+// "some", "comment": "more garlic", "revision": 12}, for asking "how was
+// dinner?" once it's eaten; "veto": true rules the dinner out from then on
+func handleFeedback(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Mutation
+        Feedback
+    }
+    if !decodeMutation(w, r, &req, &req.Mutation) {
+        return
+    }
+    day, ok := normalizeDay(r.PathValue("day"))
+    if !ok {
+        http.Error(w, fmt.Sprintf("unknown day: %q", r.PathValue("day")), http.StatusBadRequest)
+        return
+    }
+    if err := req.Feedback.validate(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    pantry, err := LoadPantry()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state, err := LoadState()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state.CheckNewWeek()
+    if !currentRevision(w, state, req.Mutation) {
+        return
+    }
+
+    entry, err := state.GiveFeedback(day, req.Feedback, pantry, time.Now())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    if err := state.RecordIfCurrent("feedback", fmt.Sprintf("%s: %s, %s (via API)", day, entry.Dinner.Name, req.Feedback.summary()), *req.Revision); err != nil {
+        writeRecordError(w, err)
+        return
+    }
+    if err := pantry.SavePantry(); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, struct {
+        PlanDay
+        StateRevision int `json:"state_revision"`
+    }{entry, state.JournalSeq})
+}
+
+// handleDinners serves GET /dinners?category=&tag=&sort=&limit=&page=&cursor=
+func handleDinners(w http.ResponseWriter, r *http.Request) {
+    s, err := store()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    dinners, err := s.LoadDinners()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    // The ETag comes from the catalog's version plus the query
+    version, err := s.CatalogVersion(dinners)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    hash := fnv.New32a()
+    hash.Write([]byte(r.URL.RawQuery))
+    hash.Write([]byte(version))
+    etag := fmt.Sprintf(`"dinners-%x"`, hash.Sum32())
+    if notModified(w, r, etag) {
+        return
+    }
+
+    query := r.URL.Query()
+    var matched []Dinner
+    for _, dinner := range dinners.AllDinners() {
+        if category := query.Get("category"); category != "" && !strings.EqualFold(dinner.Category, category) {
+            continue
+        }
+        if tag := query.Get("tag"); tag != "" && !dinner.HasTag(tag) {
+            continue
+        }
+        matched = append(matched, dinner)
+    }
+
+    id := func(i int) string {
+        return "\x00" + strings.ToLower(matched[i].Name)
+    }
+    indexes, next, err := paginate(len(matched), sortKeys{
+        "name": id,
+        "category": func(i int) string {
+            return strings.ToLower(matched[i].Category) + id(i)
+        },
+        "cook_time": func(i int) string {
+            return fmt.Sprintf("%06d", matched[i].CookTime) + id(i)
+        },
+    }, "name", query)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    items := []Dinner{}
+    for _, i := range indexes {
+        items = append(items, matched[i])
+    }
+    writeJSON(w, PageResponse{Items: items, Total: len(matched), NextCursor: next})
+}
+
+// handleAddDinner serves POST /dinners {"name": "Pad thai", "category":
+// "noodles-rice", "ingredients": ["200 g rice noodles", "2 eggs"]}, adding a
+// dinner as "dinner add" does. A category the catalog doesn't have needs
+// "new_category": true; without one, the closest match is used.
+func handleAddDinner(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Dinner
+        NewCategory bool `json:"new_category"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    dinner := req.Dinner
+    dinner.Name = strings.TrimSpace(dinner.Name)
+    if dinner.Name == "" || len(dinner.Ingredients) == 0 {
+        http.Error(w, "a dinner needs a name and ingredients", http.StatusBadRequest)
+        return
+    }
+
+    // The catalog has no revision to check, so it's locked from reading to saving
+    unlock, err := lockData()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+        return
+    }
+    defer unlock()
+    dinners, err := loadCatalog()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    category := strings.TrimSpace(dinner.Category)
+    if existing, ok := dinners.hasCategory(category); ok {
+        dinner.Category = existing
+    } else if category == "" {
+        if dinner.Category = dinners.SuggestCategory(dinner); dinner.Category == "" {
+            http.Error(w, "no category given and nothing alike to suggest one", http.StatusUnprocessableEntity)
+            return
+        }
+    } else if !req.NewCategory {
+        http.Error(w, fmt.Sprintf("no category named %q (send \"new_category\": true to start one)", category), http.StatusUnprocessableEntity)
+        return
+    }
+    dinner.Origin = &Origin{CreatedAt: time.Now(), Via: OriginManual}
+    if err := dinners.AddDinner(dinner); err != nil {
+        http.Error(w, err.Error(), http.StatusConflict)
+        return
+    }
+    if err := saveCatalog(dinners); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(dinner)
+}
+
+// ShoppingItem is one line of the shopping list as served by /shopping-list
+type ShoppingItem struct {
+    Item  string `json:"item"`
+    Text  string `json:"text"`
+    Store string `json:"store,omitempty"`
+
+    // Have is how much the pantry has of an item it doesn't have enough of
+    Have string `json:"have,omitempty"`
+
+    // Checked is whether the item was ticked off through /sync
+    Checked bool `json:"checked"`
+}
+
+// ShoppingListResponse is this week's shopping list as served by /shopping-list
+type ShoppingListResponse struct {
+    Items          []ShoppingItem `json:"items"`
+    Optional       []ShoppingItem `json:"optional,omitempty"`
+    AlreadyHave    []ShoppingItem `json:"already_have,omitempty"`
+    StaplesLeftOff int            `json:"staples_left_off"`
+}
+
+// handleShoppingList serves GET /shopping-list?store=&no-optional=&include-staples=&ignore-pantry=,
+// the list "shopping-list" prints, one item at a time
+func handleShoppingList(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    flags := make(map[string]bool)
+    for _, name := range []string{"no-optional", "include-staples", "ignore-pantry"} {
+        if value := query.Get(name); value != "" {
+            on, err := strconv.ParseBool(value)
+            if err != nil {
+                http.Error(w, name+" must be true or false", http.StatusBadRequest)
+                return
+            }
+            flags[name] = on
+        }
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    opts := ShoppingOptions{
+        Stores:         config.Stores,
+        Only:           query.Get("store"),
+        Optional:       !flags["no-optional"],
+        Staples:        config.Staples,
+        IncludeStaples: flags["include-staples"],
+    }
+    if !flags["ignore-pantry"] {
+        if opts.Pantry, err = LoadPantry(); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+    }
+
+    plan := state.Plan
+    if plan == nil {
+        plan = NewPlan(state.WeekStart)
+    }
+    sections := sortShopping(plan, opts)
+    line := func(item string) ShoppingItem {
+        entry := ShoppingItem{Item: item, Text: sections.totals[item].String()}
+        if opts.Stores != nil || opts.Only != "" {
+            entry.Store = opts.Stores.StoreFor(item)
+        }
+        entry.Have, _ = opts.Pantry.Stock(sections.totals[item])
+        if key := syncWeek(state.WeekStart) + "/list/" + item; state.Sync != nil && state.Sync.Entities[key] != nil {
+            entry.Checked = state.Sync.Entities[key].Value
+        }
+        return entry
+    }
+    response := ShoppingListResponse{Items: []ShoppingItem{}, StaplesLeftOff: sections.stocked}
+    for _, item := range sections.items {
+        if entry := line(item); opts.Only == "" || strings.EqualFold(entry.Store, opts.Only) {
+            response.Items = append(response.Items, entry)
+        }
+    }
+    if opts.Optional {
+        for _, item := range sections.extras {
+            response.Optional = append(response.Optional, line(item))
+        }
+    }
+    for _, item := range sections.have {
+        response.AlreadyHave = append(response.AlreadyHave, line(item))
+    }
+    writeJSON(w, response)
+}
+
+// handleHistory serves GET /history?category=&tag=&cooked-after=&sort=&limit=&page=&cursor=,
+// one entry per past evening
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+    dinners, err := loadCatalog()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state, err := LoadState()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    query := r.URL.Query()
+    var cookedAfter time.Time
+    if value := query.Get("cooked-after"); value != "" {
+        cookedAfter, err = time.Parse("2006-01-02", value)
+        if err != nil {
+            http.Error(w, "cooked-after must be YYYY-MM-DD", http.StatusBadRequest)
+            return
+        }
+    }
+
+    var matched []HistoryEntry
+    for _, week := range state.History {
+        for _, day := range week.Days {
+            if category := query.Get("category"); category != "" && !strings.EqualFold(day.Category, category) {
+                continue
+            }
+            if tag := query.Get("tag"); tag != "" {
+                dinner, ok := dinners.FindDinner(day.Dinner)
+                if !ok || !dinner.HasTag(tag) {
+                    continue
+                }
+            }
+            if !cookedAfter.IsZero() && (day.Outcome != OutcomeCooked || !day.Date.After(cookedAfter)) {
+                continue
+            }
+            matched = append(matched, HistoryEntry{WeekStart: week.WeekStart, HistoryDay: day})
+        }
+    }
+
+    id := func(i int) string {
+        return "\x00" + matched[i].Date.Format("2006-01-02") + "\x00" + strings.ToLower(matched[i].Dinner)
+    }
+    indexes, next, err := paginate(len(matched), sortKeys{
+        "date": id,
+        "dinner": func(i int) string {
+            return strings.ToLower(matched[i].Dinner) + id(i)
+        },
+        "rating": func(i int) string {
+            return strconv.Itoa(matched[i].Rating) + id(i)
+        },
+    }, "-date", query)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    items := []HistoryEntry{}
+    for _, i := range indexes {
+        items = append(items, matched[i])
+    }
+    writeJSON(w, PageResponse{Items: items, Total: len(matched), NextCursor: next})
+}
+
+// runServeCommand handles "serve [--addr host:port]", serving the data as a
+// JSON API, and a web page on it at /
+func runServeCommand(args []string) error {
+    fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+    addr := fs.String("addr", "localhost:8080", "address to listen on")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /{$}", handleWebUI)
+    mux.HandleFunc("GET /plan", handlePlan)
+    mux.HandleFunc("GET /api/today", handleToday)
+    mux.HandleFunc("GET /metrics", handleMetrics)
+    mux.HandleFunc("GET /dinners", handleDinners)
+    mux.HandleFunc("GET /history", handleHistory)
+    mux.HandleFunc("GET /shopping-list", handleShoppingList)
+    mux.HandleFunc("POST /plan", handleNewPlan)
+    mux.HandleFunc("POST /plan/swap", handleSwap)
+    mux.HandleFunc("POST /plan/{day}/repick", handleSwap)
+    mux.HandleFunc("POST /dinners", handleAddDinner)
+    mux.HandleFunc("PUT /plan/note", handleNote)
+    mux.HandleFunc("POST /plan/{day}/feedback", handleFeedback)
+    mux.HandleFunc("GET /sync", handleSyncPull)
+    mux.HandleFunc("POST /sync", handleSyncPush)
+    mux.HandleFunc("GET /settings", handleSettingsPage)
+    mux.HandleFunc("GET /settings/config", handleGetSettings)
+    mux.HandleFunc("PUT /settings/config", handlePutSettings)
+
+    logf("Serving on http://%s (open it in a browser for the week and the shopping list, /settings for the rules)\n", *addr)
+    return http.ListenAndServe(*addr, mux)
+}
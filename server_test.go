@@ -0,0 +1,117 @@
+package main
+
+import (
+    "bytes"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// useRepoData points the data directory at a copy of the repo's catalog and
+// state, for as long as the test or benchmark runs, and returns the copy
+func useRepoData(tb testing.TB) string {
+    dir := tb.TempDir()
+    for _, name := range []string{DinnersFileName, StateFileName} {
+        data, err := os.ReadFile(name)
+        if err != nil {
+            tb.Fatal(err)
+        }
+        if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+            tb.Fatal(err)
+        }
+    }
+    savedBase, savedData := baseDirs, dataDirs
+    baseDirs, dataDirs = singleDir(dir), singleDir(dir)
+    opened = nil
+    tb.Cleanup(func() {
+        baseDirs, dataDirs = savedBase, savedData
+        opened = nil
+    })
+    return dir
+}
+
+// benchmarkPoll measures a dashboard polling path with unchanged data, first
+// without and then with the ETag it was last sent
+func benchmarkPoll(b *testing.B, path string, handler http.HandlerFunc) {
+    useRepoData(b)
+    first := httptest.NewRecorder()
+    handler(first, httptest.NewRequest("GET", path, nil))
+    if first.Code != http.StatusOK {
+        b.Fatalf("GET %s: %d %s", path, first.Code, first.Body)
+    }
+    etag := first.Header().Get("ETag")
+
+    b.Run("full", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            handler(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+        }
+    })
+    b.Run("not-modified", func(b *testing.B) {
+        request := httptest.NewRequest("GET", path, nil)
+        request.Header.Set("If-None-Match", etag)
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            recorder := httptest.NewRecorder()
+            handler(recorder, request)
+            if recorder.Code != http.StatusNotModified {
+                b.Fatalf("GET %s with its ETag: %d", path, recorder.Code)
+            }
+        }
+    })
+}
+
+func BenchmarkPlan(b *testing.B) {
+    benchmarkPoll(b, "/plan", handlePlan)
+}
+
+func BenchmarkToday(b *testing.B) {
+    benchmarkPoll(b, "/api/today", handleToday)
+}
+
+func BenchmarkMetrics(b *testing.B) {
+    benchmarkPoll(b, "/metrics", handleMetrics)
+}
+
+// A change to a file the catalog includes is served at once, though the
+// dinners file itself is untouched
+func TestCacheNoticesIncludedFiles(t *testing.T) {
+    dir := useRepoData(t)
+    catalog, err := os.ReadFile(filepath.Join(dir, DinnersFileName))
+    if err != nil {
+        t.Fatal(err)
+    }
+    catalog = bytes.Replace(catalog, []byte(`"dinners": {`), []byte(`"include": ["extra.json"], "dinners": {`), 1)
+    extra := filepath.Join(dir, "extra.json")
+    for _, write := range []struct {
+        file string
+        data string
+    }{
+        {filepath.Join(dir, DinnersFileName), string(catalog)},
+        {extra, `{"dinners": {"soup": [{"name": "Minestrone", "category": "soup"}]}}`},
+    } {
+        if err := os.WriteFile(write.file, []byte(write.data), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    get := func() string {
+        recorder := httptest.NewRecorder()
+        handleMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+        if recorder.Code != http.StatusOK {
+            t.Fatalf("GET /metrics: %d %s", recorder.Code, recorder.Body)
+        }
+        return recorder.Body.String()
+    }
+    before := get()
+
+    data := `{"dinners": {"soup": [{"name": "Minestrone", "category": "soup"}, {"name": "Pho", "category": "soup"}]}}`
+    if err := os.WriteFile(extra, []byte(data), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if after := get(); after == before {
+        t.Fatal("GET /metrics still counts the dinners from before extra.json changed")
+    }
+}
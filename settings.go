@@ -0,0 +1,237 @@
+package main
+
+import (
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "hash/fnv"
+    "net/http"
+    "sort"
+)
+
+// settingsPage is the page "serve" answers /settings with
+//
+//go:embed settings.html
+var settingsPage []byte
+
+// secretPlaceholder stands in for a bot token on the settings page; sent back
+// as it is, it keeps the saved token
+const secretPlaceholder = "********"
+
+// Settings are the parts of the config the settings page edits: how the week
+// is laid out, the rules dinners are picked by, and where messages go
+type Settings struct {
+    Schedule          *ScheduleConfig  `json:"schedule"`
+    Observances       []Observance     `json:"observances"`
+    Protein           *ProteinRules    `json:"protein"`
+    NoRepeatDays      *int             `json:"no_repeat_days"`
+    NoRepeatWeeks     *int             `json:"no_repeat_weeks"`
+    NoCookNights      int              `json:"no_cook_nights"`
+    LeftoverNights    int              `json:"leftover_nights"`
+    MinVeggieServings float64          `json:"min_veggie_servings"`
+    Household         int              `json:"household"`
+    Reminders         *RemindersConfig `json:"reminders"`
+    WeekTelegram      *TelegramStep    `json:"week_telegram"`
+}
+
+// hideToken returns a copy of a bot's settings with the token replaced by the placeholder
+func hideToken(t TelegramConfig) TelegramConfig {
+    if t.BotToken != "" {
+        t.BotToken = secretPlaceholder
+    }
+    return t
+}
+
+// keepToken puts the saved token back where the placeholder was returned
+func keepToken(t *TelegramConfig, saved *TelegramConfig) {
+    if t == nil || t.BotToken != secretPlaceholder {
+        return
+    }
+    t.BotToken = ""
+    if saved != nil {
+        t.BotToken = saved.BotToken
+    }
+}
+
+// settingsOf takes the settings out of a config, with the schedule in use
+// when none is set and bot tokens hidden
+func settingsOf(c *Config) Settings {
+    settings := Settings{
+        Schedule:          PlanOptions{Schedule: c.Schedule}.schedule(),
+        Observances:       c.Observances,
+        Protein:           c.Protein,
+        NoRepeatDays:      c.NoRepeatDays,
+        NoRepeatWeeks:     c.NoRepeatWeeks,
+        NoCookNights:      c.NoCookNights,
+        LeftoverNights:    c.LeftoverNights,
+        MinVeggieServings: c.MinVeggieServings,
+        Household:         c.Household,
+    }
+    if c.Reminders != nil {
+        reminders := *c.Reminders
+        if reminders.Telegram != nil {
+            telegram := hideToken(*reminders.Telegram)
+            reminders.Telegram = &telegram
+        }
+        settings.Reminders = &reminders
+    }
+    if c.Week != nil && c.Week.Telegram != nil {
+        step := *c.Week.Telegram
+        step.TelegramConfig = hideToken(step.TelegramConfig)
+        settings.WeekTelegram = &step
+    }
+    return settings
+}
+
+// apply puts the settings into a config
+func (s Settings) apply(c *Config) {
+    if s.Reminders != nil {
+        var saved *TelegramConfig
+        if c.Reminders != nil {
+            saved = c.Reminders.Telegram
+        }
+        keepToken(s.Reminders.Telegram, saved)
+    }
+    if s.WeekTelegram != nil {
+        var saved *TelegramConfig
+        if c.Week != nil && c.Week.Telegram != nil {
+            saved = &c.Week.Telegram.TelegramConfig
+        }
+        keepToken(&s.WeekTelegram.TelegramConfig, saved)
+    }
+
+    c.Schedule = s.Schedule
+    c.Observances = s.Observances
+    c.Protein = s.Protein
+    c.NoRepeatDays = s.NoRepeatDays
+    c.NoRepeatWeeks = s.NoRepeatWeeks
+    c.NoCookNights = s.NoCookNights
+    c.LeftoverNights = s.LeftoverNights
+    c.MinVeggieServings = s.MinVeggieServings
+    c.Household = s.Household
+    c.Reminders = s.Reminders
+    if s.WeekTelegram != nil || (c.Week != nil && c.Week.Telegram != nil) {
+        if c.Week == nil {
+            c.Week = &WeekConfig{}
+        }
+        c.Week.Telegram = s.WeekTelegram
+    }
+}
+
+// configVersion identifies a config's contents, so the settings page can't
+// save over changes it hasn't seen
+func configVersion(c *Config) string {
+    data, _ := json.Marshal(c)
+    hash := fnv.New64a()
+    hash.Write(data)
+    return fmt.Sprintf("%x", hash.Sum64())
+}
+
+// SettingsResponse is what /settings/config answers with: the settings, the
+// version to send back with changes, and the catalog's categories to choose from
+type SettingsResponse struct {
+    Settings   Settings `json:"settings"`
+    Version    string   `json:"version"`
+    Categories []string `json:"categories"`
+}
+
+// newSettingsResponse describes a config for the settings page
+func newSettingsResponse(c *Config) (SettingsResponse, error) {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return SettingsResponse{}, err
+    }
+    response := SettingsResponse{Settings: settingsOf(c), Version: configVersion(c), Categories: []string{}}
+    for category := range dinners.Dinners {
+        response.Categories = append(response.Categories, category)
+    }
+    sort.Strings(response.Categories)
+    return response, nil
+}
+
+// handleSettingsPage serves GET /settings, the page for changing the settings
+func handleSettingsPage(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Write(settingsPage)
+}
+
+// handleGetSettings serves GET /settings/config
+func handleGetSettings(w http.ResponseWriter, r *http.Request) {
+    config, err := LoadConfig()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    response, err := newSettingsResponse(config)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, response)
+}
+
+// handlePutSettings serves PUT /settings/config {"version": "...",
+// "settings": {...}}, checking the settings as "validate" would before
+// saving them. A version other than the saved config's is turned down with
+// 409, as someone changed it since.
+func handlePutSettings(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Version  string    `json:"version"`
+        Settings *Settings `json:"settings"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    if req.Version == "" || req.Settings == nil {
+        http.Error(w, "version and settings are required: send the version from GET /settings/config", http.StatusBadRequest)
+        return
+    }
+
+    unlock, err := lockData()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+        return
+    }
+    defer unlock()
+    config, err := LoadConfig()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if current := configVersion(config); req.Version != current {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusConflict)
+        json.NewEncoder(w).Encode(map[string]string{"error": "the settings were changed since they were loaded", "version": current})
+        return
+    }
+
+    req.Settings.apply(config)
+    if err := config.Validate(); err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if config.Schedule != nil {
+        for _, category := range config.Schedule.categories() {
+            if _, ok := dinners.hasCategory(category); !ok && len(config.CategoryFallbacks[category]) == 0 {
+                http.Error(w, fmt.Sprintf("the schedule draws from %q, which isn't a category", category), http.StatusUnprocessableEntity)
+                return
+            }
+        }
+    }
+    if err := config.SaveConfig(); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    response, err := newSettingsResponse(config)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, response)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// optionalMarkers flag ingredients a dinner works without, like "parsley (garnish)"
+var optionalMarkers = []string{"(optional)", "(garnish)"}
+
+// isOptional reports whether an ingredient is marked optional
+func isOptional(ingredient string) bool {
+    item := strings.ToLower(strings.TrimSpace(ingredient))
+    for _, marker := range optionalMarkers {
+        if strings.HasSuffix(item, marker) {
+            return true
+        }
+    }
+    return false
+}
+
+// normalizeIngredient lowercases and trims an ingredient, dropping any optional
+// marker, so duplicates can be matched
+func normalizeIngredient(ingredient string) string {
+    item := strings.ToLower(strings.TrimSpace(ingredient))
+    for _, marker := range optionalMarkers {
+        item = strings.TrimSpace(strings.TrimSuffix(item, marker))
+    }
+    return item
+}
+
+// ShoppingList returns the deduplicated, sorted ingredients for the given
+// dinners, by name without amounts (see Ingredient.Key)
+func ShoppingList(dinners []Dinner) []string {
+    seen := make(map[string]bool)
+    var items []string
+    for _, dinner := range dinners {
+        for _, ingredient := range dinner.Ingredients {
+            item := ingredient.Key()
+            if item == "" || seen[item] {
+                continue
+            }
+            seen[item] = true
+            items = append(items, item)
+        }
+    }
+    sort.Strings(items)
+    return items
+}
+
+// OptionalItems returns the items on the dinners' list that every dinner using them marks optional
+func OptionalItems(dinners []Dinner) map[string]bool {
+    optional := make(map[string]bool)
+    for _, dinner := range dinners {
+        for _, ingredient := range dinner.Ingredients {
+            item := ingredient.Key()
+            if _, seen := optional[item]; !seen {
+                optional[item] = ingredient.Optional
+            } else if !ingredient.Optional {
+                optional[item] = false
+            }
+        }
+    }
+    return optional
+}
+
+// NewItems returns the ingredients of a dinner that aren't already on the
+// list, leaving out optional ones since the dinner works without them
+func NewItems(dinner Dinner, onList map[string]bool) []string {
+    var items []string
+    for _, ingredient := range dinner.Ingredients {
+        item := ingredient.Key()
+        if item != "" && !onList[item] && !ingredient.Optional {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// StoreConfig says where each ingredient is bought. Ingredients not listed
+// under any store go to Default.
+type StoreConfig struct {
+    Default string              `json:"default,omitempty"`
+    Items   map[string][]string `json:"items"`
+}
+
+// defaultStore returns the name used for unassigned ingredients
+func (c *StoreConfig) defaultStore() string {
+    if c == nil || c.Default == "" {
+        return "Anywhere"
+    }
+    return c.Default
+}
+
+// StoreFor returns the store an ingredient is bought at
+func (c *StoreConfig) StoreFor(item string) string {
+    if c != nil {
+        for store, items := range c.Items {
+            for _, assigned := range items {
+                if sameIngredient(assigned, item) {
+                    return store
+                }
+            }
+        }
+    }
+    return c.defaultStore()
+}
+
+// SplitByStore groups a shopping list by store, returning the store names in
+// order with the default store last
+func (c *StoreConfig) SplitByStore(items []string) ([]string, map[string][]string) {
+    lists := make(map[string][]string)
+    for _, item := range items {
+        store := c.StoreFor(item)
+        lists[store] = append(lists[store], item)
+    }
+
+    var stores []string
+    for store := range lists {
+        if store != c.defaultStore() {
+            stores = append(stores, store)
+        }
+    }
+    sort.Strings(stores)
+    if _, ok := lists[c.defaultStore()]; ok {
+        stores = append(stores, c.defaultStore())
+    }
+    return stores, lists
+}
+
+// ShoppingOptions shapes the shopping list
+type ShoppingOptions struct {
+    Stores *StoreConfig
+
+    // Only limits the list to one store
+    Only string
+
+    // Optional adds optional items in their own section
+    Optional bool
+
+    // Staples are left off the list as always stocked, unless IncludeStaples
+    Staples        []string
+    IncludeStaples bool
+
+    // Pantry moves what's already in stock, in a large enough amount, to an
+    // "Already have" section, and notes the stock of what isn't enough
+    Pantry *Pantry
+
+    // Household and Spend price the list, when the dinners or their
+    // ingredients have costs
+    Household int
+    Spend     *SpendConfig
+}
+
+// cost estimates what buying the items costs: the priced ingredients among
+// them, for the people eating, and dinners priced as a whole in full
+func (opts ShoppingOptions) cost(plan *Plan, items []string) float64 {
+    buying := make(map[string]bool)
+    for _, item := range items {
+        if opts.Only == "" || strings.EqualFold(opts.Stores.StoreFor(item), opts.Only) {
+            buying[item] = true
+        }
+    }
+    total, _ := planCost(plan, opts.Household, opts.Spend, func(ingredient Ingredient) bool {
+        return buying[ingredient.Key()]
+    })
+    return total
+}
+
+// shoppingSections is a plan's shopping list sorted into what to buy, the
+// optional extras and what the pantry already has, with the amounts added up
+// in totals and a count of the staples left off
+type shoppingSections struct {
+    items, extras, have []string
+    stocked             int
+    totals              map[string]*GroceryItem
+}
+
+// sortShopping sorts what a plan needs into sections
+func sortShopping(plan *Plan, opts ShoppingOptions) shoppingSections {
+    sections := shoppingSections{totals: AggregateIngredients(plan.Dinners())}
+    optional := OptionalItems(plan.Dinners())
+    for _, item := range ShoppingList(plan.Dinners()) {
+        _, enough := opts.Pantry.Stock(sections.totals[item])
+        switch {
+        case !opts.IncludeStaples && isStaple(item, opts.Staples):
+            sections.stocked++
+        case enough:
+            sections.have = append(sections.have, item)
+        case optional[item]:
+            sections.extras = append(sections.extras, item)
+        default:
+            sections.items = append(sections.items, item)
+        }
+    }
+    return sections
+}
+
+// WriteShoppingList writes what a plan needs with the amounts added up, split
+// by store when stores are configured or Only is set. Staples are left out
+// with a count, and optional items and what the pantry already has follow in
+// their own sections.
+func WriteShoppingList(w io.Writer, plan *Plan, opts ShoppingOptions) {
+    stores, only := opts.Stores, opts.Only
+    sections := sortShopping(plan, opts)
+    items, extras, have, stocked, totals := sections.items, sections.extras, sections.have, sections.stocked, sections.totals
+    // line writes an item with its total, and the stock when there's some but not enough
+    line := func(item string) string {
+        if stock, _ := opts.Pantry.Stock(totals[item]); stock != "" {
+            return fmt.Sprintf("%s (have %s)", totals[item], stock)
+        }
+        return totals[item].String()
+    }
+    defer func() {
+        if opts.Optional && len(extras) > 0 {
+            fmt.Fprintln(w, "\nOptional:")
+            for _, item := range extras {
+                fmt.Fprintf(w, "  %s\n", line(item))
+            }
+        }
+        if len(have) > 0 {
+            fmt.Fprintln(w, "\nAlready have:")
+            for _, item := range have {
+                stock, _ := opts.Pantry.Stock(totals[item])
+                fmt.Fprintf(w, "  %s (%s in the pantry)\n", totals[item], stock)
+            }
+        }
+        if stocked > 0 {
+            fmt.Fprintf(w, "\n(%d staple(s) left off, --include-staples lists them)\n", stocked)
+        }
+        buying := items
+        if opts.Optional {
+            buying = append(append([]string(nil), items...), extras...)
+        }
+        if total := opts.cost(plan, buying); total > 0 {
+            fmt.Fprintf(w, "\nEstimated cost: about %s\n", opts.Spend.format(total))
+        }
+    }()
+
+    if stores == nil && only == "" {
+        for _, item := range items {
+            fmt.Fprintln(w, line(item))
+        }
+        return
+    }
+
+    names, lists := stores.SplitByStore(items)
+    found := false
+    for _, store := range names {
+        if only != "" && !strings.EqualFold(store, only) {
+            continue
+        }
+        if found {
+            fmt.Fprintln(w)
+        }
+        found = true
+        fmt.Fprintf(w, "%s:\n", store)
+        for _, item := range lists[store] {
+            fmt.Fprintf(w, "  %s\n", line(item))
+        }
+    }
+    if !found {
+        fmt.Fprintf(w, "Nothing to buy at %s\n", only)
+    }
+}
+
+// runShoppingListCommand handles "shopping-list [--store name] [--no-optional] [--include-staples] [--ignore-pantry] [--copy] [--out file]",
+// printing what the week's plan needs, split by store when stores are configured,
+// or copying it to the clipboard or writing it to a file
+func runShoppingListCommand(args []string) error {
+    fs := flag.NewFlagSet("shopping-list", flag.ContinueOnError)
+    only := fs.String("store", "", "only print the list for this store")
+    noOptional := fs.Bool("no-optional", false, "leave out optional ingredients")
+    clip := fs.Bool("copy", false, "put the list on the clipboard instead of printing it")
+    out := fs.String("out", "", "write the list to a file instead of printing it")
+    withStaples := fs.Bool("include-staples", false, "list staples too instead of assuming they're stocked")
+    ignorePantry := fs.Bool("ignore-pantry", false, "list everything, whatever the pantry has")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    if state.Plan.IsEmpty() {
+        logf("No dinners planned for this week yet\n")
+        return nil
+    }
+    opts := ShoppingOptions{
+        Stores:         config.Stores,
+        Only:           *only,
+        Optional:       !*noOptional,
+        Staples:        config.Staples,
+        IncludeStaples: *withStaples,
+        Household:      config.Household,
+        Spend:          config.Spend,
+    }
+    if !*ignorePantry {
+        if opts.Pantry, err = LoadPantry(); err != nil {
+            return err
+        }
+    }
+    if *out != "" {
+        file, err := os.Create(*out)
+        if err != nil {
+            return fmt.Errorf("error writing shopping list: %w", err)
+        }
+        defer file.Close()
+        WriteShoppingList(file, state.Plan, opts)
+        logf("Wrote the shopping list to %s\n", *out)
+        return nil
+    }
+    if !*clip {
+        WriteShoppingList(os.Stdout, state.Plan, opts)
+        return nil
+    }
+
+    var list strings.Builder
+    WriteShoppingList(&list, state.Plan, opts)
+    if err := CopyToClipboard(list.String()); err != nil {
+        return err
+    }
+    logf("Copied the shopping list (%d lines) to the clipboard\n", strings.Count(list.String(), "\n"))
+    return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+    "fmt"
+    "time"
+)
+
+// CategoryBias scores how well a category suits a day; positive is better.
+// Days or categories that aren't present count as zero.
+type CategoryBias map[string]map[string]float64
+
+// SignalProvider supplies outside hints (weather, ...) that bias which
+// category lands on which day
+type SignalProvider interface {
+    Name() string
+    Bias(weekStart time.Time) (CategoryBias, []string, error)
+}
+
+// Add merges another bias into this one, summing scores
+func (b CategoryBias) Add(other CategoryBias) {
+    for day, scores := range other {
+        if b[day] == nil {
+            b[day] = make(map[string]float64)
+        }
+        for category, score := range scores {
+            b[day][category] += score
+        }
+    }
+}
+
+// CollectBias asks every provider for its bias and notes, skipping any that
+// fail so planning still works offline
+func CollectBias(providers []SignalProvider, weekStart time.Time) (CategoryBias, []string) {
+    bias := make(CategoryBias)
+    var notes []string
+    for _, provider := range providers {
+        b, providerNotes, err := provider.Bias(weekStart)
+        if err != nil {
+            warnf("ignoring %s: %v", provider.Name(), err)
+            continue
+        }
+        for _, note := range providerNotes {
+            notes = append(notes, fmt.Sprintf("%s: %s", provider.Name(), note))
+        }
+        bias.Add(b)
+    }
+    return bias, notes
+}
+
+// ArrangeCategories reorders categories across days to maximise the total bias.
+// Categories should already be shuffled; ties keep the earliest arrangement found,
+// so an empty bias leaves the shuffle untouched.
+func ArrangeCategories(days, categories []string, bias CategoryBias) []string {
+    if len(bias) == 0 {
+        return categories
+    }
+
+    best := append([]string(nil), categories...)
+    bestScore := arrangementScore(days, best, bias)
+
+    current := append([]string(nil), categories...)
+    var permute func(k int)
+    permute = func(k int) {
+        if k == len(current) {
+            if score := arrangementScore(days, current, bias); score > bestScore {
+                bestScore = score
+                copy(best, current)
+            }
+            return
+        }
+        for i := k; i < len(current); i++ {
+            current[k], current[i] = current[i], current[k]
+            permute(k + 1)
+            current[k], current[i] = current[i], current[k]
+        }
+    }
+    permute(0)
+
+    return best
+}
+
+// arrangementScore sums the bias for each day/category pair
+func arrangementScore(days, categories []string, bias CategoryBias) float64 {
+    score := 0.0
+    for i, day := range days {
+        if i < len(categories) {
+            score += bias[day][categories[i]]
+        }
+    }
+    return score
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+)
+
+// withoutStaples drops the always-stocked items from a list
+func withoutStaples(items, staples []string) []string {
+    var kept []string
+    for _, item := range items {
+        if !isStaple(item, staples) {
+            kept = append(kept, item)
+        }
+    }
+    return kept
+}
+
+// runStaplesCommand handles "staples [list]", "staples add <item>..." and
+// "staples remove <item>...", managing what's assumed always stocked
+func runStaplesCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker staples [list | add <item>... | remove <item>...]")
+    action := "list"
+    if len(args) > 0 {
+        action, args = args[0], args[1:]
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    switch action {
+    case "list":
+        if len(args) > 0 {
+            return usage
+        }
+        if len(config.Staples) == 0 {
+            fmt.Println("No staples yet (add some with: dinner-picker staples add salt pepper \"olive oil\")")
+            return nil
+        }
+        staples := append([]string(nil), config.Staples...)
+        sort.Strings(staples)
+        for _, staple := range staples {
+            fmt.Println(staple)
+        }
+        return nil
+
+    case "add":
+        if len(args) == 0 {
+            return usage
+        }
+        for _, item := range args {
+            item = normalizeIngredient(item)
+            if item == "" {
+                continue
+            }
+            if stapleIndex(config.Staples, item) >= 0 {
+                fmt.Printf("%s is already a staple\n", item)
+                continue
+            }
+            config.Staples = append(config.Staples, item)
+            fmt.Printf("Added %s\n", item)
+        }
+
+    case "remove":
+        if len(args) == 0 {
+            return usage
+        }
+        for _, item := range args {
+            i := stapleIndex(config.Staples, normalizeIngredient(item))
+            if i < 0 {
+                fmt.Printf("%s isn't a staple\n", item)
+                continue
+            }
+            fmt.Printf("Removed %s\n", config.Staples[i])
+            config.Staples = append(config.Staples[:i], config.Staples[i+1:]...)
+        }
+
+    default:
+        return usage
+    }
+    return config.SaveConfig()
+}
+
+// stapleIndex finds an item on the staples list, ignoring case and plurals
+func stapleIndex(staples []string, item string) int {
+    for i, staple := range staples {
+        if sameIngredient(staple, item) {
+            return i
+        }
+    }
+    return -1
+}
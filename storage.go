@@ -0,0 +1,312 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// DatabaseFileName is the SQLite database's default name in the data directory
+const DatabaseFileName = "dinner-picker.db"
+
+// StorageConfig says where the catalog and the week's state are kept
+type StorageConfig struct {
+    // Backend is "json" (the default) for dinners.json and dinner_state.json,
+    // or "sqlite" for a database
+    Backend string `json:"backend,omitempty"`
+
+    // Path is the database file, relative to the data directory (default
+    // dinner-picker.db)
+    Path string `json:"path,omitempty"`
+}
+
+// validate checks the backend is one there is
+func (c *StorageConfig) validate() error {
+    switch c.Backend {
+    case "", "json", "sqlite":
+        return nil
+    }
+    return fmt.Errorf("storage: unknown backend %q (want json or sqlite)", c.Backend)
+}
+
+// databasePath returns the database file's full path
+func (c *StorageConfig) databasePath() string {
+    path := DatabaseFileName
+    if c != nil && c.Path != "" {
+        path = c.Path
+    }
+    if filepath.IsAbs(path) {
+        return path
+    }
+    return dataPath(path)
+}
+
+// Storage keeps the catalog and the week's state. Config and the pantry stay
+// in their files whichever is used, as does the journal, which is what
+// recovery and "audit" read.
+type Storage interface {
+    LoadDinners() (*DinnerData, error)
+    SaveDinners(data *DinnerData) error
+
+    // HasState reports whether a state was ever saved
+    HasState() bool
+
+    // ReadState returns the saved state as it is, or nil if there's none
+    // yet; LoadState adds recovery from the journal and migrations
+    ReadState() (*WeekState, error)
+    WriteState(state *WeekState) error
+
+    // CatalogVersion changes whenever the catalog may have, for ETags
+    CatalogVersion(data *DinnerData) (string, error)
+
+    // Files lists the files the catalog and state are written to, so a
+    // change can be noticed from their size and time without reading them
+    Files() []string
+}
+
+// damagedStateError is a state that is there but can't be read back; the
+// journal may still have a good copy
+type damagedStateError struct {
+    err error
+}
+
+func (e *damagedStateError) Error() string {
+    return e.err.Error()
+}
+
+func (e *damagedStateError) Unwrap() error {
+    return e.err
+}
+
+// opened is the storage for openedDirs, kept so the database is opened once
+var (
+    opened     Storage
+    openedDirs Dirs
+)
+
+// store returns the data directory's storage, as its config chooses
+func store() (Storage, error) {
+    if opened != nil && openedDirs == dataDirs {
+        return opened, nil
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return nil, err
+    }
+    s, err := openStorage(config.Storage)
+    if err != nil {
+        return nil, err
+    }
+    opened, openedDirs = s, dataDirs
+    return s, nil
+}
+
+// openStorage opens the backend the config names
+func openStorage(c *StorageConfig) (Storage, error) {
+    if c == nil || c.Backend == "" || c.Backend == "json" {
+        return jsonStorage{}, nil
+    }
+    return openSQLite(c.databasePath())
+}
+
+// loadCatalog loads the catalog from wherever it's kept
+func loadCatalog() (*DinnerData, error) {
+    s, err := store()
+    if err != nil {
+        return nil, err
+    }
+    data, err := s.LoadDinners()
+    if err != nil {
+        return nil, err
+    }
+    data.useIngredientTags()
+    return data, nil
+}
+
+// saveCatalog saves the catalog wherever it's kept
+func saveCatalog(data *DinnerData) error {
+    s, err := store()
+    if err != nil {
+        return err
+    }
+    return s.SaveDinners(data)
+}
+
+// hasState reports whether a state has been saved
+func hasState() bool {
+    s, err := store()
+    return err == nil && s.HasState()
+}
+
+// jsonStorage is the catalog in dinners.json and the state in dinner_state.json
+type jsonStorage struct{}
+
+func (jsonStorage) LoadDinners() (*DinnerData, error) {
+    return LoadDinners(dataPath(DinnersFileName))
+}
+
+func (jsonStorage) SaveDinners(data *DinnerData) error {
+    return SaveDinners(dataPath(DinnersFileName), data)
+}
+
+func (jsonStorage) HasState() bool {
+    _, err := os.Stat(dataPath(StateFileName))
+    return err == nil
+}
+
+func (jsonStorage) ReadState() (*WeekState, error) {
+    file, err := os.ReadFile(dataPath(StateFileName))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading state file: %w", err)
+    }
+    var state *WeekState
+    if err := json.Unmarshal(file, &state); err != nil {
+        return nil, &damagedStateError{fmt.Errorf("error parsing state JSON: %w", err)}
+    }
+    return state, nil
+}
+
+func (jsonStorage) WriteState(state *WeekState) error {
+    data, err := json.MarshalIndent(state, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error marshaling state: %w", err)
+    }
+
+    err = writeFileAtomic(dataPath(StateFileName), data)
+    if err != nil {
+        return fmt.Errorf("error writing state file: %w", err)
+    }
+
+    return nil
+}
+
+// CatalogVersion comes from the files themselves, since the catalog only
+// changes when one of them does
+func (jsonStorage) CatalogVersion(data *DinnerData) (string, error) {
+    return filesVersion(data.Files(dataPath(DinnersFileName)))
+}
+
+func (jsonStorage) Files() []string {
+    return []string{dataPath(DinnersFileName), dataPath(StateFileName)}
+}
+
+// filesVersion describes files by name, modification time and size
+func filesVersion(files []string) (string, error) {
+    var version strings.Builder
+    for _, file := range files {
+        info, err := os.Stat(file)
+        if err != nil {
+            return "", err
+        }
+        fmt.Fprintf(&version, "%s %d %d\n", file, info.ModTime().UnixNano(), info.Size())
+    }
+    return version.String(), nil
+}
+
+// runMigrateCommand handles "migrate [--to sqlite|json] [--db file]", copying
+// the catalog and state from the storage in use to the other one and
+// switching the config over
+func runMigrateCommand(args []string) error {
+    fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+    to := fs.String("to", "sqlite", "storage to move to: sqlite or json")
+    db := fs.String("db", "", "database file, relative to the data directory (default "+DatabaseFileName+")")
+    force := fs.Bool("force", false, "replace what the target already holds")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    target := &StorageConfig{Backend: *to, Path: *db}
+    if err := target.validate(); err != nil {
+        return err
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    current := config.Storage
+    if current == nil {
+        current = &StorageConfig{}
+    }
+    if target.Backend == "json" {
+        target = &StorageConfig{}
+    } else if target.Path == "" {
+        target.Path = current.Path
+    }
+    using := current.Backend
+    if using == "" {
+        using = "json"
+    }
+    if using == *to && (*to == "json" || current.databasePath() == target.databasePath()) {
+        return fmt.Errorf("already using %s storage", *to)
+    }
+
+    from, err := store()
+    if err != nil {
+        return err
+    }
+    into, err := openStorage(target)
+    if err != nil {
+        return err
+    }
+    dinners, err := from.LoadDinners()
+    if err != nil {
+        return err
+    }
+    if existing, err := into.LoadDinners(); err == nil && len(existing.Dinners) > 0 && !*force {
+        return fmt.Errorf("the %s storage already has a catalog, add --force to replace it", *to)
+    }
+    var state *WeekState
+    if from.HasState() {
+        if state, err = LoadState(); err != nil {
+            return err
+        }
+    }
+
+    unlock, err := lockData()
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    if err := into.SaveDinners(dinners); err != nil {
+        return err
+    }
+    if state != nil {
+        if err := into.WriteState(state); err != nil {
+            return err
+        }
+    }
+
+    if *to == "json" {
+        config.Storage = nil
+    } else {
+        config.Storage = target
+    }
+    if err := config.SaveConfig(); err != nil {
+        return err
+    }
+    opened = nil
+
+    count := 0
+    for _, list := range dinners.own().Dinners {
+        count += len(list)
+    }
+    moved := fmt.Sprintf("%d dinners", count)
+    if state != nil {
+        moved += fmt.Sprintf(" and the state, with %d weeks of history,", len(state.History))
+    }
+    where := dataPath(DinnersFileName) + " and " + dataPath(StateFileName)
+    if *to == "sqlite" {
+        where = target.databasePath()
+    }
+    fmt.Printf("Moved %s to %s, and switched the config over\n", moved, where)
+    if *to == "sqlite" {
+        fmt.Printf("%s and %s are left as they were; delete them once you're happy\n", DinnersFileName, StateFileName)
+    }
+    return nil
+}
@@ -0,0 +1,11 @@
+//go:build !sqlite
+
+package main
+
+import "fmt"
+
+// openSQLite is unavailable: the SQLite driver needs cgo, so it's only built
+// in with -tags sqlite
+func openSQLite(path string) (Storage, error) {
+    return nil, fmt.Errorf("can't open %s: this build has no SQLite support (build with -tags sqlite)", path)
+}
@@ -0,0 +1,429 @@
+//go:build sqlite
+
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    _ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema keeps dinners with their ingredients one per row, and plans
+// and history one row per day, so the database can be queried directly.
+// Fields without a column of their own are kept as JSON in data.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS dinners (
+    name              TEXT PRIMARY KEY COLLATE NOCASE,
+    category          TEXT NOT NULL,
+    category_position INTEGER NOT NULL,
+    position          INTEGER NOT NULL,
+    data              TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS ingredients (
+    dinner   TEXT NOT NULL REFERENCES dinners(name) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    item     TEXT NOT NULL,
+    data     TEXT NOT NULL,
+    PRIMARY KEY (dinner, position)
+);
+CREATE INDEX IF NOT EXISTS ingredients_item ON ingredients(item);
+CREATE TABLE IF NOT EXISTS plans (
+    week_start TEXT PRIMARY KEY,
+    revision   INTEGER NOT NULL,
+    data       TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS plan_days (
+    week_start TEXT NOT NULL REFERENCES plans(week_start) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    day        TEXT NOT NULL,
+    date       TEXT NOT NULL,
+    dinner     TEXT NOT NULL,
+    category   TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    PRIMARY KEY (week_start, position)
+);
+CREATE TABLE IF NOT EXISTS history_weeks (
+    week_start TEXT PRIMARY KEY,
+    note       TEXT NOT NULL,
+    pattern    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS history_days (
+    week_start TEXT NOT NULL REFERENCES history_weeks(week_start) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    day        TEXT NOT NULL,
+    date       TEXT NOT NULL,
+    dinner     TEXT NOT NULL,
+    category   TEXT NOT NULL,
+    outcome    TEXT NOT NULL,
+    rating     INTEGER NOT NULL,
+    data       TEXT NOT NULL,
+    PRIMARY KEY (week_start, position)
+);
+`
+
+// sqliteStorage keeps the catalog and state in a SQLite database. Each save
+// replaces what was there in one transaction, so readers never see half of it.
+type sqliteStorage struct {
+    db   *sql.DB
+    path string
+}
+
+// openSQLite opens the database, creating it and its tables if need be
+func openSQLite(path string) (Storage, error) {
+    db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=10000&_journal_mode=WAL")
+    if err != nil {
+        return nil, fmt.Errorf("error opening %s: %w", path, err)
+    }
+    if _, err := db.Exec(sqliteSchema); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("error setting up %s: %w", path, err)
+    }
+    return &sqliteStorage{db: db, path: path}, nil
+}
+
+// catalogMeta is the catalog apart from its dinners
+type catalogMeta struct {
+    Renamed    map[string]string        `json:"renamed_categories,omitempty"`
+    Categories map[string]CategoryStyle `json:"categories,omitempty"`
+    Include    []string                 `json:"include,omitempty"`
+
+    IngredientTags map[string][]string `json:"ingredient_tags,omitempty"`
+}
+
+// getMeta reads a meta value as JSON, reporting whether it was there
+func getMeta(q interface {
+    QueryRow(string, ...interface{}) *sql.Row
+}, key string, v interface{}) (bool, error) {
+    var value string
+    err := q.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+    if err == sql.ErrNoRows {
+        return false, nil
+    }
+    if err != nil {
+        return false, fmt.Errorf("error reading %s: %w", key, err)
+    }
+    if err := json.Unmarshal([]byte(value), v); err != nil {
+        return true, fmt.Errorf("error parsing %s: %w", key, err)
+    }
+    return true, nil
+}
+
+// putMeta writes a meta value as JSON
+func putMeta(tx *sql.Tx, key string, v interface{}) error {
+    value, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Errorf("error marshaling %s: %w", key, err)
+    }
+    if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, string(value)); err != nil {
+        return fmt.Errorf("error writing %s: %w", key, err)
+    }
+    return nil
+}
+
+func (s *sqliteStorage) LoadDinners() (*DinnerData, error) {
+    data := &DinnerData{Dinners: make(map[string][]Dinner)}
+    var meta catalogMeta
+    found, err := getMeta(s.db, "catalog", &meta)
+    if err != nil {
+        return nil, err
+    }
+    if !found {
+        return nil, fmt.Errorf("%w in %s: run \"migrate\" to bring in dinners.json, or add some with \"dinner add\"", errNoDinners, s.path)
+    }
+    data.Renamed, data.Categories, data.Include = meta.Renamed, meta.Categories, meta.Include
+    data.IngredientTags = meta.IngredientTags
+
+    ingredients := make(map[string][]Ingredient)
+    rows, err := s.db.Query(`SELECT dinner, data FROM ingredients ORDER BY dinner, position`)
+    if err != nil {
+        return nil, fmt.Errorf("error reading ingredients: %w", err)
+    }
+    for rows.Next() {
+        var name, raw string
+        var ingredient Ingredient
+        if err := rows.Scan(&name, &raw); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("error reading ingredients: %w", err)
+        }
+        if err := json.Unmarshal([]byte(raw), &ingredient); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("error parsing an ingredient of %s: %w", name, err)
+        }
+        ingredients[name] = append(ingredients[name], ingredient)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error reading ingredients: %w", err)
+    }
+
+    rows, err = s.db.Query(`SELECT name, category, data FROM dinners ORDER BY category_position, position`)
+    if err != nil {
+        return nil, fmt.Errorf("error reading dinners: %w", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var name, category, raw string
+        if err := rows.Scan(&name, &category, &raw); err != nil {
+            return nil, fmt.Errorf("error reading dinners: %w", err)
+        }
+        var dinner Dinner
+        if err := json.Unmarshal([]byte(raw), &dinner); err != nil {
+            return nil, fmt.Errorf("error parsing %s: %w", name, err)
+        }
+        dinner.Ingredients = ingredients[name]
+        if _, ok := data.Dinners[category]; !ok {
+            data.order = append(data.order, category)
+        }
+        data.Dinners[category] = append(data.Dinners[category], dinner)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error reading dinners: %w", err)
+    }
+
+    // Included files are still files, found next to where dinners.json would be
+    if err := data.mergeIncludes(dataPath(DinnersFileName)); err != nil {
+        return nil, err
+    }
+    return data, nil
+}
+
+func (s *sqliteStorage) SaveDinners(data *DinnerData) error {
+    own := data.own()
+    tx, err := s.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error saving dinners: %w", err)
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec(`DELETE FROM dinners`); err != nil {
+        return fmt.Errorf("error saving dinners: %w", err)
+    }
+    for c, category := range own.categoryOrder() {
+        for i, dinner := range own.Dinners[category] {
+            ingredients := dinner.Ingredients
+            dinner.Ingredients = nil
+            raw, err := json.Marshal(dinner)
+            if err != nil {
+                return fmt.Errorf("error marshaling %s: %w", dinner.Name, err)
+            }
+            if _, err := tx.Exec(`INSERT INTO dinners (name, category, category_position, position, data) VALUES (?, ?, ?, ?, ?)`,
+                dinner.Name, category, c, i, string(raw)); err != nil {
+                return fmt.Errorf("error saving %s: %w", dinner.Name, err)
+            }
+            for j, ingredient := range ingredients {
+                raw, err := json.Marshal(ingredient)
+                if err != nil {
+                    return fmt.Errorf("error marshaling an ingredient of %s: %w", dinner.Name, err)
+                }
+                if _, err := tx.Exec(`INSERT INTO ingredients (dinner, position, item, data) VALUES (?, ?, ?, ?)`,
+                    dinner.Name, j, ingredient.Key(), string(raw)); err != nil {
+                    return fmt.Errorf("error saving the ingredients of %s: %w", dinner.Name, err)
+                }
+            }
+        }
+    }
+    if err := putMeta(tx, "catalog", catalogMeta{Renamed: own.Renamed, Categories: own.Categories, Include: own.Include, IngredientTags: own.IngredientTags}); err != nil {
+        return err
+    }
+    if err := putMeta(tx, "catalog_saved", time.Now().UnixNano()); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error saving dinners: %w", err)
+    }
+    return nil
+}
+
+func (s *sqliteStorage) HasState() bool {
+    var n int
+    return s.db.QueryRow(`SELECT COUNT(*) FROM meta WHERE key = 'state'`).Scan(&n) == nil && n > 0
+}
+
+func (s *sqliteStorage) ReadState() (*WeekState, error) {
+    var state WeekState
+    found, err := getMeta(s.db, "state", &state)
+    if err != nil {
+        return nil, &damagedStateError{err}
+    }
+    if !found {
+        return nil, nil
+    }
+
+    var planWeek *string
+    if _, err := getMeta(s.db, "plan_week", &planWeek); err != nil {
+        return nil, &damagedStateError{err}
+    }
+    if planWeek != nil {
+        var plan Plan
+        var raw string
+        err := s.db.QueryRow(`SELECT data FROM plans WHERE week_start = ?`, *planWeek).Scan(&raw)
+        if err != nil {
+            return nil, &damagedStateError{fmt.Errorf("error reading the plan: %w", err)}
+        }
+        if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+            return nil, &damagedStateError{fmt.Errorf("error parsing the plan: %w", err)}
+        }
+        rows, err := s.db.Query(`SELECT data FROM plan_days WHERE week_start = ? ORDER BY position`, *planWeek)
+        if err != nil {
+            return nil, fmt.Errorf("error reading the plan: %w", err)
+        }
+        for rows.Next() {
+            var day PlanDay
+            if err := rows.Scan(&raw); err != nil {
+                rows.Close()
+                return nil, fmt.Errorf("error reading the plan: %w", err)
+            }
+            if err := json.Unmarshal([]byte(raw), &day); err != nil {
+                rows.Close()
+                return nil, &damagedStateError{fmt.Errorf("error parsing the plan: %w", err)}
+            }
+            plan.Days = append(plan.Days, day)
+        }
+        rows.Close()
+        state.Plan = &plan
+    }
+
+    weeks := make(map[string]*HistoryWeek)
+    var order []string
+    rows, err := s.db.Query(`SELECT week_start, note, pattern FROM history_weeks ORDER BY week_start`)
+    if err != nil {
+        return nil, fmt.Errorf("error reading history: %w", err)
+    }
+    for rows.Next() {
+        var start, note, pattern string
+        if err := rows.Scan(&start, &note, &pattern); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("error reading history: %w", err)
+        }
+        weekStart, err := time.Parse(time.RFC3339, start)
+        if err != nil {
+            rows.Close()
+            return nil, &damagedStateError{fmt.Errorf("error parsing history week %s: %w", start, err)}
+        }
+        weeks[start] = &HistoryWeek{WeekStart: weekStart, Note: note, Pattern: pattern, Days: []HistoryDay{}}
+        order = append(order, start)
+    }
+    rows.Close()
+    rows, err = s.db.Query(`SELECT week_start, data FROM history_days ORDER BY week_start, position`)
+    if err != nil {
+        return nil, fmt.Errorf("error reading history: %w", err)
+    }
+    for rows.Next() {
+        var start, raw string
+        var day HistoryDay
+        if err := rows.Scan(&start, &raw); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("error reading history: %w", err)
+        }
+        if err := json.Unmarshal([]byte(raw), &day); err != nil {
+            rows.Close()
+            return nil, &damagedStateError{fmt.Errorf("error parsing history: %w", err)}
+        }
+        weeks[start].Days = append(weeks[start].Days, day)
+    }
+    rows.Close()
+    for _, start := range order {
+        state.History = append(state.History, *weeks[start])
+    }
+    return &state, nil
+}
+
+func (s *sqliteStorage) WriteState(state *WeekState) error {
+    // The state row holds everything but the plan and history, which get
+    // tables of their own
+    rest := *state
+    rest.Plan, rest.History = nil, nil
+
+    tx, err := s.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error saving state: %w", err)
+    }
+    defer tx.Rollback()
+    if err := putMeta(tx, "state", rest); err != nil {
+        return err
+    }
+
+    var planWeek *string
+    if plan := state.Plan; plan != nil {
+        start := plan.WeekStart.Format(time.RFC3339)
+        planWeek = &start
+        days := plan.Days
+        plan := *plan
+        plan.Days = nil
+        raw, err := json.Marshal(plan)
+        if err != nil {
+            return fmt.Errorf("error marshaling the plan: %w", err)
+        }
+        if _, err := tx.Exec(`DELETE FROM plans WHERE week_start = ?`, start); err != nil {
+            return fmt.Errorf("error saving the plan: %w", err)
+        }
+        if _, err := tx.Exec(`INSERT INTO plans (week_start, revision, data) VALUES (?, ?, ?)`, start, plan.Revision, string(raw)); err != nil {
+            return fmt.Errorf("error saving the plan: %w", err)
+        }
+        for i, day := range days {
+            raw, err := json.Marshal(day)
+            if err != nil {
+                return fmt.Errorf("error marshaling %s: %w", day.Day, err)
+            }
+            if _, err := tx.Exec(`INSERT INTO plan_days (week_start, position, day, date, dinner, category, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+                start, i, day.Day, day.Date.Format("2006-01-02"), day.Dinner.Name, day.Dinner.Category, string(raw)); err != nil {
+                return fmt.Errorf("error saving the plan: %w", err)
+            }
+        }
+    }
+    if err := putMeta(tx, "plan_week", planWeek); err != nil {
+        return err
+    }
+
+    // History is rewritten whole, as compaction and imports change old weeks
+    if _, err := tx.Exec(`DELETE FROM history_weeks`); err != nil {
+        return fmt.Errorf("error saving history: %w", err)
+    }
+    for _, week := range state.History {
+        start := week.WeekStart.Format(time.RFC3339)
+        if _, err := tx.Exec(`INSERT OR REPLACE INTO history_weeks (week_start, note, pattern) VALUES (?, ?, ?)`, start, week.Note, week.Pattern); err != nil {
+            return fmt.Errorf("error saving history: %w", err)
+        }
+        for i, day := range week.Days {
+            raw, err := json.Marshal(day)
+            if err != nil {
+                return fmt.Errorf("error marshaling history: %w", err)
+            }
+            if _, err := tx.Exec(`INSERT INTO history_days (week_start, position, day, date, dinner, category, outcome, rating, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+                start, i, day.Day, day.Date.Format("2006-01-02"), day.Dinner, day.Category, day.Outcome, day.Rating, string(raw)); err != nil {
+                return fmt.Errorf("error saving history: %w", err)
+            }
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error saving state: %w", err)
+    }
+    return nil
+}
+
+// CatalogVersion changes with each save, which is stamped in the database,
+// and with the included files
+func (s *sqliteStorage) CatalogVersion(data *DinnerData) (string, error) {
+    var saved int64
+    if _, err := getMeta(s.db, "catalog_saved", &saved); err != nil {
+        return "", err
+    }
+    files, err := filesVersion(data.Files(s.path)[1:])
+    if err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("%s %d\n%s", s.path, saved, files), nil
+}
+
+// Files includes the write-ahead log, where saves land until they're
+// checkpointed into the database
+func (s *sqliteStorage) Files() []string {
+    return []string{s.path, s.path + "-wal"}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// CategoryStyle is how a category looks wherever dinners are shown: an icon
+// (usually an emoji) put before dinner names, and a colour for the terminal
+// and calendars
+type CategoryStyle struct {
+    Icon  string `json:"icon,omitempty"`
+    Color string `json:"color,omitempty"`
+}
+
+// categoryColors maps the colour names a category can use to 256-colour
+// terminal codes; the names are also CSS colours, as calendars expect
+var categoryColors = map[string]int{
+    "red":     160,
+    "orange":  208,
+    "yellow":  178,
+    "green":   34,
+    "cyan":    37,
+    "blue":    33,
+    "purple":  93,
+    "magenta": 163,
+    "brown":   130,
+    "gray":    245,
+}
+
+// colorNames lists the known colour names for error messages
+func colorNames() string {
+    var names []string
+    for name := range categoryColors {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return strings.Join(names, ", ")
+}
+
+// CategoryStyles looks up the style of each category by name
+type CategoryStyles map[string]CategoryStyle
+
+// Styles returns the category styles, with renamed categories' old names
+// styled like their new ones so older plans still match
+func (d *DinnerData) Styles() CategoryStyles {
+    if d == nil || len(d.Categories) == 0 {
+        return nil
+    }
+    styles := make(CategoryStyles)
+    for name, style := range d.Categories {
+        styles[name] = style
+    }
+    for from, to := range d.Renamed {
+        if style, ok := d.Categories[to]; ok {
+            styles[from] = style
+        }
+    }
+    return styles
+}
+
+// loadStyles reads the category styles from the catalog; without one, or
+// when it can't be read, everything is shown plain
+func loadStyles() CategoryStyles {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil
+    }
+    return dinners.Styles()
+}
+
+// Style returns a category's style, matching its name case-insensitively, or an empty one
+func (s CategoryStyles) Style(category string) CategoryStyle {
+    if style, ok := s[category]; ok {
+        return style
+    }
+    for name, style := range s {
+        if strings.EqualFold(name, category) {
+            return style
+        }
+    }
+    return CategoryStyle{}
+}
+
+// Label puts the category's icon before text, e.g. "🍝 Pasta Carbonara"
+func (s CategoryStyles) Label(category, text string) string {
+    if icon := s.Style(category).Icon; icon != "" {
+        return icon + " " + text
+    }
+    return text
+}
+
+// Paint colours text in the category's colour when writing to a terminal
+func (s CategoryStyles) Paint(category, text string) string {
+    code, ok := categoryColors[s.Style(category).Color]
+    if !ok || !useColor() {
+        return text
+    }
+    return fmt.Sprintf("\x1b[38;5;%dm%s\x1b[0m", code, text)
+}
+
+// validate checks the colour is one the terminal and calendars both know
+func (c CategoryStyle) validate(category string) error {
+    if _, ok := categoryColors[c.Color]; c.Color != "" && !ok {
+        return fmt.Errorf("category %s has unknown color %q (want one of %s)", category, c.Color, colorNames())
+    }
+    return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// dinnerTokens returns the words of a dinner's name and ingredients
+func dinnerTokens(dinner Dinner) map[string]bool {
+    tokens := make(map[string]bool)
+    for _, token := range tokenize(dinner.Name) {
+        tokens[token] = true
+    }
+    for _, ingredient := range dinner.Ingredients {
+        for _, token := range tokenize(ingredient.Name) {
+            tokens[token] = true
+        }
+    }
+    return tokens
+}
+
+// similarity is the Jaccard overlap of two token sets, 0 to 1
+func similarity(a, b map[string]bool) float64 {
+    shared := 0
+    for token := range a {
+        if b[token] {
+            shared++
+        }
+    }
+    if total := len(a) + len(b) - shared; total > 0 {
+        return float64(shared) / float64(total)
+    }
+    return 0
+}
+
+// SuggestCategory returns the existing category holding the dinner most like
+// this one by name and ingredients, or "" if nothing is alike at all
+func (d *DinnerData) SuggestCategory(dinner Dinner) string {
+    tokens := dinnerTokens(dinner)
+    var categories []string
+    for category := range d.Dinners {
+        categories = append(categories, category)
+    }
+    sort.Strings(categories)
+
+    best, bestScore := "", 0.0
+    for _, category := range categories {
+        for _, other := range d.Dinners[category] {
+            if score := similarity(tokens, dinnerTokens(other)); score > bestScore {
+                best, bestScore = category, score
+            }
+        }
+    }
+    return best
+}
+
+// hasCategory reports whether a category exists, matching case-insensitively,
+// and returns its spelling in the catalog
+func (d *DinnerData) hasCategory(name string) (string, bool) {
+    for category := range d.Dinners {
+        if strings.EqualFold(category, name) {
+            return category, true
+        }
+    }
+    return "", false
+}
+
+// looksLike formats a suggestion for messages, e.g. " (looks like pasta)"
+func looksLike(suggestion string) string {
+    if suggestion == "" {
+        return ""
+    }
+    return " (looks like " + suggestion + ")"
+}
+
+// stdinIsTerminal reports whether someone is at the keyboard to answer
+// questions. The null device is a character device too, but nobody's there.
+func stdinIsTerminal() bool {
+    info, err := os.Stdin.Stat()
+    if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+        return false
+    }
+    null, err := os.Stat(os.DevNull)
+    return err != nil || !os.SameFile(info, null)
+}
+
+// askCategory offers the suggested category for a dinner and returns the
+// accepted or typed one; "" skips the dinner
+func askCategory(in *bufio.Reader, data *DinnerData, dinner Dinner, suggestion string) (string, error) {
+    question := fmt.Sprintf("Category for %s", dinner.Name)
+    if dinner.Category != "" {
+        question += fmt.Sprintf(" (recipe says %q)", dinner.Category)
+    }
+    if suggestion != "" {
+        question += fmt.Sprintf(" [enter for %s, - to skip]: ", suggestion)
+    } else {
+        question += " [- to skip]: "
+    }
+
+    for {
+        answer, err := prompt(in, question)
+        if err != nil {
+            return "", err
+        }
+        switch {
+        case answer == "-":
+            return "", nil
+        case answer == "" && suggestion != "":
+            return suggestion, nil
+        case answer == "":
+            continue
+        }
+        if category, ok := data.hasCategory(answer); ok {
+            return category, nil
+        }
+        confirm, err := prompt(in, fmt.Sprintf("  %s is a new category, create it? [y/N]: ", answer))
+        if err != nil {
+            return "", err
+        }
+        if strings.EqualFold(confirm, "y") {
+            return answer, nil
+        }
+    }
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// Swapping a day twice leaves only its latest dinner among the week's
+// selections, so the dinners swapped out can be picked again
+func TestSwapSameDayTwice(t *testing.T) {
+    dinners := &DinnerData{Dinners: map[string][]Dinner{
+        "soup": {
+            {Name: "Tomato soup", Category: "soup"},
+            {Name: "Dumplings", Category: "soup"},
+            {Name: "Chicken Noodle Soup", Category: "soup"},
+            {Name: "Minestrone", Category: "soup"},
+        },
+    }}
+    weekStart := time.Date(2026, 10, 11, 0, 0, 0, 0, time.Local)
+    first := dinners.Dinners["soup"][0]
+    state := &WeekState{WeekStart: weekStart, CurrentWeek: []Dinner{first}, Plan: &Plan{WeekStart: weekStart, Days: []PlanDay{
+        {Day: "Monday", Date: weekStart.AddDate(0, 0, 1), Dinner: first},
+    }}}
+
+    var swappedOut []string
+    for i := 0; i < 2; i++ {
+        result, err := swapDay(dinners, state, &Config{}, "Monday", false, 0, 0)
+        if err != nil {
+            t.Fatal(err)
+        }
+        swappedOut = append(swappedOut, result.Previous)
+    }
+
+    planned, _ := state.Plan.Dinner("Monday")
+    if len(state.CurrentWeek) != 1 || state.CurrentWeek[0].Name != planned.Name {
+        var names []string
+        for _, dinner := range state.CurrentWeek {
+            names = append(names, dinner.Name)
+        }
+        t.Fatalf("selections are %v after swapping out %v, want only %s", names, swappedOut, planned.Name)
+    }
+}
+
+// A swap with nothing to swap in says which rule left nothing
+func TestSwapSaysWhyNothingFits(t *testing.T) {
+    weekStart := time.Date(2026, 10, 11, 0, 0, 0, 0, time.Local)
+    tomato := Dinner{Name: "Tomato soup", Category: "soup", CookTime: 20}
+    dumplings := Dinner{Name: "Dumplings", Category: "soup", CookTime: 45}
+    minestrone := Dinner{Name: "Minestrone", Category: "soup", CookTime: 60}
+
+    tests := []struct {
+        name       string
+        soups      []Dinner
+        selected   []Dinner
+        bans       []string
+        maxMinutes int
+        want       string
+    }{
+        {"only dinner", []Dinner{tomato}, nil, nil, 0, "Tomato soup is the only soup dinner"},
+        {"all planned", []Dinner{tomato, dumplings}, []Dinner{dumplings}, nil, 0, "every other soup dinner is planned this week already"},
+        {"all banned", []Dinner{tomato, dumplings, minestrone}, nil, []string{"dumplings", "Minestrone"}, 0, "every other soup dinner left is banned"},
+        {"too slow", []Dinner{tomato, dumplings, minestrone}, nil, nil, 30, "no other soup dinner left cooks in 30m or less"},
+        {"planned or too slow", []Dinner{tomato, dumplings, minestrone}, []Dinner{dumplings}, nil, 50, "no other soup dinner left cooks in 50m or less"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            dinners := &DinnerData{Dinners: map[string][]Dinner{"soup": test.soups}}
+            state := &WeekState{WeekStart: weekStart, CurrentWeek: append([]Dinner{tomato}, test.selected...), Bans: test.bans, Plan: &Plan{WeekStart: weekStart, Days: []PlanDay{
+                {Day: "Monday", Date: weekStart.AddDate(0, 0, 1), Dinner: tomato},
+            }}}
+            _, err := swapDay(dinners, state, &Config{}, "Monday", false, 0, test.maxMinutes)
+            if err == nil || !strings.Contains(err.Error(), test.want) {
+                t.Fatalf("got %v, want %q", err, test.want)
+            }
+        })
+    }
+}
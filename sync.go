@@ -0,0 +1,321 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+)
+
+// serverReplica is the server's own name in version vectors, for changes
+// made on the command line or merged by the server
+const serverReplica = "server"
+
+// VersionVector counts the changes each replica (a phone, a browser, the
+// server) has made to an entity. One vector descends from another when it
+// has seen everything the other has; when neither does, the changes were
+// made concurrently.
+type VersionVector map[string]int
+
+// descends reports whether v has seen every change o has
+func (v VersionVector) descends(o VersionVector) bool {
+    for replica, count := range o {
+        if v[replica] < count {
+            return false
+        }
+    }
+    return true
+}
+
+// merged returns a vector that has seen every change of both
+func (v VersionVector) merged(o VersionVector) VersionVector {
+    result := make(VersionVector)
+    for replica, count := range v {
+        result[replica] = count
+    }
+    for replica, count := range o {
+        if count > result[replica] {
+            result[replica] = count
+        }
+    }
+    return result
+}
+
+// SyncEntity is the synced value of one thing clients can change offline,
+// with the vector of changes that led to it
+type SyncEntity struct {
+    Value bool          `json:"value"`
+    Stamp VersionVector `json:"stamp"`
+}
+
+// SyncState holds this week's synced entities, keyed "<week>/list/<item>"
+// for a shopping list item being checked off and "<week>/cooked/<Day>" for a
+// day's dinner being cooked, where the week is the date it starts. It starts
+// afresh each week.
+type SyncState struct {
+    Entities map[string]*SyncEntity `json:"entities"`
+}
+
+// entity returns the stored entity, creating an unset one
+func (s *WeekState) entity(key string) *SyncEntity {
+    if s.Sync == nil {
+        s.Sync = &SyncState{}
+    }
+    if s.Sync.Entities == nil {
+        s.Sync.Entities = make(map[string]*SyncEntity)
+    }
+    if _, ok := s.Sync.Entities[key]; !ok {
+        s.Sync.Entities[key] = &SyncEntity{Stamp: VersionVector{}}
+    }
+    return s.Sync.Entities[key]
+}
+
+// touch records a change made on the server itself, so clients see it as newer
+func (s *WeekState) touch(key string, value bool) {
+    entity := s.entity(key)
+    entity.Value = value
+    entity.Stamp = entity.Stamp.merged(VersionVector{serverReplica: entity.Stamp[serverReplica] + 1})
+}
+
+// SyncOp is one change a client queued while offline: the new value, and
+// the entity's stamp as the client last saw it with its own count bumped
+type SyncOp struct {
+    Entity string        `json:"entity"`
+    Value  bool          `json:"value"`
+    Stamp  VersionVector `json:"stamp"`
+}
+
+// SyncResult says what became of one op: "applied" when it was based on the
+// latest value, "merged" when it raced another change and the two were
+// combined, "stale" when the server already had it or something newer, and
+// "rejected" with a reason when it can't be done
+type SyncResult struct {
+    Entity string        `json:"entity"`
+    Status string        `json:"status"`
+    Value  bool          `json:"value"`
+    Stamp  VersionVector `json:"stamp,omitempty"`
+    Reason string        `json:"reason,omitempty"`
+}
+
+// syncWeek is how entity keys name the week starting at start
+func syncWeek(start time.Time) string {
+    return start.Format("2006-01-02")
+}
+
+// syncKey checks an entity key is for the week starting at week and returns
+// it in canonical form, with the day for cooked entities. Ops queued in an
+// earlier week are turned down rather than applied to this one.
+func syncKey(key string, week time.Time) (string, string, error) {
+    prefix, rest, _ := strings.Cut(key, "/")
+    start, err := time.ParseInLocation("2006-01-02", prefix, week.Location())
+    if err != nil {
+        return "", "", fmt.Errorf("unknown entity %q, want <week>/list/<item> or <week>/cooked/<day>", key)
+    }
+    if !start.Equal(week) {
+        return "", "", fmt.Errorf("%q is for the week of %s, and this is the week of %s", key, prefix, syncWeek(week))
+    }
+    kind, name, _ := strings.Cut(rest, "/")
+    switch kind {
+    case "list":
+        item := ParseIngredient(name).Key()
+        if item == "" {
+            return "", "", fmt.Errorf("list entity %q names no item", key)
+        }
+        return prefix + "/list/" + item, "", nil
+    case "cooked":
+        day, ok := normalizeDay(name)
+        if !ok {
+            return "", "", fmt.Errorf("unknown day in %q", key)
+        }
+        return prefix + "/cooked/" + day, day, nil
+    }
+    return "", "", fmt.Errorf("unknown entity %q, want <week>/list/<item> or <week>/cooked/<day>", key)
+}
+
+// applySync reconciles one op with the state. Both kinds of entity only
+// move one way in a race: a checked item stays checked and a cooked dinner
+// stays cooked, so concurrent changes merge to true. A dinner cooked comes
+// out of pantry.
+func (s *WeekState) applySync(op SyncOp, pantry *Pantry) SyncResult {
+    key, day, err := syncKey(op.Entity, s.WeekStart)
+    if err != nil {
+        return SyncResult{Entity: op.Entity, Status: "rejected", Reason: err.Error()}
+    }
+    current := s.entity(key)
+    result := SyncResult{Entity: key}
+    value := op.Value
+    switch {
+    case current.Stamp.descends(op.Stamp):
+        result.Status = "stale"
+        value = current.Value
+    case op.Stamp.descends(current.Stamp):
+        result.Status = "applied"
+    default:
+        result.Status = "merged"
+        value = op.Value || current.Value
+    }
+
+    if day != "" && value != current.Value {
+        if !value {
+            return SyncResult{Entity: key, Status: "rejected", Value: current.Value, Stamp: current.Stamp, Reason: "a cooked dinner can't be un-cooked, use review"}
+        }
+        if _, _, err := s.markCooked(day, pantry); err != nil {
+            return SyncResult{Entity: key, Status: "rejected", Value: current.Value, Stamp: current.Stamp, Reason: err.Error()}
+        }
+    }
+
+    if result.Status != "stale" {
+        current.Value = value
+        current.Stamp = current.Stamp.merged(op.Stamp)
+        if result.Status == "merged" {
+            // The merge is a change of its own, newer than both sides
+            current.Stamp[serverReplica]++
+        }
+    }
+    result.Value, result.Stamp = current.Value, current.Stamp
+    return result
+}
+
+// markCooked marks a planned day cooked and takes its dinner out of the
+// pantry, returning the day's entry and what was used. The caller records
+// the change, then saves the pantry, so the two can't drift apart when
+// recording fails.
+func (s *WeekState) markCooked(day string, pantry *Pantry) (*PlanDay, []string, error) {
+    var entry *PlanDay
+    if s.Plan != nil {
+        for i := range s.Plan.Days {
+            if s.Plan.Days[i].Day == day {
+                entry = &s.Plan.Days[i]
+            }
+        }
+    }
+    if entry == nil {
+        return nil, nil, fmt.Errorf("nothing planned for %s this week", day)
+    }
+    if entry.Outcome == OutcomeCooked {
+        return entry, nil, nil
+    }
+
+    // Leftovers were used up with the batch
+    var used []string
+    if !entry.IsLeftovers() {
+        used = pantry.Consume(entry.Dinner)
+    }
+    entry.Outcome = OutcomeCooked
+    s.Plan.Revision++
+    key := syncWeek(s.WeekStart) + "/cooked/" + day
+    if current := s.entity(key); !current.Value {
+        s.touch(key, true)
+    }
+    return entry, used, nil
+}
+
+// syncResponse is what both sync endpoints answer with: the week entity
+// keys start with, every entity this week, and the results of any ops sent
+type syncResponse struct {
+    Week          string                 `json:"week"`
+    Results       []SyncResult           `json:"results,omitempty"`
+    Entities      map[string]*SyncEntity `json:"entities"`
+    StateRevision int                    `json:"state_revision"`
+}
+
+// newSyncResponse lists the state's entities
+func newSyncResponse(state *WeekState) syncResponse {
+    response := syncResponse{Week: syncWeek(state.WeekStart), Entities: map[string]*SyncEntity{}, StateRevision: state.JournalSeq}
+    if state.Sync != nil && state.Sync.Entities != nil {
+        response.Entities = state.Sync.Entities
+    }
+    return response
+}
+
+// handleSyncPull serves GET /sync, every synced entity this week with its stamp
+func handleSyncPull(w http.ResponseWriter, r *http.Request) {
+    state, err := LoadState()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    state.CheckNewWeek()
+    writeJSON(w, newSyncResponse(state))
+}
+
+// handleSyncPush serves POST /sync {"ops": [{"entity": "2026-10-11/list/onion",
+// "value": true, "stamp": {"phone": 3}}]}, reconciling a client's queued
+// ops in order. Unlike the other changes it needs no revision: ops carry
+// their own stamps, and only real conflicts are merged or rejected. If
+// another change is saved while the ops are applied, they're applied again
+// to the state as it is then, a few times before answering 409.
+func handleSyncPush(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Ops []SyncOp `json:"ops"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    for _, op := range req.Ops {
+        if len(op.Stamp) == 0 {
+            http.Error(w, fmt.Sprintf("op on %q has no stamp", op.Entity), http.StatusBadRequest)
+            return
+        }
+    }
+
+    for attempt := 1; ; attempt++ {
+        state, err := LoadState()
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        state.CheckNewWeek()
+        pantry, err := LoadPantry()
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        var results []SyncResult
+        changed := make(map[string]bool)
+        for _, op := range req.Ops {
+            result := state.applySync(op, pantry)
+            results = append(results, result)
+            if result.Status == "applied" || result.Status == "merged" {
+                changed[result.Entity] = true
+            }
+        }
+        if len(changed) > 0 {
+            var keys []string
+            cooked := false
+            for key := range changed {
+                keys = append(keys, key)
+                cooked = cooked || strings.Contains(key, "/cooked/")
+            }
+            sort.Strings(keys)
+            err := state.RecordIfCurrent("sync", strings.Join(keys, ", ")+" (via API)", state.JournalSeq)
+            var conflict *ConflictError
+            if errors.As(err, &conflict) && attempt < syncAttempts {
+                continue
+            }
+            if err != nil {
+                writeRecordError(w, err)
+                return
+            }
+            if cooked {
+                if err := pantry.SavePantry(); err != nil {
+                    http.Error(w, err.Error(), http.StatusInternalServerError)
+                    return
+                }
+            }
+        }
+        response := newSyncResponse(state)
+        response.Results = results
+        writeJSON(w, response)
+        return
+    }
+}
+
+// syncAttempts is how many times a push is applied when other changes keep
+// being saved under it
+const syncAttempts = 3
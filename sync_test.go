@@ -0,0 +1,155 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestVersionVectors(t *testing.T) {
+    tests := []struct {
+        name     string
+        v, o     VersionVector
+        descends bool
+        merged   VersionVector
+    }{
+        {"equal", VersionVector{"phone": 2}, VersionVector{"phone": 2}, true, VersionVector{"phone": 2}},
+        {"newer", VersionVector{"phone": 3}, VersionVector{"phone": 2}, true, VersionVector{"phone": 3}},
+        {"older", VersionVector{"phone": 1}, VersionVector{"phone": 2}, false, VersionVector{"phone": 2}},
+        {"from nothing", VersionVector{"phone": 1}, VersionVector{}, true, VersionVector{"phone": 1}},
+        {"concurrent", VersionVector{"phone": 2}, VersionVector{"laptop": 1}, false, VersionVector{"phone": 2, "laptop": 1}},
+        {"each ahead", VersionVector{"phone": 2, "server": 1}, VersionVector{"phone": 1, "server": 2}, false, VersionVector{"phone": 2, "server": 2}},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if got := test.v.descends(test.o); got != test.descends {
+                t.Errorf("descends = %v, want %v", got, test.descends)
+            }
+            if got := test.v.merged(test.o); !reflect.DeepEqual(got, test.merged) {
+                t.Errorf("merged = %v, want %v", got, test.merged)
+            }
+        })
+    }
+}
+
+func TestApplySync(t *testing.T) {
+    week := GetCurrentWeekStart()
+    this := syncWeek(week)
+    last := syncWeek(week.AddDate(0, 0, -7))
+
+    tests := []struct {
+        name     string
+        existing map[string]*SyncEntity
+        op       SyncOp
+        status   string
+        value    bool
+        cooked   bool
+    }{
+        {"first tick", nil, SyncOp{this + "/list/onion", true, VersionVector{"phone": 1}}, "applied", true, false},
+        {"untick after it", map[string]*SyncEntity{this + "/list/onion": {true, VersionVector{"phone": 1}}},
+            SyncOp{this + "/list/onion", false, VersionVector{"phone": 2}}, "applied", false, false},
+        {"already seen", map[string]*SyncEntity{this + "/list/onion": {true, VersionVector{"phone": 2}}},
+            SyncOp{this + "/list/onion", false, VersionVector{"phone": 1}}, "stale", true, false},
+        {"raced a tick", map[string]*SyncEntity{this + "/list/onion": {true, VersionVector{"laptop": 1}}},
+            SyncOp{this + "/list/onion", false, VersionVector{"phone": 1}}, "merged", true, false},
+        {"item in canonical form", nil, SyncOp{this + "/list/Onions", true, VersionVector{"phone": 1}}, "applied", true, false},
+        {"cooked", nil, SyncOp{this + "/cooked/monday", true, VersionVector{"phone": 1}}, "applied", true, true},
+        {"un-cooked", map[string]*SyncEntity{this + "/cooked/Monday": {true, VersionVector{"server": 1}}},
+            SyncOp{this + "/cooked/Monday", false, VersionVector{"server": 1, "phone": 1}}, "rejected", true, false},
+        {"day not planned", nil, SyncOp{this + "/cooked/friday", true, VersionVector{"phone": 1}}, "rejected", false, false},
+        {"queued last week", nil, SyncOp{last + "/cooked/monday", true, VersionVector{"phone": 1}}, "rejected", false, false},
+        {"no week", nil, SyncOp{"list/onion", true, VersionVector{"phone": 1}}, "rejected", false, false},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            state := &WeekState{WeekStart: week, Sync: &SyncState{Entities: test.existing}, Plan: &Plan{WeekStart: week, Days: []PlanDay{
+                {Day: "Monday", Date: week.AddDate(0, 0, 1), Dinner: Dinner{Name: "Tacos", Category: "mexican"}},
+            }}}
+            result := state.applySync(test.op, &Pantry{})
+            if result.Status != test.status || result.Value != test.value {
+                t.Fatalf("got %s %v (%s), want %s %v", result.Status, result.Value, result.Reason, test.status, test.value)
+            }
+            if cooked := state.Plan.Days[0].Outcome == OutcomeCooked; cooked != test.cooked {
+                t.Errorf("Monday cooked = %v, want %v", cooked, test.cooked)
+            }
+            if test.status == "merged" && result.Stamp[serverReplica] != 1 {
+                t.Errorf("merged stamp %v doesn't count the merge as a server change", result.Stamp)
+            }
+        })
+    }
+}
+
+// conflictingStorage hands out states that don't match the journal for its
+// first conflicts reads, as if another change was saved while each was used
+type conflictingStorage struct {
+    Storage
+    conflicts int
+}
+
+func (s *conflictingStorage) ReadState() (*WeekState, error) {
+    state, err := s.Storage.ReadState()
+    if err == nil && state != nil && s.conflicts > 0 {
+        s.conflicts--
+        state.JournalSeq += 1000
+    }
+    return state, err
+}
+
+// A push is applied again when the state changes under it, and turned down
+// with 409 once that has happened syncAttempts times
+func TestSyncPushRetries(t *testing.T) {
+    tests := []struct {
+        conflicts int
+        code      int
+    }{
+        {0, http.StatusOK},
+        {syncAttempts - 1, http.StatusOK},
+        {syncAttempts, http.StatusConflict},
+    }
+    for _, test := range tests {
+        useRepoData(t)
+        saved, err := store()
+        if err != nil {
+            t.Fatal(err)
+        }
+        state, err := LoadState()
+        if err != nil {
+            t.Fatal(err)
+        }
+        state.CheckNewWeek()
+        if err := state.Record("test", "new week"); err != nil {
+            t.Fatal(err)
+        }
+        opened = &conflictingStorage{Storage: saved, conflicts: test.conflicts}
+
+        key := syncWeek(state.WeekStart) + "/list/onion"
+        body := `{"ops": [{"entity": "` + key + `", "value": true, "stamp": {"phone": 1}}]}`
+        recorder := httptest.NewRecorder()
+        handleSyncPush(recorder, httptest.NewRequest("POST", "/sync", strings.NewReader(body)))
+        if recorder.Code != test.code {
+            t.Fatalf("%d conflicts: %d %s, want %d", test.conflicts, recorder.Code, recorder.Body, test.code)
+        }
+
+        opened = saved
+        after, err := LoadState()
+        if err != nil {
+            t.Fatal(err)
+        }
+        ticked := after.Sync != nil && after.Sync.Entities[key] != nil && after.Sync.Entities[key].Value
+        if ticked != (test.code == http.StatusOK) {
+            t.Errorf("%d conflicts: onion ticked = %v after %d", test.conflicts, ticked, test.code)
+        }
+        if test.code == http.StatusOK {
+            var response syncResponse
+            if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+                t.Fatal(err)
+            }
+            if response.Week != syncWeek(after.WeekStart) || len(response.Results) != 1 || response.Results[0].Status != "applied" {
+                t.Errorf("%d conflicts: answered %s", test.conflicts, recorder.Body)
+            }
+        }
+    }
+}
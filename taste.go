@@ -0,0 +1,224 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Preferences holds the household's adjustments to the learned taste profile
+type Preferences struct {
+    // Pinned affinities override what was learned, keyed like "tag:spicy" or "ingredient:tofu"
+    Pinned map[string]float64 `json:"pinned,omitempty"`
+    // Vetoed dinners count as strongly disliked
+    Vetoed []string `json:"vetoed,omitempty"`
+    // Ratings are the stars given to dinners with "rate"
+    Ratings map[string]int `json:"ratings,omitempty"`
+    // LearnSince ignores history from before the last reset
+    LearnSince time.Time `json:"learn_since,omitempty"`
+}
+
+// TasteProfile is the affinity, roughly -2 to 2, of each ingredient and tag
+type TasteProfile map[string]float64
+
+// tasteFeatures returns the profile keys a dinner contributes to
+func tasteFeatures(dinner Dinner) []string {
+    var features []string
+    for _, tag := range dinner.Tags {
+        features = append(features, "tag:"+strings.ToLower(strings.TrimSpace(tag)))
+    }
+    for _, ingredient := range dinner.Ingredients {
+        if item := ingredient.Key(); item != "" {
+            features = append(features, "ingredient:"+item)
+        }
+    }
+    return uniqueStrings(features)
+}
+
+// LearnTaste builds a profile from history: ratings count from -2 (1 star) to
+// +2 (5 stars), skips count -1 and vetoes -2. Each feature's affinity is its
+// average signal, shrunk towards zero while there's little evidence.
+func LearnTaste(dinners *DinnerData, history []HistoryWeek, prefs *Preferences) TasteProfile {
+    sums := make(map[string]float64)
+    counts := make(map[string]int)
+    add := func(name string, signal float64) {
+        dinner, ok := dinners.FindDinner(name)
+        if !ok {
+            return
+        }
+        for _, feature := range tasteFeatures(dinner) {
+            sums[feature] += signal
+            counts[feature]++
+        }
+    }
+
+    var since time.Time
+    if prefs != nil {
+        since = prefs.LearnSince
+    }
+    for _, week := range history {
+        for _, day := range week.Days {
+            if day.Date.Before(since) {
+                continue
+            }
+            switch {
+            case day.Rating > 0:
+                add(day.Dinner, float64(day.Rating-3))
+            case day.Outcome == OutcomeSkipped:
+                add(day.Dinner, -1)
+            }
+        }
+    }
+
+    profile := make(TasteProfile)
+    if prefs != nil {
+        for _, name := range prefs.Vetoed {
+            add(name, -2)
+        }
+    }
+    for feature, sum := range sums {
+        profile[feature] = sum / float64(counts[feature]+1)
+    }
+    if prefs != nil {
+        for feature, score := range prefs.Pinned {
+            profile[feature] = score
+        }
+    }
+    return profile
+}
+
+// Score is a dinner's average affinity over its features
+func (t TasteProfile) Score(dinner Dinner) float64 {
+    features := tasteFeatures(dinner)
+    if len(features) == 0 {
+        return 0
+    }
+    total := 0.0
+    for _, feature := range features {
+        total += t[feature]
+    }
+    return total / float64(len(features))
+}
+
+// Weight turns a dinner's score into a selection weight, 1 for no opinion
+func (t TasteProfile) Weight(dinner Dinner) float64 {
+    return math.Exp(t.Score(dinner))
+}
+
+// TasteWeights returns selection weights from the household's learned taste
+// and the stars dinners were given, scaled by the ratings curve; vetoed
+// dinners are all but ruled out
+func (s *WeekState) TasteWeights(dinners *DinnerData, ratings *RatingsConfig) func(Dinner) float64 {
+    profile := LearnTaste(dinners, s.History, s.Preferences)
+    return func(dinner Dinner) float64 {
+        if s.Preferences.isVetoed(dinner.Name) {
+            return 0.01
+        }
+        return profile.Weight(dinner) * ratings.weight(s.Preferences.rating(dinner.Name))
+    }
+}
+
+// isVetoed reports whether a dinner has been vetoed
+func (p *Preferences) isVetoed(name string) bool {
+    if p == nil {
+        return false
+    }
+    for _, vetoed := range p.Vetoed {
+        if strings.EqualFold(vetoed, name) {
+            return true
+        }
+    }
+    return false
+}
+
+// runPreferencesCommand handles "preferences show|reset|pin <feature> <score>|unpin <feature>|veto <dinner>"
+func runPreferencesCommand(args []string) error {
+    usage := fmt.Errorf("usage: dinner-picker preferences show|reset|pin <tag:x|ingredient:x> <score>|unpin <feature>|veto <dinner>")
+    if len(args) == 0 {
+        return usage
+    }
+
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    if state.Preferences == nil {
+        state.Preferences = &Preferences{}
+    }
+    prefs := state.Preferences
+
+    switch args[0] {
+    case "show":
+        profile := LearnTaste(dinners, state.History, prefs)
+        var features []string
+        for feature, score := range profile {
+            if score != 0 {
+                features = append(features, feature)
+            }
+        }
+        if len(features) == 0 {
+            fmt.Println("Nothing learned yet - rate dinners with \"review\"")
+            return nil
+        }
+        sort.Slice(features, func(i, j int) bool {
+            if profile[features[i]] != profile[features[j]] {
+                return profile[features[i]] > profile[features[j]]
+            }
+            return features[i] < features[j]
+        })
+        for _, feature := range features {
+            line := fmt.Sprintf("%+.2f  %s", profile[feature], feature)
+            if _, ok := prefs.Pinned[feature]; ok {
+                line += " (pinned)"
+            }
+            fmt.Println(line)
+        }
+        for _, name := range prefs.Vetoed {
+            fmt.Printf("vetoed: %s\n", name)
+        }
+        return nil
+    case "reset":
+        *prefs = Preferences{LearnSince: time.Now()}
+        fmt.Println("Forgot everything learned so far, including pins, vetoes and ratings")
+    case "pin":
+        if len(args) != 3 || !strings.Contains(args[1], ":") {
+            return usage
+        }
+        score, err := strconv.ParseFloat(args[2], 64)
+        if err != nil || score < -2 || score > 2 {
+            return fmt.Errorf("score must be a number from -2 to 2")
+        }
+        if prefs.Pinned == nil {
+            prefs.Pinned = make(map[string]float64)
+        }
+        feature := strings.ToLower(args[1])
+        prefs.Pinned[feature] = score
+        fmt.Printf("Pinned %s at %+.2f\n", feature, score)
+    case "unpin":
+        if len(args) != 2 {
+            return usage
+        }
+        delete(prefs.Pinned, strings.ToLower(args[1]))
+        fmt.Printf("Unpinned %s\n", strings.ToLower(args[1]))
+    case "veto":
+        name := strings.TrimSpace(strings.Join(args[1:], " "))
+        dinner, ok := dinners.FindDinner(name)
+        if !ok {
+            return fmt.Errorf("no dinner named %q", name)
+        }
+        if !prefs.isVetoed(dinner.Name) {
+            prefs.Vetoed = append(prefs.Vetoed, dinner.Name)
+        }
+        fmt.Printf("Vetoed %s\n", dinner.Name)
+    default:
+        return usage
+    }
+    return state.Record("preferences", strings.Join(args, " "))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// PlanTemplate is a theme week, like an Italian week of pasta and bread-y
+// dinners: the days it plans, the categories each draws from, and tags every
+// dinner in it must or mustn't have
+type PlanTemplate struct {
+    Name        string              `json:"name"`
+    Description string              `json:"description,omitempty"`
+    Days        map[string][]string `json:"days"`
+    RequireTags []string            `json:"require_tags,omitempty"`
+    ExcludeTags []string            `json:"exclude_tags,omitempty"`
+}
+
+// validateTemplates checks every template has a unique name and days like a
+// schedule's
+func validateTemplates(templates []PlanTemplate) error {
+    seen := make(map[string]bool)
+    for _, t := range templates {
+        if strings.TrimSpace(t.Name) == "" || seen[strings.ToLower(t.Name)] {
+            return fmt.Errorf("templates: every template needs a unique name")
+        }
+        seen[strings.ToLower(t.Name)] = true
+        if err := t.schedule(nil).validate(); err != nil {
+            return fmt.Errorf("template %q: %v", t.Name, strings.TrimPrefix(err.Error(), "schedule: "))
+        }
+    }
+    return nil
+}
+
+// findTemplate returns the template with a name, ignoring case
+func (c *Config) findTemplate(name string) (PlanTemplate, error) {
+    for _, t := range c.Templates {
+        if strings.EqualFold(t.Name, name) {
+            return t, nil
+        }
+    }
+    if len(c.Templates) == 0 {
+        return PlanTemplate{}, fmt.Errorf("no templates in %s", ConfigFileName)
+    }
+    return PlanTemplate{}, fmt.Errorf("no template named %q (see: dinner-picker template list)", name)
+}
+
+// schedule returns the template as a schedule in place of base: its days,
+// with days planned outside them drawing from the template's categories too.
+// Shuffling follows base; frequency limits don't carry over, as they're set
+// for base's days.
+func (t PlanTemplate) schedule(base *ScheduleConfig) *ScheduleConfig {
+    s := &ScheduleConfig{Days: t.Days}
+    s.OtherDays = s.categories()
+    if base != nil {
+        s.Shuffle = base.Shuffle
+    }
+    return s
+}
+
+// rule returns the template's tags as a rule for every day, or nil if it
+// has none
+func (t PlanTemplate) rule() *Observance {
+    parts := append([]string(nil), t.RequireTags...)
+    for _, tag := range t.ExcludeTags {
+        parts = append(parts, "no "+tag)
+    }
+    if len(parts) == 0 {
+        return nil
+    }
+    return &Observance{Name: strings.Join(parts, ", "), RequireTags: t.RequireTags, ExcludeTags: t.ExcludeTags, adHoc: true}
+}
+
+// summary describes the template's days and tags on one line, e.g.
+// "Sunday soup; Monday-Thursday pasta or bread-y; only vegetarian"
+func (t PlanTemplate) summary() string {
+    s := t.schedule(nil)
+    var parts []string
+    days := s.planDays()
+    for i := 0; i < len(days); {
+        categories := strings.Join(s.categoriesOn(&DinnerData{}, days[i]), " or ")
+        j := i + 1
+        for j < len(days) && dayIndex(days[j]) == dayIndex(days[j-1])+1 && strings.Join(s.categoriesOn(&DinnerData{}, days[j]), " or ") == categories {
+            j++
+        }
+        span := days[i]
+        if j-i > 1 {
+            span += "-" + days[j-1]
+        }
+        parts = append(parts, span+" "+categories)
+        i = j
+    }
+    if len(t.RequireTags) > 0 {
+        parts = append(parts, "only "+strings.Join(t.RequireTags, ", "))
+    }
+    if len(t.ExcludeTags) > 0 {
+        parts = append(parts, "no "+strings.Join(t.ExcludeTags, ", "))
+    }
+    return strings.Join(parts, "; ")
+}
+
+// runTemplateCommand handles "template list", showing the theme weeks "plan
+// --template" can use
+func runTemplateCommand(args []string) error {
+    if len(args) > 0 && args[0] != "list" && args[0] != "ls" {
+        return fmt.Errorf("usage: dinner-picker template list")
+    }
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    if len(config.Templates) == 0 {
+        fmt.Printf("No templates yet; add them under \"templates\" in %s\n", ConfigFileName)
+        return nil
+    }
+    templates := append([]PlanTemplate(nil), config.Templates...)
+    sort.SliceStable(templates, func(i, j int) bool {
+        return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+    })
+    for _, t := range templates {
+        fmt.Printf("%s\n", t.Name)
+        if t.Description != "" {
+            fmt.Printf("  %s\n", t.Description)
+        }
+        fmt.Printf("  %s\n", t.summary())
+    }
+    return nil
+}
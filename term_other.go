@@ -0,0 +1,39 @@
+//go:build !windows
+
+package main
+
+import (
+    "os"
+    "os/exec"
+    "strings"
+)
+
+// enableEscapes reports whether the terminal understands ANSI escapes, which
+// every terminal outside Windows does
+func enableEscapes(f *os.File) bool {
+    return true
+}
+
+// rawInput switches the terminal to reading single key presses without
+// echo (Ctrl-C included, so the caller can restore it), returning a function
+// that puts it back. It reports false when stty isn't there to do it.
+func rawInput(f *os.File) (func(), bool) {
+    saved, err := stty(f, "-g")
+    if err != nil {
+        return nil, false
+    }
+    if _, err := stty(f, "-icanon", "-echo", "-isig", "min", "1"); err != nil {
+        return nil, false
+    }
+    return func() {
+        stty(f, strings.TrimSpace(saved))
+    }, true
+}
+
+// stty runs stty on the terminal f
+func stty(f *os.File, args ...string) (string, error) {
+    cmd := exec.Command("stty", args...)
+    cmd.Stdin = f
+    out, err := cmd.Output()
+    return string(out), err
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package main
+
+import (
+    "os"
+    "syscall"
+    "unsafe"
+)
+
+const enableVirtualTerminalProcessing = 0x0004
+
+var (
+    kernel32           = syscall.NewLazyDLL("kernel32.dll")
+    procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+    procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+)
+
+// enableEscapes turns on ANSI escape handling in the Windows console, which
+// older consoles (and cmd.exe by default) leave off. It reports whether the
+// console will understand them.
+func enableEscapes(f *os.File) bool {
+    handle := syscall.Handle(f.Fd())
+    var mode uint32
+    if ok, _, _ := procGetConsoleMode.Call(uintptr(handle), uintptr(unsafe.Pointer(&mode))); ok == 0 {
+        return false
+    }
+    if mode&enableVirtualTerminalProcessing != 0 {
+        return true
+    }
+    ok, _, _ := procSetConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing))
+    return ok != 0
+}
+
+// rawInput would switch the console to single key presses; Windows consoles
+// get line input instead, a key and enter at a time
+func rawInput(f *os.File) (func(), bool) {
+    return nil, false
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// driftWeeks is how many weeks a category or protein can go uneaten before
+// it's pointed out as drifting out of rotation
+const driftWeeks = 4
+
+// takeoutWords mark a dinner's tag, or a substitute's description, as a takeaway night
+var takeoutWords = []string{"takeout", "takeaway", "take-out", "delivery"}
+
+// isTakeout reports whether a dinner, or a substitute described as text, was a takeaway
+func isTakeout(dinner Dinner, found bool, text string) bool {
+    for _, word := range takeoutWords {
+        if found && dinner.HasTag(word) {
+            return true
+        }
+        if !found && strings.Contains(strings.ToLower(text), word) {
+            return true
+        }
+    }
+    return false
+}
+
+// weekFacts is what a past week's eaten dinners added up to
+type weekFacts struct {
+    start      time.Time
+    takeout    int
+    veggies    float64
+    counted    bool
+    categories map[string]bool
+    proteins   map[string]bool
+}
+
+// factsOf tallies a past week the way goals do: skipped days count nothing,
+// substitutes count by their own dinner or, failing that, their description
+func factsOf(week HistoryWeek, dinners *DinnerData) weekFacts {
+    facts := weekFacts{start: week.WeekStart, categories: make(map[string]bool), proteins: make(map[string]bool)}
+    var unknown int
+    facts.veggies, unknown = eatenVeggies(week, dinners)
+    facts.counted = unknown == 0
+    for _, day := range week.Days {
+        name := day.Dinner
+        switch day.Outcome {
+        case OutcomeSkipped:
+            continue
+        case OutcomeSubstituted:
+            name = day.Substitute
+        }
+        dinner, ok := dinners.FindDinner(name)
+        if isTakeout(dinner, ok, name) {
+            facts.takeout++
+        }
+        switch {
+        case ok:
+            facts.categories[strings.ToLower(dinner.Category)] = true
+            if protein := dinner.MainProtein(); protein != "" && protein != "none" {
+                facts.proteins[protein] = true
+            }
+        case day.Outcome != OutcomeSubstituted:
+            // A dinner since removed from the catalog still has its category
+            facts.categories[strings.ToLower(day.Category)] = true
+        }
+    }
+    return facts
+}
+
+// halves averages a weekly figure over the older and newer half of the weeks
+func halves(facts []weekFacts, value func(weekFacts) float64) (float64, float64) {
+    mid := len(facts) / 2
+    average := func(part []weekFacts) float64 {
+        sum := 0.0
+        for _, f := range part {
+            sum += value(f)
+        }
+        return sum / float64(len(part))
+    }
+    return average(facts[:mid]), average(facts[mid:])
+}
+
+// weeksWithout returns how many weeks before current have gone by since
+// something was last eaten, or -1 if it wasn't eaten in any of the weeks
+func weeksWithout(facts []weekFacts, current time.Time, eaten func(weekFacts) bool) int {
+    for i := len(facts) - 1; i >= 0; i-- {
+        if eaten(facts[i]) {
+            return int(current.Sub(facts[i].start).Hours()/24/7+0.5) - 1
+        }
+    }
+    return -1
+}
+
+// Nudges looks for trends in the recent weeks (oldest first) and says what it
+// sees in plain language: takeaway rising, vegetables falling, and categories
+// or proteins that haven't been eaten in a while
+func Nudges(weeks []HistoryWeek, dinners *DinnerData, current time.Time) []string {
+    if len(weeks) < driftWeeks {
+        return nil
+    }
+    var facts []weekFacts
+    for _, week := range weeks {
+        facts = append(facts, factsOf(week, dinners))
+    }
+
+    var nudges []string
+    before, lately := halves(facts, func(f weekFacts) float64 { return float64(f.takeout) })
+    if lately-before >= 0.5 {
+        nudges = append(nudges, fmt.Sprintf("Takeaway is creeping up: %s nights a week lately, up from %s", formatServings(lately), formatServings(before)))
+    }
+    // Only weeks with every dinner estimated say anything about vegetables
+    var counted []weekFacts
+    for _, f := range facts {
+        if f.counted {
+            counted = append(counted, f)
+        }
+    }
+    if len(counted) >= driftWeeks {
+        before, lately = halves(counted, func(f weekFacts) float64 { return f.veggies })
+    } else {
+        before, lately = 0, 0
+    }
+    if before > 0 && lately < before*0.85 {
+        nudges = append(nudges, fmt.Sprintf("Fewer vegetables lately: %s servings per person a week, down from %s", formatServings(lately), formatServings(before)))
+    }
+
+    drifting := func(eaten func(weekFacts) bool) (string, bool) {
+        switch weeks := weeksWithout(facts, current, eaten); {
+        case weeks < 0:
+            return fmt.Sprintf("in the last %d weeks", len(facts)), true
+        case weeks >= driftWeeks:
+            return fmt.Sprintf("in %d weeks", weeks), true
+        }
+        return "", false
+    }
+    var categories []string
+    proteins := make(map[string]bool)
+    for category, list := range dinners.Dinners {
+        if len(list) > 0 {
+            categories = append(categories, category)
+        }
+        for _, dinner := range list {
+            if protein := dinner.MainProtein(); protein != "" && protein != "none" {
+                proteins[protein] = true
+            }
+        }
+    }
+    sort.Strings(categories)
+    for _, category := range categories {
+        if since, ok := drifting(func(f weekFacts) bool { return f.categories[strings.ToLower(category)] }); ok {
+            nudges = append(nudges, fmt.Sprintf("No %s %s", category, since))
+        }
+    }
+    for _, protein := range sortedKeys(proteins) {
+        if since, ok := drifting(func(f weekFacts) bool { return f.proteins[protein] }); ok {
+            nudges = append(nudges, fmt.Sprintf("You haven't cooked %s %s", protein, since))
+        }
+    }
+    return nudges
+}
+
+// sortedKeys returns a set's members in order
+func sortedKeys(set map[string]bool) []string {
+    var keys []string
+    for key := range set {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// nudgeWeeks is how far back the weekly routine looks for trends
+const nudgeWeeks = 13
+
+// weekNudges returns the nudges for the weekly routine, or none if the
+// catalog can't be read
+func weekNudges(state *WeekState) []string {
+    dinners, err := loadCatalog()
+    if err != nil {
+        return nil
+    }
+    return Nudges(recentWeeks(state, nudgeWeeks), dinners, state.WeekStart)
+}
+
+// printTrendReport prints takeaway nights and vegetables per week, oldest
+// first, followed by the nudges, for "stats trends"
+func printTrendReport(weeks []HistoryWeek, dinners *DinnerData, current time.Time) {
+    fmt.Println("  Week        Takeaway  Veggies")
+    partial := false
+    for _, week := range weeks {
+        facts := factsOf(week, dinners)
+        veggies := formatServings(facts.veggies)
+        switch {
+        case facts.counted:
+        case facts.veggies == 0:
+            veggies = "?"
+        default:
+            veggies += "+"
+        }
+        partial = partial || !facts.counted
+        fmt.Printf("  %s  %8d  %7s\n", week.WeekStart.Format("2006-01-02"), facts.takeout, veggies)
+    }
+    if partial {
+        fmt.Println("  (+ at least, some dinners had no veggie_servings; ? none had)")
+    }
+    nudges := Nudges(weeks, dinners, current)
+    switch {
+    case len(weeks) < driftWeeks:
+        fmt.Printf("Trends need at least %d weeks of history\n", driftWeeks)
+    case len(nudges) == 0:
+        fmt.Println("Nothing drifting, carry on")
+    default:
+        fmt.Println()
+        for _, nudge := range nudges {
+            fmt.Println("- " + nudge)
+        }
+    }
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// planEditor is "plan --interactive": the proposed week, a cursor on one of
+// its days, and the adjustments made so far. Nothing is saved until the plan
+// is confirmed.
+type planEditor struct {
+    dinners    *DinnerData
+    state      *WeekState
+    config     *Config
+    styles     CategoryStyles
+    repeatDays int
+
+    cursor int
+    pinned map[string]bool
+    marked string // the day picked as the first half of a swap
+    status string
+}
+
+// editorKeys is the help line shown under the week
+const editorKeys = "↑/↓ move  r re-roll  a re-roll unpinned  s swap two days  p pin  y save  q quit"
+
+// day returns the day under the cursor
+func (e *planEditor) day() string {
+    return e.state.Plan.Days[e.cursor].Day
+}
+
+// render redraws the whole screen
+func (e *planEditor) render() {
+    fmt.Print("\x1b[H\x1b[2J")
+    fmt.Printf("Week of %s\r\n\r\n", e.state.Plan.WeekStart.Format("January 2"))
+    for i, entry := range e.state.Plan.Days {
+        cursor := "  "
+        if i == e.cursor {
+            cursor = "> "
+        }
+        line := fmt.Sprintf("%s%-10s %s", cursor, entry.Day, e.styles.Paint(entry.Dinner.Category, e.styles.Label(entry.Dinner.Category, entry.Dinner.Name)))
+        switch {
+        case e.pinned[entry.Day]:
+            line += "  (pinned)"
+        case e.marked == entry.Day:
+            line += "  (swap with...)"
+        }
+        fmt.Print(line + "\r\n")
+    }
+    fmt.Print("\r\n" + editorKeys + "\r\n")
+    if e.status != "" {
+        fmt.Print(e.status + "\r\n")
+    }
+}
+
+// reroll swaps a day's dinner for another from its category
+func (e *planEditor) reroll(day string) error {
+    if e.pinned[day] {
+        return fmt.Errorf("%s is pinned", day)
+    }
+    swap, err := swapDay(e.dinners, e.state, e.config, day, false, e.repeatDays, 0)
+    if err != nil {
+        return err
+    }
+    e.status = fmt.Sprintf("%s: %s instead of %s", day, swap.Replacement.Name, swap.Previous)
+    return nil
+}
+
+// swapDays exchanges the dinners of two days, each day keeping its mode
+func (e *planEditor) swapDays(a, b string) {
+    var first, second *PlanDay
+    for i := range e.state.Plan.Days {
+        switch e.state.Plan.Days[i].Day {
+        case a:
+            first = &e.state.Plan.Days[i]
+        case b:
+            second = &e.state.Plan.Days[i]
+        }
+    }
+    first.Dinner, second.Dinner = second.Dinner, first.Dinner
+    e.state.Plan.Revision++
+    e.status = fmt.Sprintf("Swapped %s and %s", a, b)
+}
+
+// handle applies one key press, reporting whether editing is done and
+// whether to save
+func (e *planEditor) handle(key string) (done, save bool) {
+    e.status = ""
+    last := len(e.state.Plan.Days) - 1
+    switch key {
+    case "up", "k":
+        if e.cursor > 0 {
+            e.cursor--
+        }
+    case "down", "j":
+        if e.cursor < last {
+            e.cursor++
+        }
+    case "r":
+        if err := e.reroll(e.day()); err != nil {
+            e.status = err.Error()
+        }
+    case "a":
+        var failed []string
+        for _, entry := range e.state.Plan.Days {
+            if !e.pinned[entry.Day] {
+                if err := e.reroll(entry.Day); err != nil {
+                    failed = append(failed, entry.Day)
+                }
+            }
+        }
+        e.status = "Re-rolled every unpinned day"
+        if len(failed) > 0 {
+            e.status += ", nothing else for " + strings.Join(failed, ", ")
+        }
+    case "s":
+        switch day := e.day(); {
+        case e.marked == "":
+            e.marked = day
+            e.status = "Move to the other day and press s again"
+        case e.marked == day:
+            e.marked = ""
+        default:
+            e.swapDays(e.marked, day)
+            e.marked = ""
+        }
+    case "p":
+        e.pinned[e.day()] = !e.pinned[e.day()]
+    case "y":
+        return true, true
+    case "q", "ctrl-c":
+        return true, false
+    }
+    return false, false
+}
+
+// readKey reads one key press: arrows arrive as escape sequences in raw mode,
+// and in line mode a whole line stands for its first letter
+func readKey(in *bufio.Reader, raw bool) (string, error) {
+    if !raw {
+        line, err := in.ReadString('\n')
+        if err != nil {
+            return "", err
+        }
+        line = strings.ToLower(strings.TrimSpace(line))
+        if line == "" {
+            return "", nil
+        }
+        return line[:1], nil
+    }
+    b, err := in.ReadByte()
+    if err != nil {
+        return "", err
+    }
+    switch b {
+    case 3:
+        return "ctrl-c", nil
+    case 0x1b:
+        if in.Buffered() < 2 {
+            return "q", nil
+        }
+        seq := make([]byte, 2)
+        in.Read(seq)
+        switch seq[1] {
+        case 'A':
+            return "up", nil
+        case 'B':
+            return "down", nil
+        }
+        return "", nil
+    }
+    return strings.ToLower(string(b)), nil
+}
+
+// editPlan runs the editor on a drafted plan until it's saved or abandoned,
+// reporting whether to save it
+func editPlan(dinners *DinnerData, state *WeekState, config *Config, repeatDays int) (bool, error) {
+    editor := &planEditor{
+        dinners:    dinners,
+        state:      state,
+        config:     config,
+        styles:     dinners.Styles(),
+        repeatDays: repeatDays,
+        pinned:     make(map[string]bool),
+    }
+    restore, raw := rawInput(os.Stdin)
+    if raw {
+        defer restore()
+    }
+    in := bufio.NewReader(os.Stdin)
+    for {
+        editor.render()
+        if !raw {
+            fmt.Print("key and enter: ")
+        }
+        key, err := readKey(in, raw)
+        if err != nil {
+            return false, err
+        }
+        if done, save := editor.handle(key); done {
+            fmt.Print("\x1b[H\x1b[2J")
+            return save, nil
+        }
+    }
+}
+
+// runInteractivePlan drafts the week and opens it in the editor, saving
+// only once the plan is confirmed
+func runInteractivePlan(req PlanRequest, menu MenuOptions, formatter MenuFormatter) error {
+    if !stdinIsTerminal() || !isTerminal() {
+        return fmt.Errorf("--interactive needs a terminal")
+    }
+    req.Draft = true
+    state, config, err := planWeek(req)
+    if err != nil {
+        return err
+    }
+    if state.Plan.IsEmpty() {
+        return fmt.Errorf("nothing to plan this week")
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+
+    save, err := editPlan(dinners, state, config, config.RepeatDays(req.RepeatWeeks))
+    if err != nil {
+        return err
+    }
+    if !save {
+        fmt.Println("Left the week as it was, nothing saved")
+        return nil
+    }
+    if err := state.Record("plan", planSummary(state.Plan)+" (interactive)"); err != nil {
+        return err
+    }
+    return printPlan(formatter, state, config, menu)
+}
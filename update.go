@@ -0,0 +1,219 @@
+package main
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// version and releasePublicKey are set at build time, e.g.
+// go build -ldflags "-X main.version=1.4.0 -X main.releasePublicKey=<base64>"
+var (
+    version          = "dev"
+    releasePublicKey = ""
+)
+
+// UpdateConfig says where to look for releases
+type UpdateConfig struct {
+    URL       string `json:"url"`
+    PublicKey string `json:"public_key,omitempty"`
+}
+
+// ReleaseManifest describes the latest release and its per-platform binaries
+type ReleaseManifest struct {
+    Version string                  `json:"version"`
+    Assets  map[string]ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is one platform's binary with its checksum and the ed25519
+// signature of its releaseMessage
+type ReleaseAsset struct {
+    URL       string `json:"url"`
+    SHA256    string `json:"sha256"`
+    Signature string `json:"signature"`
+}
+
+// platformKey identifies this build's asset, e.g. "linux-arm64"
+func platformKey() string {
+    return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// compareVersions compares dotted versions like "1.10.2" and "v1.9", returning -1, 0 or 1
+func compareVersions(a, b string) int {
+    as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+    bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+    for i := 0; i < len(as) || i < len(bs); i++ {
+        var x, y int
+        if i < len(as) {
+            x, _ = strconv.Atoi(as[i])
+        }
+        if i < len(bs) {
+            y, _ = strconv.Atoi(bs[i])
+        }
+        if x != y {
+            if x < y {
+                return -1
+            }
+            return 1
+        }
+    }
+    return 0
+}
+
+// isNewer reports whether release should replace the running version; a dev
+// build takes any release
+func isNewer(release, current string) bool {
+    return current == "dev" || compareVersions(release, current) > 0
+}
+
+// fetch downloads a URL into memory
+func fetch(client *http.Client, url string) ([]byte, error) {
+    resp, err := client.Get(url)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+    }
+    return io.ReadAll(resp.Body)
+}
+
+// releaseMessage is what a release is signed as: its version and platform
+// with the binary's SHA-256, so a signed binary can't be passed off as
+// another release, say an older one, or as the build for another platform
+func releaseMessage(version, platform string, binary []byte) []byte {
+    sum := sha256.Sum256(binary)
+    return []byte(fmt.Sprintf("dinner-picker %s %s %x", version, platform, sum))
+}
+
+// verifyRelease checks the binary against the asset's checksum and that its
+// signature covers this version and platform
+func verifyRelease(binary []byte, version, platform string, asset ReleaseAsset, publicKey string) error {
+    key, err := base64.StdEncoding.DecodeString(publicKey)
+    if err != nil || len(key) != ed25519.PublicKeySize {
+        return fmt.Errorf("invalid release public key")
+    }
+
+    if asset.SHA256 != "" {
+        sum := sha256.Sum256(binary)
+        if !strings.EqualFold(hex.EncodeToString(sum[:]), asset.SHA256) {
+            return fmt.Errorf("checksum mismatch")
+        }
+    }
+
+    signature, err := base64.StdEncoding.DecodeString(asset.Signature)
+    if err != nil {
+        return fmt.Errorf("invalid signature encoding: %w", err)
+    }
+    if !ed25519.Verify(key, releaseMessage(version, platform, binary), signature) {
+        return fmt.Errorf("signature verification failed for %s on %s", version, platform)
+    }
+    return nil
+}
+
+// replaceExecutable swaps the running binary for a new one. The old binary is
+// moved aside first since Windows can't overwrite a running executable.
+func replaceExecutable(binary []byte) error {
+    exe, err := os.Executable()
+    if err != nil {
+        return fmt.Errorf("error locating executable: %w", err)
+    }
+    exe, err = filepath.EvalSymlinks(exe)
+    if err != nil {
+        return fmt.Errorf("error locating executable: %w", err)
+    }
+
+    tmp := exe + ".new"
+    if err := os.WriteFile(tmp, binary, 0755); err != nil {
+        return fmt.Errorf("error writing new binary: %w", err)
+    }
+
+    old := exe + ".old"
+    os.Remove(old)
+    if err := os.Rename(exe, old); err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("error moving old binary: %w", err)
+    }
+    if err := os.Rename(tmp, exe); err != nil {
+        os.Rename(old, exe)
+        return fmt.Errorf("error installing new binary: %w", err)
+    }
+    // Best effort; on Windows the old binary stays until the next update
+    os.Remove(old)
+    return nil
+}
+
+// runSelfUpdateCommand handles "self-update [--check] [--force]"
+func runSelfUpdateCommand(args []string) error {
+    fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+    check := fs.Bool("check", false, "only report whether an update is available")
+    force := fs.Bool("force", false, "install even if the release isn't newer")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+    if config.Update == nil || config.Update.URL == "" {
+        return fmt.Errorf("no update url configured")
+    }
+    publicKey := releasePublicKey
+    if publicKey == "" {
+        publicKey = config.Update.PublicKey
+    }
+    if publicKey == "" {
+        return fmt.Errorf("no release public key built in or configured")
+    }
+
+    client := &http.Client{Timeout: 2 * time.Minute}
+    data, err := fetch(client, config.Update.URL)
+    if err != nil {
+        return fmt.Errorf("error fetching release manifest: %w", err)
+    }
+    var manifest ReleaseManifest
+    if err := json.Unmarshal(data, &manifest); err != nil {
+        return fmt.Errorf("error parsing release manifest: %w", err)
+    }
+
+    if !isNewer(manifest.Version, version) && !*force {
+        fmt.Printf("Already up to date (%s)\n", version)
+        return nil
+    }
+    if *check {
+        fmt.Printf("Update available: %s -> %s\n", version, manifest.Version)
+        return nil
+    }
+
+    asset, ok := manifest.Assets[platformKey()]
+    if !ok {
+        return fmt.Errorf("release %s has no build for %s", manifest.Version, platformKey())
+    }
+    binary, err := fetch(client, asset.URL)
+    if err != nil {
+        return fmt.Errorf("error downloading release: %w", err)
+    }
+    if err := verifyRelease(binary, manifest.Version, platformKey(), asset, publicKey); err != nil {
+        return fmt.Errorf("refusing to install release %s: %w", manifest.Version, err)
+    }
+    if err := replaceExecutable(binary); err != nil {
+        return err
+    }
+
+    fmt.Printf("Updated %s -> %s\n", version, manifest.Version)
+    return nil
+}
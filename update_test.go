@@ -0,0 +1,56 @@
+package main
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "encoding/base64"
+    "testing"
+)
+
+func TestIsNewer(t *testing.T) {
+    tests := []struct {
+        release, current string
+        newer            bool
+    }{
+        {"1.2.0", "1.1.9", true},
+        {"1.10.0", "1.9.3", true},
+        {"v1.2", "1.2.0", false},
+        {"1.2.0", "1.2.1", false},
+        {"1.2.0", "dev", true},
+        {"0.0.1", "dev", true},
+    }
+    for _, test := range tests {
+        if got := isNewer(test.release, test.current); got != test.newer {
+            t.Errorf("isNewer(%q, %q) = %v, want %v", test.release, test.current, got, test.newer)
+        }
+    }
+}
+
+// A signature only verifies for the version, platform and binary it was made for
+func TestVerifyRelease(t *testing.T) {
+    public, private, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    key := base64.StdEncoding.EncodeToString(public)
+    binary := []byte("new binary")
+    asset := ReleaseAsset{Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(private, releaseMessage("1.2.0", "linux-arm64", binary)))}
+
+    tests := []struct {
+        name              string
+        binary            []byte
+        version, platform string
+        ok                bool
+    }{
+        {"as signed", binary, "1.2.0", "linux-arm64", true},
+        {"other version", binary, "1.3.0", "linux-arm64", false},
+        {"other platform", binary, "1.2.0", "windows-amd64", false},
+        {"other binary", []byte("old binary"), "1.2.0", "linux-arm64", false},
+    }
+    for _, test := range tests {
+        err := verifyRelease(test.binary, test.version, test.platform, asset, key)
+        if (err == nil) != test.ok {
+            t.Errorf("%s: verifyRelease = %v, want ok %v", test.name, err, test.ok)
+        }
+    }
+}
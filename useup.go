@@ -0,0 +1,147 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// parseUseUp reads what plan --use-up lists, e.g. "half a cabbage" and
+// "200g feta", as ingredients
+func parseUseUp(lines []string) []Ingredient {
+    var items []Ingredient
+    for _, line := range lines {
+        items = append(items, ParseIngredient(line))
+    }
+    return items
+}
+
+// sameFood reports whether an ingredient is the food a use-up item names,
+// by the words in their names: "feta" is "crumbled feta", and "red cabbage"
+// is "cabbage"
+func sameFood(a, b string) bool {
+    x, y := matchWords(a), matchWords(b)
+    if strings.TrimSpace(x) == "" || strings.TrimSpace(y) == "" {
+        return false
+    }
+    return strings.Contains(x, y) || strings.Contains(y, x)
+}
+
+// usesUp returns the dinner's ingredient that is the item, if it has one
+func (d Dinner) usesUp(item Ingredient) (Ingredient, bool) {
+    for _, ingredient := range d.Ingredients {
+        if sameFood(ingredient.Name, item.Name) {
+            return ingredient, true
+        }
+    }
+    return Ingredient{}, false
+}
+
+// usedUp counts the plan's days that cook with each item, leftover days
+// left out as they cook nothing
+func usedUp(plan *Plan, items []Ingredient) []int {
+    counts := make([]int, len(items))
+    for _, dinner := range plan.Dinners() {
+        for i, item := range items {
+            if _, ok := dinner.usesUp(item); ok {
+                counts[i]++
+            }
+        }
+    }
+    return counts
+}
+
+// ensureUseUp swaps days within their categories for dinners that use up
+// what --use-up listed, an item at a time, until each is used or nothing
+// in the week's categories uses it. A swap keeps goals, protein rules and
+// the items already used as they were, doesn't take the week over budget,
+// and leaves leftover nights alone. It runs last so nothing after it swaps
+// the items back out.
+func ensureUseUp(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    if len(opts.UseUp) == 0 {
+        return
+    }
+    leftovers := func(day string) bool {
+        for _, entry := range plan.Days {
+            if entry.Day == day && entry.IsLeftovers() || entry.LeftoversFrom == day {
+                return true
+            }
+        }
+        return false
+    }
+    keeps := func(day string, current, candidate Dinner) bool {
+        if !keepsRules(plan, opts, current, candidate) || !opts.withinBudget(plan, day, current, candidate) {
+            return false
+        }
+        used := usedUp(plan, opts.UseUp)
+        for i, item := range opts.UseUp {
+            _, was := current.usesUp(item)
+            _, still := candidate.usesUp(item)
+            if was && !still && used[i] == 1 {
+                return false
+            }
+        }
+        return true
+    }
+    for i, item := range opts.UseUp {
+        if usedUp(plan, opts.UseUp)[i] > 0 {
+            continue
+        }
+        replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+            _, uses := candidate.usesUp(item)
+            return uses && !leftovers(day) && keeps(day, current, candidate)
+        })
+    }
+}
+
+// useUpLines says which planned dinners use each --use-up item and how much
+// of it, where the amounts compare, and which items nothing uses
+func useUpLines(plan *Plan, items []Ingredient) []string {
+    var lines []string
+    for _, item := range items {
+        stock := PantryItem{Name: item.Name, Quantity: item.Quantity, Unit: item.Unit}
+        if stock.Quantity == 0 {
+            stock.Quantity = 1
+        }
+        var users []string
+        used, measured := 0.0, item.Quantity > 0
+        for _, entry := range plan.Days {
+            if entry.IsLeftovers() {
+                continue
+            }
+            ingredient, ok := entry.Dinner.usesUp(item)
+            if !ok {
+                continue
+            }
+            amount, ok := stock.uses(ingredient)
+            if ingredient.Quantity > 0 {
+                users = append(users, fmt.Sprintf("%s %s (%s)", entry.Day, entry.Dinner.Name, strings.TrimSpace(formatNumber(ingredient.Quantity)+" "+ingredient.Unit)))
+            } else {
+                users = append(users, fmt.Sprintf("%s %s", entry.Day, entry.Dinner.Name))
+            }
+            used += amount
+            measured = measured && ok
+        }
+        line := item.String() + ": "
+        switch {
+        case len(users) == 0:
+            line += "unused"
+        case measured && used < stock.Quantity:
+            line += fmt.Sprintf("%s, about %s left", strings.Join(users, ", "), strings.TrimSpace(formatQuantity(stock.Quantity-used)+" "+stock.Unit))
+        default:
+            line += strings.Join(users, ", ")
+        }
+        lines = append(lines, line)
+    }
+    return lines
+}
+
+// printUseUpSummary prints where the plan uses what --use-up listed
+func printUseUpSummary(plan *Plan) {
+    if len(plan.UseUp) == 0 {
+        return
+    }
+    fmt.Println("Use up:")
+    for _, line := range useUpLines(plan, parseUseUp(plan.UseUp)) {
+        fmt.Printf("  %s\n", line)
+    }
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// VeggieServings totals the vegetable servings per person in the planned dinners
+func VeggieServings(plan *Plan) float64 {
+    total := 0.0
+    for _, dinner := range plan.Dinners() {
+        total += dinner.VeggieServings
+    }
+    return total
+}
+
+// unestimated lists planned dinners without a veggie_servings estimate
+func unestimated(dinners []Dinner) []string {
+    var names []string
+    for _, dinner := range dinners {
+        if dinner.VeggieServings == 0 {
+            names = append(names, dinner.Name)
+        }
+    }
+    return names
+}
+
+// ensureVeggies swaps days within their categories for dinners with more
+// vegetables until the week reaches the minimum, keeping goals and protein
+// rules as they were. A shortfall the catalog can't make up is noted.
+func ensureVeggies(dinners *DinnerData, state *WeekState, plan *Plan, opts PlanOptions) {
+    if opts.MinVeggies <= 0 {
+        return
+    }
+    for VeggieServings(plan) < opts.MinVeggies {
+        if !replaceOneDay(dinners, state, plan, opts, func(day string, current, candidate Dinner) bool {
+            return candidate.VeggieServings > current.VeggieServings && keepsRules(plan, opts, current, candidate)
+        }) {
+            break
+        }
+    }
+    if total := VeggieServings(plan); total < opts.MinVeggies {
+        note := fmt.Sprintf("Veggies: %s servings per person planned, short of %s", formatServings(total), formatServings(opts.MinVeggies))
+        // Dinners without an estimate may well make up the difference
+        if missing := unestimated(plan.Dinners()); len(missing) > 0 {
+            note = fmt.Sprintf("Veggies: %s servings per person counted against %s, %s", formatServings(total), formatServings(opts.MinVeggies), insufficientData("veggie_servings", missing))
+        }
+        plan.Notes = append(plan.Notes, note)
+    }
+}
+
+// formatServings prints 12 as "12" and 12.5 as "12.5"
+func formatServings(n float64) string {
+    return strings.TrimSuffix(fmt.Sprintf("%.1f", n), ".0")
+}
+
+// printVeggieSummary prints the week's vegetable servings per person, once
+// any dinner carries an estimate or a minimum is set
+func printVeggieSummary(plan *Plan, aim float64) {
+    total := VeggieServings(plan)
+    if total == 0 && aim == 0 {
+        return
+    }
+    line := fmt.Sprintf("Veggies: %s servings per person", formatServings(total))
+    if aim > 0 {
+        line += fmt.Sprintf(" (aim %s)", formatServings(aim))
+    }
+    if missing := unestimated(plan.Dinners()); len(missing) > 0 {
+        line += ", " + insufficientData("veggie_servings", missing)
+    }
+    fmt.Println(line)
+}
+
+// eatenVeggies totals the vegetable servings per person actually eaten in a
+// past week: skipped days count nothing, substitutes count if they're in the
+// catalog. It also returns the days it couldn't count.
+func eatenVeggies(week HistoryWeek, dinners *DinnerData) (float64, int) {
+    total, unknown := 0.0, 0
+    for _, day := range week.Days {
+        name := day.Dinner
+        switch day.Outcome {
+        case OutcomeSkipped:
+            continue
+        case OutcomeSubstituted:
+            name = day.Substitute
+        }
+        dinner, ok := dinners.FindDinner(name)
+        if !ok || dinner.VeggieServings == 0 {
+            unknown++
+            continue
+        }
+        total += dinner.VeggieServings
+    }
+    return total, unknown
+}
+
+// printVeggieReport prints vegetable servings per person for recent weeks,
+// oldest first, for "stats veggies". Weeks where nothing eaten had an
+// estimate are left out of the average rather than counted as none.
+func printVeggieReport(weeks []HistoryWeek, dinners *DinnerData, aim float64) {
+    fmt.Println("Vegetable servings per person at dinner:")
+    sum, counted := 0.0, 0
+    for _, week := range weeks {
+        total, unknown := eatenVeggies(week, dinners)
+        if total == 0 && unknown > 0 {
+            fmt.Printf("  %s  insufficient data, no veggie_servings for the %d dinners eaten\n", week.WeekStart.Format("2006-01-02"), unknown)
+            continue
+        }
+        sum += total
+        counted++
+        line := fmt.Sprintf("  %s  %5s", week.WeekStart.Format("2006-01-02"), formatServings(total))
+        if aim > 0 && total < aim {
+            line += fmt.Sprintf("  below %s", formatServings(aim))
+        }
+        if unknown > 0 {
+            line += fmt.Sprintf("  (%d not counted)", unknown)
+        }
+        fmt.Println(line)
+    }
+    if counted == 0 {
+        fmt.Println("  No average: add veggie_servings to the dinners to count them")
+        return
+    }
+    fmt.Printf("  Average %s a week, %s a day\n", formatServings(sum/float64(counted)), formatServings(sum/float64(counted)/7))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+const openMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// WeatherConfig enables forecast-based biasing for a location
+type WeatherConfig struct {
+    Enabled         bool     `json:"enabled"`
+    Latitude        float64  `json:"latitude"`
+    Longitude       float64  `json:"longitude"`
+    ColdBelow       float64  `json:"cold_below,omitempty"`
+    HotAbove        float64  `json:"hot_above,omitempty"`
+    RainyAbove      float64  `json:"rainy_above,omitempty"`
+    WarmCategories  []string `json:"warm_categories,omitempty"`
+    LightCategories []string `json:"light_categories,omitempty"`
+}
+
+// WeatherProvider biases warming categories onto cold or rainy days and light
+// ones onto hot days using the Open-Meteo daily forecast
+type WeatherProvider struct {
+    Config *WeatherConfig
+    Client *http.Client
+}
+
+type openMeteoResponse struct {
+    Daily struct {
+        Time             []string  `json:"time"`
+        TemperatureMax   []float64 `json:"temperature_2m_max"`
+        PrecipitationSum []float64 `json:"precipitation_sum"`
+    } `json:"daily"`
+}
+
+// NewWeatherProvider fills in defaults for unset thresholds and categories
+func NewWeatherProvider(config *WeatherConfig) *WeatherProvider {
+    c := *config
+    if c.ColdBelow == 0 {
+        c.ColdBelow = 12
+    }
+    if c.HotAbove == 0 {
+        c.HotAbove = 25
+    }
+    if c.RainyAbove == 0 {
+        c.RainyAbove = 5
+    }
+    if len(c.WarmCategories) == 0 {
+        c.WarmCategories = []string{"soup"}
+    }
+    if len(c.LightCategories) == 0 {
+        c.LightCategories = []string{"Salad"}
+    }
+    return &WeatherProvider{Config: &c, Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Name identifies the provider in warnings
+func (w *WeatherProvider) Name() string {
+    return "weather"
+}
+
+// Bias fetches the forecast and scores categories for each day it covers
+func (w *WeatherProvider) Bias(weekStart time.Time) (CategoryBias, []string, error) {
+    params := url.Values{}
+    params.Set("latitude", fmt.Sprintf("%.4f", w.Config.Latitude))
+    params.Set("longitude", fmt.Sprintf("%.4f", w.Config.Longitude))
+    params.Set("daily", "temperature_2m_max,precipitation_sum")
+    params.Set("timezone", "auto")
+
+    resp, err := w.Client.Get(openMeteoURL + "?" + params.Encode())
+    if err != nil {
+        return nil, nil, fmt.Errorf("error fetching forecast: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, nil, fmt.Errorf("error fetching forecast: %s", resp.Status)
+    }
+
+    var forecast openMeteoResponse
+    err = json.NewDecoder(resp.Body).Decode(&forecast)
+    if err != nil {
+        return nil, nil, fmt.Errorf("error parsing forecast JSON: %w", err)
+    }
+
+    bias := make(CategoryBias)
+    var notes []string
+    daily := forecast.Daily
+    for i, date := range daily.Time {
+        if i >= len(daily.TemperatureMax) || i >= len(daily.PrecipitationSum) {
+            break
+        }
+        day, ok := weekDayFor(date, weekStart)
+        if !ok {
+            continue
+        }
+
+        temp, rain := daily.TemperatureMax[i], daily.PrecipitationSum[i]
+        scores := make(map[string]float64)
+        switch {
+        case temp < w.Config.ColdBelow || rain > w.Config.RainyAbove:
+            for _, category := range w.Config.WarmCategories {
+                scores[category] += 1
+            }
+            for _, category := range w.Config.LightCategories {
+                scores[category] -= 1
+            }
+            notes = append(notes, fmt.Sprintf("%s looks cold or wet (%.0f°C, %.0fmm)", day, temp, rain))
+        case temp > w.Config.HotAbove:
+            for _, category := range w.Config.LightCategories {
+                scores[category] += 1
+            }
+            for _, category := range w.Config.WarmCategories {
+                scores[category] -= 1
+            }
+            notes = append(notes, fmt.Sprintf("%s looks hot (%.0f°C)", day, temp))
+        }
+        bias[day] = scores
+    }
+    return bias, notes, nil
+}
+
+// weekDayFor maps a YYYY-MM-DD date onto a weekday name if it falls in the week
+func weekDayFor(date string, weekStart time.Time) (string, bool) {
+    t, err := time.ParseInLocation("2006-01-02", date, weekStart.Location())
+    if err != nil {
+        return "", false
+    }
+    offset := int(t.Sub(weekStart).Hours() / 24)
+    if offset < 0 || offset >= len(weekDays) {
+        return "", false
+    }
+    return weekDays[offset], true
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+    _ "embed"
+    "net/http"
+)
+
+// webUI is the page "serve" answers / with: the week and the shopping list
+// for phones, built on the JSON API
+//
+//go:embed webui.html
+var webUI []byte
+
+// handleWebUI serves GET /, the web page
+func handleWebUI(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Write(webUI)
+}
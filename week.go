@@ -0,0 +1,208 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+)
+
+// WeekConfig sets up the steps "week" runs. Each step can be switched off;
+// the file steps only run when they have a file to write.
+type WeekConfig struct {
+    Plan         *bool           `json:"plan,omitempty"`
+    ShoppingList *FileStep       `json:"shopping_list,omitempty"`
+    ICS          *FileStep       `json:"ics,omitempty"`
+    Telegram     *TelegramStep   `json:"telegram,omitempty"`
+    Print        *bool           `json:"print,omitempty"`
+
+    // Nudges adds what "stats trends" notices to the message and printout
+    Nudges *bool `json:"nudges,omitempty"`
+
+    // Badges adds the badges earned, or nearly, to the message and printout
+    Badges *bool `json:"badges,omitempty"`
+}
+
+// FileStep writes something to a file when enabled
+type FileStep struct {
+    Enabled bool   `json:"enabled"`
+    File    string `json:"file"`
+}
+
+// TelegramStep sends the plan to a Telegram chat when enabled
+type TelegramStep struct {
+    Enabled bool `json:"enabled"`
+    TelegramConfig
+}
+
+// enabled reports whether an on-by-default switch is on
+func enabled(on *bool) bool {
+    return on == nil || *on
+}
+
+// errPlanNotSaved stops a "week" run whose new plan wasn't confirmed, so
+// nothing is sent for a week that wasn't saved
+var errPlanNotSaved = errors.New("plan not saved")
+
+// weekStep is one stage of the "week" run
+type weekStep struct {
+    name string
+    on   bool
+    run  func(state *WeekState, config *Config) error
+}
+
+// weekSteps returns the configured steps in the order they run; with
+// confirm the new plan is shown and only saved once it's accepted
+func weekSteps(week *WeekConfig, confirm bool) []weekStep {
+    if week == nil {
+        week = &WeekConfig{}
+    }
+    writeFile := func(step *FileStep, write func(f *os.File, state *WeekState, config *Config) error) func(*WeekState, *Config) error {
+        return func(state *WeekState, config *Config) error {
+            file, err := os.Create(step.File)
+            if err != nil {
+                return err
+            }
+            defer file.Close()
+            if err := write(file, state, config); err != nil {
+                return err
+            }
+            logf("Wrote %s\n", step.File)
+            return nil
+        }
+    }
+
+    steps := []weekStep{{name: "plan", on: enabled(week.Plan), run: func(state *WeekState, config *Config) error {
+        draft, _, err := planWeek(PlanRequest{Draft: confirm})
+        if errors.Is(err, errWeekPlanned) {
+            fmt.Println("Every day this week is planned already, keeping the plan")
+            return nil
+        }
+        if !confirm || err != nil {
+            return err
+        }
+        menu, err := NewMenuOptions(MenuNames, config)
+        if err != nil {
+            return err
+        }
+        PrintWeeklyMenu(draft.Plan, draft.Note, menu)
+        save, err := confirmPlan(draft, false)
+        if err != nil {
+            return err
+        }
+        if !save {
+            return errPlanNotSaved
+        }
+        return draft.Record("plan", planSummary(draft.Plan))
+    }}}
+    if step := week.ShoppingList; step != nil {
+        steps = append(steps, weekStep{name: "shopping-list", on: step.Enabled && step.File != "", run: writeFile(step, func(f *os.File, state *WeekState, config *Config) error {
+            pantry, err := LoadPantry()
+            if err != nil {
+                return err
+            }
+            WriteShoppingList(f, state.Plan, ShoppingOptions{Stores: config.Stores, Optional: true, Staples: config.Staples, Pantry: pantry, Household: config.Household, Spend: config.Spend})
+            return nil
+        })})
+    }
+    if step := week.ICS; step != nil {
+        steps = append(steps, weekStep{name: "ics", on: step.Enabled && step.File != "", run: writeFile(step, func(f *os.File, state *WeekState, config *Config) error {
+            return WritePlanICS(f, state.Plan, ICSOptions{Styles: loadStyles(), Config: config})
+        })})
+    }
+    if step := week.Telegram; step != nil {
+        steps = append(steps, weekStep{name: "telegram", on: step.Enabled, run: func(state *WeekState, config *Config) error {
+            message := planMessage(state.Plan, state.Note, loadStyles())
+            if enabled(week.Nudges) {
+                if nudges := weekNudges(state); len(nudges) > 0 {
+                    message += "\n\n" + strings.Join(nudges, "\n")
+                }
+            }
+            if enabled(week.Badges) {
+                if badges := weekBadges(state); len(badges) > 0 {
+                    message += "\n\n" + strings.Join(badges, "\n")
+                }
+            }
+            return deliver(config, weekTelegram, message)
+        }})
+    }
+    steps = append(steps, weekStep{name: "print", on: enabled(week.Print), run: func(state *WeekState, config *Config) error {
+        menu, err := NewMenuOptions("", config)
+        if err != nil {
+            return err
+        }
+        PrintWeeklyMenu(state.Plan, state.Note, menu)
+        PrintPlanSummary(state.Plan, config)
+        if enabled(week.Nudges) {
+            for _, nudge := range weekNudges(state) {
+                fmt.Println("Nudge: " + nudge)
+            }
+        }
+        if enabled(week.Badges) {
+            for _, badge := range weekBadges(state) {
+                fmt.Println(badge)
+            }
+        }
+        return nil
+    }})
+    return steps
+}
+
+// runWeekRun handles "week [--skip step,...] [--yes]", running the configured
+// steps in turn. A failing step is reported and the rest still run on the
+// saved plan; a new plan turned down at the terminal stops the run.
+func runWeekRun(args []string) error {
+    fs := flag.NewFlagSet("week", flag.ContinueOnError)
+    skip := fs.String("skip", "", "comma-separated steps to leave out this time")
+    yes := fs.Bool("yes", false, "save the new plan without asking, even at a terminal")
+    if _, err := parseArgs(fs, args); err != nil {
+        return err
+    }
+    skipped := make(map[string]bool)
+    for _, name := range strings.Split(*skip, ",") {
+        skipped[strings.TrimSpace(name)] = true
+    }
+
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    var failed []string
+    for _, step := range weekSteps(config.Week, !*yes && confirmingPlan(config)) {
+        if !step.on || skipped[step.name] {
+            continue
+        }
+        // Each step reads the state afresh so one failure can't leave the next half-updated
+        state, err := LoadState()
+        if err == nil {
+            state.CheckNewWeek()
+            if step.name != "plan" && state.Plan.IsEmpty() {
+                err = fmt.Errorf("no dinners planned for this week")
+            }
+        }
+        if err == nil {
+            err = step.run(state, config)
+        }
+        if errors.Is(err, errPlanNotSaved) {
+            logf("Not saved, the week stays as it was and nothing was sent\n")
+            return nil
+        }
+        if err != nil {
+            warnf("%s failed: %v", step.name, err)
+            failed = append(failed, step.name)
+        }
+    }
+    // Messages an earlier run couldn't send go now if they're due
+    if sent, _, err := flushOutbox(config, time.Now(), false); err != nil {
+        warnf("%v", err)
+    } else if sent > 0 {
+        logf("Sent %d queued messages from the outbox\n", sent)
+    }
+    if len(failed) > 0 {
+        return fmt.Errorf("%d step(s) failed: %s", len(failed), strings.Join(failed, ", "))
+    }
+    return nil
+}
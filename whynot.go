@@ -0,0 +1,282 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// verdict is what one of the planner's rules makes of a dinner this week
+type verdict struct {
+    rule   string
+    detail string
+
+    // blocked are the days the rule rules the dinner out on
+    blocked []string
+
+    // only narrows the week to these days without counting against the
+    // dinner, as the schedule does; nil leaves every day
+    only []string
+
+    // soft rules only make the dinner less likely to be drawn
+    soft bool
+}
+
+// whyNotDays are the days a dinner is judged for: the planned ones, or the
+// ones the next plan would get
+func whyNotDays(config *Config, state *WeekState) []string {
+    if !state.Plan.IsEmpty() {
+        var days []string
+        for _, entry := range state.Plan.Days {
+            days = append(days, entry.Day)
+        }
+        return days
+    }
+    if week, err := weekPattern(config, state.WeekStart, ""); err == nil && week.Name != "" {
+        return week.planDays()
+    }
+    return PlanOptions{Schedule: config.Schedule}.schedule().planDays()
+}
+
+// explainDinner runs a dinner through the rules the planner picks by, for
+// each of the days, the same checks SelectWeeklyDinners makes
+func explainDinner(dinners *DinnerData, state *WeekState, config *Config, dinner Dinner, days []string) []verdict {
+    var verdicts []verdict
+    dateOf := func(day string) time.Time {
+        return state.WeekStart.AddDate(0, 0, dayIndex(day))
+    }
+
+    // Banned dinners are never picked, and pinned days have their dinner already
+    if state.IsBanned(dinner) {
+        verdicts = append(verdicts, verdict{rule: "banned", detail: fmt.Sprintf("banned until unbanned (dinner-picker unban %s)", dinner.Name), blocked: days})
+    }
+    pins, _ := state.pinnedDinners(dinners)
+    if len(pins) > 0 {
+        taken := verdict{rule: "pins"}
+        var pinnedTo []string
+        for _, day := range days {
+            if pinned, ok := pins[day]; ok && pinned.Name != dinner.Name {
+                taken.blocked = append(taken.blocked, day)
+            } else if ok {
+                pinnedTo = append(pinnedTo, day)
+            }
+        }
+        switch {
+        case len(pinnedTo) > 0:
+            taken.detail = "pinned to " + strings.Join(pinnedTo, ", ")
+        case len(taken.blocked) > 0:
+            taken.detail = "another dinner is pinned there"
+        }
+        if taken.detail != "" {
+            verdicts = append(verdicts, taken)
+        }
+    }
+
+    // The schedule deals the day a category before a dinner is picked from it
+    schedule := PlanOptions{Schedule: config.Schedule}.schedule()
+    dealt := verdict{rule: "schedule"}
+    var onDays []string
+    for _, day := range days {
+        drawn := false
+        for _, category := range schedule.categoriesOn(dinners, day) {
+            drawn = drawn || strings.EqualFold(category, dinner.Category)
+        }
+        if drawn {
+            onDays = append(onDays, day)
+        } else {
+            dealt.blocked = append(dealt.blocked, day)
+        }
+    }
+    var fallbackFor []string
+    for category, fallbacks := range config.CategoryFallbacks {
+        for _, fallback := range fallbacks {
+            if strings.EqualFold(fallback, dinner.Category) {
+                fallbackFor = append(fallbackFor, category)
+            }
+        }
+    }
+    switch frequency := schedule.frequency(dinner.Category); {
+    case len(onDays) == 0 && len(fallbackFor) > 0:
+        dealt.blocked, dealt.soft = nil, true
+        dealt.detail = fmt.Sprintf("%s isn't on the schedule, only a fallback for %s", dinner.Category, strings.Join(uniqueStrings(fallbackFor), ", "))
+    case len(onDays) == 0:
+        dealt.detail = fmt.Sprintf("no day draws from %s", dinner.Category)
+    case !frequency.allows(0):
+        dealt.blocked = days
+        dealt.detail = fmt.Sprintf("%s has a frequency max of 0", dinner.Category)
+    default:
+        dealt.blocked, dealt.only = nil, onDays
+        dealt.detail = fmt.Sprintf("%s can be dealt to %s", dinner.Category, strings.Join(onDays, ", "))
+    }
+    verdicts = append(verdicts, dealt)
+
+    // No repeats within the window
+    repeatDays := config.RepeatDays(nil)
+    repeats := verdict{rule: "no repeats"}
+    if last, ok := state.LastEaten(dinner.Name); !ok {
+        repeats.detail = "not eaten before"
+    } else if repeatDays <= 0 {
+        repeats.detail = fmt.Sprintf("last eaten %s, but the rule is off", last.Format("January 2"))
+    } else if dinner.HasTag(AlwaysOKTag) {
+        repeats.detail = fmt.Sprintf("last eaten %s, but tagged %s", last.Format("January 2"), AlwaysOKTag)
+    } else {
+        for _, day := range days {
+            if state.TooRecent(dinner, dateOf(day), repeatDays) {
+                repeats.blocked = append(repeats.blocked, day)
+            }
+        }
+        repeats.detail = fmt.Sprintf("last eaten %s, rests until %s", last.Format("January 2"), last.AddDate(0, 0, repeatDays).Format("January 2"))
+    }
+    verdicts = append(verdicts, repeats)
+
+    // Observances, dietary rules and allergies among them, on the days they're in force
+    for _, o := range config.Observances {
+        rule := verdict{rule: o.Name}
+        active := false
+        conflict := o.Conflict(dinner)
+        for _, day := range days {
+            if !o.ActiveOn(dateOf(day)) {
+                continue
+            }
+            active = true
+            if conflict != "" {
+                rule.blocked = append(rule.blocked, day)
+            }
+        }
+        if !active {
+            continue
+        }
+        rule.detail = conflict
+        if conflict == "" {
+            rule.detail = "allowed"
+        }
+        verdicts = append(verdicts, rule)
+    }
+
+    // Equipment that's out
+    if len(dinner.Equipment) > 0 {
+        kit := verdict{rule: "equipment", detail: "needs " + strings.Join(dinner.Equipment, ", ")}
+        var out []string
+        for _, day := range days {
+            if config.Equipment.Permits(dateOf(day), dinner) {
+                continue
+            }
+            kit.blocked = append(kit.blocked, day)
+            for _, outage := range config.Equipment.UnavailableOn(dateOf(day)) {
+                out = append(out, outage.Item)
+            }
+        }
+        if len(out) > 0 {
+            kit.detail += ", and the " + strings.Join(uniqueStrings(out), ", ") + " is out"
+        }
+        verdicts = append(verdicts, kit)
+    }
+
+    // No-cook dinners only fill no-cook nights
+    if dinner.NoCook {
+        nights := verdict{rule: "no-cook", soft: true, detail: fmt.Sprintf("only planned on the %d no-cook night(s)", config.NoCookNights)}
+        if config.NoCookNights == 0 {
+            nights.soft, nights.blocked = false, days
+            nights.detail = "only planned on no-cook nights, and no_cook_nights isn't set"
+        }
+        verdicts = append(verdicts, nights)
+    }
+
+    // Fairness: resting in cooldown mode
+    if config.Fairness != nil && config.Fairness.Mode == FairnessCooldown {
+        rest := config.Fairness.restWeeks(len(dinners.Dinners[dinner.Category]))
+        if when, ok := lastPlanned(state.History)[dinner.Name]; ok {
+            weeks := int(state.WeekStart.Sub(when).Hours() / 24 / 7)
+            if weeks < rest {
+                verdicts = append(verdicts, verdict{rule: "fairness", soft: true, detail: fmt.Sprintf("planned %d week(s) ago, rests %d unless all of %s is resting", weeks, rest, dinner.Category)})
+            }
+        }
+    }
+
+    // Taste: vetoes and low ratings
+    if state.Preferences.isVetoed(dinner.Name) {
+        verdicts = append(verdicts, verdict{rule: "vetoed", soft: true, detail: "picked a hundredth as often as otherwise"})
+    } else if stars := state.Preferences.rating(dinner.Name); stars > 0 {
+        weight := config.Ratings.weight(stars)
+        verdicts = append(verdicts, verdict{rule: "rating", soft: weight < 1, detail: fmt.Sprintf("%d/5, picked %.2g times as often as an unrated dinner", stars, weight)})
+    }
+    return verdicts
+}
+
+// runWhyNotCommand handles "why-not <dinner>", going through the planner's
+// rules to show what keeps a dinner off this week's plan
+func runWhyNotCommand(args []string) error {
+    name := strings.TrimSpace(strings.Join(args, " "))
+    if name == "" {
+        return fmt.Errorf("usage: dinner-picker why-not <dinner>")
+    }
+    dinners, err := loadCatalog()
+    if err != nil {
+        return err
+    }
+    dinner, ok := dinners.FindDinner(name)
+    if !ok {
+        return fmt.Errorf("no dinner named %q", name)
+    }
+    state, err := LoadState()
+    if err != nil {
+        return err
+    }
+    state.CheckNewWeek()
+    config, err := LoadConfig()
+    if err != nil {
+        return err
+    }
+
+    if !state.Plan.IsEmpty() {
+        for _, entry := range state.Plan.Days {
+            if strings.EqualFold(entry.Dinner.Name, dinner.Name) && !entry.IsLeftovers() {
+                fmt.Printf("%s is planned for %s this week\n", dinner.Name, entry.Day)
+                return nil
+            }
+        }
+    }
+
+    days := whyNotDays(config, state)
+    fmt.Printf("%s (%s), week of %s\n", dinner.Name, dinner.Category, state.WeekStart.Format("January 2, 2006"))
+    open := make(map[string]bool)
+    for _, day := range days {
+        open[day] = true
+    }
+    for _, v := range explainDinner(dinners, state, config, dinner, days) {
+        if v.only != nil {
+            narrowed := make(map[string]bool)
+            for _, day := range v.only {
+                narrowed[day] = open[day]
+            }
+            open = narrowed
+        }
+        mark, detail := "ok", v.detail
+        switch {
+        case len(v.blocked) > 0:
+            mark = "no"
+            if len(v.blocked) < len(days) {
+                detail += " (" + strings.Join(v.blocked, ", ") + ")"
+            }
+            for _, day := range v.blocked {
+                delete(open, day)
+            }
+        case v.soft:
+            mark = "less"
+        }
+        fmt.Printf("  %-4s  %s: %s\n", mark, v.rule, detail)
+    }
+
+    var left []string
+    for _, day := range days {
+        if open[day] {
+            left = append(left, day)
+        }
+    }
+    if len(left) == 0 {
+        fmt.Printf("Nothing lets %s on the plan this week\n", dinner.Name)
+        return nil
+    }
+    fmt.Printf("It could go on %s; it just wasn't drawn from the %d %s dinners\n", strings.Join(left, ", "), len(dinners.Dinners[dinner.Category]), dinner.Category)
+    return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// why-not explains a dinner on a fresh install, before anything is planned
+func TestWhyNotWithNoPlan(t *testing.T) {
+    dir := useRepoData(t)
+    if err := os.Remove(filepath.Join(dir, StateFileName)); err != nil {
+        t.Fatal(err)
+    }
+    if err := runWhyNotCommand([]string{"Tomato", "soup"}); err != nil {
+        t.Fatal(err)
+    }
+}